@@ -0,0 +1,47 @@
+package grpc_clients
+
+import (
+	"os"
+
+	pb "github.com/AuraReaper/voom/shared/proto/payment"
+	"github.com/AuraReaper/voom/shared/tracing"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials/insecure"
+)
+
+type paymentServiceclient struct {
+	Client pb.PaymentServiceClient
+	conn   *grpc.ClientConn
+}
+
+func NewPaymentServiceClient() (*paymentServiceclient, error) {
+	paymentServiceURL := os.Getenv("PAYMENT_SERVICE_URL")
+	if paymentServiceURL == "" {
+		paymentServiceURL = "payment-service:9004"
+	}
+
+	dialOptions := append(
+		tracing.DialOptionsWithTracing(),
+		grpc.WithTransportCredentials(insecure.NewCredentials()),
+	)
+
+	conn, err := grpc.NewClient(paymentServiceURL, dialOptions...)
+	if err != nil {
+		return nil, err
+	}
+
+	client := pb.NewPaymentServiceClient(conn)
+
+	return &paymentServiceclient{
+		Client: client,
+		conn:   conn,
+	}, nil
+}
+
+func (c *paymentServiceclient) Close() {
+	if c.conn != nil {
+		if err := c.conn.Close(); err != nil {
+			return
+		}
+	}
+}
@@ -0,0 +1,81 @@
+package handlers
+
+import (
+	"crypto/subtle"
+	"net/http"
+
+	"github.com/AuraReaper/voom/services/api-gateway/grpc_clients"
+	"github.com/AuraReaper/voom/services/api-gateway/pkg/types"
+	"github.com/AuraReaper/voom/shared/env"
+	"github.com/labstack/echo/v4"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// RequireAdminKey only lets requests through that carry the ADMIN_API_KEY in the X-Admin-Key header.
+// Admin routes are disabled when no key is configured.
+func RequireAdminKey(next echo.HandlerFunc) echo.HandlerFunc {
+	adminKey := env.GetString("ADMIN_API_KEY", "")
+
+	return func(c echo.Context) error {
+		if adminKey == "" {
+			return c.String(http.StatusForbidden, "admin API is disabled")
+		}
+
+		key := c.Request().Header.Get("X-Admin-Key")
+		if subtle.ConstantTimeCompare([]byte(key), []byte(adminKey)) != 1 {
+			return c.String(http.StatusUnauthorized, "invalid admin key")
+		}
+
+		return next(c)
+	}
+}
+
+func HandleRefundPayment(c echo.Context) error {
+	ctx, span := tracer.Start(c.Request().Context(), "handleRefundPayment")
+	defer span.End()
+
+	tripID := c.Param("id")
+
+	var req types.RefundPaymentRequest
+	if err := c.Bind(&req); err != nil {
+		return c.String(http.StatusBadRequest, "invalid request body")
+	}
+
+	if req.Amount <= 0 || req.Reason == "" {
+		return c.String(http.StatusBadRequest, "a reason and a positive amount are required")
+	}
+
+	paymentService, err := grpc_clients.NewPaymentServiceClient()
+	if err != nil {
+		c.Logger().Errorf("failed to create payment service client: %v", err)
+		return c.String(http.StatusInternalServerError, "failed to refund the payment")
+	}
+
+	defer paymentService.Close()
+
+	refund, err := paymentService.Client.RefundPayment(ctx, req.ToProto(tripID))
+	if err != nil {
+		c.Logger().Infof("failed to refund payment of trip %s: %v", tripID, err)
+		return c.String(httpStatusFromGRPC(err), status.Convert(err).Message())
+	}
+
+	return c.JSON(http.StatusCreated, map[string]any{
+		"message": "payment refunded",
+		"data":    refund,
+	})
+}
+
+// httpStatusFromGRPC maps the gRPC error codes returned by the services to HTTP statuses
+func httpStatusFromGRPC(err error) int {
+	switch status.Code(err) {
+	case codes.InvalidArgument:
+		return http.StatusBadRequest
+	case codes.NotFound:
+		return http.StatusNotFound
+	case codes.FailedPrecondition, codes.AlreadyExists:
+		return http.StatusConflict
+	default:
+		return http.StatusInternalServerError
+	}
+}
@@ -0,0 +1,36 @@
+package handlers
+
+import (
+	"net/http"
+
+	"github.com/AuraReaper/voom/services/api-gateway/grpc_clients"
+	pbp "github.com/AuraReaper/voom/shared/proto/payment"
+	"github.com/labstack/echo/v4"
+	"google.golang.org/grpc/status"
+)
+
+func HandleGetDriverBalance(c echo.Context) error {
+	ctx, span := tracer.Start(c.Request().Context(), "handleGetDriverBalance")
+	defer span.End()
+
+	driverID := c.Param("driverID")
+
+	paymentService, err := grpc_clients.NewPaymentServiceClient()
+	if err != nil {
+		c.Logger().Errorf("failed to create payment service client: %v", err)
+		return c.String(http.StatusInternalServerError, "failed to get the driver balance")
+	}
+
+	defer paymentService.Close()
+
+	balance, err := paymentService.Client.GetDriverBalance(ctx, &pbp.GetDriverBalanceRequest{DriverID: driverID})
+	if err != nil {
+		c.Logger().Infof("failed to get balance of driver %s: %v", driverID, err)
+		return c.String(httpStatusFromGRPC(err), status.Convert(err).Message())
+	}
+
+	return c.JSON(http.StatusOK, map[string]any{
+		"message": "request valid",
+		"data":    balance,
+	})
+}
@@ -1,26 +1,27 @@
 package handlers
 
 import (
-	"encoding/json"
-	"fmt"
 	"io"
 	"log"
 	"net/http"
 
 	"github.com/AuraReaper/voom/services/api-gateway/grpc_clients"
 	"github.com/AuraReaper/voom/services/api-gateway/pkg/types"
-	"github.com/AuraReaper/voom/shared/contracts"
-	"github.com/AuraReaper/voom/shared/env"
 	"github.com/AuraReaper/voom/shared/messaging"
+	pbp "github.com/AuraReaper/voom/shared/proto/payment"
 	"github.com/AuraReaper/voom/shared/tracing"
 	"github.com/labstack/echo/v4"
-	"github.com/stripe/stripe-go/v81"
-	"github.com/stripe/stripe-go/v81/webhook"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
 )
 
 var tracer = tracing.GetTracer("api-gateway")
 
-func HealthCheck(c echo.Context) error {
+func HealthCheck(c echo.Context, rabbitmq *messaging.RabbitMQ) error {
+	if !rabbitmq.IsConnected() {
+		return c.String(http.StatusServiceUnavailable, "Service is unhealthy: not connected to RabbitMQ.")
+	}
+
 	return c.String(http.StatusOK, "Service is healthy.")
 }
 
@@ -43,6 +44,10 @@ func HandleTripPreview(c echo.Context) error {
 	tripPreview, err := tripService.Client.PreviewTrip(ctx, reqBody.ToProto())
 	if err != nil {
 		c.Logger().Infof("failed to preview a trip: %v", err)
+		// A rejected promo code is reported to the rider
+		if code := status.Code(err); code == codes.InvalidArgument || code == codes.FailedPrecondition {
+			return c.String(httpStatusFromGRPC(err), status.Convert(err).Message())
+		}
 		return c.String(http.StatusInternalServerError, "failed to preview a trip")
 	}
 
@@ -70,7 +75,7 @@ func HandleCreateTrip(c echo.Context) error {
 	createTrip, err := tripService.Client.CreateTrip(ctx, req.ToProto())
 	if err != nil {
 		c.Logger().Infof("failed to create a trip: %v", err)
-		return c.String(http.StatusInternalServerError, "failed to create a trip")
+		return c.String(httpStatusFromGRPC(err), "failed to create a trip")
 	}
 
 	return c.JSON(http.StatusCreated, map[string]any{
@@ -79,7 +84,9 @@ func HandleCreateTrip(c echo.Context) error {
 	})
 }
 
-func HandleStripeWebHook(c echo.Context, rb *messaging.RabbitMQ) error {
+// HandleStripeWebHook forwards the raw webhook to the payment service, which verifies the signature
+// and processes the event. A non 2xx answer makes Stripe retry the delivery.
+func HandleStripeWebHook(c echo.Context) error {
 	ctx, span := tracer.Start(c.Request().Context(), "handleStripeWebhook")
 	defer span.End()
 
@@ -89,64 +96,28 @@ func HandleStripeWebHook(c echo.Context, rb *messaging.RabbitMQ) error {
 	}
 	defer c.Request().Body.Close()
 
-	webHookKey := env.GetString("STRIPE_WEBHOOK_KEY", "")
-	if webHookKey == "" {
-		return fmt.Errorf("webhook key is required")
-	}
-
-	sigHeader := c.Request().Header.Get("Stripe-Signature")
-
-	event, err := webhook.ConstructEventWithOptions(
-		body,
-		sigHeader,
-		webHookKey,
-		webhook.ConstructEventOptions{
-			IgnoreAPIVersionMismatch: true,
-		},
-	)
+	paymentService, err := grpc_clients.NewPaymentServiceClient()
 	if err != nil {
-		log.Printf("Error verifying webhook signature: %v", err)
-		return c.String(http.StatusBadRequest, "Invalid signature")
+		log.Printf("Failed to create payment service client: %v", err)
+		return c.String(http.StatusInternalServerError, "failed to handle webhook")
 	}
 
-	log.Printf("Received Stripe event: %s", event.Type)
-
-	switch event.Type {
-	case "checkout.session.completed":
-		var session stripe.CheckoutSession
-
-		err := json.Unmarshal(event.Data.Raw, &session)
-		if err != nil {
-			log.Printf("Error parsing webhook session: %v", err)
-			return c.String(http.StatusBadRequest, "Invalid Payload")
-		}
-
-		payload := messaging.PaymentStatusUpdateData{
-			TripID:   session.Metadata["trip_id"],
-			UserID:   session.Metadata["user_id"],
-			DriverID: session.Metadata["driver_id"],
-		}
+	defer paymentService.Close()
 
-		payloadBytes, err := json.Marshal(payload)
-		if err != nil {
-			log.Printf("Error marshalling payload: %v", err)
-			return c.String(http.StatusInternalServerError, "failed to marshall Payload")
-		}
-
-		message := contracts.AmqpMessage{
-			OwnerID: session.Metadata["user_id"],
-			Data:    payloadBytes,
-		}
-
-		if err := rb.PublishMessage(
-			ctx,
-			contracts.PaymentEventSuccess,
-			message,
-		); err != nil {
-			log.Printf("Error publishing paymeny event: %v", err)
-			return c.String(http.StatusInternalServerError, "failed to publish payment event")
+	resp, err := paymentService.Client.HandleStripeWebhook(ctx, &pbp.HandleStripeWebhookRequest{
+		Payload:   body,
+		Signature: c.Request().Header.Get("Stripe-Signature"),
+	})
+	if err != nil {
+		log.Printf("Error handling Stripe webhook: %v", err)
+		if status.Code(err) == codes.InvalidArgument {
+			return c.String(http.StatusBadRequest, "Invalid webhook")
 		}
+		return c.String(http.StatusInternalServerError, "failed to handle webhook")
 	}
 
-	return nil
+	return c.JSON(http.StatusOK, map[string]any{
+		"eventID":   resp.GetEventID(),
+		"duplicate": resp.GetDuplicate(),
+	})
 }
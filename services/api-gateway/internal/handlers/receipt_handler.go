@@ -0,0 +1,51 @@
+package handlers
+
+import (
+	"fmt"
+	"net/http"
+
+	"github.com/AuraReaper/voom/services/api-gateway/grpc_clients"
+	pb "github.com/AuraReaper/voom/shared/proto/trip"
+	"github.com/labstack/echo/v4"
+	"google.golang.org/grpc/status"
+)
+
+// HandleGetReceipt serves the receipt of a paid trip to its rider, as a PDF unless ?format=html
+func HandleGetReceipt(c echo.Context) error {
+	ctx, span := tracer.Start(c.Request().Context(), "handleGetReceipt")
+	defer span.End()
+
+	tripID := c.Param("id")
+	userID := c.QueryParam("userID")
+	format := c.QueryParam("format")
+
+	if userID == "" {
+		return c.String(http.StatusBadRequest, "a userID is required")
+	}
+
+	tripService, err := grpc_clients.NewTripServiceClient()
+	if err != nil {
+		c.Logger().Errorf("failed to create trip service client: %v", err)
+		return c.String(http.StatusInternalServerError, "failed to get the receipt")
+	}
+
+	defer tripService.Close()
+
+	receipt, err := tripService.Client.GetReceipt(ctx, &pb.GetReceiptRequest{
+		TripID: tripID,
+		UserID: userID,
+		Format: format,
+	})
+	if err != nil {
+		c.Logger().Infof("failed to get the receipt of trip %s: %v", tripID, err)
+		return c.String(httpStatusFromGRPC(err), status.Convert(err).Message())
+	}
+
+	extension := "pdf"
+	if format != "" {
+		extension = format
+	}
+	c.Response().Header().Set(echo.HeaderContentDisposition, fmt.Sprintf("inline; filename=%q", "receipt-"+receipt.Number+"."+extension))
+
+	return c.Blob(http.StatusOK, receipt.ContentType, receipt.Content)
+}
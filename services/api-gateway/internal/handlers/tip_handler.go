@@ -0,0 +1,45 @@
+package handlers
+
+import (
+	"net/http"
+
+	"github.com/AuraReaper/voom/services/api-gateway/grpc_clients"
+	"github.com/AuraReaper/voom/services/api-gateway/pkg/types"
+	"github.com/labstack/echo/v4"
+	"google.golang.org/grpc/status"
+)
+
+func HandleTipTrip(c echo.Context) error {
+	ctx, span := tracer.Start(c.Request().Context(), "handleTipTrip")
+	defer span.End()
+
+	tripID := c.Param("id")
+
+	var req types.TipTripRequest
+	if err := c.Bind(&req); err != nil {
+		return c.String(http.StatusBadRequest, "invalid request body")
+	}
+
+	if req.UserID == "" || req.Amount <= 0 {
+		return c.String(http.StatusBadRequest, "a userID and a positive amount are required")
+	}
+
+	paymentService, err := grpc_clients.NewPaymentServiceClient()
+	if err != nil {
+		c.Logger().Errorf("failed to create payment service client: %v", err)
+		return c.String(http.StatusInternalServerError, "failed to tip the trip")
+	}
+
+	defer paymentService.Close()
+
+	tip, err := paymentService.Client.TipTrip(ctx, req.ToProto(tripID))
+	if err != nil {
+		c.Logger().Infof("failed to tip trip %s: %v", tripID, err)
+		return c.String(httpStatusFromGRPC(err), status.Convert(err).Message())
+	}
+
+	return c.JSON(http.StatusCreated, map[string]any{
+		"message": "tip session created",
+		"data":    tip,
+	})
+}
@@ -0,0 +1,96 @@
+package handlers
+
+import (
+	"net/http"
+
+	"github.com/AuraReaper/voom/services/api-gateway/grpc_clients"
+	"github.com/AuraReaper/voom/services/api-gateway/pkg/types"
+	pbp "github.com/AuraReaper/voom/shared/proto/payment"
+	"github.com/labstack/echo/v4"
+	"google.golang.org/grpc/status"
+)
+
+func HandleTopUpWallet(c echo.Context) error {
+	ctx, span := tracer.Start(c.Request().Context(), "handleTopUpWallet")
+	defer span.End()
+
+	var req types.TopUpWalletRequest
+	if err := c.Bind(&req); err != nil {
+		return c.String(http.StatusBadRequest, "invalid request body")
+	}
+
+	if req.UserID == "" || req.Amount <= 0 {
+		return c.String(http.StatusBadRequest, "a userID and a positive amount are required")
+	}
+
+	paymentService, err := grpc_clients.NewPaymentServiceClient()
+	if err != nil {
+		c.Logger().Errorf("failed to create payment service client: %v", err)
+		return c.String(http.StatusInternalServerError, "failed to top up the wallet")
+	}
+
+	defer paymentService.Close()
+
+	topUp, err := paymentService.Client.TopUpWallet(ctx, req.ToProto())
+	if err != nil {
+		c.Logger().Infof("failed to top up wallet of rider %s: %v", req.UserID, err)
+		return c.String(httpStatusFromGRPC(err), status.Convert(err).Message())
+	}
+
+	return c.JSON(http.StatusCreated, map[string]any{
+		"message": "top-up session created",
+		"data":    topUp,
+	})
+}
+
+func HandleGetWallet(c echo.Context) error {
+	ctx, span := tracer.Start(c.Request().Context(), "handleGetWallet")
+	defer span.End()
+
+	userID := c.Param("userID")
+
+	paymentService, err := grpc_clients.NewPaymentServiceClient()
+	if err != nil {
+		c.Logger().Errorf("failed to create payment service client: %v", err)
+		return c.String(http.StatusInternalServerError, "failed to get the wallet")
+	}
+
+	defer paymentService.Close()
+
+	wallet, err := paymentService.Client.GetWallet(ctx, &pbp.GetWalletRequest{UserID: userID})
+	if err != nil {
+		c.Logger().Infof("failed to get wallet of rider %s: %v", userID, err)
+		return c.String(httpStatusFromGRPC(err), status.Convert(err).Message())
+	}
+
+	return c.JSON(http.StatusOK, map[string]any{
+		"message": "request valid",
+		"data":    wallet,
+	})
+}
+
+func HandleListWalletTransactions(c echo.Context) error {
+	ctx, span := tracer.Start(c.Request().Context(), "handleListWalletTransactions")
+	defer span.End()
+
+	userID := c.Param("userID")
+
+	paymentService, err := grpc_clients.NewPaymentServiceClient()
+	if err != nil {
+		c.Logger().Errorf("failed to create payment service client: %v", err)
+		return c.String(http.StatusInternalServerError, "failed to list the wallet transactions")
+	}
+
+	defer paymentService.Close()
+
+	txs, err := paymentService.Client.ListWalletTransactions(ctx, &pbp.ListWalletTransactionsRequest{UserID: userID})
+	if err != nil {
+		c.Logger().Infof("failed to list wallet transactions of rider %s: %v", userID, err)
+		return c.String(httpStatusFromGRPC(err), status.Convert(err).Message())
+	}
+
+	return c.JSON(http.StatusOK, map[string]any{
+		"message": "request valid",
+		"data":    txs,
+	})
+}
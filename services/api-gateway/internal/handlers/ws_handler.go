@@ -5,6 +5,7 @@ import (
 	"encoding/json"
 	"log"
 	"net/http"
+	"sync"
 	"time"
 
 	"github.com/AuraReaper/voom/services/api-gateway/grpc_clients"
@@ -17,8 +18,24 @@ import (
 
 var (
 	connManager = messaging.NewConnectionManager()
+
+	// The consumers forward to every connected user, they are started with the first connection
+	riderConsumers  sync.Once
+	driverConsumers sync.Once
 )
 
+// startQueueConsumers forwards the messages of queues to the connected users. The messages about
+// one trip reach the user in order.
+func startQueueConsumers(rabbitmq *messaging.RabbitMQ, queues []string) {
+	for _, q := range queues {
+		consumer := messaging.NewQueueConsumer(rabbitmq, connManager, q)
+
+		if err := consumer.Start(messaging.WithWorkers(4), messaging.WithOrderingKey(messaging.TripIDKey)); err != nil {
+			log.Printf("failed to start comnsumer for queue: %s: err: %v", q, err)
+		}
+	}
+}
+
 func HandleRidersWebSocket(c echo.Context, rabbitmq *messaging.RabbitMQ) error {
 	conn, err := connManager.Upgrade(c.Response(), c.Request())
 	if err != nil {
@@ -36,19 +53,17 @@ func HandleRidersWebSocket(c echo.Context, rabbitmq *messaging.RabbitMQ) error {
 	connManager.Add(userID, conn)
 	defer connManager.Remove(userID)
 
-	queues := []string{
-		messaging.NotifyNoDriverFoundQueue,
-		messaging.NotifyDriverAssignQueue,
-		messaging.NotifyPaymentSessionCreatedQueue,
-	}
-
-	for _, q := range queues {
-		consumer := messaging.NewQueueConsumer(rabbitmq, connManager, q)
-
-		if err := consumer.Start(); err != nil {
-			log.Printf("failed to start comnsumer for queue: %s: err: %v", q, err)
-		}
-	}
+	riderConsumers.Do(func() {
+		startQueueConsumers(rabbitmq, []string{
+			messaging.NotifyNoDriverFoundQueue,
+			messaging.NotifyDriverAssignQueue,
+			messaging.NotifyPaymentSessionCreatedQueue,
+			messaging.NotifyPaymentRetryQueue,
+			messaging.NotifyPaymentRefundedQueue,
+			messaging.NotifyTripCompletedQueue,
+			messaging.NotifySplitQueue,
+		})
+	})
 
 	// Read loop
 	for {
@@ -60,6 +75,30 @@ func HandleRidersWebSocket(c echo.Context, rabbitmq *messaging.RabbitMQ) error {
 			break
 		}
 		c.Logger().Infof("Received message: %s", string(msg))
+
+		type riderMessage struct {
+			Type string          `json:"type"`
+			Data json.RawMessage `json:"data"`
+		}
+
+		var riderMsg riderMessage
+		if err := json.Unmarshal(msg, &riderMsg); err != nil {
+			log.Printf("Error unmarsahlling rider message: %v", err)
+			continue
+		}
+
+		switch riderMsg.Type {
+		case contracts.RiderCmdTip, contracts.RiderCmdSplitInvite, contracts.RiderCmdSplitRespond:
+			// forward msg to rabbitmq, the consuming service checks the rider is part of the trip
+			if err := rabbitmq.PublishMessage(c.Request().Context(), riderMsg.Type, contracts.AmqpMessage{
+				OwnerID: userID,
+				Data:    riderMsg.Data,
+			}); err != nil {
+				log.Printf("error publishing message to rabbitmq: %v", err)
+			}
+		default:
+			log.Printf("unknown message type: %s", riderMsg.Type)
+		}
 	}
 
 	return nil
@@ -123,17 +162,12 @@ func HandleDriversWebSocket(c echo.Context, rabbitmq *messaging.RabbitMQ) error
 	}
 	c.Logger().Info("message sent successfully.")
 
-	queues := []string{
-		messaging.DriverCmdTripRequestQueue,
-	}
-
-	for _, q := range queues {
-		consumer := messaging.NewQueueConsumer(rabbitmq, connManager, q)
-
-		if err := consumer.Start(); err != nil {
-			log.Printf("failed to start comnsumer for queue: %s: err: %v", q, err)
-		}
-	}
+	driverConsumers.Do(func() {
+		startQueueConsumers(rabbitmq, []string{
+			messaging.DriverCmdTripRequestQueue,
+			messaging.NotifyDriverTipQueue,
+		})
+	})
 
 	// Read loop
 	for {
@@ -162,7 +196,8 @@ func HandleDriversWebSocket(c echo.Context, rabbitmq *messaging.RabbitMQ) error
 		case contracts.DriverCmdLocation:
 			// handle location here
 			continue
-		case contracts.DriverCmdTripAccept, contracts.DriverCmdTripDecline:
+		case contracts.DriverCmdTripAccept, contracts.DriverCmdTripDecline, contracts.DriverCmdTripComplete,
+			contracts.DriverCmdCashCollected:
 			// forward msg to rabbitmq
 			if err := rabbitmq.PublishMessage(ctx, driverMsg.Type, contracts.AmqpMessage{
 				OwnerID: userID,
@@ -3,15 +3,17 @@ package main
 import (
 	"context"
 	"log"
-	"os"
-	"os/signal"
-	"syscall"
 	"time"
 
 	"net/http"
 
 	"github.com/AuraReaper/voom/services/api-gateway/internal/handlers"
+	gatewayTopology "github.com/AuraReaper/voom/services/api-gateway/pkg/topology"
+	driverTopology "github.com/AuraReaper/voom/services/driver-service/pkg/topology"
+	paymentTopology "github.com/AuraReaper/voom/services/payment-service/pkg/topology"
+	tripTopology "github.com/AuraReaper/voom/services/trip-service/pkg/topology"
 	"github.com/AuraReaper/voom/shared/env"
+	"github.com/AuraReaper/voom/shared/lifecycle"
 	"github.com/AuraReaper/voom/shared/messaging"
 	"github.com/AuraReaper/voom/shared/tracing"
 
@@ -30,9 +32,10 @@ func main() {
 
 	// Initialize Tracing
 	tracerCfg := tracing.Config{
-		ServiceName:  "api-gateway",
-		Environment:  env.GetString("ENVIRONMENT", "development"),
-		OTLPEndpoint: env.GetString("OTLP_ENDPOINT", "http://jaeger:4318/v1/traces"),
+		ServiceName:         "api-gateway",
+		Environment:         env.GetString("ENVIRONMENT", "development"),
+		OTLPEndpoint:        env.GetString("OTLP_ENDPOINT", "http://jaeger:4318/v1/traces"),
+		OTLPMetricsEndpoint: env.GetString("OTLP_METRICS_ENDPOINT", "http://otel-collector:4318/v1/metrics"),
 	}
 
 	sh, err := tracing.InitTracer(tracerCfg)
@@ -40,16 +43,27 @@ func main() {
 		log.Fatalf("Failed to initialize the tracer: %v", err)
 	}
 
-	ctx, cancel := context.WithCancel(context.Background())
-	defer cancel()
-	defer sh(ctx)
+	shMeter, err := tracing.InitMeter(tracerCfg)
+	if err != nil {
+		log.Fatalf("Failed to initialize the meter: %v", err)
+	}
+
+	// Stops the gateway on SIGTERM, draining the consumers before the broker connection is closed
+	lc := lifecycle.New(10 * time.Second)
+	lc.RegisterTracer(sh)
+	lc.RegisterMeter(shMeter)
 
 	// RabbitMQ connection
-	rabbitmq, err := messaging.NewRabbitMQ(rabbitMqURI)
+	rabbitmq, err := messaging.NewRabbitMQ(rabbitMqURI, gatewayTopology.Topology, tripTopology.Topology, paymentTopology.Topology, driverTopology.Topology)
 	if err != nil {
 		log.Fatal(err)
 	}
-	defer rabbitmq.Close()
+	lc.RegisterBroker("rabbitmq", rabbitmq)
+
+	// Consumers read both encodings, so services can switch to protobuf one at a time
+	if err := rabbitmq.UsePayloadContentType(env.GetString("MESSAGE_CONTENT_TYPE", messaging.ContentTypeJSON)); err != nil {
+		log.Fatal(err)
+	}
 
 	log.Println("Starting RabbitMQ connection")
 
@@ -63,7 +77,9 @@ func main() {
 	}))
 
 	// routes
-	e.GET("/health", handlers.HealthCheck)
+	e.GET("/health", func(c echo.Context) error {
+		return handlers.HealthCheck(c, rabbitmq)
+	})
 	e.POST("/trip/preview", tracing.WrapHandler(handlers.HandleTripPreview))
 	e.GET("/ws/drivers", tracing.WrapHandler(func(c echo.Context) error {
 		return handlers.HandleDriversWebSocket(c, rabbitmq)
@@ -72,33 +88,31 @@ func main() {
 		return handlers.HandleRidersWebSocket(c, rabbitmq)
 	}))
 	e.POST("/trip/start", tracing.WrapHandler(handlers.HandleCreateTrip))
-	e.POST("/webhook/stripe", tracing.WrapHandler(func(c echo.Context) error {
-		return handlers.HandleStripeWebHook(c, rabbitmq)
-	}))
+	e.POST("/trips/:id/tip", tracing.WrapHandler(handlers.HandleTipTrip))
+	e.GET("/trips/:id/receipt", tracing.WrapHandler(handlers.HandleGetReceipt))
+	e.POST("/webhook/stripe", tracing.WrapHandler(handlers.HandleStripeWebHook))
+	e.POST("/wallet/topup", tracing.WrapHandler(handlers.HandleTopUpWallet))
+	e.GET("/wallet/:userID", tracing.WrapHandler(handlers.HandleGetWallet))
+	e.GET("/wallet/:userID/transactions", tracing.WrapHandler(handlers.HandleListWalletTransactions))
+	e.GET("/drivers/:driverID/balance", tracing.WrapHandler(handlers.HandleGetDriverBalance))
+
+	// admin routes
+	admin := e.Group("/admin", handlers.RequireAdminKey)
+	admin.POST("/trips/:id/refund", tracing.WrapHandler(handlers.HandleRefundPayment))
 
 	// graceful shutdown handling and starting server
-	serverErrors := make(chan error, 1)
-
-	go func() {
-		e.Logger.Info("Server listening on port %s", httpAddr)
-		serverErrors <- e.Start(httpAddr)
-	}()
-
-	shutdown := make(chan os.Signal, 1)
-	signal.Notify(shutdown, os.Interrupt, syscall.SIGTERM)
-
-	select {
-	case err := <-serverErrors:
-		e.Logger.Error("error starting the server: %v", err)
-	case sig := <-shutdown:
-		e.Logger.Info("Server is shutting down due to %v signal", sig)
-	}
-
-	ctx, cancel = context.WithTimeout(context.Background(), 10*time.Second)
-	defer cancel()
-
-	if err := e.Shutdown(ctx); err != nil {
-		e.Logger.Info("could not shut down the server gracefully: %v", err)
-		e.Close()
+	e.Logger.Info("Server listening on port %s", httpAddr)
+	lc.ServeHTTP("http server", func() error {
+		return e.Start(httpAddr)
+	}, func(ctx context.Context) error {
+		if err := e.Shutdown(ctx); err != nil {
+			e.Close()
+			return err
+		}
+		return nil
+	})
+
+	if err := lc.Wait(); err != nil {
+		e.Logger.Info("could not shut down gracefully: %v", err)
 	}
 }
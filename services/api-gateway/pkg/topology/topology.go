@@ -0,0 +1,79 @@
+package topology
+
+import (
+	"github.com/AuraReaper/voom/shared/contracts"
+	"github.com/AuraReaper/voom/shared/messaging"
+)
+
+// Topology is the part of the trip exchange the API gateway owns, its queues are forwarded to the
+// connected riders and drivers
+var Topology = messaging.ServiceTopology{
+	Service: messaging.APIGatewayService,
+	Queues: []messaging.QueueBinding{
+		{
+			Queue: messaging.DriverCmdTripRequestQueue,
+			RoutingKeys: []string{
+				contracts.DriverCmdTripRequest,
+			},
+		},
+		{
+			Queue: messaging.NotifyNoDriverFoundQueue,
+			RoutingKeys: []string{
+				contracts.TripEventNoDriversFound,
+			},
+		},
+		{
+			Queue: messaging.NotifyDriverAssignQueue,
+			RoutingKeys: []string{
+				contracts.TripEventDriverAssigned,
+			},
+		},
+		{
+			Queue: messaging.NotifyPaymentSessionCreatedQueue,
+			RoutingKeys: []string{
+				contracts.PaymentEventSessionCreated,
+			},
+		},
+		{
+			Queue: messaging.NotifyPaymentRetryQueue,
+			RoutingKeys: []string{
+				contracts.PaymentEventRetry,
+				contracts.PaymentEventDunning,
+			},
+		},
+		{
+			Queue: messaging.NotifyPaymentRefundedQueue,
+			RoutingKeys: []string{
+				contracts.PaymentEventRefunded,
+			},
+		},
+		{
+			Queue: messaging.NotifyDriverTipQueue,
+			RoutingKeys: []string{
+				contracts.PaymentEventTipReceived,
+			},
+		},
+		{
+			Queue: messaging.NotifySplitQueue,
+			RoutingKeys: []string{
+				contracts.TripEventSplitInvited,
+				contracts.TripEventSplitUpdated,
+			},
+		},
+		{
+			Queue: messaging.NotifyTripCompletedQueue,
+			RoutingKeys: []string{
+				contracts.TripEventCompleted,
+			},
+		},
+	},
+	Produces: []string{
+		contracts.RiderCmdTip,
+		contracts.RiderCmdSplitInvite,
+		contracts.RiderCmdSplitRespond,
+		contracts.DriverCmdTripAccept,
+		contracts.DriverCmdTripDecline,
+		contracts.DriverCmdTripComplete,
+		contracts.DriverCmdCashCollected,
+	},
+}
@@ -1,6 +1,9 @@
 package types
 
 import (
+	"math"
+
+	pbp "github.com/AuraReaper/voom/shared/proto/payment"
 	pb "github.com/AuraReaper/voom/shared/proto/trip"
 	"github.com/AuraReaper/voom/shared/types"
 )
@@ -9,6 +12,7 @@ type PreviewTripRequest struct {
 	UserID      string           `json:"userID"`
 	Pickup      types.Coordinate `json:"pickup"`
 	Destination types.Coordinate `json:"destination"`
+	PromoCode   string           `json:"promoCode"`
 }
 
 func (p *PreviewTripRequest) ToProto() *pb.PreviewTripRequest {
@@ -22,17 +26,65 @@ func (p *PreviewTripRequest) ToProto() *pb.PreviewTripRequest {
 			Latitude:  p.Destination.Latitude,
 			Longitude: p.Destination.Longitude,
 		},
+		PromoCode: p.PromoCode,
 	}
 }
 
 type CreateTripRequest struct {
-	RiderFairID string `json:"rideFareID"`
-	UserID      string `json:"userID"`
+	RiderFairID   string `json:"rideFareID"`
+	UserID        string `json:"userID"`
+	PaymentMethod string `json:"paymentMethod"` // card, wallet or cash
 }
 
 func (p *CreateTripRequest) ToProto() *pb.CreateTripRequest {
 	return &pb.CreateTripRequest{
-		RideFareID: p.RiderFairID,
-		UserID:     p.UserID,
+		RideFareID:    p.RiderFairID,
+		UserID:        p.UserID,
+		PaymentMethod: p.PaymentMethod,
+	}
+}
+
+type RefundPaymentRequest struct {
+	Amount float64 `json:"amount"`
+	Reason string  `json:"reason"`
+}
+
+func (r *RefundPaymentRequest) ToProto(tripID string) *pbp.RefundPaymentRequest {
+	return &pbp.RefundPaymentRequest{
+		TripID: tripID,
+		Amount: int64(math.Round(r.Amount * 100)), // Convert to cents
+		Reason: r.Reason,
+	}
+}
+
+type TopUpWalletRequest struct {
+	UserID   string  `json:"userID"`
+	Amount   float64 `json:"amount"`
+	Currency string  `json:"currency"` // defaults to INR
+}
+
+func (r *TopUpWalletRequest) ToProto() *pbp.TopUpWalletRequest {
+	currency := r.Currency
+	if currency == "" {
+		currency = "INR"
+	}
+
+	return &pbp.TopUpWalletRequest{
+		UserID:   r.UserID,
+		Amount:   int64(math.Round(r.Amount * 100)), // Convert to cents
+		Currency: currency,
+	}
+}
+
+type TipTripRequest struct {
+	UserID string  `json:"userID"`
+	Amount float64 `json:"amount"`
+}
+
+func (r *TipTripRequest) ToProto(tripID string) *pbp.TipTripRequest {
+	return &pbp.TipTripRequest{
+		TripID: tripID,
+		UserID: r.UserID,
+		Amount: int64(math.Round(r.Amount * 100)), // Convert to cents
 	}
 }
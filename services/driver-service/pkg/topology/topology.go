@@ -0,0 +1,24 @@
+package topology
+
+import (
+	"github.com/AuraReaper/voom/shared/contracts"
+	"github.com/AuraReaper/voom/shared/messaging"
+)
+
+// Topology is the part of the trip exchange the driver service owns
+var Topology = messaging.ServiceTopology{
+	Service: messaging.DriverService,
+	Queues: []messaging.QueueBinding{
+		{
+			Queue: messaging.FindAvailableDriversQueue,
+			RoutingKeys: []string{
+				contracts.TripEventCreated,
+				contracts.TripEventDriverNotInterested,
+			},
+		},
+	},
+	Produces: []string{
+		contracts.TripEventNoDriversFound,
+		contracts.DriverCmdTripRequest,
+	},
+}
@@ -2,50 +2,42 @@ package main
 
 import (
 	"context"
-	"encoding/json"
 	"log"
 
 	"github.com/AuraReaper/voom/shared/contracts"
 	"github.com/AuraReaper/voom/shared/messaging"
-	"github.com/rabbitmq/amqp091-go"
 )
 
+// tripOrdered handles the messages about one trip in order and different trips concurrently
+var tripOrdered = []messaging.ConsumerOption{
+	messaging.WithWorkers(4),
+	messaging.WithOrderingKey(messaging.TripIDKey),
+}
+
 type tripEventConsumer struct {
-	rabbitmq *messaging.RabbitMQ
-	service  *Service
+	bus     messaging.Bus
+	service *Service
 }
 
-func NewTripEventComsumer(rabbitmq *messaging.RabbitMQ, service *Service) *tripEventConsumer {
+func NewTripEventComsumer(bus messaging.Bus, service *Service) *tripEventConsumer {
 	return &tripEventConsumer{
-		rabbitmq: rabbitmq,
-		service:  service,
+		bus:     bus,
+		service: service,
 	}
 }
 
 func (c *tripEventConsumer) Listen() error {
-	return c.rabbitmq.ConsumeMessages(messaging.FindAvailableDriversQueue, func(ctx context.Context, msg amqp091.Delivery) error {
-		var tripEvent contracts.AmqpMessage
-		if err := json.Unmarshal(msg.Body, &tripEvent); err != nil {
-			log.Fatalf("failed to unmrashal the message: %v", err)
-			return err
-		}
-
-		var payload messaging.TripEventData
-		if err := json.Unmarshal(tripEvent.Data, &payload); err != nil {
-			log.Printf("failed to unmarshall message: %v", err)
-			return err
-		}
-
-		switch msg.RoutingKey {
+	return messaging.Consume(c.bus, messaging.FindAvailableDriversQueue, func(ctx context.Context, tripEvent *contracts.Envelope, payload messaging.TripEventData) error {
+		switch tripEvent.Type {
 		case contracts.TripEventCreated, contracts.TripEventDriverNotInterested:
 			return c.handleFindAndNotifyDrivers(ctx, payload)
 		}
 
 		log.Printf("unknown trip event: %+v", payload)
 
-		log.Printf("driver received message: %+v", msg)
+		log.Printf("driver received message: %+v", tripEvent)
 		return nil
-	})
+	}, tripOrdered...)
 }
 
 func (c *tripEventConsumer) handleFindAndNotifyDrivers(ctx context.Context, payload messaging.TripEventData) error {
@@ -58,10 +50,8 @@ func (c *tripEventConsumer) handleFindAndNotifyDrivers(ctx context.Context, payl
 	log.Printf("Found suitable drivers %v", len(suitableIDs))
 
 	if len(suitableIDs) == 0 {
-		// Notify the driver that no drivers are available
-		if err := c.rabbitmq.PublishMessage(ctx, contracts.TripEventNoDriversFound, contracts.AmqpMessage{
-			OwnerID: payload.Trip.UserID,
-		}); err != nil {
+		// Notify the rider that no drivers are available, the trip service releases the card hold
+		if err := messaging.Publish(ctx, c.bus, contracts.TripEventNoDriversFound, payload.Trip.UserID, payload); err != nil {
 			log.Printf("Failed to publish message to exchange: %v", err)
 			return err
 		}
@@ -70,17 +60,8 @@ func (c *tripEventConsumer) handleFindAndNotifyDrivers(ctx context.Context, payl
 	}
 
 	for _, suitableDriverID := range suitableIDs {
-		marshalledEvent, err := json.Marshal(payload)
-		if err != nil {
-			log.Printf("Failed to marshal payload for driver %s: %v", suitableDriverID, err)
-			continue // Skip to the next driver
-		}
-
 		// Notify the driver about a potential trip
-		if err := c.rabbitmq.PublishMessage(ctx, contracts.DriverCmdTripRequest, contracts.AmqpMessage{
-			OwnerID: suitableDriverID,
-			Data:    marshalledEvent,
-		}); err != nil {
+		if err := messaging.Publish(ctx, c.bus, contracts.DriverCmdTripRequest, suitableDriverID, payload); err != nil {
 			log.Printf("Failed to publish message to exchange for driver %s: %v", suitableDriverID, err)
 			// Decide if you want to stop or continue. For now, we'll log and continue.
 		}
@@ -0,0 +1,168 @@
+// Command reconcile compares the payments stored for a period with what the processor actually
+// captured, and reports the payments that are missing, duplicated or disagree on the amount.
+//
+// It exits with status 2 when discrepancies were found, so a scheduled run can alert on it. The
+// mismatch counters are exported to OTLP_METRICS_ENDPOINT before it exits.
+package main
+
+import (
+	"context"
+	"flag"
+	"log"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"github.com/AuraReaper/voom/services/payment-service/infrastructure/fake"
+	"github.com/AuraReaper/voom/services/payment-service/infrastructure/stripe"
+	"github.com/AuraReaper/voom/services/payment-service/internal/domain"
+	"github.com/AuraReaper/voom/services/payment-service/internal/infrastructure/repository"
+	"github.com/AuraReaper/voom/services/payment-service/internal/service"
+	"github.com/AuraReaper/voom/services/payment-service/pkg/types"
+	"github.com/AuraReaper/voom/shared/db"
+	"github.com/AuraReaper/voom/shared/env"
+	"github.com/AuraReaper/voom/shared/tracing"
+)
+
+const dateLayout = "2006-01-02"
+
+func main() {
+	if report := run(); len(report.Discrepancies) > 0 {
+		os.Exit(2)
+	}
+}
+
+func run() *types.ReconciliationReport {
+	yesterday := time.Now().UTC().AddDate(0, 0, -1).Format(dateLayout)
+
+	fromFlag := flag.String("from", yesterday, "Start of the period, a date (2006-01-02) or an RFC 3339 time")
+	toFlag := flag.String("to", "", "End of the period, exclusive. Defaults to one day after -from")
+	format := flag.String("format", "json", "Report format, json or csv")
+	out := flag.String("out", "", "File to write the report to, stdout when empty")
+	transactions := flag.String("transactions", "", "JSON file of processor transactions to reconcile against when PAYMENT_PROCESSOR=fake")
+	flag.Parse()
+
+	from, err := parseTime(*fromFlag)
+	if err != nil {
+		log.Fatalf("Invalid -from: %v", err)
+	}
+
+	to := from.AddDate(0, 0, 1)
+	if *toFlag != "" {
+		if to, err = parseTime(*toFlag); err != nil {
+			log.Fatalf("Invalid -to: %v", err)
+		}
+	}
+
+	if !to.After(from) {
+		log.Fatalf("-to must be after -from")
+	}
+
+	if *format != "json" && *format != "csv" {
+		log.Fatalf("Unknown report format %q, use json or csv", *format)
+	}
+
+	ctx, cancel := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer cancel()
+
+	shMeter, err := tracing.InitMeter(tracing.Config{
+		ServiceName:         "payment-reconciliation",
+		Environment:         env.GetString("ENVIRONMENT", "development"),
+		OTLPMetricsEndpoint: env.GetString("OTLP_METRICS_ENDPOINT", "http://otel-collector:4318/v1/metrics"),
+	})
+	if err != nil {
+		log.Fatalf("Failed to initialize the meter: %v", err)
+	}
+
+	// The run is over long before the next export interval, the counters are exported on the way out
+	defer func() {
+		flushCtx, flushCancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer flushCancel()
+
+		if err := shMeter(flushCtx); err != nil {
+			log.Printf("Failed to export the reconciliation metrics: %v", err)
+		}
+	}()
+
+	mongoCfg := db.NewMongoDefaultConfig()
+	if mongoCfg.URI == "" {
+		log.Fatalf("MONGODB_URI is not set, there are no stored payments to reconcile")
+	}
+
+	mongoClient, err := db.NewMongoClient(ctx, mongoCfg)
+	if err != nil {
+		log.Fatalf("Failed to connect to MongoDB: %v", err)
+	}
+	defer mongoClient.Disconnect(context.Background())
+
+	repo, err := repository.NewMongoRepository(ctx, db.GetDatabase(mongoClient, mongoCfg))
+	if err != nil {
+		log.Fatalf("Failed to create payment repository: %v", err)
+	}
+
+	reconciler := service.NewReconciler(repo, newTransactionSource(*transactions))
+
+	report, err := reconciler.Reconcile(ctx, from, to)
+	if err != nil {
+		log.Fatalf("Failed to reconcile payments: %v", err)
+	}
+
+	w := os.Stdout
+	if *out != "" {
+		f, err := os.Create(*out)
+		if err != nil {
+			log.Fatalf("Failed to create the report file: %v", err)
+		}
+		defer f.Close()
+		w = f
+	}
+
+	if *format == "csv" {
+		err = writeCSV(w, report)
+	} else {
+		err = writeJSON(w, report)
+	}
+	if err != nil {
+		log.Fatalf("Failed to write the report: %v", err)
+	}
+
+	log.Printf(
+		"Reconciled %s to %s: %d transactions, %d payments, %d matched, %d discrepancies %v",
+		from.Format(time.RFC3339), to.Format(time.RFC3339),
+		report.Transactions, report.Payments, report.Matched, len(report.Discrepancies), report.Summary,
+	)
+
+	return report
+}
+
+// newTransactionSource returns the source selected by PAYMENT_PROCESSOR, Stripe unless set to "fake"
+func newTransactionSource(transactionsFile string) domain.TransactionSource {
+	if env.GetString("PAYMENT_PROCESSOR", "stripe") == "fake" {
+		if transactionsFile == "" {
+			log.Fatalf("-transactions is required with the fake payment processor")
+		}
+
+		source, err := fake.LoadTransactions(transactionsFile)
+		if err != nil {
+			log.Fatalf("Failed to load fake transactions: %v", err)
+		}
+		return source
+	}
+
+	secretKey := env.GetString("STRIPE_SECRET_KEY", "")
+	if secretKey == "" {
+		log.Fatalf("STRIPE_SECRET_KEY is not set")
+	}
+
+	return stripe.NewTransactionSource(&types.PaymentConfig{
+		StripeSecretKey: secretKey,
+	})
+}
+
+func parseTime(value string) (time.Time, error) {
+	if t, err := time.Parse(dateLayout, value); err == nil {
+		return t, nil
+	}
+	return time.Parse(time.RFC3339, value)
+}
@@ -0,0 +1,51 @@
+package main
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"io"
+	"strconv"
+	"strings"
+
+	"github.com/AuraReaper/voom/services/payment-service/pkg/types"
+)
+
+func writeJSON(w io.Writer, report *types.ReconciliationReport) error {
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(report)
+}
+
+// writeCSV writes one row per discrepancy, the totals are only part of the JSON report
+func writeCSV(w io.Writer, report *types.ReconciliationReport) error {
+	cw := csv.NewWriter(w)
+
+	if err := cw.Write([]string{
+		"type", "trip_id", "user_id", "purpose", "payment_ids", "transaction_ids",
+		"stored_amount", "processor_amount", "stored_refunded", "processor_refunded", "currency", "detail",
+	}); err != nil {
+		return err
+	}
+
+	for _, d := range report.Discrepancies {
+		if err := cw.Write([]string{
+			string(d.Type),
+			d.TripID,
+			d.UserID,
+			string(d.Purpose),
+			strings.Join(d.PaymentIDs, ";"),
+			strings.Join(d.TransactionIDs, ";"),
+			strconv.FormatInt(d.StoredAmount, 10),
+			strconv.FormatInt(d.ProcessorAmount, 10),
+			strconv.FormatInt(d.StoredRefunded, 10),
+			strconv.FormatInt(d.ProcessorRefunded, 10),
+			d.Currency,
+			d.Detail,
+		}); err != nil {
+			return err
+		}
+	}
+
+	cw.Flush()
+	return cw.Error()
+}
@@ -0,0 +1,263 @@
+package fake
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/AuraReaper/voom/services/payment-service/internal/domain"
+	"github.com/AuraReaper/voom/services/payment-service/pkg/types"
+
+	"github.com/google/uuid"
+	"github.com/stripe/stripe-go/v81"
+	"github.com/stripe/stripe-go/v81/webhook"
+)
+
+// fakeSession is the processor side state of a simulated checkout session
+type fakeSession struct {
+	id              string
+	paymentIntentID string
+	amount          int64
+	currency        string
+	metadata        map[string]string
+	manualCapture   bool
+	status          stripe.CheckoutSessionStatus
+	captured        int64
+	refunded        int64
+	cancelled       bool
+	created         time.Time
+}
+
+type fakeClient struct {
+	config     *types.FakeProcessorConfig
+	httpClient *http.Client
+	sessions   map[string]*fakeSession
+	byPayment  map[string]string // payment_id metadata -> session ID
+	mu         sync.Mutex
+}
+
+// NewFakeClient creates a processor that settles sessions in memory and reports the outcome
+// with the same signed webhooks Stripe sends, so the payment flow runs without network access
+func NewFakeClient(config *types.FakeProcessorConfig) domain.PaymentProcessor {
+	return &fakeClient{
+		config:     config,
+		httpClient: &http.Client{Timeout: 10 * time.Second},
+		sessions:   make(map[string]*fakeSession),
+		byPayment:  make(map[string]string),
+	}
+}
+
+func (f *fakeClient) CreatePaymentSession(ctx context.Context, amount int64, currency string, metadata map[string]string) (string, error) {
+	return f.createSession(amount, currency, metadata, false), nil
+}
+
+func (f *fakeClient) AuthorizePayment(ctx context.Context, amount int64, currency string, metadata map[string]string) (string, error) {
+	return f.createSession(amount, currency, metadata, true), nil
+}
+
+func (f *fakeClient) CapturePayment(ctx context.Context, sessionID string, amount int64) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	s, ok := f.sessions[sessionID]
+	if !ok {
+		return fmt.Errorf("no such checkout session: %s", sessionID)
+	}
+
+	if !s.manualCapture || s.status != stripe.CheckoutSessionStatusComplete || s.cancelled || s.captured > 0 {
+		return fmt.Errorf("%w: payment intent %s can't be captured", domain.ErrCaptureDeclined, s.paymentIntentID)
+	}
+
+	if amount > s.amount {
+		return fmt.Errorf("%w: amount to capture %d exceeds the authorized %d", domain.ErrCaptureDeclined, amount, s.amount)
+	}
+
+	s.captured = amount
+	return nil
+}
+
+func (f *fakeClient) VoidAuthorization(ctx context.Context, sessionID string) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	s, ok := f.sessions[sessionID]
+	if !ok {
+		return fmt.Errorf("no such checkout session: %s", sessionID)
+	}
+
+	// Like Stripe, expiring an open session is reported with a webhook
+	if s.status == stripe.CheckoutSessionStatusOpen {
+		s.status = stripe.CheckoutSessionStatusExpired
+		go f.sendSessionEvent(stripe.EventTypeCheckoutSessionExpired, *s)
+		return nil
+	}
+
+	if s.captured > 0 || s.cancelled {
+		return fmt.Errorf("payment intent %s can't be cancelled", s.paymentIntentID)
+	}
+
+	s.cancelled = true
+	return nil
+}
+
+func (f *fakeClient) RefundPayment(ctx context.Context, sessionID string, amount int64, reason string) (string, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	s, ok := f.sessions[sessionID]
+	if !ok {
+		return "", fmt.Errorf("no such checkout session: %s", sessionID)
+	}
+
+	if s.status != stripe.CheckoutSessionStatusComplete {
+		return "", fmt.Errorf("checkout session %s has no payment intent", sessionID)
+	}
+
+	if s.refunded+amount > s.captured {
+		return "", fmt.Errorf("refund of %d exceeds the %d left on payment intent %s", amount, s.captured-s.refunded, s.paymentIntentID)
+	}
+
+	s.refunded += amount
+	return "re_fake_" + uuid.New().String(), nil
+}
+
+func (f *fakeClient) createSession(amount int64, currency string, metadata map[string]string, manualCapture bool) string {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	// Like the Stripe idempotency key, a payment gets the same session every time
+	paymentID := metadata["payment_id"]
+	if id, ok := f.byPayment[paymentID]; ok && paymentID != "" {
+		return id
+	}
+
+	s := &fakeSession{
+		id:              "cs_fake_" + uuid.New().String(),
+		paymentIntentID: "pi_fake_" + uuid.New().String(),
+		amount:          amount,
+		currency:        currency,
+		metadata:        metadata,
+		manualCapture:   manualCapture,
+		status:          stripe.CheckoutSessionStatusOpen,
+		created:         time.Now(),
+	}
+
+	f.sessions[s.id] = s
+	if paymentID != "" {
+		f.byPayment[paymentID] = s.id
+	}
+
+	go f.settle(s.id)
+
+	return s.id
+}
+
+// settle plays the rider's part of the checkout once the configured delay has passed
+func (f *fakeClient) settle(sessionID string) {
+	time.Sleep(f.config.Delay)
+
+	f.mu.Lock()
+	s := f.sessions[sessionID]
+	if s.status != stripe.CheckoutSessionStatusOpen {
+		// The session was expired in the meantime
+		f.mu.Unlock()
+		return
+	}
+
+	if f.config.Outcome == types.FakeOutcomeFail {
+		snapshot := *s
+		f.mu.Unlock()
+		f.sendPaymentFailedEvent(snapshot)
+		return
+	}
+
+	s.status = stripe.CheckoutSessionStatusComplete
+	if !s.manualCapture {
+		s.captured = s.amount
+	}
+	snapshot := *s
+	f.mu.Unlock()
+
+	f.sendSessionEvent(stripe.EventTypeCheckoutSessionCompleted, snapshot)
+}
+
+func (f *fakeClient) sendSessionEvent(eventType stripe.EventType, s fakeSession) {
+	f.sendEvent(eventType, map[string]any{
+		"id":             s.id,
+		"object":         "checkout.session",
+		"status":         s.status,
+		"amount_total":   s.amount,
+		"currency":       s.currency,
+		"metadata":       s.metadata,
+		"payment_intent": s.paymentIntentID,
+		"mode":           stripe.CheckoutSessionModePayment,
+	})
+}
+
+func (f *fakeClient) sendPaymentFailedEvent(s fakeSession) {
+	f.sendEvent(stripe.EventTypePaymentIntentPaymentFailed, map[string]any{
+		"id":       s.paymentIntentID,
+		"object":   "payment_intent",
+		"status":   stripe.PaymentIntentStatusRequiresPaymentMethod,
+		"amount":   s.amount,
+		"currency": s.currency,
+		"metadata": s.metadata,
+		"last_payment_error": map[string]any{
+			"type":         stripe.ErrorTypeCard,
+			"code":         stripe.ErrorCodeCardDeclined,
+			"message":      "Your card was declined.",
+			"decline_code": "generic_decline",
+		},
+	})
+}
+
+// sendEvent posts a webhook signed with the webhook secret, exactly as Stripe would deliver it
+func (f *fakeClient) sendEvent(eventType stripe.EventType, object map[string]any) {
+	body, err := json.Marshal(map[string]any{
+		"id":          "evt_fake_" + uuid.New().String(),
+		"object":      "event",
+		"api_version": stripe.APIVersion,
+		"created":     time.Now().Unix(),
+		"type":        eventType,
+		"livemode":    false,
+		"data": map[string]any{
+			"object": object,
+		},
+	})
+	if err != nil {
+		log.Printf("Failed to marshal fake %s webhook: %v", eventType, err)
+		return
+	}
+
+	signed := webhook.GenerateTestSignedPayload(&webhook.UnsignedPayload{
+		Payload: body,
+		Secret:  f.config.WebhookSecret,
+	})
+
+	req, err := http.NewRequest(http.MethodPost, f.config.WebhookURL, bytes.NewReader(body))
+	if err != nil {
+		log.Printf("Failed to create fake %s webhook request: %v", eventType, err)
+		return
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Stripe-Signature", signed.Header)
+
+	resp, err := f.httpClient.Do(req)
+	if err != nil {
+		log.Printf("Failed to deliver fake %s webhook: %v", eventType, err)
+		return
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode >= http.StatusMultipleChoices {
+		log.Printf("Fake %s webhook was rejected with status %d", eventType, resp.StatusCode)
+		return
+	}
+
+	log.Printf("Delivered fake %s webhook for %s", eventType, object["id"])
+}
@@ -0,0 +1,104 @@
+package fake
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/AuraReaper/voom/services/payment-service/internal/domain"
+	"github.com/AuraReaper/voom/services/payment-service/pkg/types"
+
+	"github.com/stripe/stripe-go/v81"
+)
+
+const transactionPageSize = 50
+
+type fakeTransactions struct {
+	transactions []*types.ProcessorTransaction
+}
+
+// NewFakeTransactionSource creates a source that pages through the given transactions the way
+// the processor would, oldest first
+func NewFakeTransactionSource(transactions []*types.ProcessorTransaction) domain.TransactionSource {
+	sorted := append([]*types.ProcessorTransaction(nil), transactions...)
+	sort.SliceStable(sorted, func(i, j int) bool {
+		return sorted[i].CreatedAt.Before(sorted[j].CreatedAt)
+	})
+
+	return &fakeTransactions{
+		transactions: sorted,
+	}
+}
+
+// LoadTransactions creates a fake source from a JSON array of processor transactions, so the
+// reconciliation can run offline against an export of the processor records
+func LoadTransactions(path string) (domain.TransactionSource, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read transactions file: %w", err)
+	}
+
+	var transactions []*types.ProcessorTransaction
+	if err := json.Unmarshal(data, &transactions); err != nil {
+		return nil, fmt.Errorf("failed to parse transactions file: %w", err)
+	}
+
+	return NewFakeTransactionSource(transactions), nil
+}
+
+// ListTransactions pages through the transactions in range, the cursor is the offset of the next page
+func (f *fakeTransactions) ListTransactions(ctx context.Context, from, to time.Time, cursor string) (*types.TransactionPage, error) {
+	var inRange []*types.ProcessorTransaction
+	for _, txn := range f.transactions {
+		if !txn.CreatedAt.Before(from) && txn.CreatedAt.Before(to) {
+			inRange = append(inRange, txn)
+		}
+	}
+
+	offset := 0
+	if cursor != "" {
+		var err error
+		if offset, err = strconv.Atoi(cursor); err != nil || offset < 0 || offset > len(inRange) {
+			return nil, fmt.Errorf("invalid transaction cursor: %q", cursor)
+		}
+	}
+
+	end := min(offset+transactionPageSize, len(inRange))
+	page := &types.TransactionPage{
+		Transactions: inRange[offset:end],
+	}
+	if end < len(inRange) {
+		page.NextCursor = strconv.Itoa(end)
+	}
+
+	return page, nil
+}
+
+// ListTransactions lists the sessions this client created, as the processor would report them
+func (f *fakeClient) ListTransactions(ctx context.Context, from, to time.Time, cursor string) (*types.TransactionPage, error) {
+	f.mu.Lock()
+	transactions := make([]*types.ProcessorTransaction, 0, len(f.sessions))
+	for _, s := range f.sessions {
+		txn := &types.ProcessorTransaction{
+			SessionID:      s.id,
+			TripID:         s.metadata["trip_id"],
+			UserID:         s.metadata["user_id"],
+			Purpose:        types.PaymentPurpose(s.metadata["purpose"]),
+			Amount:         s.captured,
+			RefundedAmount: s.refunded,
+			Currency:       s.currency,
+			CreatedAt:      s.created,
+		}
+		if s.status == stripe.CheckoutSessionStatusComplete {
+			txn.ID = s.paymentIntentID
+		}
+		transactions = append(transactions, txn)
+	}
+	f.mu.Unlock()
+
+	return NewFakeTransactionSource(transactions).ListTransactions(ctx, from, to, cursor)
+}
@@ -2,12 +2,15 @@ package stripe
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"github.com/AuraReaper/voom/services/payment-service/internal/domain"
 	"github.com/AuraReaper/voom/services/payment-service/pkg/types"
 
 	"github.com/stripe/stripe-go/v81"
 	"github.com/stripe/stripe-go/v81/checkout/session"
+	"github.com/stripe/stripe-go/v81/paymentintent"
+	"github.com/stripe/stripe-go/v81/refund"
 )
 
 type stripeClient struct {
@@ -23,10 +26,115 @@ func NewStripeClient(config *types.PaymentConfig) domain.PaymentProcessor {
 }
 
 func (s *stripeClient) CreatePaymentSession(ctx context.Context, amount int64, currency string, metadata map[string]string) (string, error) {
+	params := s.newSessionParams(ctx, amount, currency, metadata)
+
+	result, err := session.New(params)
+	if err != nil {
+		return "", fmt.Errorf("failed to create a payment session on stripe: %w", err)
+	}
+
+	return result.ID, nil
+}
+
+func (s *stripeClient) AuthorizePayment(ctx context.Context, amount int64, currency string, metadata map[string]string) (string, error) {
+	params := s.newSessionParams(ctx, amount, currency, metadata)
+	params.PaymentIntentData.CaptureMethod = stripe.String(string(stripe.PaymentIntentCaptureMethodManual))
+
+	result, err := session.New(params)
+	if err != nil {
+		return "", fmt.Errorf("failed to create an authorization session on stripe: %w", err)
+	}
+
+	return result.ID, nil
+}
+
+func (s *stripeClient) CapturePayment(ctx context.Context, sessionID string, amount int64) error {
+	paymentIntentID, err := s.getPaymentIntentID(ctx, sessionID)
+	if err != nil {
+		return err
+	}
+
+	// Capturing less than the authorized amount releases the remainder of the hold
+	_, err = paymentintent.Capture(paymentIntentID, &stripe.PaymentIntentCaptureParams{
+		Params:          stripe.Params{Context: ctx},
+		AmountToCapture: stripe.Int64(amount),
+	})
+	if err != nil {
+		// A declined card or a hold that expired or was released won't capture on a retry
+		var stripeErr *stripe.Error
+		if errors.As(err, &stripeErr) && (stripeErr.Type == stripe.ErrorTypeCard || stripeErr.Type == stripe.ErrorTypeInvalidRequest) {
+			return fmt.Errorf("%w: %v", domain.ErrCaptureDeclined, err)
+		}
+		return fmt.Errorf("failed to capture the payment on stripe: %w", err)
+	}
+
+	return nil
+}
+
+func (s *stripeClient) VoidAuthorization(ctx context.Context, sessionID string) error {
+	checkoutSession, err := session.Get(sessionID, &stripe.CheckoutSessionParams{
+		Params: stripe.Params{Context: ctx},
+	})
+	if err != nil {
+		return fmt.Errorf("failed to get the checkout session from stripe: %w", err)
+	}
+
+	// The rider never completed the session, so there is no hold yet
+	if checkoutSession.Status == stripe.CheckoutSessionStatusOpen {
+		if _, err := session.Expire(sessionID, &stripe.CheckoutSessionExpireParams{
+			Params: stripe.Params{Context: ctx},
+		}); err != nil {
+			return fmt.Errorf("failed to expire the checkout session on stripe: %w", err)
+		}
+		return nil
+	}
+
+	if checkoutSession.PaymentIntent == nil {
+		return fmt.Errorf("checkout session %s has no payment intent", sessionID)
+	}
+
+	if _, err := paymentintent.Cancel(checkoutSession.PaymentIntent.ID, &stripe.PaymentIntentCancelParams{
+		Params: stripe.Params{Context: ctx},
+	}); err != nil {
+		return fmt.Errorf("failed to cancel the payment intent on stripe: %w", err)
+	}
+
+	return nil
+}
+
+func (s *stripeClient) RefundPayment(ctx context.Context, sessionID string, amount int64, reason string) (string, error) {
+	paymentIntentID, err := s.getPaymentIntentID(ctx, sessionID)
+	if err != nil {
+		return "", err
+	}
+
+	params := &stripe.RefundParams{
+		Params:        stripe.Params{Context: ctx},
+		PaymentIntent: stripe.String(paymentIntentID),
+		Amount:        stripe.Int64(amount),
+		// Stripe only accepts a fixed set of reasons, the free text reason is kept in the metadata
+		Reason: stripe.String(string(stripe.RefundReasonRequestedByCustomer)),
+	}
+	params.AddMetadata("reason", reason)
+
+	result, err := refund.New(params)
+	if err != nil {
+		return "", fmt.Errorf("failed to create a refund on stripe: %w", err)
+	}
+
+	return result.ID, nil
+}
+
+func (s *stripeClient) newSessionParams(ctx context.Context, amount int64, currency string, metadata map[string]string) *stripe.CheckoutSessionParams {
 	params := &stripe.CheckoutSessionParams{
+		Params:     stripe.Params{Context: ctx},
 		SuccessURL: stripe.String(s.config.SuccessURL),
 		CancelURL:  stripe.String(s.config.CancelURL),
 		Metadata:   metadata,
+		// Copy the metadata onto the payment intent so payment_intent.* webhooks can be matched to the trip
+		PaymentIntentData: &stripe.CheckoutSessionPaymentIntentDataParams{
+			Metadata: metadata,
+		},
 		LineItems: []*stripe.CheckoutSessionLineItemParams{
 			{
 				PriceData: &stripe.CheckoutSessionLineItemPriceDataParams{
@@ -42,10 +150,26 @@ func (s *stripeClient) CreatePaymentSession(ctx context.Context, amount int64, c
 		Mode: stripe.String(string(stripe.CheckoutSessionModePayment)),
 	}
 
-	result, err := session.New(params)
+	// A session requested again for the same payment, e.g. by a retried command, is the same session
+	if paymentID := metadata["payment_id"]; paymentID != "" {
+		params.SetIdempotencyKey("checkout-session-" + paymentID)
+	}
+
+	return params
+}
+
+// getPaymentIntentID returns the payment intent created by a completed checkout session
+func (s *stripeClient) getPaymentIntentID(ctx context.Context, sessionID string) (string, error) {
+	checkoutSession, err := session.Get(sessionID, &stripe.CheckoutSessionParams{
+		Params: stripe.Params{Context: ctx},
+	})
 	if err != nil {
-		return "", fmt.Errorf("failed to create a payment session on stripe: %w", err)
+		return "", fmt.Errorf("failed to get the checkout session from stripe: %w", err)
 	}
 
-	return result.ID, nil
+	if checkoutSession.PaymentIntent == nil {
+		return "", fmt.Errorf("checkout session %s has no payment intent", sessionID)
+	}
+
+	return checkoutSession.PaymentIntent.ID, nil
 }
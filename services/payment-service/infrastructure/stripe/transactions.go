@@ -0,0 +1,83 @@
+package stripe
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/AuraReaper/voom/services/payment-service/internal/domain"
+	"github.com/AuraReaper/voom/services/payment-service/pkg/types"
+
+	"github.com/stripe/stripe-go/v81"
+	"github.com/stripe/stripe-go/v81/checkout/session"
+)
+
+// transactionPageSize is the largest page Stripe returns
+const transactionPageSize = 100
+
+// NewTransactionSource creates a source listing the checkout sessions of the Stripe account
+func NewTransactionSource(config *types.PaymentConfig) domain.TransactionSource {
+	stripe.Key = config.StripeSecretKey
+
+	return &stripeClient{
+		config: config,
+	}
+}
+
+// ListTransactions returns a page of the checkout sessions created between from and to, with the
+// amounts captured and refunded on their payment intents
+func (s *stripeClient) ListTransactions(ctx context.Context, from, to time.Time, cursor string) (*types.TransactionPage, error) {
+	params := &stripe.CheckoutSessionListParams{
+		ListParams: stripe.ListParams{
+			Context: ctx,
+			Limit:   stripe.Int64(transactionPageSize),
+			Single:  true,
+		},
+		CreatedRange: &stripe.RangeQueryParams{
+			GreaterThanOrEqual: from.Unix(),
+			LesserThan:         to.Unix(),
+		},
+	}
+	if cursor != "" {
+		params.StartingAfter = stripe.String(cursor)
+	}
+	params.AddExpand("data.payment_intent.latest_charge")
+
+	page := &types.TransactionPage{}
+
+	it := session.List(params)
+	for it.Next() {
+		page.Transactions = append(page.Transactions, toTransaction(it.CheckoutSession()))
+	}
+	if err := it.Err(); err != nil {
+		return nil, fmt.Errorf("failed to list checkout sessions on stripe: %w", err)
+	}
+
+	if list := it.CheckoutSessionList(); list != nil && list.HasMore && len(page.Transactions) > 0 {
+		page.NextCursor = page.Transactions[len(page.Transactions)-1].SessionID
+	}
+
+	return page, nil
+}
+
+func toTransaction(cs *stripe.CheckoutSession) *types.ProcessorTransaction {
+	txn := &types.ProcessorTransaction{
+		SessionID: cs.ID,
+		TripID:    cs.Metadata["trip_id"],
+		UserID:    cs.Metadata["user_id"],
+		Purpose:   types.PaymentPurpose(cs.Metadata["purpose"]),
+		Currency:  string(cs.Currency),
+		CreatedAt: time.Unix(cs.Created, 0),
+	}
+
+	// Sessions that were never completed have no payment intent and collected nothing
+	if intent := cs.PaymentIntent; intent != nil {
+		txn.ID = intent.ID
+		txn.Amount = intent.AmountReceived
+		if intent.LatestCharge != nil {
+			txn.RefundedAmount = intent.LatestCharge.AmountRefunded
+		}
+	}
+
+	return txn
+}
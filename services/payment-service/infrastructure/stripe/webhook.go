@@ -0,0 +1,93 @@
+package stripe
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/AuraReaper/voom/services/payment-service/internal/domain"
+	"github.com/AuraReaper/voom/services/payment-service/pkg/types"
+
+	"github.com/stripe/stripe-go/v81"
+	"github.com/stripe/stripe-go/v81/webhook"
+)
+
+type webhookVerifier struct {
+	secret string
+}
+
+// NewWebhookVerifier creates a verifier for webhooks signed with the endpoint secret
+func NewWebhookVerifier(secret string) domain.WebhookVerifier {
+	return &webhookVerifier{
+		secret: secret,
+	}
+}
+
+func (v *webhookVerifier) ParseWebhook(payload []byte, signature string) (*types.WebhookEvent, error) {
+	event, err := webhook.ConstructEventWithOptions(
+		payload,
+		signature,
+		v.secret,
+		webhook.ConstructEventOptions{
+			IgnoreAPIVersionMismatch: true,
+		},
+	)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", domain.ErrInvalidWebhook, err)
+	}
+
+	result := &types.WebhookEvent{
+		ID:   event.ID,
+		Type: string(event.Type),
+	}
+
+	switch event.Type {
+	case stripe.EventTypeCheckoutSessionCompleted,
+		stripe.EventTypeCheckoutSessionExpired,
+		stripe.EventTypeCheckoutSessionAsyncPaymentFailed:
+		var session stripe.CheckoutSession
+		if err := json.Unmarshal(event.Data.Raw, &session); err != nil {
+			return nil, fmt.Errorf("%w: failed to parse checkout session: %v", domain.ErrInvalidWebhook, err)
+		}
+
+		setMetadata(result, session.Metadata)
+		result.SessionID = session.ID
+
+		switch event.Type {
+		case stripe.EventTypeCheckoutSessionCompleted:
+			// A completed hold session only authorizes the card, the fare is captured when the trip completes
+			result.Status = types.PaymentStatusSuccess
+			if result.Purpose == types.PaymentPurposeTripHold {
+				result.Status = types.PaymentStatusAuthorized
+			}
+		case stripe.EventTypeCheckoutSessionExpired:
+			result.Status = types.PaymentStatusCancelled
+			result.Reason = "checkout session expired"
+		default:
+			result.Status = types.PaymentStatusFailed
+			result.Reason = "asynchronous payment failed"
+		}
+	case stripe.EventTypePaymentIntentPaymentFailed:
+		var intent stripe.PaymentIntent
+		if err := json.Unmarshal(event.Data.Raw, &intent); err != nil {
+			return nil, fmt.Errorf("%w: failed to parse payment intent: %v", domain.ErrInvalidWebhook, err)
+		}
+
+		// The session is not part of the payment intent, the metadata naming our payment is copied onto it instead
+		setMetadata(result, intent.Metadata)
+		result.Status = types.PaymentStatusFailed
+		result.Reason = "payment failed"
+		if intent.LastPaymentError != nil && intent.LastPaymentError.Msg != "" {
+			result.Reason = intent.LastPaymentError.Msg
+		}
+	}
+
+	return result, nil
+}
+
+func setMetadata(event *types.WebhookEvent, metadata map[string]string) {
+	event.TripID = metadata["trip_id"]
+	event.UserID = metadata["user_id"]
+	event.DriverID = metadata["driver_id"]
+	event.Purpose = types.PaymentPurpose(metadata["purpose"])
+	event.PaymentID = metadata["payment_id"]
+}
@@ -2,14 +2,153 @@ package domain
 
 import (
 	"context"
+	"errors"
+	"time"
 
 	"github.com/AuraReaper/voom/services/payment-service/pkg/types"
 )
 
+var (
+	ErrPaymentNotFound            = errors.New("payment not found")
+	ErrPaymentExists              = errors.New("payment already exists")
+	ErrInvalidStatusTransition    = errors.New("invalid payment status transition")
+	ErrPaymentAttemptsExhausted   = errors.New("payment attempts exhausted")
+	ErrPaymentNotRefundable       = errors.New("payment is not refundable")
+	ErrRefundExceedsCaptured      = errors.New("refund exceeds the captured amount")
+	ErrInvalidRefundAmount        = errors.New("refund amount must be positive")
+	ErrPaymentNotAuthorized       = errors.New("payment is not authorized")
+	ErrCaptureDeclined            = errors.New("capture declined by the processor")
+	ErrInvalidWebhook             = errors.New("invalid webhook")
+	ErrDuplicateWebhookEvent      = errors.New("webhook event already processed")
+	ErrWalletNotFound             = errors.New("wallet not found")
+	ErrInsufficientBalance        = errors.New("insufficient wallet balance")
+	ErrDuplicateWalletTransaction = errors.New("wallet transaction already applied")
+	ErrInvalidTipAmount           = errors.New("invalid tip amount")
+	ErrTipNotAllowed              = errors.New("trip can't be tipped")
+	ErrTipWindowClosed            = errors.New("tipping window has closed")
+	ErrTipExists                  = errors.New("trip was already tipped")
+	ErrSplitNotFound              = errors.New("split fare not found")
+	ErrSplitExists                = errors.New("fare is already split")
+	ErrSplitSettled               = errors.New("split fare is already settled")
+)
+
 type Service interface {
 	CreatePaymentSession(ctx context.Context, tripID, userID, driverID string, amount int64, currency string) (*types.PaymentIntent, error)
+	GetPaymentByTripID(ctx context.Context, tripID string) (*types.Payment, error)
+	// UpdatePaymentStatus applies the status of a webhook event to the payment it was sent for
+	UpdatePaymentStatus(ctx context.Context, event *types.WebhookEvent) (*types.Payment, error)
+	RetryPayment(ctx context.Context, failed *types.Payment) (*types.PaymentIntent, error)
+	MaxPaymentAttempts() int
+	RefundPayment(ctx context.Context, tripID string, amount int64, reason string) (*types.Refund, *types.Payment, error)
+	AuthorizePayment(ctx context.Context, tripID, userID string, amount int64, currency string) (*types.PaymentIntent, error)
+	// CapturePayment settles a completed trip, from the wallet first when method is wallet
+	CapturePayment(ctx context.Context, tripID, driverID string, amount int64, method types.PaymentMethod) (*types.Payment, error)
+	// FailCapture marks the hold of a trip failed once it can't be captured
+	FailCapture(ctx context.Context, tripID string) (*types.Payment, error)
+	// RecordCashPayment records the fare a driver collected in cash and books the platform commission
+	// as a debt on the driver's ledger. Recording the same trip twice fails with ErrPaymentExists.
+	RecordCashPayment(ctx context.Context, tripID, userID, driverID string, amount int64, currency string) (*types.Payment, error)
+	VoidPayment(ctx context.Context, tripID string) (*types.Payment, error)
+	// RecordWebhookEvent claims a webhook event for processing, it fails with
+	// ErrDuplicateWebhookEvent when the event was already claimed
+	RecordWebhookEvent(ctx context.Context, event *types.WebhookEvent) error
+	// ForgetWebhookEvent releases a claimed event whose processing failed so a retried delivery is handled again
+	ForgetWebhookEvent(ctx context.Context, eventID string) error
+
+	// TopUpWallet creates a session the rider pays to add amount to their wallet,
+	// the wallet is credited once the processor confirms the payment
+	TopUpWallet(ctx context.Context, userID string, amount int64, currency string) (*types.PaymentIntent, error)
+	GetWallet(ctx context.Context, userID string) (*types.Wallet, error)
+	ListWalletTransactions(ctx context.Context, userID string) ([]*types.WalletTransaction, error)
+
+	// GetDriverBalance sums the ledger of a driver, netting the commission owed on cash fares
+	// against their card and wallet earnings
+	GetDriverBalance(ctx context.Context, driverID string) (*types.DriverBalance, error)
+
+	// TipTrip creates a session the rider pays to tip the driver of a trip whose fare they paid,
+	// the whole tip is credited to the driver once the processor confirms the payment
+	TipTrip(ctx context.Context, tripID, userID string, amount int64) (*types.PaymentIntent, error)
+
+	// SplitPayment starts collecting the fare of a completed trip from the riders sharing it, creating
+	// a session for the share of each of them. The owner's hold is captured once the split is settled.
+	SplitPayment(ctx context.Context, tripID, driverID string, amount int64, method types.PaymentMethod, userIDs []string) ([]*types.PaymentIntent, error)
+	// RecordSplitSharePaid marks the share behind a paid payment, returning the owner's captured
+	// payment when it was the last share and the split got settled
+	RecordSplitSharePaid(ctx context.Context, share *types.Payment) (*types.Payment, error)
+	// SettleDueSplits charges the owners of splits whose deadline has passed, returning their payments
+	// captured, or failed when the owner couldn't be charged
+	SettleDueSplits(ctx context.Context) ([]*types.Payment, error)
 }
 
+// PaymentProcessor creates one session per payment_id in the metadata, creating it again returns
+// the first session
 type PaymentProcessor interface {
 	CreatePaymentSession(ctx context.Context, amount int64, currency string, metadata map[string]string) (string, error)
+	RefundPayment(ctx context.Context, sessionID string, amount int64, reason string) (string, error)
+	// AuthorizePayment creates a session that only places a hold of amount on the card
+	AuthorizePayment(ctx context.Context, amount int64, currency string, metadata map[string]string) (string, error)
+	// CapturePayment collects amount from the hold, the remainder is released. It fails with
+	// ErrCaptureDeclined when capturing again can't succeed.
+	CapturePayment(ctx context.Context, sessionID string, amount int64) error
+	// VoidAuthorization releases the hold, or expires the session if it was never completed
+	VoidAuthorization(ctx context.Context, sessionID string) error
+}
+
+// TransactionSource pages through the transactions the processor recorded between from and to,
+// starting after cursor, or from the first page when cursor is empty
+type TransactionSource interface {
+	ListTransactions(ctx context.Context, from, to time.Time, cursor string) (*types.TransactionPage, error)
+}
+
+// WebhookVerifier checks the signature of a processor webhook and parses it,
+// returning ErrInvalidWebhook when the payload can't be trusted
+type WebhookVerifier interface {
+	ParseWebhook(payload []byte, signature string) (*types.WebhookEvent, error)
+}
+
+type PaymentRepository interface {
+	CreatePayment(ctx context.Context, payment *types.Payment) (*types.Payment, error)
+	// GetPaymentByTripID returns the latest fare payment of a trip, tips are left out
+	GetPaymentByTripID(ctx context.Context, tripID string) (*types.Payment, error)
+	GetTipByTripID(ctx context.Context, tripID string) (*types.Payment, error)
+	GetPaymentByID(ctx context.Context, paymentID string) (*types.Payment, error)
+	GetPaymentBySessionID(ctx context.Context, sessionID string) (*types.Payment, error)
+	UpdatePaymentStatus(ctx context.Context, paymentID string, status types.PaymentStatus) (*types.Payment, error)
+	// ListPaymentsCreatedBetween returns the payments of every purpose created in [from, to)
+	ListPaymentsCreatedBetween(ctx context.Context, from, to time.Time) ([]*types.Payment, error)
+	// CapturePayment marks a payment as successful with the captured amount, and assigns the
+	// driver when the payment was created before one was known
+	CapturePayment(ctx context.Context, paymentID, driverID string, amount int64) (*types.Payment, error)
+
+	// ReserveRefund atomically adds amount to the refunded amount of a successful payment,
+	// failing with ErrRefundExceedsCaptured instead of refunding more than was captured
+	ReserveRefund(ctx context.Context, paymentID string, amount int64) (*types.Payment, error)
+	// ReleaseRefund undoes a reservation when the processor rejected the refund
+	ReleaseRefund(ctx context.Context, paymentID string, amount int64) error
+	CreateRefund(ctx context.Context, refund *types.Refund) error
+	ListRefundsByTripID(ctx context.Context, tripID string) ([]*types.Refund, error)
+
+	AddLedgerEntry(ctx context.Context, entry *types.LedgerEntry) error
+	ListLedgerEntriesByDriverID(ctx context.Context, driverID string) ([]*types.LedgerEntry, error)
+
+	// CreateWebhookEvent stores a processed webhook event ID, failing with ErrDuplicateWebhookEvent if it exists
+	CreateWebhookEvent(ctx context.Context, eventID, eventType string) error
+	DeleteWebhookEvent(ctx context.Context, eventID string) error
+
+	// ApplyWalletTransaction atomically changes the wallet balance by the transaction amount and
+	// records it. Debits fail with ErrInsufficientBalance instead of overdrawing the wallet, and a
+	// reference that was already applied fails with ErrDuplicateWalletTransaction.
+	ApplyWalletTransaction(ctx context.Context, txn *types.WalletTransaction) (*types.WalletTransaction, error)
+	GetWallet(ctx context.Context, userID string) (*types.Wallet, error)
+	ListWalletTransactions(ctx context.Context, userID string) ([]*types.WalletTransaction, error)
+
+	// CreateSplit stores a split fare, failing with ErrSplitExists when the trip already has one
+	CreateSplit(ctx context.Context, split *types.Split) error
+	GetSplit(ctx context.Context, tripID string) (*types.Split, error)
+	// MarkSplitSharePaid marks the share of a rider as paid, failing with ErrSplitSettled once the split is settled
+	MarkSplitSharePaid(ctx context.Context, tripID, userID string) (*types.Split, error)
+	// SettleSplit atomically closes an open split, failing with ErrSplitSettled if it was already closed.
+	// Shares that were not paid are marked unpaid.
+	SettleSplit(ctx context.Context, tripID string) (*types.Split, error)
+	ListDueSplits(ctx context.Context, now time.Time) ([]*types.Split, error)
 }
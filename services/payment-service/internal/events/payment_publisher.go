@@ -0,0 +1,101 @@
+package events
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/AuraReaper/voom/services/payment-service/pkg/types"
+	"github.com/AuraReaper/voom/shared/contracts"
+	"github.com/AuraReaper/voom/shared/messaging"
+)
+
+type PaymentEventPublisher struct {
+	bus messaging.Bus
+}
+
+func NewPaymentEventPublisher(bus messaging.Bus) *PaymentEventPublisher {
+	return &PaymentEventPublisher{
+		bus: bus,
+	}
+}
+
+func (p *PaymentEventPublisher) PublishRefunded(ctx context.Context, refund *types.Refund, payment *types.Payment) error {
+	payload := messaging.PaymentEventRefundedData{
+		TripID:        refund.TripID,
+		RefundID:      refund.ID,
+		UserID:        refund.UserID,
+		DriverID:      refund.DriverID,
+		Amount:        float64(refund.Amount) / 100.0, // Convert from cents to dollars
+		TotalRefunded: float64(payment.RefundedAmount) / 100.0,
+		Currency:      refund.Currency,
+		Reason:        refund.Reason,
+	}
+
+	return messaging.Publish(ctx, p.bus, contracts.PaymentEventRefunded, refund.UserID, payload)
+}
+
+// PublishTipReceived notifies the driver of a paid tip
+func (p *PaymentEventPublisher) PublishTipReceived(ctx context.Context, tip *types.Payment) error {
+	payload := messaging.PaymentEventTipData{
+		TripID:   tip.TripID,
+		UserID:   tip.UserID,
+		DriverID: tip.DriverID,
+		Amount:   float64(tip.CapturedAmount) / 100.0,
+		Currency: tip.Currency,
+	}
+
+	return messaging.Publish(ctx, p.bus, contracts.PaymentEventTipReceived, tip.DriverID, payload)
+}
+
+// paymentStatusEvents maps a payment status to the event announcing it
+var paymentStatusEvents = map[types.PaymentStatus]string{
+	types.PaymentStatusAuthorized: contracts.PaymentEventAuthorized,
+	types.PaymentStatusSuccess:    contracts.PaymentEventSuccess,
+	types.PaymentStatusFailed:     contracts.PaymentEventFailed,
+	types.PaymentStatusCancelled:  contracts.PaymentEventCancelled,
+}
+
+// PublishStatusUpdate announces the current status of a payment
+func (p *PaymentEventPublisher) PublishStatusUpdate(ctx context.Context, payment *types.Payment, reason string) error {
+	routingKey, ok := paymentStatusEvents[payment.Status]
+	if !ok {
+		return fmt.Errorf("no event for payment status %s", payment.Status)
+	}
+
+	payload := messaging.PaymentStatusUpdateData{
+		TripID:    payment.TripID,
+		UserID:    payment.UserID,
+		DriverID:  payment.DriverID,
+		SessionID: payment.StripeSessionID,
+		Reason:    reason,
+	}
+
+	return messaging.Publish(ctx, p.bus, routingKey, payment.UserID, payload)
+}
+
+func (p *PaymentEventPublisher) PublishRetry(ctx context.Context, retry *types.PaymentIntent, maxAttempts int, reason string) error {
+	payload := messaging.PaymentEventRetryData{
+		TripID:      retry.TripID,
+		SessionID:   retry.StripeSessionID,
+		Amount:      float64(retry.Amount) / 100.0, // Convert from cents to dollars
+		Currency:    retry.Currency,
+		Attempt:     retry.Attempt,
+		MaxAttempts: maxAttempts,
+		Reason:      reason,
+	}
+
+	return messaging.Publish(ctx, p.bus, contracts.PaymentEventRetry, retry.UserID, payload)
+}
+
+func (p *PaymentEventPublisher) PublishDunning(ctx context.Context, payment *types.Payment) error {
+	payload := messaging.PaymentEventDunningData{
+		TripID:   payment.TripID,
+		UserID:   payment.UserID,
+		DriverID: payment.DriverID,
+		Amount:   float64(payment.Amount) / 100.0,
+		Currency: payment.Currency,
+		Attempts: payment.Attempt,
+	}
+
+	return messaging.Publish(ctx, p.bus, contracts.PaymentEventDunning, payment.UserID, payload)
+}
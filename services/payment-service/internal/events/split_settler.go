@@ -0,0 +1,53 @@
+package events
+
+import (
+	"context"
+	"log"
+	"time"
+
+	"github.com/AuraReaper/voom/services/payment-service/internal/domain"
+)
+
+// SplitSettler charges trip owners for the shares riders didn't pay before the split deadline
+type SplitSettler struct {
+	service   domain.Service
+	publisher *PaymentEventPublisher
+	interval  time.Duration
+}
+
+func NewSplitSettler(service domain.Service, publisher *PaymentEventPublisher, interval time.Duration) *SplitSettler {
+	return &SplitSettler{
+		service:   service,
+		publisher: publisher,
+		interval:  interval,
+	}
+}
+
+// Run settles due splits every interval until ctx is cancelled
+func (s *SplitSettler) Run(ctx context.Context) {
+	ticker := time.NewTicker(s.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			s.settle(ctx)
+		}
+	}
+}
+
+func (s *SplitSettler) settle(ctx context.Context) {
+	payments, err := s.service.SettleDueSplits(ctx)
+	if err != nil {
+		log.Printf("Failed to settle due splits: %v", err)
+		return
+	}
+
+	for _, payment := range payments {
+		if err := s.publisher.PublishStatusUpdate(ctx, payment, ""); err != nil {
+			log.Printf("Failed to publish payment %s event for trip %s: %v", payment.Status, payment.TripID, err)
+		}
+	}
+}
@@ -2,58 +2,102 @@ package events
 
 import (
 	"context"
-	"encoding/json"
+	"errors"
 	"log"
 
 	"github.com/AuraReaper/voom/services/payment-service/internal/domain"
+	"github.com/AuraReaper/voom/services/payment-service/pkg/types"
 	"github.com/AuraReaper/voom/shared/contracts"
 	"github.com/AuraReaper/voom/shared/messaging"
 
 	"github.com/rabbitmq/amqp091-go"
 )
 
+// tripOrdered handles the messages about one trip in order and different trips concurrently
+var tripOrdered = []messaging.ConsumerOption{
+	messaging.WithWorkers(4),
+	messaging.WithOrderingKey(messaging.TripIDKey),
+}
+
 type TripConsumer struct {
-	rabbitmq *messaging.RabbitMQ
-	service  domain.Service
+	bus     messaging.Bus
+	service domain.Service
 }
 
-func NewTripConsumer(rabbitmq *messaging.RabbitMQ, service domain.Service) *TripConsumer {
+func NewTripConsumer(bus messaging.Bus, service domain.Service) *TripConsumer {
 	return &TripConsumer{
-		rabbitmq: rabbitmq,
-		service:  service,
+		bus:     bus,
+		service: service,
 	}
 }
 
 func (c *TripConsumer) Listen() error {
-	return c.rabbitmq.ConsumeMessages(messaging.PaymentTripResponseQueue, func(ctx context.Context, msg amqp091.Delivery) error {
-		var message contracts.AmqpMessage
-		if err := json.Unmarshal(msg.Body, &message); err != nil {
-			log.Printf("Failed to unmarshal message: %v", err)
-			return err
-		}
-
+	return c.bus.ConsumeMessages(messaging.PaymentTripResponseQueue, func(ctx context.Context, msg amqp091.Delivery) error {
 		switch msg.RoutingKey {
 		case contracts.PaymentCmdCreateSession:
-			var payload messaging.PaymentTripResponseData
-			if err := json.Unmarshal(message.Data, &payload); err != nil {
-				log.Printf("Failed to unmarshal payload: %v", err)
+			_, payload, err := messaging.Decode[messaging.PaymentTripResponseData](msg)
+			if err != nil {
+				log.Printf("Failed to decode message: %v", err)
 				return err
 			}
 			if err := c.handleTripAccepted(ctx, payload); err != nil {
 				log.Printf("Failed to handle trip accepted: %v", err)
 				return err
 			}
-		case contracts.PaymentEventSuccess:
-			var payload messaging.PaymentStatusUpdateData
-			if err := json.Unmarshal(message.Data, &payload); err != nil {
-				log.Printf("Failed to unmarshal payload: %v", err)
+		case contracts.PaymentCmdAuthorize:
+			_, payload, err := messaging.Decode[messaging.PaymentAuthorizeData](msg)
+			if err != nil {
+				log.Printf("Failed to decode message: %v", err)
+				return err
+			}
+			if err := c.handleAuthorize(ctx, payload); err != nil {
+				log.Printf("Failed to handle payment authorization: %v", err)
+				return err
+			}
+		case contracts.PaymentCmdCapture:
+			_, payload, err := messaging.Decode[messaging.PaymentCaptureData](msg)
+			if err != nil {
+				log.Printf("Failed to decode message: %v", err)
+				return err
+			}
+			if err := c.handleCapture(ctx, payload, messaging.IsLastAttempt(msg)); err != nil {
+				log.Printf("Failed to handle payment capture: %v", err)
+				return err
+			}
+		case contracts.PaymentCmdRecordCash:
+			_, payload, err := messaging.Decode[messaging.PaymentCaptureData](msg)
+			if err != nil {
+				log.Printf("Failed to decode message: %v", err)
+				return err
+			}
+			if err := c.handleRecordCash(ctx, payload); err != nil {
+				log.Printf("Failed to handle cash collected: %v", err)
+				return err
+			}
+		case contracts.PaymentCmdVoid:
+			_, payload, err := messaging.Decode[messaging.PaymentVoidData](msg)
+			if err != nil {
+				log.Printf("Failed to decode message: %v", err)
+				return err
+			}
+			if err := c.handleVoid(ctx, payload); err != nil {
+				log.Printf("Failed to handle payment void: %v", err)
+				return err
+			}
+		case contracts.RiderCmdTip:
+			message, payload, err := messaging.Decode[messaging.RiderTipData](msg)
+			if err != nil {
+				log.Printf("Failed to decode message: %v", err)
+				return err
+			}
+			if err := c.handleTip(ctx, message.OwnerID, payload); err != nil {
+				log.Printf("Failed to handle tip: %v", err)
 				return err
 			}
-			log.Printf("Payment success event received for trip %s", payload.TripID)
 		}
 
 		return nil
-	})
+	}, tripOrdered...)
 }
 
 func (c *TripConsumer) handleTripAccepted(ctx context.Context, payload messaging.PaymentTripResponseData) error {
@@ -74,30 +118,201 @@ func (c *TripConsumer) handleTripAccepted(ctx context.Context, payload messaging
 
 	log.Printf("Payment session created: %s", paymentSession.StripeSessionID)
 
-	// Publish payment session created event
-	paymentPayload := messaging.PaymentEventSessionCreatedData{
-		TripID:    payload.TripID,
-		SessionID: paymentSession.StripeSessionID,
-		Amount:    float64(paymentSession.Amount) / 100.0, // Convert from cents to dollars
-		Currency:  paymentSession.Currency,
+	return c.publishSessionCreated(ctx, paymentSession)
+}
+
+// handleAuthorize asks the rider to place a hold on their card for a newly booked trip
+func (c *TripConsumer) handleAuthorize(ctx context.Context, payload messaging.PaymentAuthorizeData) error {
+	log.Printf("Handling payment authorization for trip: %s", payload.TripID)
+
+	paymentSession, err := c.service.AuthorizePayment(
+		ctx,
+		payload.TripID,
+		payload.UserID,
+		int64(payload.Amount*100),
+		payload.Currency,
+	)
+	if err != nil {
+		log.Printf("Failed to create authorization session: %v", err)
+		return err
+	}
+
+	log.Printf("Authorization session created: %s", paymentSession.StripeSessionID)
+
+	return c.publishSessionCreated(ctx, paymentSession)
+}
+
+// handleCapture collects the final fare of a completed trip from its hold. A capture the processor
+// declined, or that still fails on the last attempt, fails the payment so the trip is marked unpaid.
+func (c *TripConsumer) handleCapture(ctx context.Context, payload messaging.PaymentCaptureData, lastAttempt bool) error {
+	log.Printf("Handling payment capture for trip: %s", payload.TripID)
+
+	// The trip service sends the method the rider booked with, only wallet changes how the hold is
+	// captured, so a command without one is charged to the card
+	method := types.PaymentMethod(payload.PaymentMethod)
+
+	if len(payload.SplitWith) > 0 {
+		return c.handleSplitCapture(ctx, payload, method)
+	}
+
+	payment, err := c.service.CapturePayment(ctx, payload.TripID, payload.DriverID, int64(payload.Amount*100), method)
+	if err != nil {
+		// A redelivered command finds the payment already captured
+		if errors.Is(err, domain.ErrPaymentNotAuthorized) {
+			log.Printf("Ignoring payment capture for trip %s: %v", payload.TripID, err)
+			return nil
+		}
+		if errors.Is(err, domain.ErrCaptureDeclined) || lastAttempt {
+			return c.failCapture(ctx, payload.TripID, err)
+		}
+		return err
+	}
+
+	if err := c.publishPaymentSuccess(ctx, payment); err != nil {
+		return err
+	}
+
+	log.Printf("Captured %d of %d held for trip: %s", payment.CapturedAmount, payment.Amount, payment.TripID)
+	return nil
+}
+
+// failCapture fails the hold of a trip that can't be captured and announces the failure
+func (c *TripConsumer) failCapture(ctx context.Context, tripID string, cause error) error {
+	log.Printf("Giving up capturing the payment of trip %s: %v", tripID, cause)
+
+	payment, err := c.service.FailCapture(ctx, tripID)
+	if err != nil {
+		return err
+	}
+
+	if err := messaging.Publish(ctx, c.bus, contracts.PaymentEventFailed, payment.UserID, messaging.PaymentStatusUpdateData{
+		TripID:    payment.TripID,
+		UserID:    payment.UserID,
+		DriverID:  payment.DriverID,
+		SessionID: payment.StripeSessionID,
+		Reason:    cause.Error(),
+	}); err != nil {
+		log.Printf("Failed to publish payment failed event: %v", err)
+		return err
+	}
+
+	return nil
+}
+
+// handleSplitCapture asks each rider sharing the fare for their share, the owner's hold is
+// captured for the rest once the split is settled
+func (c *TripConsumer) handleSplitCapture(ctx context.Context, payload messaging.PaymentCaptureData, method types.PaymentMethod) error {
+	shares, err := c.service.SplitPayment(ctx, payload.TripID, payload.DriverID, int64(payload.Amount*100), method, payload.SplitWith)
+	if err != nil {
+		if errors.Is(err, domain.ErrPaymentNotAuthorized) || errors.Is(err, domain.ErrSplitExists) {
+			log.Printf("Ignoring split capture for trip %s: %v", payload.TripID, err)
+			return nil
+		}
+		return err
+	}
+
+	for _, share := range shares {
+		if err := c.publishSessionCreated(ctx, share); err != nil {
+			return err
+		}
 	}
 
-	payloadBytes, err := json.Marshal(paymentPayload)
+	log.Printf("Requested %d shares of the fare of trip: %s", len(shares), payload.TripID)
+	return nil
+}
+
+// handleRecordCash records the fare the driver collected in cash
+func (c *TripConsumer) handleRecordCash(ctx context.Context, payload messaging.PaymentCaptureData) error {
+	log.Printf("Handling cash collected for trip: %s", payload.TripID)
+
+	payment, err := c.service.RecordCashPayment(ctx, payload.TripID, payload.UserID, payload.DriverID, int64(payload.Amount*100), payload.Currency)
 	if err != nil {
-		log.Printf("Failed to marshal payment session payload: %v", err)
+		if errors.Is(err, domain.ErrPaymentExists) {
+			log.Printf("Ignoring cash collected for trip %s: %v", payload.TripID, err)
+			return nil
+		}
 		return err
 	}
 
-	if err := c.rabbitmq.PublishMessage(ctx, contracts.PaymentEventSessionCreated,
-		contracts.AmqpMessage{
-			OwnerID: payload.UserID,
-			Data:    payloadBytes,
-		},
-	); err != nil {
+	if err := c.publishPaymentSuccess(ctx, payment); err != nil {
+		return err
+	}
+
+	log.Printf("Recorded %d collected in cash for trip: %s", payment.CapturedAmount, payment.TripID)
+	return nil
+}
+
+func (c *TripConsumer) publishPaymentSuccess(ctx context.Context, payment *types.Payment) error {
+	if err := messaging.Publish(ctx, c.bus, contracts.PaymentEventSuccess, payment.UserID, messaging.PaymentStatusUpdateData{
+		TripID:    payment.TripID,
+		UserID:    payment.UserID,
+		DriverID:  payment.DriverID,
+		SessionID: payment.StripeSessionID,
+	}); err != nil {
+		log.Printf("Failed to publish payment success event: %v", err)
+		return err
+	}
+
+	return nil
+}
+
+// handleVoid releases the hold of a trip that will never be completed
+func (c *TripConsumer) handleVoid(ctx context.Context, payload messaging.PaymentVoidData) error {
+	log.Printf("Handling payment void for trip %s: %s", payload.TripID, payload.Reason)
+
+	payment, err := c.service.VoidPayment(ctx, payload.TripID)
+	if err != nil {
+		// Nothing to release when the rider never got a session or the hold is already final
+		if errors.Is(err, domain.ErrPaymentNotFound) || errors.Is(err, domain.ErrInvalidStatusTransition) {
+			log.Printf("Ignoring payment void for trip %s: %v", payload.TripID, err)
+			return nil
+		}
+		return err
+	}
+
+	log.Printf("Voided payment %s for trip: %s", payment.ID, payment.TripID)
+	return nil
+}
+
+// handleTip offers the rider a session to pay the tip they sent from their socket
+func (c *TripConsumer) handleTip(ctx context.Context, userID string, payload messaging.RiderTipData) error {
+	log.Printf("Handling tip from rider %s for trip: %s", userID, payload.TripID)
+
+	tip, err := c.service.TipTrip(ctx, payload.TripID, userID, int64(payload.Amount*100))
+	if err != nil {
+		// Redelivering a tip the rider is not allowed to give would fail the same way
+		if isTipRejected(err) {
+			log.Printf("Rejected tip from rider %s for trip %s: %v", userID, payload.TripID, err)
+			return nil
+		}
+		return err
+	}
+
+	return c.publishSessionCreated(ctx, tip)
+}
+
+// isTipRejected reports whether err is a tip the rules don't allow rather than a failure
+func isTipRejected(err error) bool {
+	return errors.Is(err, domain.ErrInvalidTipAmount) ||
+		errors.Is(err, domain.ErrTipNotAllowed) ||
+		errors.Is(err, domain.ErrTipWindowClosed) ||
+		errors.Is(err, domain.ErrTipExists) ||
+		errors.Is(err, domain.ErrPaymentNotFound)
+}
+
+func (c *TripConsumer) publishSessionCreated(ctx context.Context, paymentSession *types.PaymentIntent) error {
+	paymentPayload := messaging.PaymentEventSessionCreatedData{
+		TripID:    paymentSession.TripID,
+		SessionID: paymentSession.StripeSessionID,
+		Amount:    float64(paymentSession.Amount) / 100.0, // Convert from cents to dollars
+		Currency:  paymentSession.Currency,
+	}
+
+	if err := messaging.Publish(ctx, c.bus, contracts.PaymentEventSessionCreated, paymentSession.UserID, paymentPayload); err != nil {
 		log.Printf("Failed to publish payment session created event: %v", err)
 		return err
 	}
 
-	log.Printf("Published payment session created event for trip: %s", payload.TripID)
+	log.Printf("Published payment session created event for trip: %s", paymentSession.TripID)
 	return nil
 }
@@ -0,0 +1,97 @@
+package events
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/AuraReaper/voom/services/payment-service/internal/domain"
+	"github.com/AuraReaper/voom/services/payment-service/internal/infrastructure/repository"
+	"github.com/AuraReaper/voom/services/payment-service/internal/service"
+	paymentTopology "github.com/AuraReaper/voom/services/payment-service/pkg/topology"
+	"github.com/AuraReaper/voom/services/payment-service/pkg/types"
+	tripTopology "github.com/AuraReaper/voom/services/trip-service/pkg/topology"
+	"github.com/AuraReaper/voom/shared/contracts"
+	"github.com/AuraReaper/voom/shared/messaging"
+)
+
+// failingProcessor fails every capture with err
+type failingProcessor struct {
+	domain.PaymentProcessor
+	err error
+}
+
+func (p failingProcessor) CapturePayment(ctx context.Context, sessionID string, amount int64) error {
+	return p.err
+}
+
+func TestCaptureFailurePublishesPaymentFailed(t *testing.T) {
+	tests := []struct {
+		name     string
+		err      error
+		attempts int
+	}{
+		{"declined", domain.ErrCaptureDeclined, 1},
+		{"failing until out of retries", errors.New("processor unavailable"), len(messaging.RetryDelays) + 1},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := context.Background()
+			bus := messaging.NewInmemBus(paymentTopology.Topology, tripTopology.Topology)
+			repo := repository.NewInmemRepository()
+			svc := service.NewPaymentService(failingProcessor{err: tt.err}, repo, &types.PaymentConfig{})
+
+			if _, err := repo.CreatePayment(ctx, &types.Payment{
+				ID:              "hold-1",
+				TripID:          "trip-1",
+				UserID:          "rider-1",
+				Purpose:         types.PaymentPurposeTripHold,
+				Amount:          300,
+				Currency:        "inr",
+				Status:          types.PaymentStatusAuthorized,
+				StripeSessionID: "session-1",
+				Attempt:         1,
+			}); err != nil {
+				t.Fatal(err)
+			}
+
+			if err := NewTripConsumer(bus, svc).Listen(); err != nil {
+				t.Fatal(err)
+			}
+			if err := messaging.Publish(ctx, bus, contracts.PaymentCmdCapture, "rider-1", messaging.PaymentCaptureData{
+				TripID:   "trip-1",
+				UserID:   "rider-1",
+				DriverID: "driver-1",
+				Amount:   3,
+				Currency: "inr",
+			}); err != nil {
+				t.Fatal(err)
+			}
+
+			handled, err := bus.Drain(ctx)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if handled != tt.attempts {
+				t.Errorf("the capture was handled %d times, want %d", handled, tt.attempts)
+			}
+			if letters := bus.DeadLetters(messaging.PaymentTripResponseQueue); len(letters) != 0 {
+				t.Errorf("the capture was dead-lettered: %+v", letters)
+			}
+
+			failed := bus.Pending(messaging.NotifyPaymentFailedQueue)
+			if len(failed) != 1 || failed[0].RoutingKey != contracts.PaymentEventFailed {
+				t.Fatalf("got %d messages on %s, want one payment failed event", len(failed), messaging.NotifyPaymentFailedQueue)
+			}
+
+			payment, err := repo.GetPaymentByTripID(ctx, "trip-1")
+			if err != nil {
+				t.Fatal(err)
+			}
+			if payment.Status != types.PaymentStatusFailed {
+				t.Errorf("the hold is %s, want %s", payment.Status, types.PaymentStatusFailed)
+			}
+		})
+	}
+}
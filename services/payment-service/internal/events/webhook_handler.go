@@ -0,0 +1,159 @@
+package events
+
+import (
+	"context"
+	"errors"
+	"log"
+
+	"github.com/AuraReaper/voom/services/payment-service/internal/domain"
+	"github.com/AuraReaper/voom/services/payment-service/pkg/types"
+)
+
+// WebhookHandler verifies processor webhooks, applies them to the stored payments and
+// publishes the resulting payment events
+type WebhookHandler struct {
+	service   domain.Service
+	verifier  domain.WebhookVerifier
+	publisher *PaymentEventPublisher
+}
+
+func NewWebhookHandler(service domain.Service, verifier domain.WebhookVerifier, publisher *PaymentEventPublisher) *WebhookHandler {
+	return &WebhookHandler{
+		service:   service,
+		verifier:  verifier,
+		publisher: publisher,
+	}
+}
+
+// Handle processes a raw webhook delivery. Stripe retries deliveries, so an event that was
+// already processed is skipped and reported as a duplicate.
+func (h *WebhookHandler) Handle(ctx context.Context, payload []byte, signature string) (event *types.WebhookEvent, duplicate bool, err error) {
+	event, err = h.verifier.ParseWebhook(payload, signature)
+	if err != nil {
+		return nil, false, err
+	}
+
+	log.Printf("Received Stripe event %s: %s", event.ID, event.Type)
+
+	if event.Status == "" {
+		return event, false, nil
+	}
+
+	if err := h.service.RecordWebhookEvent(ctx, event); err != nil {
+		if errors.Is(err, domain.ErrDuplicateWebhookEvent) {
+			log.Printf("Skipping duplicate Stripe event %s", event.ID)
+			return event, true, nil
+		}
+		return nil, false, err
+	}
+
+	if err := h.process(ctx, event); err != nil {
+		// Let the retried delivery process the event again
+		if forgetErr := h.service.ForgetWebhookEvent(ctx, event.ID); forgetErr != nil {
+			log.Printf("Failed to forget Stripe event %s: %v", event.ID, forgetErr)
+		}
+		return nil, false, err
+	}
+
+	return event, false, nil
+}
+
+func (h *WebhookHandler) process(ctx context.Context, event *types.WebhookEvent) error {
+	if event.TripID == "" && event.SessionID == "" && event.PaymentID == "" {
+		log.Printf("Ignoring Stripe event %s that references no trip, session or payment", event.ID)
+		return nil
+	}
+
+	payment, err := h.service.UpdatePaymentStatus(ctx, event)
+	if err != nil {
+		// A payment already final, e.g. a session we expired ourselves, is not an error
+		if errors.Is(err, domain.ErrInvalidStatusTransition) {
+			log.Printf("Ignoring payment status update for trip %s: %v", event.TripID, err)
+			return nil
+		}
+		// An event matched by trip only, e.g. about a share or a tip, must not touch the fare
+		if errors.Is(err, domain.ErrPaymentNotFound) && event.SessionID == "" && event.PaymentID == "" {
+			log.Printf("Ignoring Stripe event %s: %v", event.ID, err)
+			return nil
+		}
+		log.Printf("Failed to update payment status: %v", err)
+		return err
+	}
+
+	log.Printf("Payment %s for trip %s is now %s", payment.ID, payment.TripID, payment.Status)
+
+	// Top-ups only concern the wallet, a failed one is simply started over by the rider
+	if payment.Purpose == types.PaymentPurposeWalletTopUp {
+		return nil
+	}
+
+	// A failed share is left to the owner, the trip is paid once the split is settled
+	if payment.Purpose == types.PaymentPurposeSplitShare {
+		if payment.Status != types.PaymentStatusSuccess {
+			return nil
+		}
+		settled, err := h.service.RecordSplitSharePaid(ctx, payment)
+		if err != nil {
+			log.Printf("Failed to record split share: %v", err)
+			return err
+		}
+		if settled == nil {
+			return nil
+		}
+		if err := h.publisher.PublishStatusUpdate(ctx, settled, ""); err != nil {
+			log.Printf("Failed to publish payment %s event: %v", settled.Status, err)
+			return err
+		}
+		return nil
+	}
+
+	// Tips don't affect the trip, only the driver hears about a paid one
+	if payment.Purpose == types.PaymentPurposeTip {
+		if payment.Status != types.PaymentStatusSuccess {
+			return nil
+		}
+		if err := h.publisher.PublishTipReceived(ctx, payment); err != nil {
+			log.Printf("Failed to publish tip received event: %v", err)
+			return err
+		}
+		return nil
+	}
+
+	if err := h.publisher.PublishStatusUpdate(ctx, payment, event.Reason); err != nil {
+		log.Printf("Failed to publish payment %s event: %v", payment.Status, err)
+		return err
+	}
+
+	if payment.Status == types.PaymentStatusAuthorized || payment.Status == types.PaymentStatusSuccess {
+		return nil
+	}
+
+	return h.handlePaymentFailure(ctx, payment, event.Reason)
+}
+
+// handlePaymentFailure offers the rider a fresh session, or escalates the trip to dunning
+// once every attempt has been used
+func (h *WebhookHandler) handlePaymentFailure(ctx context.Context, payment *types.Payment, reason string) error {
+	retry, err := h.service.RetryPayment(ctx, payment)
+	if errors.Is(err, domain.ErrPaymentAttemptsExhausted) {
+		if err := h.publisher.PublishDunning(ctx, payment); err != nil {
+			log.Printf("Failed to publish dunning event: %v", err)
+			return err
+		}
+
+		log.Printf("Trip %s is unpaid after %d attempts, escalated to dunning", payment.TripID, payment.Attempt)
+		return nil
+	}
+	if err != nil {
+		log.Printf("Failed to create retry payment session: %v", err)
+		return err
+	}
+
+	if err := h.publisher.PublishRetry(ctx, retry, h.service.MaxPaymentAttempts(), reason); err != nil {
+		log.Printf("Failed to publish payment retry event: %v", err)
+		return err
+	}
+
+	log.Printf("Published payment retry %d/%d for trip: %s", retry.Attempt, h.service.MaxPaymentAttempts(), retry.TripID)
+	return nil
+}
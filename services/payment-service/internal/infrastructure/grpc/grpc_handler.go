@@ -0,0 +1,202 @@
+package grpc
+
+import (
+	"context"
+	"errors"
+	"log"
+
+	"github.com/AuraReaper/voom/services/payment-service/internal/domain"
+	"github.com/AuraReaper/voom/services/payment-service/internal/events"
+	pb "github.com/AuraReaper/voom/shared/proto/payment"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+type gRPCHandler struct {
+	pb.UnimplementedPaymentServiceServer
+	service   domain.Service
+	publisher *events.PaymentEventPublisher
+	webhooks  *events.WebhookHandler
+}
+
+func NewGRPCHandler(server *grpc.Server, service domain.Service, publisher *events.PaymentEventPublisher, webhooks *events.WebhookHandler) *gRPCHandler {
+	handler := &gRPCHandler{
+		service:   service,
+		publisher: publisher,
+		webhooks:  webhooks,
+	}
+
+	pb.RegisterPaymentServiceServer(server, handler)
+	return handler
+}
+
+func (h *gRPCHandler) GetPaymentByTrip(ctx context.Context, req *pb.GetPaymentByTripRequest) (*pb.GetPaymentByTripResponse, error) {
+	tripID := req.GetTripID()
+	if tripID == "" {
+		return nil, status.Error(codes.InvalidArgument, "tripID is required")
+	}
+
+	payment, err := h.service.GetPaymentByTripID(ctx, tripID)
+	if err != nil {
+		if errors.Is(err, domain.ErrPaymentNotFound) {
+			return nil, status.Errorf(codes.NotFound, "no payment found for trip: %s", tripID)
+		}
+		return nil, status.Errorf(codes.Internal, "failed to get payment: %v", err)
+	}
+
+	return &pb.GetPaymentByTripResponse{
+		Payment: payment.ToProto(),
+	}, nil
+}
+
+func (h *gRPCHandler) RefundPayment(ctx context.Context, req *pb.RefundPaymentRequest) (*pb.RefundPaymentResponse, error) {
+	tripID := req.GetTripID()
+	if tripID == "" {
+		return nil, status.Error(codes.InvalidArgument, "tripID is required")
+	}
+
+	if req.GetReason() == "" {
+		return nil, status.Error(codes.InvalidArgument, "reason is required")
+	}
+
+	refund, payment, err := h.service.RefundPayment(ctx, tripID, req.GetAmount(), req.GetReason())
+	if err != nil {
+		switch {
+		case errors.Is(err, domain.ErrPaymentNotFound):
+			return nil, status.Errorf(codes.NotFound, "no payment found for trip: %s", tripID)
+		case errors.Is(err, domain.ErrPaymentNotRefundable):
+			return nil, status.Errorf(codes.FailedPrecondition, "payment of trip %s can't be refunded", tripID)
+		case errors.Is(err, domain.ErrRefundExceedsCaptured), errors.Is(err, domain.ErrInvalidRefundAmount):
+			return nil, status.Errorf(codes.InvalidArgument, "%v", err)
+		}
+		return nil, status.Errorf(codes.Internal, "failed to refund payment: %v", err)
+	}
+
+	// The refund already happened on the processor, a failed notification must not fail the request
+	if err := h.publisher.PublishRefunded(ctx, refund, payment); err != nil {
+		log.Printf("Failed to publish the refunded event for trip %s: %v", tripID, err)
+	}
+
+	return &pb.RefundPaymentResponse{
+		Refund:  refund.ToProto(),
+		Payment: payment.ToProto(),
+	}, nil
+}
+
+func (h *gRPCHandler) HandleStripeWebhook(ctx context.Context, req *pb.HandleStripeWebhookRequest) (*pb.HandleStripeWebhookResponse, error) {
+	if len(req.GetPayload()) == 0 || req.GetSignature() == "" {
+		return nil, status.Error(codes.InvalidArgument, "payload and signature are required")
+	}
+
+	event, duplicate, err := h.webhooks.Handle(ctx, req.GetPayload(), req.GetSignature())
+	if err != nil {
+		if errors.Is(err, domain.ErrInvalidWebhook) {
+			log.Printf("Rejected Stripe webhook: %v", err)
+			return nil, status.Errorf(codes.InvalidArgument, "%v", err)
+		}
+		// Stripe retries the delivery when the gateway doesn't answer with a 2xx
+		return nil, status.Errorf(codes.Internal, "failed to handle webhook: %v", err)
+	}
+
+	return &pb.HandleStripeWebhookResponse{
+		EventID:   event.ID,
+		Duplicate: duplicate,
+	}, nil
+}
+
+func (h *gRPCHandler) TopUpWallet(ctx context.Context, req *pb.TopUpWalletRequest) (*pb.TopUpWalletResponse, error) {
+	if req.GetUserID() == "" {
+		return nil, status.Error(codes.InvalidArgument, "userID is required")
+	}
+
+	if req.GetAmount() <= 0 || req.GetCurrency() == "" {
+		return nil, status.Error(codes.InvalidArgument, "a positive amount and a currency are required")
+	}
+
+	intent, err := h.service.TopUpWallet(ctx, req.GetUserID(), req.GetAmount(), req.GetCurrency())
+	if err != nil {
+		return nil, status.Errorf(codes.Internal, "failed to top up wallet: %v", err)
+	}
+
+	return &pb.TopUpWalletResponse{
+		SessionID: intent.StripeSessionID,
+		Payment:   intent.ToPayment().ToProto(),
+	}, nil
+}
+
+func (h *gRPCHandler) GetWallet(ctx context.Context, req *pb.GetWalletRequest) (*pb.GetWalletResponse, error) {
+	if req.GetUserID() == "" {
+		return nil, status.Error(codes.InvalidArgument, "userID is required")
+	}
+
+	wallet, err := h.service.GetWallet(ctx, req.GetUserID())
+	if err != nil {
+		return nil, status.Errorf(codes.Internal, "failed to get wallet: %v", err)
+	}
+
+	return &pb.GetWalletResponse{
+		Wallet: wallet.ToProto(),
+	}, nil
+}
+
+func (h *gRPCHandler) ListWalletTransactions(ctx context.Context, req *pb.ListWalletTransactionsRequest) (*pb.ListWalletTransactionsResponse, error) {
+	if req.GetUserID() == "" {
+		return nil, status.Error(codes.InvalidArgument, "userID is required")
+	}
+
+	txs, err := h.service.ListWalletTransactions(ctx, req.GetUserID())
+	if err != nil {
+		return nil, status.Errorf(codes.Internal, "failed to list wallet transactions: %v", err)
+	}
+
+	transactions := make([]*pb.WalletTransaction, len(txs))
+	for i, txn := range txs {
+		transactions[i] = txn.ToProto()
+	}
+
+	return &pb.ListWalletTransactionsResponse{
+		Transactions: transactions,
+	}, nil
+}
+
+func (h *gRPCHandler) GetDriverBalance(ctx context.Context, req *pb.GetDriverBalanceRequest) (*pb.GetDriverBalanceResponse, error) {
+	if req.GetDriverID() == "" {
+		return nil, status.Error(codes.InvalidArgument, "driverID is required")
+	}
+
+	balance, err := h.service.GetDriverBalance(ctx, req.GetDriverID())
+	if err != nil {
+		return nil, status.Errorf(codes.Internal, "failed to get driver balance: %v", err)
+	}
+
+	return &pb.GetDriverBalanceResponse{
+		Balance: balance.ToProto(),
+	}, nil
+}
+
+func (h *gRPCHandler) TipTrip(ctx context.Context, req *pb.TipTripRequest) (*pb.TipTripResponse, error) {
+	if req.GetTripID() == "" || req.GetUserID() == "" {
+		return nil, status.Error(codes.InvalidArgument, "tripID and userID are required")
+	}
+
+	tip, err := h.service.TipTrip(ctx, req.GetTripID(), req.GetUserID(), req.GetAmount())
+	if err != nil {
+		switch {
+		case errors.Is(err, domain.ErrPaymentNotFound):
+			return nil, status.Errorf(codes.NotFound, "no payment found for trip: %s", req.GetTripID())
+		case errors.Is(err, domain.ErrInvalidTipAmount):
+			return nil, status.Errorf(codes.InvalidArgument, "%v", err)
+		case errors.Is(err, domain.ErrTipExists):
+			return nil, status.Errorf(codes.AlreadyExists, "%v", err)
+		case errors.Is(err, domain.ErrTipNotAllowed), errors.Is(err, domain.ErrTipWindowClosed):
+			return nil, status.Errorf(codes.FailedPrecondition, "%v", err)
+		}
+		return nil, status.Errorf(codes.Internal, "failed to tip trip: %v", err)
+	}
+
+	return &pb.TipTripResponse{
+		SessionID: tip.StripeSessionID,
+		Payment:   tip.ToPayment().ToProto(),
+	}, nil
+}
@@ -0,0 +1,450 @@
+package repository
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/AuraReaper/voom/services/payment-service/internal/domain"
+	"github.com/AuraReaper/voom/services/payment-service/pkg/types"
+)
+
+type inmemRepository struct {
+	payments  map[string]*types.Payment
+	byTrip    map[string]string // tripID -> latest paymentID
+	tipByTrip map[string]string // tripID -> latest tip paymentID
+	bySession map[string]string // stripeSessionID -> paymentID
+	refunds   []*types.Refund
+	ledger    []*types.LedgerEntry
+	webhooks  map[string]time.Time // webhook event ID -> processed at
+	wallets   map[string]*inmemWallet
+	walletTxs []*types.WalletTransaction
+	splits    map[string]*types.Split
+	mu        sync.RWMutex
+}
+
+type inmemWallet struct {
+	wallet     types.Wallet
+	references map[string]bool // references of the applied transactions
+}
+
+func NewInmemRepository() domain.PaymentRepository {
+	return &inmemRepository{
+		payments:  make(map[string]*types.Payment),
+		byTrip:    make(map[string]string),
+		tipByTrip: make(map[string]string),
+		bySession: make(map[string]string),
+		refunds:   []*types.Refund{},
+		ledger:    []*types.LedgerEntry{},
+		webhooks:  make(map[string]time.Time),
+		wallets:   make(map[string]*inmemWallet),
+		walletTxs: []*types.WalletTransaction{},
+		splits:    make(map[string]*types.Split),
+	}
+}
+
+func (r *inmemRepository) CreatePayment(ctx context.Context, payment *types.Payment) (*types.Payment, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if _, exists := r.payments[payment.ID]; exists {
+		return nil, fmt.Errorf("%w with ID: %s", domain.ErrPaymentExists, payment.ID)
+	}
+
+	r.payments[payment.ID] = payment
+	if payment.TripID != "" {
+		switch {
+		case payment.Purpose == types.PaymentPurposeTip:
+			r.tipByTrip[payment.TripID] = payment.ID
+		case payment.Purpose.IsTripFare():
+			r.byTrip[payment.TripID] = payment.ID
+		}
+	}
+	if payment.StripeSessionID != "" {
+		r.bySession[payment.StripeSessionID] = payment.ID
+	}
+
+	return copyPayment(payment), nil
+}
+
+func (r *inmemRepository) GetPaymentByTripID(ctx context.Context, tripID string) (*types.Payment, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	id, ok := r.byTrip[tripID]
+	if !ok {
+		return nil, domain.ErrPaymentNotFound
+	}
+
+	return copyPayment(r.payments[id]), nil
+}
+
+func (r *inmemRepository) GetTipByTripID(ctx context.Context, tripID string) (*types.Payment, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	id, ok := r.tipByTrip[tripID]
+	if !ok {
+		return nil, domain.ErrPaymentNotFound
+	}
+
+	return copyPayment(r.payments[id]), nil
+}
+
+func (r *inmemRepository) GetPaymentByID(ctx context.Context, paymentID string) (*types.Payment, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	payment, ok := r.payments[paymentID]
+	if !ok {
+		return nil, domain.ErrPaymentNotFound
+	}
+
+	return copyPayment(payment), nil
+}
+
+func (r *inmemRepository) GetPaymentBySessionID(ctx context.Context, sessionID string) (*types.Payment, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	id, ok := r.bySession[sessionID]
+	if !ok {
+		return nil, domain.ErrPaymentNotFound
+	}
+
+	return copyPayment(r.payments[id]), nil
+}
+
+func (r *inmemRepository) UpdatePaymentStatus(ctx context.Context, paymentID string, status types.PaymentStatus) (*types.Payment, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	payment, ok := r.payments[paymentID]
+	if !ok {
+		return nil, domain.ErrPaymentNotFound
+	}
+
+	if !payment.Status.CanTransitionTo(status) {
+		return nil, fmt.Errorf("%w: %s -> %s", domain.ErrInvalidStatusTransition, payment.Status, status)
+	}
+
+	now := time.Now()
+	payment.Status = status
+	payment.UpdatedAt = now
+	payment.StatusHistory = append(payment.StatusHistory, types.PaymentStatusChange{
+		Status:    status,
+		ChangedAt: now,
+	})
+
+	return copyPayment(payment), nil
+}
+
+func (r *inmemRepository) ListPaymentsCreatedBetween(ctx context.Context, from, to time.Time) ([]*types.Payment, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	var payments []*types.Payment
+	for _, payment := range r.payments {
+		if !payment.CreatedAt.Before(from) && payment.CreatedAt.Before(to) {
+			payments = append(payments, copyPayment(payment))
+		}
+	}
+
+	return payments, nil
+}
+
+func (r *inmemRepository) CapturePayment(ctx context.Context, paymentID, driverID string, amount int64) (*types.Payment, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	payment, ok := r.payments[paymentID]
+	if !ok {
+		return nil, domain.ErrPaymentNotFound
+	}
+
+	if !payment.Status.CanTransitionTo(types.PaymentStatusSuccess) {
+		return nil, fmt.Errorf("%w: %s -> %s", domain.ErrInvalidStatusTransition, payment.Status, types.PaymentStatusSuccess)
+	}
+
+	now := time.Now()
+	payment.Status = types.PaymentStatusSuccess
+	payment.CapturedAmount = amount
+	if driverID != "" {
+		payment.DriverID = driverID
+	}
+	payment.UpdatedAt = now
+	payment.StatusHistory = append(payment.StatusHistory, types.PaymentStatusChange{
+		Status:    types.PaymentStatusSuccess,
+		ChangedAt: now,
+	})
+
+	return copyPayment(payment), nil
+}
+
+func (r *inmemRepository) ReserveRefund(ctx context.Context, paymentID string, amount int64) (*types.Payment, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	payment, ok := r.payments[paymentID]
+	if !ok {
+		return nil, domain.ErrPaymentNotFound
+	}
+
+	if payment.Status != types.PaymentStatusSuccess {
+		return nil, domain.ErrPaymentNotRefundable
+	}
+
+	if amount > payment.RefundableAmount() {
+		return nil, fmt.Errorf("%w: %d requested, %d refundable", domain.ErrRefundExceedsCaptured, amount, payment.RefundableAmount())
+	}
+
+	payment.RefundedAmount += amount
+	payment.UpdatedAt = time.Now()
+
+	return copyPayment(payment), nil
+}
+
+func (r *inmemRepository) ReleaseRefund(ctx context.Context, paymentID string, amount int64) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	payment, ok := r.payments[paymentID]
+	if !ok {
+		return domain.ErrPaymentNotFound
+	}
+
+	payment.RefundedAmount -= amount
+	payment.UpdatedAt = time.Now()
+
+	return nil
+}
+
+func (r *inmemRepository) CreateRefund(ctx context.Context, refund *types.Refund) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.refunds = append(r.refunds, refund)
+	return nil
+}
+
+func (r *inmemRepository) ListRefundsByTripID(ctx context.Context, tripID string) ([]*types.Refund, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	var refunds []*types.Refund
+	for _, refund := range r.refunds {
+		if refund.TripID == tripID {
+			c := *refund
+			refunds = append(refunds, &c)
+		}
+	}
+
+	return refunds, nil
+}
+
+func (r *inmemRepository) AddLedgerEntry(ctx context.Context, entry *types.LedgerEntry) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.ledger = append(r.ledger, entry)
+	return nil
+}
+
+func (r *inmemRepository) ListLedgerEntriesByDriverID(ctx context.Context, driverID string) ([]*types.LedgerEntry, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	var entries []*types.LedgerEntry
+	for _, entry := range r.ledger {
+		if entry.DriverID == driverID {
+			c := *entry
+			entries = append(entries, &c)
+		}
+	}
+
+	return entries, nil
+}
+
+func (r *inmemRepository) CreateWebhookEvent(ctx context.Context, eventID, eventType string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if _, exists := r.webhooks[eventID]; exists {
+		return domain.ErrDuplicateWebhookEvent
+	}
+
+	r.webhooks[eventID] = time.Now()
+	return nil
+}
+
+func (r *inmemRepository) DeleteWebhookEvent(ctx context.Context, eventID string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	delete(r.webhooks, eventID)
+	return nil
+}
+
+func (r *inmemRepository) ApplyWalletTransaction(ctx context.Context, txn *types.WalletTransaction) (*types.WalletTransaction, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	w, ok := r.wallets[txn.UserID]
+	if !ok {
+		w = &inmemWallet{
+			wallet:     types.Wallet{UserID: txn.UserID, Currency: txn.Currency},
+			references: make(map[string]bool),
+		}
+	}
+
+	if w.references[txn.Reference] {
+		return nil, domain.ErrDuplicateWalletTransaction
+	}
+
+	if w.wallet.Balance+txn.Amount < 0 {
+		return nil, fmt.Errorf("%w: %d requested, %d available", domain.ErrInsufficientBalance, -txn.Amount, w.wallet.Balance)
+	}
+
+	now := time.Now()
+	w.wallet.Balance += txn.Amount
+	w.wallet.UpdatedAt = now
+	w.references[txn.Reference] = true
+	r.wallets[txn.UserID] = w
+
+	c := *txn
+	c.BalanceAfter = w.wallet.Balance
+	if c.CreatedAt.IsZero() {
+		c.CreatedAt = now
+	}
+	r.walletTxs = append(r.walletTxs, &c)
+
+	result := c
+	return &result, nil
+}
+
+func (r *inmemRepository) GetWallet(ctx context.Context, userID string) (*types.Wallet, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	w, ok := r.wallets[userID]
+	if !ok {
+		return nil, domain.ErrWalletNotFound
+	}
+
+	wallet := w.wallet
+	return &wallet, nil
+}
+
+func (r *inmemRepository) ListWalletTransactions(ctx context.Context, userID string) ([]*types.WalletTransaction, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	var txs []*types.WalletTransaction
+	for i := len(r.walletTxs) - 1; i >= 0; i-- {
+		if r.walletTxs[i].UserID == userID {
+			c := *r.walletTxs[i]
+			txs = append(txs, &c)
+		}
+	}
+
+	return txs, nil
+}
+
+func (r *inmemRepository) CreateSplit(ctx context.Context, split *types.Split) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if _, exists := r.splits[split.TripID]; exists {
+		return domain.ErrSplitExists
+	}
+
+	r.splits[split.TripID] = copySplit(split)
+	return nil
+}
+
+func (r *inmemRepository) GetSplit(ctx context.Context, tripID string) (*types.Split, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	split, ok := r.splits[tripID]
+	if !ok {
+		return nil, domain.ErrSplitNotFound
+	}
+
+	return copySplit(split), nil
+}
+
+func (r *inmemRepository) MarkSplitSharePaid(ctx context.Context, tripID, userID string) (*types.Split, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	split, ok := r.splits[tripID]
+	if !ok {
+		return nil, domain.ErrSplitNotFound
+	}
+
+	if split.Status != types.SplitStatusOpen {
+		return nil, domain.ErrSplitSettled
+	}
+
+	for i := range split.Shares {
+		if split.Shares[i].UserID == userID {
+			split.Shares[i].Status = types.SplitSharePaid
+			return copySplit(split), nil
+		}
+	}
+
+	return nil, fmt.Errorf("%w: no share for rider %s", domain.ErrSplitNotFound, userID)
+}
+
+func (r *inmemRepository) SettleSplit(ctx context.Context, tripID string) (*types.Split, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	split, ok := r.splits[tripID]
+	if !ok {
+		return nil, domain.ErrSplitNotFound
+	}
+
+	if split.Status != types.SplitStatusOpen {
+		return nil, domain.ErrSplitSettled
+	}
+
+	split.Status = types.SplitStatusSettled
+	split.SettledAt = time.Now()
+	for i := range split.Shares {
+		if split.Shares[i].Status != types.SplitSharePaid {
+			split.Shares[i].Status = types.SplitShareUnpaid
+		}
+	}
+
+	return copySplit(split), nil
+}
+
+func (r *inmemRepository) ListDueSplits(ctx context.Context, now time.Time) ([]*types.Split, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	var splits []*types.Split
+	for _, split := range r.splits {
+		if split.Status == types.SplitStatusOpen && !split.Deadline.After(now) {
+			splits = append(splits, copySplit(split))
+		}
+	}
+
+	return splits, nil
+}
+
+func copySplit(s *types.Split) *types.Split {
+	c := *s
+	c.Shares = append([]types.SplitShare(nil), s.Shares...)
+	return &c
+}
+
+// copyPayment returns a copy so callers can't mutate the stored payment without holding the lock
+func copyPayment(p *types.Payment) *types.Payment {
+	c := *p
+	c.StatusHistory = append([]types.PaymentStatusChange(nil), p.StatusHistory...)
+	return &c
+}
@@ -0,0 +1,525 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/AuraReaper/voom/services/payment-service/internal/domain"
+	"github.com/AuraReaper/voom/services/payment-service/pkg/types"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+const (
+	paymentsCollection = "payments"
+	refundsCollection  = "refunds"
+	ledgerCollection   = "driver_ledger"
+	webhookCollection  = "webhook_events"
+	walletCollection   = "wallets"
+	walletTxCollection = "wallet_transactions"
+	splitsCollection   = "splits"
+
+	// Stripe stops retrying a webhook after three days, processed event IDs are kept a while longer
+	webhookEventRetention = 30 * 24 * time.Hour
+)
+
+type mongoRepository struct {
+	payments *mongo.Collection
+	refunds  *mongo.Collection
+	ledger   *mongo.Collection
+	webhooks *mongo.Collection
+	wallets  *mongo.Collection
+	walletTx *mongo.Collection
+	splits   *mongo.Collection
+}
+
+func NewMongoRepository(ctx context.Context, db *mongo.Database) (domain.PaymentRepository, error) {
+	payments := db.Collection(paymentsCollection)
+
+	_, err := payments.Indexes().CreateMany(ctx, []mongo.IndexModel{
+		{Keys: bson.D{{Key: "trip_id", Value: 1}, {Key: "created_at", Value: -1}}},
+		{Keys: bson.D{{Key: "stripe_session_id", Value: 1}}},
+		{Keys: bson.D{{Key: "created_at", Value: 1}}},
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to create payment indexes: %w", err)
+	}
+
+	refunds := db.Collection(refundsCollection)
+	if _, err := refunds.Indexes().CreateOne(ctx, mongo.IndexModel{
+		Keys: bson.D{{Key: "trip_id", Value: 1}},
+	}); err != nil {
+		return nil, fmt.Errorf("failed to create refund indexes: %w", err)
+	}
+
+	ledger := db.Collection(ledgerCollection)
+	if _, err := ledger.Indexes().CreateOne(ctx, mongo.IndexModel{
+		Keys: bson.D{{Key: "driver_id", Value: 1}, {Key: "created_at", Value: 1}},
+	}); err != nil {
+		return nil, fmt.Errorf("failed to create ledger indexes: %w", err)
+	}
+
+	webhooks := db.Collection(webhookCollection)
+	if _, err := webhooks.Indexes().CreateOne(ctx, mongo.IndexModel{
+		Keys:    bson.D{{Key: "processed_at", Value: 1}},
+		Options: options.Index().SetExpireAfterSeconds(int32(webhookEventRetention.Seconds())),
+	}); err != nil {
+		return nil, fmt.Errorf("failed to create webhook event indexes: %w", err)
+	}
+
+	walletTx := db.Collection(walletTxCollection)
+	if _, err := walletTx.Indexes().CreateOne(ctx, mongo.IndexModel{
+		Keys: bson.D{{Key: "user_id", Value: 1}, {Key: "created_at", Value: -1}},
+	}); err != nil {
+		return nil, fmt.Errorf("failed to create wallet transaction indexes: %w", err)
+	}
+
+	splits := db.Collection(splitsCollection)
+	if _, err := splits.Indexes().CreateOne(ctx, mongo.IndexModel{
+		Keys: bson.D{{Key: "status", Value: 1}, {Key: "deadline", Value: 1}},
+	}); err != nil {
+		return nil, fmt.Errorf("failed to create split indexes: %w", err)
+	}
+
+	return &mongoRepository{
+		payments: payments,
+		refunds:  refunds,
+		ledger:   ledger,
+		webhooks: webhooks,
+		wallets:  db.Collection(walletCollection),
+		walletTx: walletTx,
+		splits:   splits,
+	}, nil
+}
+
+func (r *mongoRepository) CreatePayment(ctx context.Context, payment *types.Payment) (*types.Payment, error) {
+	if _, err := r.payments.InsertOne(ctx, payment); err != nil {
+		if mongo.IsDuplicateKeyError(err) {
+			return nil, fmt.Errorf("%w with ID: %s", domain.ErrPaymentExists, payment.ID)
+		}
+		return nil, fmt.Errorf("failed to insert payment: %w", err)
+	}
+
+	return payment, nil
+}
+
+func (r *mongoRepository) GetPaymentByTripID(ctx context.Context, tripID string) (*types.Payment, error) {
+	opts := options.FindOne().SetSort(bson.D{{Key: "created_at", Value: -1}})
+	filter := bson.M{
+		"trip_id": tripID,
+		"purpose": bson.M{"$in": bson.A{types.PaymentPurposeTripCharge, types.PaymentPurposeTripHold}},
+	}
+	return r.findOne(ctx, filter, opts)
+}
+
+func (r *mongoRepository) GetTipByTripID(ctx context.Context, tripID string) (*types.Payment, error) {
+	opts := options.FindOne().SetSort(bson.D{{Key: "created_at", Value: -1}})
+	return r.findOne(ctx, bson.M{"trip_id": tripID, "purpose": types.PaymentPurposeTip}, opts)
+}
+
+func (r *mongoRepository) GetPaymentByID(ctx context.Context, paymentID string) (*types.Payment, error) {
+	return r.findOne(ctx, bson.M{"_id": paymentID})
+}
+
+func (r *mongoRepository) GetPaymentBySessionID(ctx context.Context, sessionID string) (*types.Payment, error) {
+	return r.findOne(ctx, bson.M{"stripe_session_id": sessionID})
+}
+
+func (r *mongoRepository) UpdatePaymentStatus(ctx context.Context, paymentID string, status types.PaymentStatus) (*types.Payment, error) {
+	now := time.Now()
+
+	return r.transition(ctx, paymentID, status, bson.M{
+		"status":     status,
+		"updated_at": now,
+	}, now)
+}
+
+func (r *mongoRepository) ListPaymentsCreatedBetween(ctx context.Context, from, to time.Time) ([]*types.Payment, error) {
+	cursor, err := r.payments.Find(ctx, bson.M{
+		"created_at": bson.M{"$gte": from, "$lt": to},
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to find payments: %w", err)
+	}
+
+	var payments []*types.Payment
+	if err := cursor.All(ctx, &payments); err != nil {
+		return nil, fmt.Errorf("failed to decode payments: %w", err)
+	}
+
+	return payments, nil
+}
+
+func (r *mongoRepository) CapturePayment(ctx context.Context, paymentID, driverID string, amount int64) (*types.Payment, error) {
+	now := time.Now()
+	set := bson.M{
+		"status":          types.PaymentStatusSuccess,
+		"captured_amount": amount,
+		"updated_at":      now,
+	}
+	if driverID != "" {
+		set["driver_id"] = driverID
+	}
+
+	return r.transition(ctx, paymentID, types.PaymentStatusSuccess, set, now)
+}
+
+// transition applies set to the payment if it can move to status, the filter makes the check and the write atomic
+func (r *mongoRepository) transition(ctx context.Context, paymentID string, status types.PaymentStatus, set bson.M, now time.Time) (*types.Payment, error) {
+	filter := bson.M{
+		"_id":    paymentID,
+		"status": bson.M{"$in": types.StatusesTransitioningTo(status)},
+	}
+	update := bson.M{
+		"$set": set,
+		"$push": bson.M{
+			"status_history": types.PaymentStatusChange{Status: status, ChangedAt: now},
+		},
+	}
+	opts := options.FindOneAndUpdate().SetReturnDocument(options.After)
+
+	var payment types.Payment
+	err := r.payments.FindOneAndUpdate(ctx, filter, update, opts).Decode(&payment)
+	if err == nil {
+		return &payment, nil
+	}
+	if !errors.Is(err, mongo.ErrNoDocuments) {
+		return nil, fmt.Errorf("failed to update payment status: %w", err)
+	}
+
+	// Distinguish a missing payment from a payment that can't make this transition
+	existing, err := r.findOne(ctx, bson.M{"_id": paymentID})
+	if err != nil {
+		return nil, err
+	}
+
+	return nil, fmt.Errorf("%w: %s -> %s", domain.ErrInvalidStatusTransition, existing.Status, status)
+}
+
+func (r *mongoRepository) ReserveRefund(ctx context.Context, paymentID string, amount int64) (*types.Payment, error) {
+	// The filter only matches when the refund still fits in the captured amount
+	filter := bson.M{
+		"_id":    paymentID,
+		"status": types.PaymentStatusSuccess,
+		"$expr": bson.M{
+			"$lte": bson.A{bson.M{"$add": bson.A{"$refunded_amount", amount}}, "$captured_amount"},
+		},
+	}
+	update := bson.M{
+		"$inc": bson.M{"refunded_amount": amount},
+		"$set": bson.M{"updated_at": time.Now()},
+	}
+	opts := options.FindOneAndUpdate().SetReturnDocument(options.After)
+
+	var payment types.Payment
+	err := r.payments.FindOneAndUpdate(ctx, filter, update, opts).Decode(&payment)
+	if err == nil {
+		return &payment, nil
+	}
+	if !errors.Is(err, mongo.ErrNoDocuments) {
+		return nil, fmt.Errorf("failed to reserve refund: %w", err)
+	}
+
+	existing, err := r.findOne(ctx, bson.M{"_id": paymentID})
+	if err != nil {
+		return nil, err
+	}
+	if existing.Status != types.PaymentStatusSuccess {
+		return nil, domain.ErrPaymentNotRefundable
+	}
+
+	return nil, fmt.Errorf("%w: %d requested, %d refundable", domain.ErrRefundExceedsCaptured, amount, existing.RefundableAmount())
+}
+
+func (r *mongoRepository) ReleaseRefund(ctx context.Context, paymentID string, amount int64) error {
+	update := bson.M{
+		"$inc": bson.M{"refunded_amount": -amount},
+		"$set": bson.M{"updated_at": time.Now()},
+	}
+
+	result, err := r.payments.UpdateByID(ctx, paymentID, update)
+	if err != nil {
+		return fmt.Errorf("failed to release refund: %w", err)
+	}
+	if result.MatchedCount == 0 {
+		return domain.ErrPaymentNotFound
+	}
+
+	return nil
+}
+
+func (r *mongoRepository) CreateRefund(ctx context.Context, refund *types.Refund) error {
+	if _, err := r.refunds.InsertOne(ctx, refund); err != nil {
+		return fmt.Errorf("failed to insert refund: %w", err)
+	}
+
+	return nil
+}
+
+func (r *mongoRepository) ListRefundsByTripID(ctx context.Context, tripID string) ([]*types.Refund, error) {
+	cursor, err := r.refunds.Find(ctx, bson.M{"trip_id": tripID})
+	if err != nil {
+		return nil, fmt.Errorf("failed to find refunds: %w", err)
+	}
+
+	var refunds []*types.Refund
+	if err := cursor.All(ctx, &refunds); err != nil {
+		return nil, fmt.Errorf("failed to decode refunds: %w", err)
+	}
+
+	return refunds, nil
+}
+
+func (r *mongoRepository) AddLedgerEntry(ctx context.Context, entry *types.LedgerEntry) error {
+	if _, err := r.ledger.InsertOne(ctx, entry); err != nil {
+		return fmt.Errorf("failed to insert ledger entry: %w", err)
+	}
+
+	return nil
+}
+
+func (r *mongoRepository) ListLedgerEntriesByDriverID(ctx context.Context, driverID string) ([]*types.LedgerEntry, error) {
+	opts := options.Find().SetSort(bson.D{{Key: "created_at", Value: 1}})
+	cursor, err := r.ledger.Find(ctx, bson.M{"driver_id": driverID}, opts)
+	if err != nil {
+		return nil, fmt.Errorf("failed to find ledger entries: %w", err)
+	}
+
+	var entries []*types.LedgerEntry
+	if err := cursor.All(ctx, &entries); err != nil {
+		return nil, fmt.Errorf("failed to decode ledger entries: %w", err)
+	}
+
+	return entries, nil
+}
+
+func (r *mongoRepository) CreateWebhookEvent(ctx context.Context, eventID, eventType string) error {
+	// The event ID is the document ID, so a second insert of the same event hits the unique _id index
+	_, err := r.webhooks.InsertOne(ctx, bson.M{
+		"_id":          eventID,
+		"type":         eventType,
+		"processed_at": time.Now(),
+	})
+	if mongo.IsDuplicateKeyError(err) {
+		return domain.ErrDuplicateWebhookEvent
+	}
+	if err != nil {
+		return fmt.Errorf("failed to insert webhook event: %w", err)
+	}
+
+	return nil
+}
+
+func (r *mongoRepository) DeleteWebhookEvent(ctx context.Context, eventID string) error {
+	if _, err := r.webhooks.DeleteOne(ctx, bson.M{"_id": eventID}); err != nil {
+		return fmt.Errorf("failed to delete webhook event: %w", err)
+	}
+
+	return nil
+}
+
+func (r *mongoRepository) ApplyWalletTransaction(ctx context.Context, txn *types.WalletTransaction) (*types.WalletTransaction, error) {
+	now := time.Now()
+
+	// The wallet keeps the references it applied, so the balance check, the duplicate check
+	// and the balance change are a single atomic update of the wallet document
+	filter := bson.M{
+		"_id":        txn.UserID,
+		"references": bson.M{"$ne": txn.Reference},
+	}
+	if txn.Amount < 0 {
+		filter["balance"] = bson.M{"$gte": -txn.Amount}
+	}
+	update := bson.M{
+		"$inc":         bson.M{"balance": txn.Amount},
+		"$push":        bson.M{"references": txn.Reference},
+		"$set":         bson.M{"updated_at": now},
+		"$setOnInsert": bson.M{"currency": txn.Currency},
+	}
+	// Only credits may open a wallet
+	opts := options.FindOneAndUpdate().SetReturnDocument(options.After).SetUpsert(txn.Amount > 0)
+
+	var wallet types.Wallet
+	err := r.wallets.FindOneAndUpdate(ctx, filter, update, opts).Decode(&wallet)
+	if mongo.IsDuplicateKeyError(err) {
+		// The upsert collided with the wallet that already applied the reference
+		return nil, domain.ErrDuplicateWalletTransaction
+	}
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		applied, countErr := r.wallets.CountDocuments(ctx, bson.M{"_id": txn.UserID, "references": txn.Reference})
+		if countErr != nil {
+			return nil, fmt.Errorf("failed to check wallet transaction: %w", countErr)
+		}
+		if applied > 0 {
+			return nil, domain.ErrDuplicateWalletTransaction
+		}
+		return nil, fmt.Errorf("%w: %d requested", domain.ErrInsufficientBalance, -txn.Amount)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to update wallet: %w", err)
+	}
+
+	result := *txn
+	result.BalanceAfter = wallet.Balance
+	if result.CreatedAt.IsZero() {
+		result.CreatedAt = now
+	}
+
+	if _, err := r.walletTx.InsertOne(ctx, &result); err != nil {
+		return nil, fmt.Errorf("failed to insert wallet transaction: %w", err)
+	}
+
+	return &result, nil
+}
+
+func (r *mongoRepository) GetWallet(ctx context.Context, userID string) (*types.Wallet, error) {
+	var wallet types.Wallet
+	if err := r.wallets.FindOne(ctx, bson.M{"_id": userID}).Decode(&wallet); err != nil {
+		if errors.Is(err, mongo.ErrNoDocuments) {
+			return nil, domain.ErrWalletNotFound
+		}
+		return nil, fmt.Errorf("failed to find wallet: %w", err)
+	}
+
+	return &wallet, nil
+}
+
+func (r *mongoRepository) ListWalletTransactions(ctx context.Context, userID string) ([]*types.WalletTransaction, error) {
+	opts := options.Find().SetSort(bson.D{{Key: "created_at", Value: -1}})
+	cursor, err := r.walletTx.Find(ctx, bson.M{"user_id": userID}, opts)
+	if err != nil {
+		return nil, fmt.Errorf("failed to find wallet transactions: %w", err)
+	}
+
+	var txs []*types.WalletTransaction
+	if err := cursor.All(ctx, &txs); err != nil {
+		return nil, fmt.Errorf("failed to decode wallet transactions: %w", err)
+	}
+
+	return txs, nil
+}
+
+func (r *mongoRepository) CreateSplit(ctx context.Context, split *types.Split) error {
+	if _, err := r.splits.InsertOne(ctx, split); err != nil {
+		if mongo.IsDuplicateKeyError(err) {
+			return domain.ErrSplitExists
+		}
+		return fmt.Errorf("failed to insert split: %w", err)
+	}
+
+	return nil
+}
+
+func (r *mongoRepository) GetSplit(ctx context.Context, tripID string) (*types.Split, error) {
+	var split types.Split
+	if err := r.splits.FindOne(ctx, bson.M{"_id": tripID}).Decode(&split); err != nil {
+		if errors.Is(err, mongo.ErrNoDocuments) {
+			return nil, domain.ErrSplitNotFound
+		}
+		return nil, fmt.Errorf("failed to find split: %w", err)
+	}
+
+	return &split, nil
+}
+
+func (r *mongoRepository) MarkSplitSharePaid(ctx context.Context, tripID, userID string) (*types.Split, error) {
+	filter := bson.M{
+		"_id":            tripID,
+		"status":         types.SplitStatusOpen,
+		"shares.user_id": userID,
+	}
+	update := bson.M{
+		"$set": bson.M{"shares.$.status": types.SplitSharePaid},
+	}
+	opts := options.FindOneAndUpdate().SetReturnDocument(options.After)
+
+	var split types.Split
+	err := r.splits.FindOneAndUpdate(ctx, filter, update, opts).Decode(&split)
+	if err == nil {
+		return &split, nil
+	}
+	if !errors.Is(err, mongo.ErrNoDocuments) {
+		return nil, fmt.Errorf("failed to mark split share paid: %w", err)
+	}
+
+	existing, err := r.GetSplit(ctx, tripID)
+	if err != nil {
+		return nil, err
+	}
+	if existing.Status != types.SplitStatusOpen {
+		return nil, domain.ErrSplitSettled
+	}
+
+	return nil, fmt.Errorf("%w: no share for rider %s", domain.ErrSplitNotFound, userID)
+}
+
+func (r *mongoRepository) SettleSplit(ctx context.Context, tripID string) (*types.Split, error) {
+	filter := bson.M{"_id": tripID, "status": types.SplitStatusOpen}
+	update := bson.A{
+		bson.M{"$set": bson.M{
+			"status":     types.SplitStatusSettled,
+			"settled_at": time.Now(),
+			// Shares that are still pending are left to the owner
+			"shares": bson.M{"$map": bson.M{
+				"input": "$shares",
+				"as":    "share",
+				"in": bson.M{"$mergeObjects": bson.A{"$$share", bson.M{
+					"status": bson.M{"$cond": bson.A{
+						bson.M{"$eq": bson.A{"$$share.status", types.SplitSharePaid}},
+						types.SplitSharePaid,
+						types.SplitShareUnpaid,
+					}},
+				}}},
+			}},
+		}},
+	}
+	opts := options.FindOneAndUpdate().SetReturnDocument(options.After)
+
+	var split types.Split
+	err := r.splits.FindOneAndUpdate(ctx, filter, update, opts).Decode(&split)
+	if err == nil {
+		return &split, nil
+	}
+	if !errors.Is(err, mongo.ErrNoDocuments) {
+		return nil, fmt.Errorf("failed to settle split: %w", err)
+	}
+
+	if _, err := r.GetSplit(ctx, tripID); err != nil {
+		return nil, err
+	}
+
+	return nil, domain.ErrSplitSettled
+}
+
+func (r *mongoRepository) ListDueSplits(ctx context.Context, now time.Time) ([]*types.Split, error) {
+	cursor, err := r.splits.Find(ctx, bson.M{
+		"status":   types.SplitStatusOpen,
+		"deadline": bson.M{"$lte": now},
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to find due splits: %w", err)
+	}
+
+	var splits []*types.Split
+	if err := cursor.All(ctx, &splits); err != nil {
+		return nil, fmt.Errorf("failed to decode splits: %w", err)
+	}
+
+	return splits, nil
+}
+
+func (r *mongoRepository) findOne(ctx context.Context, filter bson.M, opts ...*options.FindOneOptions) (*types.Payment, error) {
+	var payment types.Payment
+	if err := r.payments.FindOne(ctx, filter, opts...).Decode(&payment); err != nil {
+		if errors.Is(err, mongo.ErrNoDocuments) {
+			return nil, domain.ErrPaymentNotFound
+		}
+		return nil, fmt.Errorf("failed to find payment: %w", err)
+	}
+
+	return &payment, nil
+}
@@ -0,0 +1,225 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/AuraReaper/voom/services/payment-service/internal/domain"
+	"github.com/AuraReaper/voom/services/payment-service/pkg/types"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/metric"
+)
+
+// reconcileResultMatched is the result recorded for payments both sides agree on
+const reconcileResultMatched = "matched"
+
+// Reconciler compares the payments we stored with the transactions the processor settled
+type Reconciler struct {
+	repo    domain.PaymentRepository
+	source  domain.TransactionSource
+	records metric.Int64Counter
+	scanned metric.Int64Counter
+}
+
+func NewReconciler(repo domain.PaymentRepository, source domain.TransactionSource) *Reconciler {
+	meter := otel.Meter("payment-service/reconciliation")
+
+	records, err := meter.Int64Counter("payment.reconciliation.records",
+		metric.WithDescription("Payments reconciled with the processor, by result"))
+	if err != nil {
+		log.Printf("Failed to create the reconciliation records counter: %v", err)
+	}
+
+	scanned, err := meter.Int64Counter("payment.reconciliation.scanned",
+		metric.WithDescription("Stored payments and processor transactions examined, by side"))
+	if err != nil {
+		log.Printf("Failed to create the reconciliation scanned counter: %v", err)
+	}
+
+	return &Reconciler{
+		repo:    repo,
+		source:  source,
+		records: records,
+		scanned: scanned,
+	}
+}
+
+// reconcileGroup holds both sides of one payment, identified by its trip metadata
+type reconcileGroup struct {
+	tripID       string
+	userID       string
+	purpose      types.PaymentPurpose
+	payments     []*types.Payment
+	transactions []*types.ProcessorTransaction
+}
+
+// Reconcile pages through the processor transactions created in [from, to) and matches them with
+// the stored payments of the same period by trip, rider and purpose
+func (r *Reconciler) Reconcile(ctx context.Context, from, to time.Time) (*types.ReconciliationReport, error) {
+	report := &types.ReconciliationReport{
+		From:          from,
+		To:            to,
+		GeneratedAt:   time.Now(),
+		Summary:       make(map[types.DiscrepancyType]int),
+		Discrepancies: []*types.Discrepancy{},
+	}
+
+	groups := make(map[string]*reconcileGroup)
+	group := func(tripID, userID, sessionID string, purpose types.PaymentPurpose) *reconcileGroup {
+		key := reconcileKey(tripID, userID, sessionID, purpose)
+		g, ok := groups[key]
+		if !ok {
+			g = &reconcileGroup{tripID: tripID, userID: userID, purpose: purpose}
+			groups[key] = g
+		}
+		return g
+	}
+
+	cursor := ""
+	for {
+		page, err := r.source.ListTransactions(ctx, from, to, cursor)
+		if err != nil {
+			return nil, fmt.Errorf("failed to list processor transactions: %w", err)
+		}
+
+		for _, txn := range page.Transactions {
+			report.Transactions++
+
+			// Expired and failed sessions collected nothing, there is nothing to match
+			if txn.Amount <= 0 {
+				continue
+			}
+
+			g := group(txn.TripID, txn.UserID, txn.SessionID, txn.Purpose)
+			g.transactions = append(g.transactions, txn)
+		}
+
+		if page.NextCursor == "" {
+			break
+		}
+		cursor = page.NextCursor
+	}
+
+	payments, err := r.repo.ListPaymentsCreatedBetween(ctx, from, to)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list stored payments: %w", err)
+	}
+
+	for _, payment := range payments {
+		report.Payments++
+
+		if !collectedByProcessor(payment) {
+			continue
+		}
+
+		g := group(payment.TripID, payment.UserID, payment.StripeSessionID, payment.Purpose)
+		g.payments = append(g.payments, payment)
+	}
+
+	r.scanned.Add(ctx, int64(report.Transactions), metric.WithAttributes(attribute.String("side", "processor")))
+	r.scanned.Add(ctx, int64(report.Payments), metric.WithAttributes(attribute.String("side", "store")))
+
+	keys := make([]string, 0, len(groups))
+	for key := range groups {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	for _, key := range keys {
+		discrepancy := compareGroup(groups[key])
+
+		result := reconcileResultMatched
+		if discrepancy == nil {
+			report.Matched++
+		} else {
+			result = string(discrepancy.Type)
+			report.Summary[discrepancy.Type]++
+			report.Discrepancies = append(report.Discrepancies, discrepancy)
+		}
+
+		r.records.Add(ctx, 1, metric.WithAttributes(attribute.String("result", result)))
+	}
+
+	return report, nil
+}
+
+// reconcileKey identifies a payment on both sides. Top-ups don't belong to a trip, a rider may
+// top up many times so they are told apart by their session instead.
+func reconcileKey(tripID, userID, sessionID string, purpose types.PaymentPurpose) string {
+	if tripID == "" {
+		return fmt.Sprintf("%s|session:%s", purpose, sessionID)
+	}
+	return fmt.Sprintf("%s|%s|%s", purpose, tripID, userID)
+}
+
+// collectedByProcessor reports whether the processor should have captured the payment, wallet and
+// cash payments never reach it
+func collectedByProcessor(p *types.Payment) bool {
+	if p.Method == types.PaymentMethodWallet || p.Method == types.PaymentMethodCash {
+		return false
+	}
+	return p.Status == types.PaymentStatusSuccess && p.CapturedAmount > 0 && p.StripeSessionID != ""
+}
+
+// compareGroup returns how the two sides of a payment disagree, or nil when they match
+func compareGroup(g *reconcileGroup) *types.Discrepancy {
+	d := &types.Discrepancy{
+		TripID:         g.tripID,
+		UserID:         g.userID,
+		Purpose:        g.purpose,
+		PaymentIDs:     []string{},
+		TransactionIDs: []string{},
+	}
+
+	for _, p := range g.payments {
+		d.PaymentIDs = append(d.PaymentIDs, p.ID)
+		d.StoredAmount += p.CapturedAmount
+		d.StoredRefunded += p.RefundedAmount
+		d.Currency = p.Currency
+	}
+
+	processorCurrency := ""
+	for _, txn := range g.transactions {
+		d.TransactionIDs = append(d.TransactionIDs, txn.ID)
+		d.ProcessorAmount += txn.Amount
+		d.ProcessorRefunded += txn.RefundedAmount
+		processorCurrency = txn.Currency
+	}
+	if d.Currency == "" {
+		d.Currency = processorCurrency
+	}
+
+	switch {
+	case len(g.transactions) > 1:
+		d.Type = types.DiscrepancyDuplicated
+		d.Detail = fmt.Sprintf("the processor captured %d transactions", len(g.transactions))
+	case len(g.payments) > 1:
+		d.Type = types.DiscrepancyDuplicated
+		d.Detail = fmt.Sprintf("%d successful payments are stored", len(g.payments))
+	case len(g.transactions) == 0:
+		d.Type = types.DiscrepancyMissingAtProcessor
+		d.Detail = "no captured transaction at the processor"
+	case len(g.payments) == 0:
+		d.Type = types.DiscrepancyMissingInStore
+		d.Detail = "no successful payment is stored"
+	case d.StoredAmount != d.ProcessorAmount:
+		d.Type = types.DiscrepancyAmountMismatch
+		d.Detail = fmt.Sprintf("captured %d stored, %d at the processor", d.StoredAmount, d.ProcessorAmount)
+	case d.StoredRefunded != d.ProcessorRefunded:
+		d.Type = types.DiscrepancyAmountMismatch
+		d.Detail = fmt.Sprintf("refunded %d stored, %d at the processor", d.StoredRefunded, d.ProcessorRefunded)
+	case processorCurrency != "" && !strings.EqualFold(d.Currency, processorCurrency):
+		d.Type = types.DiscrepancyAmountMismatch
+		d.Detail = fmt.Sprintf("currency %s stored, %s at the processor", d.Currency, processorCurrency)
+	default:
+		return nil
+	}
+
+	return d
+}
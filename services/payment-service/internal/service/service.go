@@ -2,7 +2,10 @@ package service
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"log"
+	"strings"
 	"time"
 
 	"github.com/AuraReaper/voom/services/payment-service/internal/domain"
@@ -11,14 +14,59 @@ import (
 	"github.com/google/uuid"
 )
 
+const (
+	defaultMaxPaymentAttempts = 3
+	defaultCommissionPercent  = 20
+	defaultMaxTipAmount       = 50000 // in cents
+	defaultTipWindow          = 24 * time.Hour
+	defaultSplitDeadline      = 30 * time.Minute
+)
+
 type paymentService struct {
-	paymentProcessor domain.PaymentProcessor
+	paymentProcessor  domain.PaymentProcessor
+	repo              domain.PaymentRepository
+	maxAttempts       int
+	commissionPercent int
+	maxTipAmount      int64
+	tipWindow         time.Duration
+	splitDeadline     time.Duration
 }
 
 // NewPaymentService creates a new instance of the payment service
-func NewPaymentService(paymentProcessor domain.PaymentProcessor) domain.Service {
+func NewPaymentService(paymentProcessor domain.PaymentProcessor, repo domain.PaymentRepository, cfg *types.PaymentConfig) domain.Service {
+	maxAttempts := cfg.MaxPaymentAttempts
+	if maxAttempts <= 0 {
+		maxAttempts = defaultMaxPaymentAttempts
+	}
+
+	commissionPercent := cfg.CommissionPercent
+	if commissionPercent <= 0 {
+		commissionPercent = defaultCommissionPercent
+	}
+
+	maxTipAmount := cfg.MaxTipAmount
+	if maxTipAmount <= 0 {
+		maxTipAmount = defaultMaxTipAmount
+	}
+
+	tipWindow := cfg.TipWindow
+	if tipWindow <= 0 {
+		tipWindow = defaultTipWindow
+	}
+
+	splitDeadline := cfg.SplitDeadline
+	if splitDeadline <= 0 {
+		splitDeadline = defaultSplitDeadline
+	}
+
 	return &paymentService{
-		paymentProcessor: paymentProcessor,
+		paymentProcessor:  paymentProcessor,
+		repo:              repo,
+		maxAttempts:       maxAttempts,
+		commissionPercent: commissionPercent,
+		maxTipAmount:      maxTipAmount,
+		tipWindow:         tipWindow,
+		splitDeadline:     splitDeadline,
 	}
 }
 
@@ -31,27 +79,644 @@ func (s *paymentService) CreatePaymentSession(
 	amount int64,
 	currency string,
 ) (*types.PaymentIntent, error) {
+	return s.createPaymentSession(ctx, uuid.New().String(), tripID, userID, driverID, amount, currency, types.PaymentPurposeTripCharge, 1)
+}
+
+// AuthorizePayment creates a session that places a hold of amount on the rider's card when booking.
+// The hold is captured with CapturePayment once the trip is completed. A trip gets one hold, asking
+// again returns the session of the first one.
+func (s *paymentService) AuthorizePayment(
+	ctx context.Context,
+	tripID string,
+	userID string,
+	amount int64,
+	currency string,
+) (*types.PaymentIntent, error) {
+	paymentID := holdPaymentID(tripID)
+
+	payment, err := s.repo.GetPaymentByID(ctx, paymentID)
+	if err == nil {
+		return payment.ToIntent(), nil
+	}
+	if !errors.Is(err, domain.ErrPaymentNotFound) {
+		return nil, err
+	}
+
+	return s.createPaymentSession(ctx, paymentID, tripID, userID, "", amount, currency, types.PaymentPurposeTripHold, 1)
+}
+
+// holdPaymentID is the ID of the hold placed when booking a trip, one per trip
+func holdPaymentID(tripID string) string {
+	return "hold:" + tripID
+}
+
+// RetryPayment offers a fresh payment session for a trip whose payment failed or was cancelled.
+// It returns ErrPaymentAttemptsExhausted once the trip has used all of its attempts.
+func (s *paymentService) RetryPayment(ctx context.Context, failed *types.Payment) (*types.PaymentIntent, error) {
+	if failed.Attempt >= s.maxAttempts {
+		return nil, domain.ErrPaymentAttemptsExhausted
+	}
+
+	return s.createPaymentSession(
+		ctx,
+		uuid.New().String(),
+		failed.TripID,
+		failed.UserID,
+		failed.DriverID,
+		failed.Amount,
+		failed.Currency,
+		failed.Purpose,
+		failed.Attempt+1,
+	)
+}
+
+// MaxPaymentAttempts returns how many sessions a trip is offered before escalating to dunning
+func (s *paymentService) MaxPaymentAttempts() int {
+	return s.maxAttempts
+}
+
+// createPaymentSession creates the session of payment paymentID and stores the payment. The
+// processor creates one session per payment ID, so a payment requested again gets the same session.
+func (s *paymentService) createPaymentSession(
+	ctx context.Context,
+	paymentID string,
+	tripID string,
+	userID string,
+	driverID string,
+	amount int64,
+	currency string,
+	purpose types.PaymentPurpose,
+	attempt int,
+) (*types.PaymentIntent, error) {
+	// The payment ID names the payment in the webhooks, including the ones about the payment intent
 	metadata := map[string]string{
-		"trip_id":   tripID,
-		"user_id":   userID,
-		"driver_id": driverID,
+		"payment_id": paymentID,
+		"trip_id":    tripID,
+		"user_id":    userID,
+		"driver_id":  driverID,
+		"purpose":    string(purpose),
 	}
 
-	sessionID, err := s.paymentProcessor.CreatePaymentSession(ctx, amount, currency, metadata)
+	var (
+		sessionID string
+		err       error
+	)
+
+	if purpose == types.PaymentPurposeTripHold {
+		sessionID, err = s.paymentProcessor.AuthorizePayment(ctx, amount, currency, metadata)
+	} else {
+		sessionID, err = s.paymentProcessor.CreatePaymentSession(ctx, amount, currency, metadata)
+	}
 	if err != nil {
 		return nil, fmt.Errorf("failed to create payment session: %w", err)
 	}
 
 	paymentIntent := &types.PaymentIntent{
-		ID:              uuid.New().String(),
+		ID:              paymentID,
 		TripID:          tripID,
 		UserID:          userID,
 		DriverID:        driverID,
+		Purpose:         purpose,
+		Method:          types.PaymentMethodCard,
 		Amount:          amount,
 		Currency:        currency,
 		StripeSessionID: sessionID,
+		Attempt:         attempt,
+		CreatedAt:       time.Now(),
+	}
+
+	if _, err := s.repo.CreatePayment(ctx, paymentIntent.ToPayment()); err != nil {
+		return nil, fmt.Errorf("failed to store payment: %w", err)
+	}
+
+	return paymentIntent, nil
+}
+
+// GetPaymentByTripID returns the latest payment created for a trip
+func (s *paymentService) GetPaymentByTripID(ctx context.Context, tripID string) (*types.Payment, error) {
+	return s.repo.GetPaymentByTripID(ctx, tripID)
+}
+
+// UpdatePaymentStatus moves the payment a webhook event was sent for to the status of the event
+func (s *paymentService) UpdatePaymentStatus(ctx context.Context, event *types.WebhookEvent) (*types.Payment, error) {
+	status := event.Status
+
+	payment, err := s.getWebhookPayment(ctx, event)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get payment for trip %s: %w", event.TripID, err)
+	}
+
+	var updated *types.Payment
+	if status == types.PaymentStatusSuccess {
+		updated, err = s.repo.CapturePayment(ctx, payment.ID, "", payment.Amount)
+	} else {
+		updated, err = s.repo.UpdatePaymentStatus(ctx, payment.ID, status)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to update payment %s: %w", payment.ID, err)
+	}
+
+	if status == types.PaymentStatusSuccess {
+		if updated.Purpose == types.PaymentPurposeWalletTopUp {
+			err = s.creditTopUp(ctx, updated)
+		} else {
+			err = s.creditDriver(ctx, updated)
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return updated, nil
+}
+
+// getWebhookPayment looks up the payment of a webhook event by the payment ID in its metadata,
+// or by its session. Events without either, from sessions created before the payment ID was
+// added to the metadata, fall back to the latest fare payment of the trip when it belongs to the
+// same rider and has the same purpose. Shares, tips and top-ups are never matched by trip.
+func (s *paymentService) getWebhookPayment(ctx context.Context, event *types.WebhookEvent) (*types.Payment, error) {
+	switch {
+	case event.PaymentID != "":
+		return s.repo.GetPaymentByID(ctx, event.PaymentID)
+	case event.SessionID != "":
+		return s.repo.GetPaymentBySessionID(ctx, event.SessionID)
+	case event.TripID == "" || !event.Purpose.IsTripFare():
+		return nil, fmt.Errorf("%w: %s event names no fare payment", domain.ErrPaymentNotFound, event.Purpose)
+	}
+
+	payment, err := s.repo.GetPaymentByTripID(ctx, event.TripID)
+	if err != nil {
+		return nil, err
+	}
+
+	if payment.Purpose != event.Purpose || payment.UserID != event.UserID {
+		return nil, fmt.Errorf("%w: latest payment %s is the %s of rider %s, not the %s of rider %s",
+			domain.ErrPaymentNotFound, payment.ID, payment.Purpose, payment.UserID, event.Purpose, event.UserID)
+	}
+
+	return payment, nil
+}
+
+// CapturePayment collects the final fare of a trip from the hold placed at booking.
+// The captured amount never exceeds the hold, the rest of the hold is released.
+func (s *paymentService) CapturePayment(ctx context.Context, tripID, driverID string, amount int64, method types.PaymentMethod) (*types.Payment, error) {
+	payment, err := s.repo.GetPaymentByTripID(ctx, tripID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get payment for trip %s: %w", tripID, err)
+	}
+
+	if payment.Status != types.PaymentStatusAuthorized {
+		return nil, fmt.Errorf("%w: payment %s is %s", domain.ErrPaymentNotAuthorized, payment.ID, payment.Status)
+	}
+
+	if amount <= 0 || amount > payment.Amount {
+		amount = payment.Amount
+	}
+
+	// The fare is taken from the wallet when it can cover it, the card is the fallback
+	if method == types.PaymentMethodWallet {
+		walletPayment, err := s.payFromWallet(ctx, payment, driverID, amount)
+		if err != nil {
+			return nil, err
+		}
+		if walletPayment != nil {
+			return walletPayment, nil
+		}
+	}
+
+	if err := s.paymentProcessor.CapturePayment(ctx, payment.StripeSessionID, amount); err != nil {
+		return nil, fmt.Errorf("failed to capture payment %s: %w", payment.ID, err)
+	}
+
+	updated, err := s.repo.CapturePayment(ctx, payment.ID, driverID, amount)
+	if err != nil {
+		return nil, fmt.Errorf("failed to update payment %s: %w", payment.ID, err)
+	}
+
+	if err := s.creditDriver(ctx, updated); err != nil {
+		return nil, err
+	}
+
+	return updated, nil
+}
+
+// FailCapture marks the hold of a trip failed, the processor declined to capture it or kept
+// failing until the command ran out of retries
+func (s *paymentService) FailCapture(ctx context.Context, tripID string) (*types.Payment, error) {
+	payment, err := s.repo.GetPaymentByTripID(ctx, tripID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get payment for trip %s: %w", tripID, err)
+	}
+
+	if payment.Status != types.PaymentStatusAuthorized {
+		return nil, fmt.Errorf("%w: payment %s is %s", domain.ErrPaymentNotAuthorized, payment.ID, payment.Status)
+	}
+
+	return s.repo.UpdatePaymentStatus(ctx, payment.ID, types.PaymentStatusFailed)
+}
+
+// RecordCashPayment records the fare a driver collected in cash. The driver kept the whole fare,
+// so the platform commission is booked as a debit on their ledger.
+func (s *paymentService) RecordCashPayment(ctx context.Context, tripID, userID, driverID string, amount int64, currency string) (*types.Payment, error) {
+	now := time.Now()
+	payment := &types.Payment{
+		// One cash payment per trip, a redelivered command collides with the first one
+		ID:             "cash:" + tripID,
+		TripID:         tripID,
+		UserID:         userID,
+		DriverID:       driverID,
+		Purpose:        types.PaymentPurposeTripCharge,
+		Method:         types.PaymentMethodCash,
+		Amount:         amount,
+		CapturedAmount: amount,
+		Currency:       currency,
+		Status:         types.PaymentStatusSuccess,
+		Attempt:        1,
+		StatusHistory: []types.PaymentStatusChange{
+			{Status: types.PaymentStatusSuccess, ChangedAt: now},
+		},
+		CreatedAt: now,
+		UpdatedAt: now,
+	}
+
+	if _, err := s.repo.CreatePayment(ctx, payment); err != nil {
+		return nil, err
+	}
+
+	commission := amount * int64(s.commissionPercent) / 100
+	if err := s.repo.AddLedgerEntry(ctx, &types.LedgerEntry{
+		ID:        uuid.New().String(),
+		DriverID:  driverID,
+		TripID:    tripID,
+		Type:      types.LedgerEntryCommission,
+		Amount:    -commission,
+		Currency:  currency,
+		Reference: payment.ID,
+		CreatedAt: now,
+	}); err != nil {
+		return nil, fmt.Errorf("failed to book commission of driver %s: %w", driverID, err)
+	}
+
+	return payment, nil
+}
+
+// VoidPayment releases the hold of a trip that will never be completed
+func (s *paymentService) VoidPayment(ctx context.Context, tripID string) (*types.Payment, error) {
+	payment, err := s.repo.GetPaymentByTripID(ctx, tripID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get payment for trip %s: %w", tripID, err)
+	}
+
+	if !payment.Status.CanTransitionTo(types.PaymentStatusCancelled) {
+		return nil, fmt.Errorf("%w: %s -> %s", domain.ErrInvalidStatusTransition, payment.Status, types.PaymentStatusCancelled)
+	}
+
+	if err := s.paymentProcessor.VoidAuthorization(ctx, payment.StripeSessionID); err != nil {
+		return nil, fmt.Errorf("failed to void payment %s: %w", payment.ID, err)
+	}
+
+	updated, err := s.repo.UpdatePaymentStatus(ctx, payment.ID, types.PaymentStatusCancelled)
+	if err != nil {
+		return nil, fmt.Errorf("failed to update payment %s: %w", payment.ID, err)
+	}
+
+	return updated, nil
+}
+
+// TopUpWallet creates a checkout session adding amount to the rider's wallet once paid
+func (s *paymentService) TopUpWallet(ctx context.Context, userID string, amount int64, currency string) (*types.PaymentIntent, error) {
+	if amount <= 0 {
+		return nil, fmt.Errorf("top-up amount must be positive, got %d", amount)
+	}
+
+	return s.createPaymentSession(ctx, uuid.New().String(), "", userID, "", amount, currency, types.PaymentPurposeWalletTopUp, 1)
+}
+
+// GetWallet returns the wallet of a rider, an empty one if they never topped up
+func (s *paymentService) GetWallet(ctx context.Context, userID string) (*types.Wallet, error) {
+	wallet, err := s.repo.GetWallet(ctx, userID)
+	if errors.Is(err, domain.ErrWalletNotFound) {
+		return &types.Wallet{UserID: userID}, nil
+	}
+
+	return wallet, err
+}
+
+// ListWalletTransactions returns the wallet history of a rider, newest first
+func (s *paymentService) ListWalletTransactions(ctx context.Context, userID string) ([]*types.WalletTransaction, error) {
+	return s.repo.ListWalletTransactions(ctx, userID)
+}
+
+// TipTrip creates a checkout session for a tip on a trip the rider paid for. A trip takes a single tip,
+// a new one can only be offered when the previous session failed or was cancelled.
+func (s *paymentService) TipTrip(ctx context.Context, tripID, userID string, amount int64) (*types.PaymentIntent, error) {
+	if amount <= 0 || amount > s.maxTipAmount {
+		return nil, fmt.Errorf("%w: %d is not between 1 and %d", domain.ErrInvalidTipAmount, amount, s.maxTipAmount)
+	}
+
+	fare, err := s.repo.GetPaymentByTripID(ctx, tripID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get payment for trip %s: %w", tripID, err)
+	}
+
+	if fare.UserID != userID || fare.DriverID == "" || fare.Status != types.PaymentStatusSuccess {
+		return nil, fmt.Errorf("%w: trip %s is not a paid trip of rider %s", domain.ErrTipNotAllowed, tripID, userID)
+	}
+
+	if time.Since(paidAt(fare)) > s.tipWindow {
+		return nil, domain.ErrTipWindowClosed
+	}
+
+	attempt := 1
+	previous, err := s.repo.GetTipByTripID(ctx, tripID)
+	switch {
+	case errors.Is(err, domain.ErrPaymentNotFound):
+		// First tip of the trip
+	case err != nil:
+		return nil, fmt.Errorf("failed to get tip for trip %s: %w", tripID, err)
+	case previous.Status == types.PaymentStatusPending || previous.Status == types.PaymentStatusSuccess:
+		return nil, domain.ErrTipExists
+	default:
+		attempt = previous.Attempt + 1
+	}
+
+	// Concurrent tips on the same trip collide on the attempt number
+	paymentID := fmt.Sprintf("tip:%s:%d", tripID, attempt)
+
+	sessionID, err := s.paymentProcessor.CreatePaymentSession(ctx, amount, fare.Currency, map[string]string{
+		"payment_id": paymentID,
+		"trip_id":    tripID,
+		"user_id":    userID,
+		"driver_id":  fare.DriverID,
+		"purpose":    string(types.PaymentPurposeTip),
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to create payment session: %w", err)
+	}
+
+	paymentIntent := &types.PaymentIntent{
+		ID:              paymentID,
+		TripID:          tripID,
+		UserID:          userID,
+		DriverID:        fare.DriverID,
+		Purpose:         types.PaymentPurposeTip,
+		Method:          types.PaymentMethodCard,
+		Amount:          amount,
+		Currency:        fare.Currency,
+		StripeSessionID: sessionID,
+		Attempt:         attempt,
 		CreatedAt:       time.Now(),
 	}
 
+	if _, err := s.repo.CreatePayment(ctx, paymentIntent.ToPayment()); err != nil {
+		if errors.Is(err, domain.ErrPaymentExists) {
+			return nil, domain.ErrTipExists
+		}
+		return nil, fmt.Errorf("failed to store tip: %w", err)
+	}
+
 	return paymentIntent, nil
 }
+
+// paidAt returns when a payment succeeded
+func paidAt(payment *types.Payment) time.Time {
+	for _, change := range payment.StatusHistory {
+		if change.Status == types.PaymentStatusSuccess {
+			return change.ChangedAt
+		}
+	}
+	return payment.UpdatedAt
+}
+
+// payFromWallet settles a trip from the rider's wallet and releases the card hold. It returns
+// a nil payment when the wallet can't cover the amount, so the caller captures the card instead.
+func (s *paymentService) payFromWallet(ctx context.Context, hold *types.Payment, driverID string, amount int64) (*types.Payment, error) {
+	wallet, err := s.repo.GetWallet(ctx, hold.UserID)
+	if errors.Is(err, domain.ErrWalletNotFound) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get wallet of rider %s: %w", hold.UserID, err)
+	}
+
+	if wallet.Balance < amount || !strings.EqualFold(wallet.Currency, hold.Currency) {
+		return nil, nil
+	}
+
+	// The reference makes the debit idempotent, a redelivered capture finds the trip already debited
+	_, err = s.repo.ApplyWalletTransaction(ctx, &types.WalletTransaction{
+		ID:        uuid.New().String(),
+		UserID:    hold.UserID,
+		Type:      types.WalletTransactionTripDebit,
+		Amount:    -amount,
+		Currency:  wallet.Currency,
+		TripID:    hold.TripID,
+		Reference: "trip_debit:" + hold.TripID,
+	})
+	switch {
+	case errors.Is(err, domain.ErrInsufficientBalance):
+		// Another debit got to the balance first
+		return nil, nil
+	case errors.Is(err, domain.ErrDuplicateWalletTransaction):
+		log.Printf("Wallet of rider %s was already debited for trip %s", hold.UserID, hold.TripID)
+	case err != nil:
+		return nil, fmt.Errorf("failed to debit wallet of rider %s: %w", hold.UserID, err)
+	}
+
+	now := time.Now()
+	payment := &types.Payment{
+		ID:             uuid.New().String(),
+		TripID:         hold.TripID,
+		UserID:         hold.UserID,
+		DriverID:       driverID,
+		Purpose:        types.PaymentPurposeTripCharge,
+		Method:         types.PaymentMethodWallet,
+		Amount:         amount,
+		CapturedAmount: amount,
+		Currency:       hold.Currency,
+		Status:         types.PaymentStatusSuccess,
+		Attempt:        hold.Attempt,
+		StatusHistory: []types.PaymentStatusChange{
+			{Status: types.PaymentStatusSuccess, ChangedAt: now},
+		},
+		CreatedAt: now,
+		UpdatedAt: now,
+	}
+
+	if _, err := s.repo.CreatePayment(ctx, payment); err != nil {
+		return nil, fmt.Errorf("failed to store wallet payment for trip %s: %w", hold.TripID, err)
+	}
+
+	if err := s.creditDriver(ctx, payment); err != nil {
+		return nil, err
+	}
+
+	// The trip is paid, a hold that can't be released expires on its own
+	if err := s.paymentProcessor.VoidAuthorization(ctx, hold.StripeSessionID); err != nil {
+		log.Printf("Failed to release the card hold of trip %s: %v", hold.TripID, err)
+	} else if _, err := s.repo.UpdatePaymentStatus(ctx, hold.ID, types.PaymentStatusCancelled); err != nil {
+		log.Printf("Failed to cancel the hold payment %s: %v", hold.ID, err)
+	}
+
+	return payment, nil
+}
+
+// creditTopUp adds a paid top-up to the rider's wallet
+func (s *paymentService) creditTopUp(ctx context.Context, payment *types.Payment) error {
+	_, err := s.repo.ApplyWalletTransaction(ctx, &types.WalletTransaction{
+		ID:        uuid.New().String(),
+		UserID:    payment.UserID,
+		Type:      types.WalletTransactionTopUp,
+		Amount:    payment.CapturedAmount,
+		Currency:  payment.Currency,
+		Reference: "top_up:" + payment.ID,
+	})
+	if err != nil && !errors.Is(err, domain.ErrDuplicateWalletTransaction) {
+		return fmt.Errorf("failed to credit wallet of rider %s: %w", payment.UserID, err)
+	}
+
+	return nil
+}
+
+// RecordWebhookEvent claims a webhook event so retried deliveries of it are skipped
+func (s *paymentService) RecordWebhookEvent(ctx context.Context, event *types.WebhookEvent) error {
+	return s.repo.CreateWebhookEvent(ctx, event.ID, event.Type)
+}
+
+// ForgetWebhookEvent releases a webhook event whose processing failed
+func (s *paymentService) ForgetWebhookEvent(ctx context.Context, eventID string) error {
+	return s.repo.DeleteWebhookEvent(ctx, eventID)
+}
+
+// refundToSource gives amount back the way the payment was made. Wallet payments are refunded
+// to the wallet, and so are cash payments since the cash can't be handed back.
+func (s *paymentService) refundToSource(ctx context.Context, payment *types.Payment, amount int64, reason string) (string, error) {
+	if payment.Method != types.PaymentMethodWallet && payment.Method != types.PaymentMethodCash {
+		return s.paymentProcessor.RefundPayment(ctx, payment.StripeSessionID, amount, reason)
+	}
+
+	refundID := uuid.New().String()
+	txn, err := s.repo.ApplyWalletTransaction(ctx, &types.WalletTransaction{
+		ID:        uuid.New().String(),
+		UserID:    payment.UserID,
+		Type:      types.WalletTransactionRefund,
+		Amount:    amount,
+		Currency:  payment.Currency,
+		TripID:    payment.TripID,
+		Reference: "refund:" + refundID,
+	})
+	if err != nil {
+		return "", fmt.Errorf("failed to credit wallet of rider %s: %w", payment.UserID, err)
+	}
+
+	return txn.ID, nil
+}
+
+// creditDriver records the captured amount of a payment as an earning of its driver, tips are
+// booked apart from fares so they are never subject to commission
+func (s *paymentService) creditDriver(ctx context.Context, payment *types.Payment) error {
+	if payment.DriverID == "" {
+		return nil
+	}
+
+	entryType := types.LedgerEntryEarning
+	if payment.Purpose == types.PaymentPurposeTip {
+		entryType = types.LedgerEntryTip
+	}
+
+	if err := s.repo.AddLedgerEntry(ctx, &types.LedgerEntry{
+		ID:        uuid.New().String(),
+		DriverID:  payment.DriverID,
+		TripID:    payment.TripID,
+		Type:      entryType,
+		Amount:    payment.CapturedAmount,
+		Currency:  payment.Currency,
+		Reference: payment.ID,
+		CreatedAt: time.Now(),
+	}); err != nil {
+		return fmt.Errorf("failed to credit driver %s: %w", payment.DriverID, err)
+	}
+
+	return nil
+}
+
+func (s *paymentService) GetDriverBalance(ctx context.Context, driverID string) (*types.DriverBalance, error) {
+	entries, err := s.repo.ListLedgerEntriesByDriverID(ctx, driverID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list the ledger of driver %s: %w", driverID, err)
+	}
+
+	return types.NewDriverBalance(driverID, entries), nil
+}
+
+// RefundPayment gives back part or all of the captured amount of a trip's payment
+func (s *paymentService) RefundPayment(ctx context.Context, tripID string, amount int64, reason string) (*types.Refund, *types.Payment, error) {
+	if amount <= 0 {
+		return nil, nil, fmt.Errorf("%w: %d", domain.ErrInvalidRefundAmount, amount)
+	}
+
+	payment, err := s.repo.GetPaymentByTripID(ctx, tripID)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to get payment for trip %s: %w", tripID, err)
+	}
+
+	return s.refund(ctx, payment, amount, reason)
+}
+
+// refund gives amount of a payment back, an amount of 0 refunds everything not refunded yet
+func (s *paymentService) refund(ctx context.Context, payment *types.Payment, amount int64, reason string) (*types.Refund, *types.Payment, error) {
+	if amount == 0 {
+		amount = payment.RefundableAmount()
+		if amount == 0 {
+			return nil, nil, domain.ErrPaymentNotRefundable
+		}
+	}
+
+	// Reserve the amount before calling the processor so concurrent refunds can't exceed the capture
+	payment, err := s.repo.ReserveRefund(ctx, payment.ID, amount)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	processorRefundID, err := s.refundToSource(ctx, payment, amount, reason)
+	if err != nil {
+		if releaseErr := s.repo.ReleaseRefund(ctx, payment.ID, amount); releaseErr != nil {
+			return nil, nil, fmt.Errorf("failed to refund payment: %w (and failed to release the reservation: %v)", err, releaseErr)
+		}
+		return nil, nil, fmt.Errorf("failed to refund payment: %w", err)
+	}
+
+	refund := &types.Refund{
+		ID:                uuid.New().String(),
+		PaymentID:         payment.ID,
+		TripID:            payment.TripID,
+		UserID:            payment.UserID,
+		DriverID:          payment.DriverID,
+		Amount:            amount,
+		Currency:          payment.Currency,
+		Reason:            reason,
+		ProcessorRefundID: processorRefundID,
+		CreatedAt:         time.Now(),
+	}
+
+	if err := s.repo.CreateRefund(ctx, refund); err != nil {
+		return nil, nil, fmt.Errorf("failed to store refund %s: %w", processorRefundID, err)
+	}
+
+	if payment.DriverID != "" {
+		if err := s.repo.AddLedgerEntry(ctx, &types.LedgerEntry{
+			ID:        uuid.New().String(),
+			DriverID:  payment.DriverID,
+			TripID:    payment.TripID,
+			Type:      types.LedgerEntryRefund,
+			Amount:    -amount,
+			Currency:  payment.Currency,
+			Reference: refund.ID,
+			CreatedAt: refund.CreatedAt,
+		}); err != nil {
+			return nil, nil, fmt.Errorf("failed to debit driver %s: %w", payment.DriverID, err)
+		}
+	}
+
+	return refund, payment, nil
+}
@@ -0,0 +1,159 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/AuraReaper/voom/services/payment-service/internal/domain"
+	"github.com/AuraReaper/voom/services/payment-service/internal/infrastructure/repository"
+	"github.com/AuraReaper/voom/services/payment-service/pkg/types"
+)
+
+// stubProcessor accepts every call, the processor side of a payment is not under test here
+type stubProcessor struct {
+	domain.PaymentProcessor
+	holds      int
+	captureErr error
+}
+
+func (p *stubProcessor) AuthorizePayment(ctx context.Context, amount int64, currency string, metadata map[string]string) (string, error) {
+	p.holds++
+	return fmt.Sprintf("session-%d", p.holds), nil
+}
+
+func (p *stubProcessor) CapturePayment(ctx context.Context, sessionID string, amount int64) error {
+	return p.captureErr
+}
+
+func TestAuthorizePaymentPlacesOneHoldPerTrip(t *testing.T) {
+	ctx := context.Background()
+	processor := &stubProcessor{}
+	svc := NewPaymentService(processor, repository.NewInmemRepository(), &types.PaymentConfig{})
+
+	first, err := svc.AuthorizePayment(ctx, "trip-1", "rider-1", 300, "inr")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// A republished command asks for the hold again
+	second, err := svc.AuthorizePayment(ctx, "trip-1", "rider-1", 300, "inr")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if processor.holds != 1 {
+		t.Errorf("placed %d holds on the rider's card, want 1", processor.holds)
+	}
+	if second.ID != first.ID || second.StripeSessionID != first.StripeSessionID {
+		t.Errorf("the second hold is %s (%s), want the first %s (%s)", second.ID, second.StripeSessionID, first.ID, first.StripeSessionID)
+	}
+}
+
+func TestDriverBalanceNetsCashCommissionAgainstCardEarnings(t *testing.T) {
+	ctx := context.Background()
+	repo := repository.NewInmemRepository()
+	svc := NewPaymentService(&stubProcessor{}, repo, &types.PaymentConfig{CommissionPercent: 20})
+
+	// The driver keeps a 500 cash fare and owes 100 of commission
+	if _, err := svc.RecordCashPayment(ctx, "trip-cash", "rider-1", "driver-1", 500, "inr"); err != nil {
+		t.Fatal(err)
+	}
+
+	balance, err := svc.GetDriverBalance(ctx, "driver-1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if balance.Commission != -100 || balance.Balance != -100 {
+		t.Fatalf("after the cash trip the balance is %+v, want 100 of commission owed", balance)
+	}
+
+	// The next trip is paid by card, its 300 fare covers the commission
+	if _, err := repo.CreatePayment(ctx, &types.Payment{
+		ID:              "hold-1",
+		TripID:          "trip-card",
+		UserID:          "rider-2",
+		Purpose:         types.PaymentPurposeTripHold,
+		Method:          types.PaymentMethodCard,
+		Amount:          300,
+		Currency:        "inr",
+		Status:          types.PaymentStatusAuthorized,
+		StripeSessionID: "session-1",
+		Attempt:         1,
+	}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := svc.CapturePayment(ctx, "trip-card", "driver-1", 300, types.PaymentMethodCard); err != nil {
+		t.Fatal(err)
+	}
+
+	balance, err = svc.GetDriverBalance(ctx, "driver-1")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := types.DriverBalance{
+		DriverID:   "driver-1",
+		Earnings:   300,
+		Commission: -100,
+		Balance:    200,
+		Currency:   "inr",
+	}
+	if *balance != want {
+		t.Errorf("after the card trip the balance is %+v, want %+v", *balance, want)
+	}
+}
+
+func TestSettleDueSplitsFailsTheHoldWhenTheOwnerCantBeCharged(t *testing.T) {
+	ctx := context.Background()
+	repo := repository.NewInmemRepository()
+	processor := &stubProcessor{captureErr: errors.New("card expired")}
+	svc := NewPaymentService(processor, repo, &types.PaymentConfig{})
+
+	if _, err := repo.CreatePayment(ctx, &types.Payment{
+		ID:              "hold-1",
+		TripID:          "trip-1",
+		UserID:          "rider-1",
+		Purpose:         types.PaymentPurposeTripHold,
+		Amount:          300,
+		Currency:        "inr",
+		Status:          types.PaymentStatusAuthorized,
+		StripeSessionID: "session-1",
+		Attempt:         1,
+	}); err != nil {
+		t.Fatal(err)
+	}
+	if err := repo.CreateSplit(ctx, &types.Split{
+		TripID:   "trip-1",
+		OwnerID:  "rider-1",
+		DriverID: "driver-1",
+		Amount:   300,
+		Currency: "inr",
+		Status:   types.SplitStatusOpen,
+		Shares: []types.SplitShare{
+			{UserID: "rider-2", Amount: 150, PaymentID: "split:trip-1:rider-2", Status: types.SplitSharePending},
+		},
+		Deadline: time.Now().Add(-time.Minute),
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	payments, err := svc.SettleDueSplits(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The settler announces the failed hold, the trip is not left waiting for the owner's part
+	if len(payments) != 1 || payments[0].Status != types.PaymentStatusFailed {
+		t.Fatalf("settling returned %+v, want the failed hold", payments)
+	}
+	hold, err := repo.GetPaymentByTripID(ctx, "trip-1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if hold.Status != types.PaymentStatusFailed {
+		t.Errorf("the hold is %s, want %s", hold.Status, types.PaymentStatusFailed)
+	}
+}
@@ -0,0 +1,206 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/AuraReaper/voom/services/payment-service/internal/domain"
+	"github.com/AuraReaper/voom/services/payment-service/pkg/types"
+)
+
+// SplitPayment asks every rider sharing a completed trip to pay an equal share of the fare, the
+// owner pays their own share and any rounding remainder when the split is settled. The split is
+// stored before the shares are requested, so a redelivered command requests the shares the first
+// delivery didn't get to and gets the same sessions for the others.
+func (s *paymentService) SplitPayment(
+	ctx context.Context,
+	tripID string,
+	driverID string,
+	amount int64,
+	method types.PaymentMethod,
+	userIDs []string,
+) ([]*types.PaymentIntent, error) {
+	hold, err := s.repo.GetPaymentByTripID(ctx, tripID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get payment for trip %s: %w", tripID, err)
+	}
+
+	if hold.Status != types.PaymentStatusAuthorized {
+		return nil, fmt.Errorf("%w: payment %s is %s", domain.ErrPaymentNotAuthorized, hold.ID, hold.Status)
+	}
+
+	if amount <= 0 || amount > hold.Amount {
+		amount = hold.Amount
+	}
+
+	shareAmount := amount / int64(len(userIDs)+1)
+
+	now := time.Now()
+	split := &types.Split{
+		TripID:    tripID,
+		OwnerID:   hold.UserID,
+		DriverID:  driverID,
+		Amount:    amount,
+		Currency:  hold.Currency,
+		Method:    method,
+		Status:    types.SplitStatusOpen,
+		Deadline:  now.Add(s.splitDeadline),
+		CreatedAt: now,
+	}
+	for _, userID := range userIDs {
+		split.Shares = append(split.Shares, types.SplitShare{
+			UserID:    userID,
+			Amount:    shareAmount,
+			PaymentID: splitSharePaymentID(tripID, userID),
+			Status:    types.SplitSharePending,
+		})
+	}
+
+	if err := s.repo.CreateSplit(ctx, split); errors.Is(err, domain.ErrSplitExists) {
+		// A redelivered command carries on with the split the first delivery stored
+		split, err = s.repo.GetSplit(ctx, tripID)
+		if err != nil {
+			return nil, fmt.Errorf("failed to get split of trip %s: %w", tripID, err)
+		}
+		if split.Status != types.SplitStatusOpen {
+			return nil, domain.ErrSplitExists
+		}
+	} else if err != nil {
+		return nil, err
+	}
+
+	intents := make([]*types.PaymentIntent, 0, len(split.Shares))
+	for _, share := range split.Shares {
+		if share.Status != types.SplitSharePending {
+			continue
+		}
+
+		intent, err := s.requestSplitShare(ctx, split, share)
+		if err != nil {
+			return nil, fmt.Errorf("failed to request the share of rider %s: %w", share.UserID, err)
+		}
+		intents = append(intents, intent)
+	}
+
+	return intents, nil
+}
+
+// splitSharePaymentID is the ID of the payment of a rider's share, one per split and rider
+func splitSharePaymentID(tripID, userID string) string {
+	return fmt.Sprintf("split:%s:%s", tripID, userID)
+}
+
+// requestSplitShare returns the payment of a share, creating its session unless a previous
+// delivery already stored it
+func (s *paymentService) requestSplitShare(ctx context.Context, split *types.Split, share types.SplitShare) (*types.PaymentIntent, error) {
+	payment, err := s.repo.GetPaymentByID(ctx, share.PaymentID)
+	if err == nil {
+		return payment.ToIntent(), nil
+	}
+	if !errors.Is(err, domain.ErrPaymentNotFound) {
+		return nil, err
+	}
+
+	return s.createPaymentSession(ctx, share.PaymentID, split.TripID, share.UserID, split.DriverID, share.Amount, split.Currency, types.PaymentPurposeSplitShare, 1)
+}
+
+// RecordSplitSharePaid marks a share as paid and settles the split once every share is paid.
+// A share paid after the owner was already charged for it is refunded.
+func (s *paymentService) RecordSplitSharePaid(ctx context.Context, share *types.Payment) (*types.Payment, error) {
+	split, err := s.repo.MarkSplitSharePaid(ctx, share.TripID, share.UserID)
+	if errors.Is(err, domain.ErrSplitSettled) {
+		if _, _, err := s.refund(ctx, share, 0, "split fare was settled before the share was paid"); err != nil {
+			return nil, fmt.Errorf("failed to refund late share %s: %w", share.ID, err)
+		}
+
+		log.Printf("Refunded the share of rider %s paid after trip %s was settled", share.UserID, share.TripID)
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if !split.AllSharesPaid() {
+		return nil, nil
+	}
+
+	return s.settleSplit(ctx, split.TripID)
+}
+
+// SettleDueSplits charges the owners of the splits whose deadline has passed
+func (s *paymentService) SettleDueSplits(ctx context.Context) ([]*types.Payment, error) {
+	splits, err := s.repo.ListDueSplits(ctx, time.Now())
+	if err != nil {
+		return nil, fmt.Errorf("failed to list due splits: %w", err)
+	}
+
+	var payments []*types.Payment
+	for _, split := range splits {
+		payment, err := s.settleSplit(ctx, split.TripID)
+		if err != nil {
+			log.Printf("Failed to settle the split of trip %s: %v", split.TripID, err)
+			continue
+		}
+		if payment != nil {
+			payments = append(payments, payment)
+		}
+	}
+
+	return payments, nil
+}
+
+// settleSplit closes a split, expires the sessions of the unpaid shares and captures what the
+// shares didn't cover from the owner's hold. It returns the captured hold, or the failed one when
+// the owner couldn't be charged, and nil when the split was already settled.
+func (s *paymentService) settleSplit(ctx context.Context, tripID string) (*types.Payment, error) {
+	split, err := s.repo.SettleSplit(ctx, tripID)
+	if errors.Is(err, domain.ErrSplitSettled) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	for _, share := range split.Shares {
+		if share.Status != types.SplitShareUnpaid {
+			continue
+		}
+
+		// A share whose session was never created has nothing to expire
+		payment, err := s.repo.GetPaymentByID(ctx, share.PaymentID)
+		if errors.Is(err, domain.ErrPaymentNotFound) {
+			continue
+		}
+		if err != nil {
+			log.Printf("Failed to get the share payment %s: %v", share.PaymentID, err)
+			continue
+		}
+
+		if err := s.paymentProcessor.VoidAuthorization(ctx, payment.StripeSessionID); err != nil {
+			log.Printf("Failed to expire the share session of rider %s: %v", share.UserID, err)
+		}
+		if _, err := s.repo.UpdatePaymentStatus(ctx, share.PaymentID, types.PaymentStatusCancelled); err != nil &&
+			!errors.Is(err, domain.ErrInvalidStatusTransition) {
+			log.Printf("Failed to cancel the share payment %s: %v", share.PaymentID, err)
+		}
+	}
+
+	payment, err := s.CapturePayment(ctx, tripID, split.DriverID, split.OwnerAmount(), split.Method)
+	if err != nil {
+		// The split is closed and won't be settled again, so the hold is failed for the trip to be
+		// marked unpaid instead of the owner's part being lost
+		log.Printf("Failed to charge owner %s for split trip %s: %v", split.OwnerID, tripID, err)
+
+		failed, failErr := s.FailCapture(ctx, tripID)
+		if failErr != nil {
+			return nil, fmt.Errorf("failed to charge owner %s for split trip %s: %w", split.OwnerID, tripID, errors.Join(err, failErr))
+		}
+		return failed, nil
+	}
+
+	log.Printf("Settled split of trip %s, the owner paid %d of %d", tripID, payment.CapturedAmount, split.Amount)
+	return payment, nil
+}
@@ -0,0 +1,35 @@
+package topology
+
+import (
+	"github.com/AuraReaper/voom/shared/contracts"
+	"github.com/AuraReaper/voom/shared/messaging"
+)
+
+// Topology is the part of the trip exchange the payment service owns
+var Topology = messaging.ServiceTopology{
+	Service: messaging.PaymentService,
+	Queues: []messaging.QueueBinding{
+		{
+			Queue: messaging.PaymentTripResponseQueue,
+			RoutingKeys: []string{
+				contracts.PaymentCmdCreateSession,
+				contracts.PaymentCmdAuthorize,
+				contracts.PaymentCmdCapture,
+				contracts.PaymentCmdVoid,
+				contracts.PaymentCmdRecordCash,
+				contracts.RiderCmdTip,
+			},
+		},
+	},
+	Produces: []string{
+		contracts.PaymentEventSessionCreated,
+		contracts.PaymentEventSuccess,
+		contracts.PaymentEventFailed,
+		contracts.PaymentEventCancelled,
+		contracts.PaymentEventRetry,
+		contracts.PaymentEventDunning,
+		contracts.PaymentEventRefunded,
+		contracts.PaymentEventAuthorized,
+		contracts.PaymentEventTipReceived,
+	},
+}
@@ -1,47 +1,501 @@
 package types
 
-import "time"
+import (
+	"time"
+
+	pb "github.com/AuraReaper/voom/shared/proto/payment"
+)
 
 // PaymentStatus represents the current status of a payment
 type PaymentStatus string
 
 const (
-	PaymentStatusPending   PaymentStatus = "pending"
-	PaymentStatusSuccess   PaymentStatus = "success"
-	PaymentStatusFailed    PaymentStatus = "failed"
-	PaymentStatusCancelled PaymentStatus = "cancelled"
+	PaymentStatusPending    PaymentStatus = "pending"
+	PaymentStatusAuthorized PaymentStatus = "authorized" // funds are held on the card, waiting for capture
+	PaymentStatusSuccess    PaymentStatus = "success"
+	PaymentStatusFailed     PaymentStatus = "failed"
+	PaymentStatusCancelled  PaymentStatus = "cancelled"
+)
+
+// paymentStatusTransitions lists the statuses each status can move to, statuses missing here are final
+var paymentStatusTransitions = map[PaymentStatus][]PaymentStatus{
+	PaymentStatusPending: {
+		PaymentStatusAuthorized, PaymentStatusSuccess, PaymentStatusFailed, PaymentStatusCancelled,
+	},
+	PaymentStatusAuthorized: {
+		PaymentStatusSuccess, PaymentStatusFailed, PaymentStatusCancelled,
+	},
+}
+
+// CanTransitionTo reports whether a payment in status s may move to next
+func (s PaymentStatus) CanTransitionTo(next PaymentStatus) bool {
+	for _, allowed := range paymentStatusTransitions[s] {
+		if allowed == next {
+			return true
+		}
+	}
+	return false
+}
+
+// StatusesTransitioningTo returns every status a payment can be in to move to next
+func StatusesTransitioningTo(next PaymentStatus) []PaymentStatus {
+	var statuses []PaymentStatus
+	for from := range paymentStatusTransitions {
+		if from.CanTransitionTo(next) {
+			statuses = append(statuses, from)
+		}
+	}
+	return statuses
+}
+
+// PaymentPurpose tells what a payment is collected for
+type PaymentPurpose string
+
+const (
+	PaymentPurposeTripCharge  PaymentPurpose = "trip_charge" // charged right away through a checkout session
+	PaymentPurposeTripHold    PaymentPurpose = "trip_hold"   // authorized at booking and captured at completion
+	PaymentPurposeWalletTopUp PaymentPurpose = "wallet_top_up"
+	PaymentPurposeTip         PaymentPurpose = "tip"         // paid by the rider after the trip, entirely for the driver
+	PaymentPurposeSplitShare  PaymentPurpose = "split_share" // a rider's part of a fare split with the trip owner
 )
 
+// IsTripFare reports whether payments of the purpose settle the fare of the trip they belong to.
+// Only these are returned when looking up the payment of a trip.
+func (p PaymentPurpose) IsTripFare() bool {
+	return p == PaymentPurposeTripCharge || p == PaymentPurposeTripHold
+}
+
+// PaymentMethod tells how a payment was settled
+type PaymentMethod string
+
+const (
+	PaymentMethodCard   PaymentMethod = "card"
+	PaymentMethodWallet PaymentMethod = "wallet"
+	PaymentMethodCash   PaymentMethod = "cash" // collected by the driver
+)
+
+// PaymentStatusChange records a single status transition of a payment
+type PaymentStatusChange struct {
+	Status    PaymentStatus `json:"status" bson:"status"`
+	ChangedAt time.Time     `json:"changed_at" bson:"changed_at"`
+}
+
 // Payment represents a payment transaction
 type Payment struct {
-	ID              string        `json:"id"`
-	TripID          string        `json:"trip_id"`
-	UserID          string        `json:"user_id"`
-	Amount          int64         `json:"amount"`   // Amount in cents
-	Currency        string        `json:"currency"` // e.g., "usd"
-	Status          PaymentStatus `json:"status"`
-	StripeSessionID string        `json:"stripe_session_id"`
-	CreatedAt       time.Time     `json:"created_at"`
-	UpdatedAt       time.Time     `json:"updated_at"`
+	ID              string                `json:"id" bson:"_id"`
+	TripID          string                `json:"trip_id" bson:"trip_id"`
+	UserID          string                `json:"user_id" bson:"user_id"`
+	DriverID        string                `json:"driver_id" bson:"driver_id"`
+	Purpose         PaymentPurpose        `json:"purpose" bson:"purpose"`
+	Method          PaymentMethod         `json:"method" bson:"method"`
+	Amount          int64                 `json:"amount" bson:"amount"` // Amount in cents, the held amount for holds
+	CapturedAmount  int64                 `json:"captured_amount" bson:"captured_amount"`
+	Currency        string                `json:"currency" bson:"currency"` // e.g., "usd"
+	Status          PaymentStatus         `json:"status" bson:"status"`
+	StripeSessionID string                `json:"stripe_session_id" bson:"stripe_session_id"`
+	Attempt         int                   `json:"attempt" bson:"attempt"` // 1 for the first session of a trip
+	RefundedAmount  int64                 `json:"refunded_amount" bson:"refunded_amount"`
+	StatusHistory   []PaymentStatusChange `json:"status_history" bson:"status_history"`
+	CreatedAt       time.Time             `json:"created_at" bson:"created_at"`
+	UpdatedAt       time.Time             `json:"updated_at" bson:"updated_at"`
+}
+
+func (p *Payment) ToProto() *pb.Payment {
+	return &pb.Payment{
+		Id:              p.ID,
+		TripID:          p.TripID,
+		UserID:          p.UserID,
+		DriverID:        p.DriverID,
+		Purpose:         string(p.Purpose),
+		Method:          string(p.Method),
+		Amount:          p.Amount,
+		CapturedAmount:  p.CapturedAmount,
+		Currency:        p.Currency,
+		Status:          string(p.Status),
+		StripeSessionID: p.StripeSessionID,
+		Attempt:         int32(p.Attempt),
+		RefundedAmount:  p.RefundedAmount,
+		CreatedAt:       p.CreatedAt.Unix(),
+		UpdatedAt:       p.UpdatedAt.Unix(),
+	}
+}
+
+// RefundableAmount returns how much of the captured amount can still be refunded
+func (p *Payment) RefundableAmount() int64 {
+	if p.Status != PaymentStatusSuccess {
+		return 0
+	}
+	return p.CapturedAmount - p.RefundedAmount
+}
+
+// Refund represents money given back to the rider for a trip
+type Refund struct {
+	ID                string    `json:"id" bson:"_id"`
+	PaymentID         string    `json:"payment_id" bson:"payment_id"`
+	TripID            string    `json:"trip_id" bson:"trip_id"`
+	UserID            string    `json:"user_id" bson:"user_id"`
+	DriverID          string    `json:"driver_id" bson:"driver_id"`
+	Amount            int64     `json:"amount" bson:"amount"` // Amount in cents
+	Currency          string    `json:"currency" bson:"currency"`
+	Reason            string    `json:"reason" bson:"reason"`
+	ProcessorRefundID string    `json:"processor_refund_id" bson:"processor_refund_id"`
+	CreatedAt         time.Time `json:"created_at" bson:"created_at"`
+}
+
+func (r *Refund) ToProto() *pb.Refund {
+	return &pb.Refund{
+		Id:                r.ID,
+		PaymentID:         r.PaymentID,
+		TripID:            r.TripID,
+		Amount:            r.Amount,
+		Currency:          r.Currency,
+		Reason:            r.Reason,
+		ProcessorRefundID: r.ProcessorRefundID,
+		CreatedAt:         r.CreatedAt.Unix(),
+	}
+}
+
+// LedgerEntryType describes why a driver's balance changed
+type LedgerEntryType string
+
+const (
+	LedgerEntryEarning    LedgerEntryType = "earning"
+	LedgerEntryRefund     LedgerEntryType = "refund"
+	LedgerEntryCommission LedgerEntryType = "commission" // platform share of a cash fare the driver kept
+	LedgerEntryTip        LedgerEntryType = "tip"
+)
+
+// LedgerEntry is a signed movement on a driver's ledger, credits are positive and debits negative.
+// Commissions owed on cash trips are debits, so they are netted against the driver's card earnings
+// in their DriverBalance.
+type LedgerEntry struct {
+	ID        string          `json:"id" bson:"_id"`
+	DriverID  string          `json:"driver_id" bson:"driver_id"`
+	TripID    string          `json:"trip_id" bson:"trip_id"`
+	Type      LedgerEntryType `json:"type" bson:"type"`
+	Amount    int64           `json:"amount" bson:"amount"` // Amount in cents
+	Currency  string          `json:"currency" bson:"currency"`
+	Reference string          `json:"reference" bson:"reference"` // ID of the payment or refund behind the entry
+	CreatedAt time.Time       `json:"created_at" bson:"created_at"`
+}
+
+// DriverBalance sums the ledger of a driver by entry type. Balance is the card and wallet earnings
+// and tips, less refunds and the commission owed on cash fares. It is negative while the commission
+// still exceeds what the driver earned.
+type DriverBalance struct {
+	DriverID   string `json:"driver_id"`
+	Earnings   int64  `json:"earnings"`
+	Tips       int64  `json:"tips"`
+	Refunds    int64  `json:"refunds"`
+	Commission int64  `json:"commission"`
+	Balance    int64  `json:"balance"`
+	Currency   string `json:"currency"`
+}
+
+// NewDriverBalance sums entries, the ledger of one driver
+func NewDriverBalance(driverID string, entries []*LedgerEntry) *DriverBalance {
+	balance := &DriverBalance{DriverID: driverID}
+
+	for _, entry := range entries {
+		switch entry.Type {
+		case LedgerEntryEarning:
+			balance.Earnings += entry.Amount
+		case LedgerEntryTip:
+			balance.Tips += entry.Amount
+		case LedgerEntryRefund:
+			balance.Refunds += entry.Amount
+		case LedgerEntryCommission:
+			balance.Commission += entry.Amount
+		}
+		balance.Balance += entry.Amount
+		balance.Currency = entry.Currency
+	}
+
+	return balance
+}
+
+func (b *DriverBalance) ToProto() *pb.DriverBalance {
+	return &pb.DriverBalance{
+		DriverID:   b.DriverID,
+		Earnings:   b.Earnings,
+		Tips:       b.Tips,
+		Refunds:    b.Refunds,
+		Commission: b.Commission,
+		Balance:    b.Balance,
+		Currency:   b.Currency,
+	}
+}
+
+// WalletTransactionType describes why a rider's wallet balance changed
+type WalletTransactionType string
+
+const (
+	WalletTransactionTopUp     WalletTransactionType = "top_up"
+	WalletTransactionTripDebit WalletTransactionType = "trip_debit"
+	WalletTransactionRefund    WalletTransactionType = "refund"
+)
+
+// Wallet holds the prepaid balance of a rider
+type Wallet struct {
+	UserID    string    `json:"user_id" bson:"_id"`
+	Balance   int64     `json:"balance" bson:"balance"` // Balance in cents
+	Currency  string    `json:"currency" bson:"currency"`
+	UpdatedAt time.Time `json:"updated_at" bson:"updated_at"`
+}
+
+func (w *Wallet) ToProto() *pb.Wallet {
+	return &pb.Wallet{
+		UserID:    w.UserID,
+		Balance:   w.Balance,
+		Currency:  w.Currency,
+		UpdatedAt: w.UpdatedAt.Unix(),
+	}
+}
+
+// WalletTransaction is a single change of a wallet balance. Reference identifies the operation
+// behind it, a wallet never applies two transactions with the same reference.
+type WalletTransaction struct {
+	ID           string                `json:"id" bson:"_id"`
+	UserID       string                `json:"user_id" bson:"user_id"`
+	Type         WalletTransactionType `json:"type" bson:"type"`
+	Amount       int64                 `json:"amount" bson:"amount"` // Signed amount in cents, negative for debits
+	Currency     string                `json:"currency" bson:"currency"`
+	TripID       string                `json:"trip_id,omitempty" bson:"trip_id,omitempty"`
+	Reference    string                `json:"reference" bson:"reference"`
+	BalanceAfter int64                 `json:"balance_after" bson:"balance_after"`
+	CreatedAt    time.Time             `json:"created_at" bson:"created_at"`
+}
+
+func (t *WalletTransaction) ToProto() *pb.WalletTransaction {
+	return &pb.WalletTransaction{
+		Id:           t.ID,
+		UserID:       t.UserID,
+		Type:         string(t.Type),
+		Amount:       t.Amount,
+		Currency:     t.Currency,
+		TripID:       t.TripID,
+		Reference:    t.Reference,
+		BalanceAfter: t.BalanceAfter,
+		CreatedAt:    t.CreatedAt.Unix(),
+	}
+}
+
+// SplitStatus tells whether a split fare is still waiting for shares
+type SplitStatus string
+
+const (
+	SplitStatusOpen    SplitStatus = "open"
+	SplitStatusSettled SplitStatus = "settled" // the owner was charged what the shares didn't cover
+)
+
+// SplitShareStatus tells whether a rider paid their share
+type SplitShareStatus string
+
+const (
+	SplitSharePending SplitShareStatus = "pending"
+	SplitSharePaid    SplitShareStatus = "paid"
+	SplitShareUnpaid  SplitShareStatus = "unpaid" // the deadline passed, the owner covered it
+)
+
+// SplitShare is the part of a split fare one rider is asked to pay
+type SplitShare struct {
+	UserID    string           `json:"user_id" bson:"user_id"`
+	Amount    int64            `json:"amount" bson:"amount"` // Amount in cents
+	PaymentID string           `json:"payment_id" bson:"payment_id"`
+	Status    SplitShareStatus `json:"status" bson:"status"`
+}
+
+// Split is a trip fare shared between its owner and other riders. The shares are paid through
+// their own sessions, the owner's card hold is captured for the rest once every share is paid or
+// the deadline has passed.
+type Split struct {
+	TripID    string        `json:"trip_id" bson:"_id"`
+	OwnerID   string        `json:"owner_id" bson:"owner_id"`
+	DriverID  string        `json:"driver_id" bson:"driver_id"`
+	Amount    int64         `json:"amount" bson:"amount"` // the whole fare in cents
+	Currency  string        `json:"currency" bson:"currency"`
+	Method    PaymentMethod `json:"method" bson:"method"` // how the owner pays the rest
+	Shares    []SplitShare  `json:"shares" bson:"shares"`
+	Status    SplitStatus   `json:"status" bson:"status"`
+	Deadline  time.Time     `json:"deadline" bson:"deadline"`
+	CreatedAt time.Time     `json:"created_at" bson:"created_at"`
+	SettledAt time.Time     `json:"settled_at,omitempty" bson:"settled_at,omitempty"`
+}
+
+// OwnerAmount returns what is left for the owner to pay after the paid shares
+func (s *Split) OwnerAmount() int64 {
+	amount := s.Amount
+	for _, share := range s.Shares {
+		if share.Status == SplitSharePaid {
+			amount -= share.Amount
+		}
+	}
+	return amount
+}
+
+// AllSharesPaid reports whether every invited rider paid their share
+func (s *Split) AllSharesPaid() bool {
+	for _, share := range s.Shares {
+		if share.Status != SplitSharePaid {
+			return false
+		}
+	}
+	return true
 }
 
 // PaymentIntent represents the intent to collect a payment
 type PaymentIntent struct {
-	ID              string    `json:"id"`
-	TripID          string    `json:"trip_id"`
-	UserID          string    `json:"user_id"`
-	DriverID        string    `json:"driver_id"`
-	Amount          int64     `json:"amount"`
-	Currency        string    `json:"currency"`
-	StripeSessionID string    `json:"stripe_session_id"`
-	CreatedAt       time.Time `json:"created_at"`
+	ID              string         `json:"id"`
+	TripID          string         `json:"trip_id"`
+	UserID          string         `json:"user_id"`
+	DriverID        string         `json:"driver_id"`
+	Purpose         PaymentPurpose `json:"purpose"`
+	Method          PaymentMethod  `json:"method"`
+	Amount          int64          `json:"amount"`
+	Currency        string         `json:"currency"`
+	StripeSessionID string         `json:"stripe_session_id"`
+	Attempt         int            `json:"attempt"`
+	CreatedAt       time.Time      `json:"created_at"`
+}
+
+// ToIntent returns the intent a payment was created from
+func (p *Payment) ToIntent() *PaymentIntent {
+	return &PaymentIntent{
+		ID:              p.ID,
+		TripID:          p.TripID,
+		UserID:          p.UserID,
+		DriverID:        p.DriverID,
+		Purpose:         p.Purpose,
+		Method:          p.Method,
+		Amount:          p.Amount,
+		Currency:        p.Currency,
+		StripeSessionID: p.StripeSessionID,
+		Attempt:         p.Attempt,
+		CreatedAt:       p.CreatedAt,
+	}
+}
+
+// ToPayment converts the intent into a pending payment ready to be persisted
+func (p *PaymentIntent) ToPayment() *Payment {
+	return &Payment{
+		ID:              p.ID,
+		TripID:          p.TripID,
+		UserID:          p.UserID,
+		DriverID:        p.DriverID,
+		Purpose:         p.Purpose,
+		Method:          p.Method,
+		Amount:          p.Amount,
+		Currency:        p.Currency,
+		Status:          PaymentStatusPending,
+		StripeSessionID: p.StripeSessionID,
+		Attempt:         p.Attempt,
+		StatusHistory: []PaymentStatusChange{
+			{Status: PaymentStatusPending, ChangedAt: p.CreatedAt},
+		},
+		CreatedAt: p.CreatedAt,
+		UpdatedAt: p.CreatedAt,
+	}
+}
+
+// WebhookEvent is a verified processor webhook reduced to what the payment flow needs
+type WebhookEvent struct {
+	ID        string
+	Type      string
+	TripID    string
+	UserID    string
+	DriverID  string
+	SessionID string // empty for events that only reference the payment intent
+	PaymentID string // our payment the session was created for, empty for sessions created before it was recorded
+	Purpose   PaymentPurpose
+	Status    PaymentStatus // empty when the event type is not handled
+	Reason    string
+}
+
+// ProcessorTransaction is a payment as the processor recorded it, tied to our payments by the
+// trip metadata attached to its session
+type ProcessorTransaction struct {
+	ID             string         `json:"id"` // the payment intent
+	SessionID      string         `json:"session_id"`
+	TripID         string         `json:"trip_id"`
+	UserID         string         `json:"user_id"`
+	Purpose        PaymentPurpose `json:"purpose"`
+	Amount         int64          `json:"amount"` // captured amount in cents, 0 when nothing was collected
+	RefundedAmount int64          `json:"refunded_amount"`
+	Currency       string         `json:"currency"`
+	CreatedAt      time.Time      `json:"created_at"`
+}
+
+// TransactionPage is one page of processor transactions
+type TransactionPage struct {
+	Transactions []*ProcessorTransaction
+	NextCursor   string // empty on the last page
+}
+
+// DiscrepancyType describes how stored payments and processor transactions disagree
+type DiscrepancyType string
+
+const (
+	DiscrepancyMissingInStore     DiscrepancyType = "missing_in_store"     // captured by the processor, no successful payment stored
+	DiscrepancyMissingAtProcessor DiscrepancyType = "missing_at_processor" // stored as paid, nothing captured by the processor
+	DiscrepancyDuplicated         DiscrepancyType = "duplicated"           // collected or stored more than once
+	DiscrepancyAmountMismatch     DiscrepancyType = "amount_mismatch"
+)
+
+// Discrepancy is a payment whose stored and processor records disagree
+type Discrepancy struct {
+	Type              DiscrepancyType `json:"type"`
+	TripID            string          `json:"trip_id"`
+	UserID            string          `json:"user_id"`
+	Purpose           PaymentPurpose  `json:"purpose"`
+	PaymentIDs        []string        `json:"payment_ids"`
+	TransactionIDs    []string        `json:"transaction_ids"`
+	StoredAmount      int64           `json:"stored_amount"`
+	ProcessorAmount   int64           `json:"processor_amount"`
+	StoredRefunded    int64           `json:"stored_refunded"`
+	ProcessorRefunded int64           `json:"processor_refunded"`
+	Currency          string          `json:"currency"`
+	Detail            string          `json:"detail"`
+}
+
+// ReconciliationReport is the outcome of comparing the stored payments of a period with the
+// transactions the processor settled in it
+type ReconciliationReport struct {
+	From          time.Time               `json:"from"`
+	To            time.Time               `json:"to"`
+	GeneratedAt   time.Time               `json:"generated_at"`
+	Transactions  int                     `json:"transactions"` // processor transactions examined
+	Payments      int                     `json:"payments"`     // stored payments examined
+	Matched       int                     `json:"matched"`
+	Summary       map[DiscrepancyType]int `json:"summary"`
+	Discrepancies []*Discrepancy          `json:"discrepancies"`
 }
 
 // PaymentConfig holds the configuration for the payment service
 type PaymentConfig struct {
-	StripeSecretKey     string `json:"stripeSecretKey"`
-	StripeWebhookSecret string `json:"stripeWebhookSecret"`
-	Currency            string `json:"currency"`
-	SuccessURL          string `json:"successURL"`
-	CancelURL           string `json:"cancelURL"`
+	StripeSecretKey     string        `json:"stripeSecretKey"`
+	StripeWebhookSecret string        `json:"stripeWebhookSecret"`
+	Currency            string        `json:"currency"`
+	SuccessURL          string        `json:"successURL"`
+	CancelURL           string        `json:"cancelURL"`
+	MaxPaymentAttempts  int           `json:"maxPaymentAttempts"` // sessions offered before a trip goes to dunning
+	CommissionPercent   int           `json:"commissionPercent"`  // platform share of a fare
+	MaxTipAmount        int64         `json:"maxTipAmount"`       // in cents
+	TipWindow           time.Duration `json:"tipWindow"`          // how long after the fare was paid a trip can be tipped
+	SplitDeadline       time.Duration `json:"splitDeadline"`      // how long riders have to pay their share of a split fare
+}
+
+// FakeOutcome is how the fake processor settles the sessions it creates
+type FakeOutcome string
+
+const (
+	FakeOutcomeSucceed FakeOutcome = "succeed"
+	FakeOutcomeFail    FakeOutcome = "fail"
+)
+
+// FakeProcessorConfig holds the configuration of the offline payment processor
+type FakeProcessorConfig struct {
+	Outcome       FakeOutcome   `json:"outcome"`
+	Delay         time.Duration `json:"delay"`         // how long the rider "takes" to pay before the webhook is sent
+	WebhookURL    string        `json:"webhookURL"`    // the gateway endpoint receiving the simulated Stripe webhooks
+	WebhookSecret string        `json:"webhookSecret"` // signs the webhooks like Stripe does
 }
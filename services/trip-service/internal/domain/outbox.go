@@ -0,0 +1,59 @@
+package domain
+
+import (
+	"context"
+	"encoding/json"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// OutboxMessageModel is an event stored in the same step as the trip change it announces. The
+// outbox relay publishes it afterwards, so the event is sent at least once if the change was stored.
+type OutboxMessageModel struct {
+	ID         primitive.ObjectID
+	RoutingKey string
+	OwnerID    string
+	Data       json.RawMessage
+	CreatedAt  time.Time
+	Attempts   int
+	LastError  string
+	SentAt     *time.Time
+	ParkedAt   *time.Time // set once the relay gave up on the message, it is no longer pending
+}
+
+// NewOutboxMessage builds the outbox message publishing payload with routingKey on behalf of ownerID
+func NewOutboxMessage(routingKey, ownerID string, payload any) (*OutboxMessageModel, error) {
+	data, err := json.Marshal(payload)
+	if err != nil {
+		return nil, err
+	}
+
+	return &OutboxMessageModel{
+		ID:         primitive.NewObjectID(),
+		RoutingKey: routingKey,
+		OwnerID:    ownerID,
+		Data:       data,
+		CreatedAt:  time.Now(),
+	}, nil
+}
+
+type OutboxRepository interface {
+	// AddOutboxMessages stores events that announce no trip change of their own
+	AddOutboxMessages(ctx context.Context, messages ...*OutboxMessageModel) error
+	// ListPendingOutboxMessages returns up to limit messages neither sent nor parked, oldest first
+	ListPendingOutboxMessages(ctx context.Context, limit int) ([]*OutboxMessageModel, error)
+	MarkOutboxMessageSent(ctx context.Context, id string) error
+	// MarkOutboxMessageFailed records a failed attempt, the message stays pending
+	MarkOutboxMessageFailed(ctx context.Context, id string, cause error) error
+	// ParkOutboxMessage records the last failed attempt and stops relaying the message
+	ParkOutboxMessage(ctx context.Context, id string, cause error) error
+}
+
+// TripEventFactory builds the outbox messages the trip service stores along with new trips
+type TripEventFactory interface {
+	// TripCreated asks the driver service to look for drivers
+	TripCreated(trip *TripModel) (*OutboxMessageModel, error)
+	// PaymentAuthorize asks the payment service to place a hold on the rider's card
+	PaymentAuthorize(trip *TripModel) (*OutboxMessageModel, error)
+}
@@ -0,0 +1,95 @@
+package domain
+
+import (
+	"errors"
+	"math"
+	"slices"
+	"strings"
+	"time"
+)
+
+var (
+	ErrPromoNotFound      = errors.New("promo code not found")
+	ErrPromoExpired       = errors.New("promo code is not valid at this time")
+	ErrPromoUsageExceeded = errors.New("promo code usage limit reached")
+	ErrPromoFirstRideOnly = errors.New("promo code is only valid on the first ride")
+	ErrPromoNotApplicable = errors.New("promo code does not apply to any ride package")
+)
+
+// Promo discount types
+const (
+	PromoDiscountPercentage = "percentage"
+	PromoDiscountFlat       = "flat"
+)
+
+// Fare line item types
+const (
+	FareLineItemBase  = "base"
+	FareLineItemPromo = "promo"
+)
+
+type PromoCodeModel struct {
+	Code             string
+	DiscountType     string
+	Value            float64 // percent off for percentage codes, INR off for flat codes
+	MaxDiscountInINR float64 // 0 for no cap
+	FirstRideOnly    bool
+	MaxUsesPerUser   int      // 0 for unlimited
+	MaxTotalUses     int      // 0 for unlimited
+	PackageSlugs     []string // packages the code applies to, all when empty
+	ValidFrom        time.Time
+	ValidUntil       time.Time // zero for no end
+}
+
+// NormalizePromoCode returns code in the form promo codes are stored in
+func NormalizePromoCode(code string) string {
+	return strings.ToUpper(strings.TrimSpace(code))
+}
+
+// IsActive reports whether the code can be used at now
+func (p *PromoCodeModel) IsActive(now time.Time) bool {
+	if now.Before(p.ValidFrom) {
+		return false
+	}
+	return p.ValidUntil.IsZero() || now.Before(p.ValidUntil)
+}
+
+// AppliesTo reports whether the code discounts fares of the package
+func (p *PromoCodeModel) AppliesTo(packageSlug string) bool {
+	return len(p.PackageSlugs) == 0 || slices.Contains(p.PackageSlugs, packageSlug)
+}
+
+// Discount returns the amount taken off price, never more than the price itself
+func (p *PromoCodeModel) Discount(price float64) float64 {
+	var discount float64
+	switch p.DiscountType {
+	case PromoDiscountPercentage:
+		discount = price * p.Value / 100
+	case PromoDiscountFlat:
+		discount = p.Value
+	}
+
+	if p.MaxDiscountInINR > 0 {
+		discount = math.Min(discount, p.MaxDiscountInINR)
+	}
+
+	return math.Round(math.Min(discount, price)*100) / 100
+}
+
+// IsPromoError reports whether err is a promo code that was rejected by its rules
+func IsPromoError(err error) bool {
+	return errors.Is(err, ErrPromoNotFound) ||
+		errors.Is(err, ErrPromoExpired) ||
+		errors.Is(err, ErrPromoUsageExceeded) ||
+		errors.Is(err, ErrPromoFirstRideOnly) ||
+		errors.Is(err, ErrPromoNotApplicable)
+}
+
+// PromoRedemptionModel records a promo code used on a trip
+type PromoRedemptionModel struct {
+	Code          string
+	UserID        string
+	TripID        string
+	DiscountInINR float64
+	RedeemedAt    time.Time
+}
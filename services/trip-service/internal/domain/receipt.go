@@ -0,0 +1,82 @@
+package domain
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/AuraReaper/voom/shared/types"
+)
+
+var (
+	ErrReceiptNotFound      = errors.New("receipt not found")
+	ErrInvalidReceiptFormat = errors.New("unknown receipt format")
+	ErrBlobNotFound         = errors.New("blob not found")
+)
+
+// Receipt formats
+const (
+	ReceiptFormatHTML = "html"
+	ReceiptFormatPDF  = "pdf"
+)
+
+// ReceiptContentTypes maps each receipt format to the content type it is served with
+var ReceiptContentTypes = map[string]string{
+	ReceiptFormatHTML: "text/html; charset=utf-8",
+	ReceiptFormatPDF:  "application/pdf",
+}
+
+// ReceiptModel is the snapshot of a paid trip a receipt is rendered from. It never changes once
+// issued, so the documents rendered from it are the same every time.
+type ReceiptModel struct {
+	Number            string // printed on the receipt, derived from Sequence
+	Sequence          int64  // gapless, in the order receipts were issued
+	TripID            string
+	UserID            string
+	IssuedAt          time.Time
+	PackageSlug       string
+	Pickup            *types.Coordinate
+	Dropoff           *types.Coordinate
+	DistanceInKm      float64
+	DurationInMinutes float64
+	LineItems         []*FareLineItemModel
+	SubtotalInINR     float64 // the fare without taxes
+	TaxPercent        float64
+	TaxInINR          float64
+	TotalInINR        float64 // what the rider paid, taxes included
+	DriverName        string
+	VehicleNumber     string
+	PaymentMethod     string
+}
+
+// ReceiptNumber formats the sequence number of a receipt
+func ReceiptNumber(sequence int64) string {
+	return fmt.Sprintf("VR-%08d", sequence)
+}
+
+// BlobKey returns where the document of the receipt in format is stored
+func (r *ReceiptModel) BlobKey(format string) string {
+	return fmt.Sprintf("receipts/%s/%s.%s", r.TripID, r.Number, format)
+}
+
+// BlobStore keeps rendered documents
+type BlobStore interface {
+	// Put stores data under key, replacing what was stored there
+	Put(ctx context.Context, key string, data []byte) error
+	// Get fails with ErrBlobNotFound when nothing is stored under key
+	Get(ctx context.Context, key string) ([]byte, error)
+}
+
+type ReceiptRenderer interface {
+	RenderHTML(receipt *ReceiptModel) ([]byte, error)
+	RenderPDF(receipt *ReceiptModel) ([]byte, error)
+}
+
+type ReceiptService interface {
+	// IssueReceipt numbers and stores the receipt of a paid trip and renders its documents.
+	// A trip that already has a receipt keeps it, it is never numbered twice.
+	IssueReceipt(ctx context.Context, tripID string) (*ReceiptModel, error)
+	// GetReceiptDocument returns the receipt of a trip in format, with the content type it is served with
+	GetReceiptDocument(ctx context.Context, tripID, userID, format string) (*ReceiptModel, []byte, string, error)
+}
@@ -9,9 +9,25 @@ import (
 type RideFareModel struct {
 	ID              primitive.ObjectID
 	UserID          string
-	PackageSlug     string // ex: van, luxury, sedan
-	TotalPriceInINR float64
+	PackageSlug     string  // ex: van, luxury, sedan
+	TotalPriceInINR float64 // after discounts
 	Route           *tripTypes.OsrmApiResponse
+	LineItems       []*FareLineItemModel
+	PromoCode       string // redeemed when the fare is booked
+}
+
+type FareLineItemModel struct {
+	Type        string
+	Description string
+	AmountInINR float64 // negative for discounts
+}
+
+func (l *FareLineItemModel) ToProto() *pb.FareLineItem {
+	return &pb.FareLineItem{
+		Type:        l.Type,
+		Description: l.Description,
+		AmountInINR: l.AmountInINR,
+	}
 }
 
 func (r *RideFareModel) ToProto() *pb.RideFare {
@@ -20,9 +36,32 @@ func (r *RideFareModel) ToProto() *pb.RideFare {
 		UserID:          r.UserID,
 		PackageSlug:     r.PackageSlug,
 		TotalPriceInINR: r.TotalPriceInINR,
+		LineItems:       toLineItemsProto(r.LineItems),
+		PromoCode:       r.PromoCode,
 	}
 }
 
+// Discount returns the total taken off the fare by promo codes
+func (r *RideFareModel) Discount() float64 {
+	var discount float64
+	for _, item := range r.LineItems {
+		if item.Type == FareLineItemPromo {
+			discount -= item.AmountInINR
+		}
+	}
+
+	return discount
+}
+
+func toLineItemsProto(items []*FareLineItemModel) []*pb.FareLineItem {
+	var protoItems []*pb.FareLineItem
+	for _, item := range items {
+		protoItems = append(protoItems, item.ToProto())
+	}
+
+	return protoItems
+}
+
 func ToRidesFaresProto(fares []*RideFareModel) []*pb.RideFare {
 	var protoFares []*pb.RideFare
 	for _, f := range fares {
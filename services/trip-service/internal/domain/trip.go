@@ -2,6 +2,8 @@ package domain
 
 import (
 	"context"
+	"errors"
+	"slices"
 
 	tripTypes "github.com/AuraReaper/voom/services/trip-service/pkg/types"
 	pbd "github.com/AuraReaper/voom/shared/proto/driver"
@@ -10,39 +12,182 @@ import (
 	"go.mongodb.org/mongo-driver/bson/primitive"
 )
 
+// Trip statuses
+const (
+	TripStatusAwaitingAuthorization = "awaiting_authorization"
+	TripStatusPending               = "pending"
+	TripStatusAccepted              = "accepted"
+	TripStatusCompleted             = "completed"
+	TripStatusNoDriversFound        = "no_drivers_found"
+	TripStatusPayed                 = "payed"
+	TripStatusPaymentFailed         = "payment_failed"
+	TripStatusPaymentDunning        = "payment_dunning"
+)
+
+// Split participant statuses
+const (
+	SplitStatusInvited  = "invited"
+	SplitStatusAccepted = "accepted"
+	SplitStatusDeclined = "declined"
+)
+
+// paymentOutcomeFrom lists, for each status a payment event moves a trip to, the statuses the trip
+// can be in. A late or redelivered event finds the trip elsewhere and is ignored.
+var paymentOutcomeFrom = map[string][]string{
+	TripStatusPayed:          {TripStatusCompleted, TripStatusPaymentFailed, TripStatusPaymentDunning},
+	TripStatusPaymentFailed:  {TripStatusAwaitingAuthorization, TripStatusCompleted, TripStatusPaymentFailed},
+	TripStatusPaymentDunning: {TripStatusCompleted, TripStatusPaymentFailed},
+}
+
+// MaxSplitParticipants is how many riders a trip owner can split a fare with
+const MaxSplitParticipants = 3
+
+var (
+	ErrTripNotFound       = errors.New("trip not found")
+	ErrNotTripOwner       = errors.New("only the trip owner can invite riders to split the fare")
+	ErrSplitNotAllowed    = errors.New("the fare of this trip can't be split")
+	ErrTooManySplitRiders = errors.New("too many riders to split the fare with")
+	ErrNotInvitedToSplit  = errors.New("rider was not invited to split the fare")
+)
+
+// Payment methods a rider can choose when booking
+const (
+	PaymentMethodCard   = "card"
+	PaymentMethodWallet = "wallet" // the wallet pays when its balance suffices, the card otherwise
+	PaymentMethodCash   = "cash"
+
+	// DefaultPaymentMethod is used when booking without a method, the card as before riders could choose
+	DefaultPaymentMethod = PaymentMethodCard
+)
+
+// IsValidPaymentMethod reports whether method is one of the supported payment methods
+func IsValidPaymentMethod(method string) bool {
+	switch method {
+	case PaymentMethodCard, PaymentMethodWallet, PaymentMethodCash:
+		return true
+	}
+	return false
+}
+
 type TripModel struct {
-	ID       primitive.ObjectID
-	UserID   string
-	Status   string
-	RideFare *RideFareModel
-	Driver   *pb.TripDriver
+	ID            primitive.ObjectID
+	UserID        string
+	Status        string
+	RideFare      *RideFareModel
+	Driver        *pb.TripDriver
+	PaymentMethod string
+	// SplitParticipants are the riders invited to share the fare, the owner pays whatever they don't
+	SplitParticipants []*SplitParticipantModel
+}
+
+type SplitParticipantModel struct {
+	UserID string
+	Status string
+}
+
+// AcceptedSplitParticipants returns the riders who agreed to share the fare
+func (t *TripModel) AcceptedSplitParticipants() []string {
+	var userIDs []string
+	for _, p := range t.SplitParticipants {
+		if p.Status == SplitStatusAccepted {
+			userIDs = append(userIDs, p.UserID)
+		}
+	}
+	return userIDs
+}
+
+// CanApplyPaymentOutcome reports whether a payment event can move the trip to status
+func (t *TripModel) CanApplyPaymentOutcome(status string) bool {
+	return slices.Contains(paymentOutcomeFrom[status], t.Status)
+}
+
+// CanBeSplit reports whether riders can still join or leave the fare split
+func (t *TripModel) CanBeSplit() bool {
+	if t.PaymentMethod == PaymentMethodCash {
+		return false
+	}
+
+	switch t.Status {
+	case TripStatusAwaitingAuthorization, TripStatusPending, TripStatusAccepted:
+		return true
+	}
+	return false
 }
 
 func (t *TripModel) ToProto() *pb.Trip {
 	return &pb.Trip{
-		Id:           t.ID.Hex(),
-		UserID:       t.UserID,
-		Status:       t.Status,
-		SelectedFare: t.RideFare.ToProto(),
-		Driber:       t.Driver,
-		Route:        t.RideFare.Route.ToProto(),
+		Id:                t.ID.Hex(),
+		UserID:            t.UserID,
+		Status:            t.Status,
+		SelectedFare:      t.RideFare.ToProto(),
+		Driber:            t.Driver,
+		Route:             t.RideFare.Route.ToProto(),
+		PaymentMethod:     t.PaymentMethod,
+		SplitParticipants: ToSplitParticipantsProto(t.SplitParticipants),
+	}
+}
+
+// ToTripDriver converts the driver assigned to a trip into the form it is stored on the trip with
+func ToTripDriver(driver *pbd.Driver) *pb.TripDriver {
+	return &pb.TripDriver{
+		Id:             driver.Id,
+		Name:           driver.Name,
+		VehicleNumber:  driver.CarPlate,
+		ProfilePicture: driver.ProfilePicture,
 	}
 }
 
+func ToSplitParticipantsProto(participants []*SplitParticipantModel) []*pb.SplitParticipant {
+	var protoParticipants []*pb.SplitParticipant
+	for _, p := range participants {
+		protoParticipants = append(protoParticipants, &pb.SplitParticipant{
+			UserID: p.UserID,
+			Status: p.Status,
+		})
+	}
+
+	return protoParticipants
+}
+
 type TripRepository interface {
-	CreateTrip(ctx context.Context, trip *TripModel) (*TripModel, error)
+	OutboxRepository
+
+	// CreateTrip stores the trip, its outbox messages, and redeems the promo code of its fare in the same
+	// step, failing with ErrPromoUsageExceeded or ErrPromoFirstRideOnly when the code can no longer be used by the rider
+	CreateTrip(ctx context.Context, trip *TripModel, outbox ...*OutboxMessageModel) (*TripModel, error)
 	SaveRideFare(ctx context.Context, f *RideFareModel) error
 	GetRideFareByID(ctx context.Context, id string) (*RideFareModel, error)
 	GetTripByID(ctx context.Context, id string) (*TripModel, error)
-	UpdateTrip(ctx context.Context, tripID string, status string, driver *pbd.Driver) error
+	// UpdateTrip stores the outbox messages in the same step as the update
+	UpdateTrip(ctx context.Context, tripID string, status string, driver *pbd.Driver, outbox ...*OutboxMessageModel) error
+	HasTrips(ctx context.Context, userID string) (bool, error)
+	// AddSplitParticipants invites riders to split the fare, riders already invited are left as they are
+	AddSplitParticipants(ctx context.Context, tripID string, userIDs []string) error
+	// UpdateSplitParticipant fails with ErrNotInvitedToSplit for riders who were not invited
+	UpdateSplitParticipant(ctx context.Context, tripID, userID, status string) error
+
+	SavePromoCode(ctx context.Context, promo *PromoCodeModel) error
+	GetPromoCode(ctx context.Context, code string) (*PromoCodeModel, error)
+	// CountPromoRedemptions returns how often a code was redeemed in total and by the user
+	CountPromoRedemptions(ctx context.Context, code, userID string) (total int, byUser int, err error)
+
+	// IssueReceipt gives the receipt the next sequence number and stores it. When the trip already
+	// has a receipt that one is returned instead, so numbers are never skipped or reused.
+	IssueReceipt(ctx context.Context, receipt *ReceiptModel) (*ReceiptModel, error)
+	// GetReceipt fails with ErrReceiptNotFound when the trip has no receipt yet
+	GetReceipt(ctx context.Context, tripID string) (*ReceiptModel, error)
 }
 
 type TripService interface {
-	CreateTrip(ctx context.Context, fare *RideFareModel) (*TripModel, error)
+	CreateTrip(ctx context.Context, fare *RideFareModel, paymentMethod string) (*TripModel, error)
 	GetRoute(ctx context.Context, pickup, destination *types.Coordinate) (*tripTypes.OsrmApiResponse, error)
 	EstimatePackagesPriceWithRoute(route *tripTypes.OsrmApiResponse) []*RideFareModel
 	GenerateTripFares(ctx context.Context, fares []*RideFareModel, userID string, route *tripTypes.OsrmApiResponse) ([]*RideFareModel, error)
+	ApplyPromoCode(ctx context.Context, fares []*RideFareModel, userID, code string) ([]*RideFareModel, error)
 	GetAndValidateFare(ctx context.Context, fareID, userID string) (*RideFareModel, error)
 	GetTripByID(ctx context.Context, id string) (*TripModel, error)
-	UpdateTrip(ctx context.Context, tripID string, status string, driver *pbd.Driver) error
+	UpdateTrip(ctx context.Context, tripID string, status string, driver *pbd.Driver, outbox ...*OutboxMessageModel) error
+	AddOutboxMessages(ctx context.Context, messages ...*OutboxMessageModel) error
+	InviteToSplit(ctx context.Context, tripID, ownerID string, userIDs []string) (*TripModel, []string, error)
+	RespondToSplit(ctx context.Context, tripID, userID string, accept bool) (*TripModel, error)
 }
@@ -31,7 +31,7 @@ func (h *TripHandler) CreateTrip(c echo.Context) error {
 		})
 	}
 
-	trip, err := h.svc.CreateTrip(c.Request().Context(), &fare)
+	trip, err := h.svc.CreateTrip(c.Request().Context(), &fare, domain.PaymentMethodWallet)
 	if err != nil {
 		return c.JSON(http.StatusInternalServerError, map[string]string{
 			"error": err.Error(),
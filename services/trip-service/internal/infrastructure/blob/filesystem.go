@@ -0,0 +1,87 @@
+package blob
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/AuraReaper/voom/services/trip-service/internal/domain"
+)
+
+type filesystemStore struct {
+	dir string
+}
+
+// NewFilesystemStore creates a store that keeps every blob as a file under dir
+func NewFilesystemStore(dir string) (domain.BlobStore, error) {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, fmt.Errorf("failed to create blob directory: %w", err)
+	}
+
+	return &filesystemStore{
+		dir: dir,
+	}, nil
+}
+
+func (s *filesystemStore) Put(ctx context.Context, key string, data []byte) error {
+	path, err := s.path(key)
+	if err != nil {
+		return err
+	}
+
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return fmt.Errorf("failed to create blob directory: %w", err)
+	}
+
+	// Written next to the blob and renamed over it, so a reader never sees a partial file
+	tmp, err := os.CreateTemp(filepath.Dir(path), ".tmp-*")
+	if err != nil {
+		return fmt.Errorf("failed to create blob file: %w", err)
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return fmt.Errorf("failed to write blob %s: %w", key, err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("failed to write blob %s: %w", key, err)
+	}
+
+	if err := os.Rename(tmp.Name(), path); err != nil {
+		return fmt.Errorf("failed to store blob %s: %w", key, err)
+	}
+
+	return nil
+}
+
+func (s *filesystemStore) Get(ctx context.Context, key string) ([]byte, error) {
+	path, err := s.path(key)
+	if err != nil {
+		return nil, err
+	}
+
+	data, err := os.ReadFile(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, domain.ErrBlobNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read blob %s: %w", key, err)
+	}
+
+	return data, nil
+}
+
+// path returns the file of a blob, refusing keys that would point outside the store
+func (s *filesystemStore) path(key string) (string, error) {
+	clean := filepath.Clean(filepath.FromSlash(key))
+	if filepath.IsAbs(clean) || clean == ".." || strings.HasPrefix(clean, ".."+string(filepath.Separator)) {
+		return "", fmt.Errorf("invalid blob key: %s", key)
+	}
+
+	return filepath.Join(s.dir, clean), nil
+}
@@ -0,0 +1,40 @@
+package blob
+
+import (
+	"context"
+	"sync"
+
+	"github.com/AuraReaper/voom/services/trip-service/internal/domain"
+)
+
+type inmemStore struct {
+	blobs map[string][]byte
+	mu    sync.RWMutex
+}
+
+// NewInmemStore creates a store that keeps blobs in memory, they are lost on restart
+func NewInmemStore() domain.BlobStore {
+	return &inmemStore{
+		blobs: make(map[string][]byte),
+	}
+}
+
+func (s *inmemStore) Put(ctx context.Context, key string, data []byte) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.blobs[key] = append([]byte(nil), data...)
+	return nil
+}
+
+func (s *inmemStore) Get(ctx context.Context, key string) ([]byte, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	data, ok := s.blobs[key]
+	if !ok {
+		return nil, domain.ErrBlobNotFound
+	}
+
+	return append([]byte(nil), data...), nil
+}
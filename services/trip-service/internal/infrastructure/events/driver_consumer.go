@@ -2,7 +2,6 @@ package events
 
 import (
 	"context"
-	"encoding/json"
 	"fmt"
 	"log"
 
@@ -10,39 +9,31 @@ import (
 	"github.com/AuraReaper/voom/shared/contracts"
 	"github.com/AuraReaper/voom/shared/messaging"
 	pbd "github.com/AuraReaper/voom/shared/proto/driver"
-
-	"github.com/rabbitmq/amqp091-go"
 )
 
+// tripOrdered handles the messages about one trip in order and different trips concurrently
+var tripOrdered = []messaging.ConsumerOption{
+	messaging.WithWorkers(4),
+	messaging.WithOrderingKey(messaging.TripIDKey),
+}
+
 type driverConsumer struct {
-	rabbitmq *messaging.RabbitMQ
-	service  domain.TripService
+	bus     messaging.Bus
+	service domain.TripService
 }
 
-func NewDriverConsumer(rabbitmq *messaging.RabbitMQ, service domain.TripService) *driverConsumer {
+func NewDriverConsumer(bus messaging.Bus, service domain.TripService) *driverConsumer {
 	return &driverConsumer{
-		rabbitmq: rabbitmq,
-		service:  service,
+		bus:     bus,
+		service: service,
 	}
 }
 
 func (c *driverConsumer) Listen() error {
-	return c.rabbitmq.ConsumeMessages(messaging.DriverTripResponseQueue, func(ctx context.Context, msg amqp091.Delivery) error {
-		var message contracts.AmqpMessage
-		if err := json.Unmarshal(msg.Body, &message); err != nil {
-			log.Printf("Failed to unmarshal message: %v", err)
-			return err
-		}
-
-		var payload messaging.DriverTripResponseData
-		if err := json.Unmarshal(message.Data, &payload); err != nil {
-			log.Printf("Failed to unmarshal message: %v", err)
-			return err
-		}
-
+	return messaging.Consume(c.bus, messaging.DriverTripResponseQueue, func(ctx context.Context, message *contracts.Envelope, payload messaging.DriverTripResponseData) error {
 		log.Printf("driver response received message: %+v", payload)
 
-		switch msg.RoutingKey {
+		switch message.Type {
 		case contracts.DriverCmdTripAccept:
 			if err := c.handleTripAccepted(ctx, payload.TripID, payload.Driver); err != nil {
 				log.Printf("Failed to handle the trip accept: %v", err)
@@ -54,11 +45,23 @@ func (c *driverConsumer) Listen() error {
 				return err
 			}
 			return nil
+		case contracts.DriverCmdTripComplete:
+			if err := c.handleTripCompleted(ctx, payload.TripID, payload.Driver); err != nil {
+				log.Printf("Failed to handle the trip complete: %v", err)
+				return err
+			}
+			return nil
+		case contracts.DriverCmdCashCollected:
+			if err := c.handleCashCollected(ctx, payload.TripID, payload.Driver); err != nil {
+				log.Printf("Failed to handle the cash collected: %v", err)
+				return err
+			}
+			return nil
 		}
 		log.Printf("unknown trip event: %+v", payload)
 
 		return nil
-	})
+	}, tripOrdered...)
 }
 
 func (c *driverConsumer) handleTripAccepted(ctx context.Context, tripID string, driver *pbd.Driver) error {
@@ -72,72 +75,137 @@ func (c *driverConsumer) handleTripAccepted(ctx context.Context, tripID string,
 		return fmt.Errorf("Trip was not found %s", tripID)
 	}
 
-	// 2. Update the trip
-	if err := c.service.UpdateTrip(ctx, tripID, "accepted", driver); err != nil {
-		log.Printf("Failed to update the trip: %v", err)
-		return err
+	// 2. Driver has been assigned -> notify the rider with the trip as it is after the update
+	accepted := *trip
+	accepted.Status = domain.TripStatusAccepted
+	if driver != nil {
+		accepted.Driver = domain.ToTripDriver(driver)
 	}
 
-	trip, err = c.service.GetTripByID(ctx, tripID)
+	event, err := domain.NewOutboxMessage(contracts.TripEventDriverAssigned, trip.UserID, accepted)
 	if err != nil {
 		return err
 	}
 
-	// 3. Driver has been assigned -> publish this event to RB
-	marshalledTrip, err := json.Marshal(trip)
-	if err != nil {
+	// 3. Update the trip, the event is published once the update is stored
+	if err := c.service.UpdateTrip(ctx, tripID, domain.TripStatusAccepted, driver, event); err != nil {
+		log.Printf("Failed to update the trip: %v", err)
 		return err
 	}
 
-	// Notify the rider that a driver has been assigned
-	if err := c.rabbitmq.PublishMessage(ctx, contracts.TripEventDriverAssigned, contracts.AmqpMessage{
-		OwnerID: trip.UserID,
-		Data:    marshalledTrip,
-	}); err != nil {
+	return nil
+}
+
+// handleTripCompleted captures the fare from the card hold once the assigned driver ends the trip
+func (c *driverConsumer) handleTripCompleted(ctx context.Context, tripID string, driver *pbd.Driver) error {
+	trip, err := c.service.GetTripByID(ctx, tripID)
+	if err != nil {
 		return err
 	}
 
-	marshalledPayload, err := json.Marshal(messaging.PaymentTripResponseData{
-		TripID:   tripID,
-		UserID:   trip.UserID,
-		DriverID: driver.Id,
-		Amount:   trip.RideFare.TotalPriceInINR,
-		Currency: "INR",
+	if trip == nil {
+		return fmt.Errorf("Trip was not found %s", tripID)
+	}
+
+	if driver == nil || trip.Driver == nil || trip.Driver.Id != driver.Id {
+		log.Printf("Ignoring trip complete for trip %s: not sent by the assigned driver", tripID)
+		return nil
+	}
+
+	// A redelivered command finds the trip already completed
+	if trip.Status != domain.TripStatusAccepted {
+		log.Printf("Ignoring trip complete for trip %s in status %s", tripID, trip.Status)
+		return nil
+	}
+
+	var outbox []*domain.OutboxMessageModel
+
+	// Cash is recorded once the driver confirms they collected it
+	if trip.PaymentMethod != domain.PaymentMethodCash {
+		command, err := paymentCommand(contracts.PaymentCmdCapture, trip, driver.Id)
+		if err != nil {
+			return err
+		}
+		outbox = append(outbox, command)
+	}
+
+	completed := *trip
+	completed.Status = domain.TripStatusCompleted
+
+	// Notify the rider that the trip is over
+	event, err := domain.NewOutboxMessage(contracts.TripEventCompleted, trip.UserID, messaging.TripEventData{
+		Trip: completed.ToProto(),
 	})
+	if err != nil {
+		return err
+	}
+	outbox = append(outbox, event)
 
-	if err := c.rabbitmq.PublishMessage(ctx, contracts.PaymentCmdCreateSession,
-		contracts.AmqpMessage{
-			OwnerID: trip.UserID,
-			Data:    marshalledPayload,
-		},
-	); err != nil {
+	if err := c.service.UpdateTrip(ctx, tripID, domain.TripStatusCompleted, nil, outbox...); err != nil {
+		log.Printf("Failed to update the trip: %v", err)
 		return err
 	}
 
 	return nil
 }
 
-func (c *driverConsumer) handleTripDecline(ctx context.Context, tripID string, riderID string) error {
+// handleCashCollected records the fare the driver of a completed cash trip collected from the rider
+func (c *driverConsumer) handleCashCollected(ctx context.Context, tripID string, driver *pbd.Driver) error {
 	trip, err := c.service.GetTripByID(ctx, tripID)
 	if err != nil {
 		return err
 	}
 
-	newPayload := messaging.TripEventData{
-		Trip: trip.ToProto(),
+	if trip == nil {
+		return fmt.Errorf("Trip was not found %s", tripID)
+	}
+
+	if driver == nil || trip.Driver == nil || trip.Driver.Id != driver.Id {
+		log.Printf("Ignoring cash collected for trip %s: not sent by the assigned driver", tripID)
+		return nil
 	}
 
-	marshalledPayload, err := json.Marshal(newPayload)
+	if trip.PaymentMethod != domain.PaymentMethodCash || trip.Status != domain.TripStatusCompleted {
+		log.Printf("Ignoring cash collected for %s trip %s in status %s", trip.PaymentMethod, tripID, trip.Status)
+		return nil
+	}
+
+	command, err := paymentCommand(contracts.PaymentCmdRecordCash, trip, driver.Id)
 	if err != nil {
 		return err
 	}
 
-	if err := c.rabbitmq.PublishMessage(ctx, contracts.TripEventDriverNotInterested, contracts.AmqpMessage{
-		OwnerID: riderID,
-		Data:    marshalledPayload,
-	}); err != nil {
+	return c.service.AddOutboxMessages(ctx, command)
+}
+
+func paymentCommand(routingKey string, trip *domain.TripModel, driverID string) (*domain.OutboxMessageModel, error) {
+	return domain.NewOutboxMessage(routingKey, trip.UserID, messaging.PaymentCaptureData{
+		TripID:        trip.ID.Hex(),
+		UserID:        trip.UserID,
+		DriverID:      driverID,
+		Amount:        trip.RideFare.TotalPriceInINR,
+		Currency:      "INR",
+		PaymentMethod: trip.PaymentMethod,
+		SplitWith:     trip.AcceptedSplitParticipants(),
+	})
+}
+
+func (c *driverConsumer) handleTripDecline(ctx context.Context, tripID string, riderID string) error {
+	trip, err := c.service.GetTripByID(ctx, tripID)
+	if err != nil {
 		return err
 	}
 
-	return nil
+	if trip == nil {
+		return fmt.Errorf("Trip was not found %s", tripID)
+	}
+
+	event, err := domain.NewOutboxMessage(contracts.TripEventDriverNotInterested, riderID, messaging.TripEventData{
+		Trip: trip.ToProto(),
+	})
+	if err != nil {
+		return err
+	}
+
+	return c.service.AddOutboxMessages(ctx, event)
 }
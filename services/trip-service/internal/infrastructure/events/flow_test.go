@@ -0,0 +1,183 @@
+package events
+
+import (
+	"context"
+	"testing"
+
+	gatewayTopology "github.com/AuraReaper/voom/services/api-gateway/pkg/topology"
+	driverTopology "github.com/AuraReaper/voom/services/driver-service/pkg/topology"
+	paymentTopology "github.com/AuraReaper/voom/services/payment-service/pkg/topology"
+	"github.com/AuraReaper/voom/services/trip-service/internal/domain"
+	"github.com/AuraReaper/voom/services/trip-service/internal/infrastructure/blob"
+	"github.com/AuraReaper/voom/services/trip-service/internal/infrastructure/receipt"
+	"github.com/AuraReaper/voom/services/trip-service/internal/infrastructure/repository"
+	"github.com/AuraReaper/voom/services/trip-service/internal/service"
+	tripTopology "github.com/AuraReaper/voom/services/trip-service/pkg/topology"
+	tripTypes "github.com/AuraReaper/voom/services/trip-service/pkg/types"
+	"github.com/AuraReaper/voom/shared/contracts"
+	"github.com/AuraReaper/voom/shared/messaging"
+	pbd "github.com/AuraReaper/voom/shared/proto/driver"
+	amqp "github.com/rabbitmq/amqp091-go"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// TestCardTripFlow runs a card trip from its creation to its receipt through the in-memory bus,
+// with the driver and payment services standing in as consumers answering right away
+func TestCardTripFlow(t *testing.T) {
+	ctx := context.Background()
+	bus := messaging.NewInmemBus(tripTopology.Topology, paymentTopology.Topology, driverTopology.Topology, gatewayTopology.Topology)
+
+	repo := repository.NewInmemRepository()
+	tripEvents := NewTripEvents()
+	svc := service.NewTripService(repo, tripEvents)
+
+	renderer, err := receipt.NewRenderer()
+	if err != nil {
+		t.Fatal(err)
+	}
+	receipts := service.NewReceiptService(repo, blob.NewInmemStore(), renderer, 5)
+
+	if err := NewDriverConsumer(bus, svc).Listen(); err != nil {
+		t.Fatal(err)
+	}
+	if err := NewPaymentConsumer(bus, svc, receipts, tripEvents).Listen(); err != nil {
+		t.Fatal(err)
+	}
+
+	driver := &pbd.Driver{Id: "driver-1", Name: "Driver"}
+	var commands []string
+
+	// The payment service authorizes the hold and captures the fare
+	if err := bus.ConsumeMessages(messaging.PaymentTripResponseQueue, func(ctx context.Context, msg amqp.Delivery) error {
+		commands = append(commands, msg.RoutingKey)
+
+		switch msg.RoutingKey {
+		case contracts.PaymentCmdAuthorize:
+			_, payload, err := messaging.Decode[messaging.PaymentAuthorizeData](msg)
+			if err != nil {
+				return err
+			}
+			return messaging.Publish(ctx, bus, contracts.PaymentEventAuthorized, payload.UserID, messaging.PaymentStatusUpdateData{
+				TripID: payload.TripID,
+				UserID: payload.UserID,
+			})
+		case contracts.PaymentCmdCapture:
+			_, payload, err := messaging.Decode[messaging.PaymentCaptureData](msg)
+			if err != nil {
+				return err
+			}
+			return messaging.Publish(ctx, bus, contracts.PaymentEventSuccess, payload.UserID, messaging.PaymentStatusUpdateData{
+				TripID:   payload.TripID,
+				UserID:   payload.UserID,
+				DriverID: payload.DriverID,
+			})
+		}
+		return nil
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	// The driver service accepts every trip it is offered
+	if err := messaging.Consume(bus, messaging.FindAvailableDriversQueue, func(ctx context.Context, message *contracts.Envelope, payload messaging.TripEventData) error {
+		if message.Type != contracts.TripEventCreated {
+			return nil
+		}
+		return messaging.Publish(ctx, bus, contracts.DriverCmdTripAccept, payload.Trip.UserID, messaging.DriverTripResponseData{
+			Driver:  driver,
+			TripID:  payload.Trip.Id,
+			RiderID: payload.Trip.UserID,
+		})
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	fare := &domain.RideFareModel{
+		ID:              primitive.NewObjectID(),
+		UserID:          "rider-1",
+		PackageSlug:     "sedan",
+		TotalPriceInINR: 250,
+		Route:           &tripTypes.OsrmApiResponse{},
+	}
+	trip, err := svc.CreateTrip(ctx, fare, domain.PaymentMethodCard)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tripID := trip.ID.Hex()
+
+	relay := NewOutboxRelay(repo, bus, 0)
+	settle(t, ctx, relay, bus)
+
+	assertStatus(t, ctx, svc, tripID, domain.TripStatusAccepted)
+	if assigned := bus.Pending(messaging.NotifyDriverAssignQueue); len(assigned) != 1 {
+		t.Errorf("the rider got %d driver assigned events, want 1", len(assigned))
+	}
+
+	if err := messaging.Publish(ctx, bus, contracts.DriverCmdTripComplete, fare.UserID, messaging.DriverTripResponseData{
+		Driver:  driver,
+		TripID:  tripID,
+		RiderID: fare.UserID,
+	}); err != nil {
+		t.Fatal(err)
+	}
+	settle(t, ctx, relay, bus)
+
+	assertStatus(t, ctx, svc, tripID, domain.TripStatusPayed)
+	if completed := bus.Pending(messaging.NotifyTripCompletedQueue); len(completed) != 1 {
+		t.Errorf("the rider got %d trip completed events, want 1", len(completed))
+	}
+	if _, err := repo.GetReceipt(ctx, tripID); err != nil {
+		t.Errorf("the paid trip has no receipt: %v", err)
+	}
+
+	want := []string{contracts.PaymentCmdAuthorize, contracts.PaymentCmdCapture}
+	if len(commands) != len(want) || commands[0] != want[0] || commands[1] != want[1] {
+		t.Errorf("the payment service got %v, want %v", commands, want)
+	}
+
+	for _, queue := range []string{
+		messaging.PaymentTripResponseQueue,
+		messaging.FindAvailableDriversQueue,
+		messaging.DriverTripResponseQueue,
+		messaging.NotifyPaymentAuthorizedQueue,
+		messaging.NotifyPaymentSuccessQueue,
+	} {
+		if letters := bus.DeadLetters(queue); len(letters) != 0 {
+			t.Errorf("%s dead-lettered %+v", queue, letters)
+		}
+	}
+}
+
+// settle relays the outbox and drains the bus until neither has anything left to do
+func settle(t *testing.T, ctx context.Context, relay *OutboxRelay, bus *messaging.InmemBus) {
+	t.Helper()
+
+	for range 20 {
+		relay.relay(ctx)
+
+		handled, err := bus.Drain(ctx)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		pending, err := relay.repo.ListPendingOutboxMessages(ctx, outboxBatchSize)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if handled == 0 && len(pending) == 0 {
+			return
+		}
+	}
+	t.Fatal("the flow did not settle")
+}
+
+func assertStatus(t *testing.T, ctx context.Context, svc domain.TripService, tripID, want string) {
+	t.Helper()
+
+	trip, err := svc.GetTripByID(ctx, tripID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if trip.Status != want {
+		t.Fatalf("trip %s is %s, want %s", tripID, trip.Status, want)
+	}
+}
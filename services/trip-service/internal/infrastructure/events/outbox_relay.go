@@ -0,0 +1,99 @@
+package events
+
+import (
+	"context"
+	"log"
+	"time"
+
+	"github.com/AuraReaper/voom/services/trip-service/internal/domain"
+	"github.com/AuraReaper/voom/shared/contracts"
+	"github.com/AuraReaper/voom/shared/messaging"
+)
+
+const (
+	// outboxBatchSize is how many pending messages the relay publishes per round
+	outboxBatchSize = 100
+	// outboxMaxAttempts is how many times a message is published before the relay parks it
+	outboxMaxAttempts = 10
+)
+
+// OutboxRelay publishes the messages stored in the outbox and marks them sent. A message is only
+// marked once the broker confirmed it, so a crash in between publishes it again. A message that
+// failed outboxMaxAttempts times is parked, so it stops holding up the messages of its owner.
+type OutboxRelay struct {
+	repo     domain.OutboxRepository
+	bus      messaging.Bus
+	interval time.Duration
+}
+
+func NewOutboxRelay(repo domain.OutboxRepository, bus messaging.Bus, interval time.Duration) *OutboxRelay {
+	return &OutboxRelay{
+		repo:     repo,
+		bus:      bus,
+		interval: interval,
+	}
+}
+
+// Run relays pending messages every interval until ctx is cancelled
+func (r *OutboxRelay) Run(ctx context.Context) {
+	ticker := time.NewTicker(r.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			r.relay(ctx)
+		}
+	}
+}
+
+func (r *OutboxRelay) relay(ctx context.Context) {
+	messages, err := r.repo.ListPendingOutboxMessages(ctx, outboxBatchSize)
+	if err != nil {
+		log.Printf("Failed to list pending outbox messages: %v", err)
+		return
+	}
+
+	// Owners with a message that failed this round, the messages behind it wait so the events of a
+	// trip are published in order. The messages of other owners go ahead.
+	blocked := make(map[string]bool)
+
+	for _, msg := range messages {
+		if blocked[msg.OwnerID] {
+			continue
+		}
+
+		// Relaying a message again reuses its ID, so consumers skip it if it was delivered before
+		if err := r.bus.PublishMessageWithID(ctx, msg.ID.Hex(), msg.RoutingKey, contracts.AmqpMessage{
+			OwnerID: msg.OwnerID,
+			Data:    msg.Data,
+		}); err != nil {
+			r.fail(ctx, msg, err)
+			blocked[msg.OwnerID] = true
+			continue
+		}
+
+		if err := r.repo.MarkOutboxMessageSent(ctx, msg.ID.Hex()); err != nil {
+			log.Printf("Failed to mark outbox message %s sent, it will be published again: %v", msg.ID.Hex(), err)
+		}
+	}
+}
+
+// fail records a failed attempt to publish msg, and parks it once it is out of attempts
+func (r *OutboxRelay) fail(ctx context.Context, msg *domain.OutboxMessageModel, cause error) {
+	attempt := msg.Attempts + 1
+	if attempt < outboxMaxAttempts {
+		log.Printf("Failed to relay outbox message %s (%s), attempt %d/%d: %v", msg.ID.Hex(), msg.RoutingKey, attempt, outboxMaxAttempts, cause)
+		if err := r.repo.MarkOutboxMessageFailed(ctx, msg.ID.Hex(), cause); err != nil {
+			log.Printf("Failed to record the failed outbox message %s: %v", msg.ID.Hex(), err)
+		}
+		return
+	}
+
+	log.Printf("ERROR: Parking outbox message %s (%s) after %d attempts: %v", msg.ID.Hex(), msg.RoutingKey, attempt, cause)
+	if err := r.repo.ParkOutboxMessage(ctx, msg.ID.Hex(), cause); err != nil {
+		log.Printf("Failed to park outbox message %s: %v", msg.ID.Hex(), err)
+	}
+}
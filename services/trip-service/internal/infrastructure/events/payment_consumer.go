@@ -2,7 +2,7 @@ package events
 
 import (
 	"context"
-	"encoding/json"
+	"fmt"
 	"log"
 
 	"github.com/AuraReaper/voom/services/trip-service/internal/domain"
@@ -13,37 +13,154 @@ import (
 )
 
 type paymentConsumer struct {
-	rabbitmq *messaging.RabbitMQ
+	bus      messaging.Bus
 	service  domain.TripService
+	receipts domain.ReceiptService
+	events   domain.TripEventFactory
 }
 
-func NewPaymentConsumer(rabbitmq *messaging.RabbitMQ, service domain.TripService) *paymentConsumer {
+func NewPaymentConsumer(bus messaging.Bus, service domain.TripService, receipts domain.ReceiptService, events domain.TripEventFactory) *paymentConsumer {
 	return &paymentConsumer{
-		rabbitmq: rabbitmq,
+		bus:      bus,
 		service:  service,
+		receipts: receipts,
+		events:   events,
 	}
 }
 
 func (c *paymentConsumer) Listen() error {
-	return c.rabbitmq.ConsumeMessages(messaging.NotifyPaymentSuccessQueue, func(ctx context.Context, msg amqp091.Delivery) error {
-		var message contracts.AmqpMessage
-		if err := json.Unmarshal(msg.Body, &message); err != nil {
-			log.Printf("Failed to unmarshal message: %v", err)
-			return err
-		}
-		var payload messaging.PaymentStatusUpdateData
-		if err := json.Unmarshal(message.Data, &payload); err != nil {
-			log.Printf("Failed to unmarshal payload: %v", err)
-			return err
-		}
+	if err := messaging.Consume(c.bus, messaging.NotifyPaymentAuthorizedQueue, c.handlePaymentAuthorized, tripOrdered...); err != nil {
+		return err
+	}
+
+	if err := messaging.Consume(c.bus, messaging.NotifyPaymentSuccessQueue, c.handlePaymentSuccess, tripOrdered...); err != nil {
+		return err
+	}
 
+	return c.bus.ConsumeMessages(messaging.NotifyPaymentFailedQueue, c.handlePaymentFailed, tripOrdered...)
+}
+
+// handlePaymentAuthorized dispatches the trip to drivers once the rider's card hold is in place
+func (c *paymentConsumer) handlePaymentAuthorized(ctx context.Context, message *contracts.Envelope, payload messaging.PaymentStatusUpdateData) error {
+
+	trip, err := c.getTrip(ctx, payload.TripID)
+	if err != nil {
+		return err
+	}
+
+	// A trip is only dispatched once, a redelivered authorization is ignored
+	if trip.Status != domain.TripStatusAwaitingAuthorization && trip.Status != domain.TripStatusPaymentFailed {
+		log.Printf("Ignoring payment authorization for trip %s in status %s", trip.ID.Hex(), trip.Status)
+		return nil
+	}
+
+	pending := *trip
+	pending.Status = domain.TripStatusPending
+
+	event, err := c.events.TripCreated(&pending)
+	if err != nil {
+		return err
+	}
+
+	// Drivers are looked for once the update is stored
+	if err := c.service.UpdateTrip(ctx, payload.TripID, domain.TripStatusPending, nil, event); err != nil {
+		log.Printf("Failed to update the trip: %v", err)
+		return err
+	}
+
+	log.Printf("Payment authorized for trip %s, looking for drivers", payload.TripID)
+
+	return nil
+}
+
+func (c *paymentConsumer) handlePaymentSuccess(ctx context.Context, message *contracts.Envelope, payload messaging.PaymentStatusUpdateData) error {
+	trip, err := c.getTrip(ctx, payload.TripID)
+	if err != nil {
+		return err
+	}
+
+	// A redelivered success finds the trip paid, its receipt is issued again in case that failed
+	switch {
+	case trip.Status == domain.TripStatusPayed:
+	case !trip.CanApplyPaymentOutcome(domain.TripStatusPayed):
+		log.Printf("Ignoring payment success for trip %s in status %s", payload.TripID, trip.Status)
+		return nil
+	default:
 		log.Printf("Trip has been completed and payed.")
 
-		return c.service.UpdateTrip(
+		if err := c.service.UpdateTrip(
 			ctx,
 			payload.TripID,
-			"payed",
+			domain.TripStatusPayed,
 			nil,
-		)
-	})
+		); err != nil {
+			return err
+		}
+	}
+
+	receipt, err := c.receipts.IssueReceipt(ctx, payload.TripID)
+	if err != nil {
+		log.Printf("Failed to issue the receipt of trip %s: %v", payload.TripID, err)
+		return err
+	}
+
+	log.Printf("Issued receipt %s for trip %s", receipt.Number, payload.TripID)
+	return nil
+}
+
+// handlePaymentFailed moves the trip of a failed, cancelled or dunning payment out of the way,
+// the routing keys of the queue carry different payloads
+func (c *paymentConsumer) handlePaymentFailed(ctx context.Context, msg amqp091.Delivery) error {
+	var tripID string
+	status := domain.TripStatusPaymentFailed
+
+	if msg.RoutingKey == contracts.PaymentEventDunning {
+		_, payload, err := messaging.Decode[messaging.PaymentEventDunningData](msg)
+		if err != nil {
+			log.Printf("Failed to decode message: %v", err)
+			return err
+		}
+		tripID = payload.TripID
+		status = domain.TripStatusPaymentDunning
+	} else {
+		_, payload, err := messaging.Decode[messaging.PaymentStatusUpdateData](msg)
+		if err != nil {
+			log.Printf("Failed to decode message: %v", err)
+			return err
+		}
+		tripID = payload.TripID
+	}
+
+	trip, err := c.getTrip(ctx, tripID)
+	if err != nil {
+		return err
+	}
+
+	// A late failure must not take back a paid trip, nor one whose hold was released
+	if !trip.CanApplyPaymentOutcome(status) {
+		log.Printf("Ignoring %s for trip %s in status %s", msg.RoutingKey, tripID, trip.Status)
+		return nil
+	}
+
+	log.Printf("Payment for trip %s was not completed (%s), moving trip to %s", tripID, msg.RoutingKey, status)
+
+	return c.service.UpdateTrip(
+		ctx,
+		tripID,
+		status,
+		nil,
+	)
+}
+
+func (c *paymentConsumer) getTrip(ctx context.Context, tripID string) (*domain.TripModel, error) {
+	trip, err := c.service.GetTripByID(ctx, tripID)
+	if err != nil {
+		return nil, err
+	}
+
+	if trip == nil {
+		return nil, fmt.Errorf("Trip was not found %s", tripID)
+	}
+
+	return trip, nil
 }
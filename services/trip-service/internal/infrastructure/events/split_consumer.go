@@ -0,0 +1,106 @@
+package events
+
+import (
+	"context"
+	"errors"
+	"log"
+
+	"github.com/AuraReaper/voom/services/trip-service/internal/domain"
+	"github.com/AuraReaper/voom/shared/contracts"
+	"github.com/AuraReaper/voom/shared/messaging"
+
+	"github.com/rabbitmq/amqp091-go"
+)
+
+type splitConsumer struct {
+	bus     messaging.Bus
+	service domain.TripService
+}
+
+func NewSplitConsumer(bus messaging.Bus, service domain.TripService) *splitConsumer {
+	return &splitConsumer{
+		bus:     bus,
+		service: service,
+	}
+}
+
+func (c *splitConsumer) Listen() error {
+	return c.bus.ConsumeMessages(messaging.TripSplitQueue, func(ctx context.Context, msg amqp091.Delivery) error {
+		switch msg.RoutingKey {
+		case contracts.RiderCmdSplitInvite:
+			message, payload, err := messaging.Decode[messaging.RiderSplitInviteData](msg)
+			if err != nil {
+				log.Printf("Failed to decode message: %v", err)
+				return err
+			}
+			if err := c.handleInvite(ctx, message.OwnerID, payload); err != nil {
+				log.Printf("Failed to handle the split invite: %v", err)
+				return err
+			}
+		case contracts.RiderCmdSplitRespond:
+			message, payload, err := messaging.Decode[messaging.RiderSplitRespondData](msg)
+			if err != nil {
+				log.Printf("Failed to decode message: %v", err)
+				return err
+			}
+			if err := c.handleRespond(ctx, message.OwnerID, payload); err != nil {
+				log.Printf("Failed to handle the split response: %v", err)
+				return err
+			}
+		}
+
+		return nil
+	}, tripOrdered...)
+}
+
+// handleInvite invites riders to split the fare and asks each of them to accept
+func (c *splitConsumer) handleInvite(ctx context.Context, ownerID string, payload messaging.RiderSplitInviteData) error {
+	trip, invited, err := c.service.InviteToSplit(ctx, payload.TripID, ownerID, payload.UserIDs)
+	if err != nil {
+		if isSplitRejected(err) {
+			log.Printf("Rejected split invite of rider %s for trip %s: %v", ownerID, payload.TripID, err)
+			return nil
+		}
+		return err
+	}
+
+	for _, userID := range invited {
+		if err := c.publishSplit(ctx, contracts.TripEventSplitInvited, userID, trip); err != nil {
+			return err
+		}
+	}
+
+	return c.publishSplit(ctx, contracts.TripEventSplitUpdated, trip.UserID, trip)
+}
+
+// handleRespond records the answer of an invited rider and tells the owner
+func (c *splitConsumer) handleRespond(ctx context.Context, userID string, payload messaging.RiderSplitRespondData) error {
+	trip, err := c.service.RespondToSplit(ctx, payload.TripID, userID, payload.Accept)
+	if err != nil {
+		if isSplitRejected(err) {
+			log.Printf("Rejected split response of rider %s for trip %s: %v", userID, payload.TripID, err)
+			return nil
+		}
+		return err
+	}
+
+	return c.publishSplit(ctx, contracts.TripEventSplitUpdated, trip.UserID, trip)
+}
+
+func (c *splitConsumer) publishSplit(ctx context.Context, routingKey, ownerID string, trip *domain.TripModel) error {
+	return messaging.Publish(ctx, c.bus, routingKey, ownerID, messaging.TripEventSplitData{
+		TripID:       trip.ID.Hex(),
+		OwnerID:      trip.UserID,
+		Fare:         trip.RideFare.TotalPriceInINR,
+		Participants: domain.ToSplitParticipantsProto(trip.SplitParticipants),
+	})
+}
+
+// isSplitRejected reports whether err is a split the rules don't allow, redelivering it would fail the same way
+func isSplitRejected(err error) bool {
+	return errors.Is(err, domain.ErrTripNotFound) ||
+		errors.Is(err, domain.ErrNotTripOwner) ||
+		errors.Is(err, domain.ErrSplitNotAllowed) ||
+		errors.Is(err, domain.ErrTooManySplitRiders) ||
+		errors.Is(err, domain.ErrNotInvitedToSplit)
+}
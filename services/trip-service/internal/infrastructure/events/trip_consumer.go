@@ -0,0 +1,54 @@
+package events
+
+import (
+	"context"
+	"log"
+
+	"github.com/AuraReaper/voom/services/trip-service/internal/domain"
+	"github.com/AuraReaper/voom/shared/contracts"
+	"github.com/AuraReaper/voom/shared/messaging"
+)
+
+type tripConsumer struct {
+	bus     messaging.Bus
+	service domain.TripService
+}
+
+func NewTripConsumer(bus messaging.Bus, service domain.TripService) *tripConsumer {
+	return &tripConsumer{
+		bus:     bus,
+		service: service,
+	}
+}
+
+func (c *tripConsumer) Listen() error {
+	return messaging.Consume(c.bus, messaging.TripNoDriversFoundQueue, c.handleNoDriversFound, tripOrdered...)
+}
+
+// handleNoDriversFound closes a trip nobody picked up and releases the rider's card hold
+func (c *tripConsumer) handleNoDriversFound(ctx context.Context, message *contracts.Envelope, payload messaging.TripEventData) error {
+
+	if payload.Trip == nil {
+		log.Printf("Ignoring no drivers found event without a trip")
+		return nil
+	}
+
+	tripID := payload.Trip.Id
+	command, err := domain.NewOutboxMessage(contracts.PaymentCmdVoid, message.OwnerID, messaging.PaymentVoidData{
+		TripID: tripID,
+		Reason: "no drivers found",
+	})
+	if err != nil {
+		return err
+	}
+
+	// The void command is published once the update is stored
+	if err := c.service.UpdateTrip(ctx, tripID, domain.TripStatusNoDriversFound, nil, command); err != nil {
+		log.Printf("Failed to update the trip: %v", err)
+		return err
+	}
+
+	log.Printf("No drivers found for trip %s, releasing the card hold", tripID)
+
+	return nil
+}
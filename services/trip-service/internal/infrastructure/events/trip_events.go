@@ -0,0 +1,32 @@
+package events
+
+import (
+	"github.com/AuraReaper/voom/services/trip-service/internal/domain"
+	tripTypes "github.com/AuraReaper/voom/services/trip-service/pkg/types"
+	"github.com/AuraReaper/voom/shared/contracts"
+	"github.com/AuraReaper/voom/shared/messaging"
+)
+
+// TripEvents builds the outbox messages announcing trip changes, the outbox relay publishes them
+type TripEvents struct{}
+
+func NewTripEvents() *TripEvents {
+	return &TripEvents{}
+}
+
+func (e *TripEvents) TripCreated(trip *domain.TripModel) (*domain.OutboxMessageModel, error) {
+	return domain.NewOutboxMessage(contracts.TripEventCreated, trip.UserID, messaging.TripEventData{
+		Trip: trip.ToProto(),
+	})
+}
+
+// PaymentAuthorize asks the payment service to place a hold on the rider's card,
+// the trip is dispatched to drivers once the hold is authorized
+func (e *TripEvents) PaymentAuthorize(trip *domain.TripModel) (*domain.OutboxMessageModel, error) {
+	return domain.NewOutboxMessage(contracts.PaymentCmdAuthorize, trip.UserID, messaging.PaymentAuthorizeData{
+		TripID:   trip.ID.Hex(),
+		UserID:   trip.UserID,
+		Amount:   tripTypes.DefaultPricingConfig().HoldAmount(trip.RideFare.TotalPriceInINR),
+		Currency: "INR",
+	})
+}
@@ -2,10 +2,10 @@ package grpc
 
 import (
 	"context"
+	"errors"
 	"log"
 
 	"github.com/AuraReaper/voom/services/trip-service/internal/domain"
-	"github.com/AuraReaper/voom/services/trip-service/internal/infrastructure/events"
 	pb "github.com/AuraReaper/voom/shared/proto/trip"
 	"github.com/AuraReaper/voom/shared/types"
 	"google.golang.org/grpc"
@@ -15,14 +15,14 @@ import (
 
 type gRPCHandler struct {
 	pb.UnimplementedTripServiceServer
-	service   domain.TripService
-	publisher *events.TripEventPublisher
+	service  domain.TripService
+	receipts domain.ReceiptService
 }
 
-func NewGRPCHandler(server *grpc.Server, service domain.TripService, publisher *events.TripEventPublisher) *gRPCHandler {
+func NewGRPCHandler(server *grpc.Server, service domain.TripService, receipts domain.ReceiptService) *gRPCHandler {
 	handler := &gRPCHandler{
-		service:   service,
-		publisher: publisher,
+		service:  service,
+		receipts: receipts,
 	}
 
 	pb.RegisterTripServiceServer(server, handler)
@@ -51,6 +51,13 @@ func (h *gRPCHandler) PreviewTrip(ctx context.Context, req *pb.PreviewTripReques
 	}
 
 	estimatedFares := h.service.EstimatePackagesPriceWithRoute(route)
+	if code := req.GetPromoCode(); code != "" {
+		estimatedFares, err = h.service.ApplyPromoCode(ctx, estimatedFares, userID, code)
+		if err != nil {
+			return nil, promoStatus(err)
+		}
+	}
+
 	fares, err := h.service.GenerateTripFares(ctx, estimatedFares, userID, route)
 	if err != nil {
 		return nil, status.Errorf(codes.Internal, "failed to generate ride fates: %v", err)
@@ -65,21 +72,69 @@ func (h *gRPCHandler) PreviewTrip(ctx context.Context, req *pb.PreviewTripReques
 func (h *gRPCHandler) CreateTrip(ctx context.Context, req *pb.CreateTripRequest) (*pb.CreateTripResponse, error) {
 	fareID := req.GetRideFareID()
 	userID := req.GetUserID()
+
+	paymentMethod := req.GetPaymentMethod()
+	if paymentMethod == "" {
+		paymentMethod = domain.DefaultPaymentMethod
+	}
+	if !domain.IsValidPaymentMethod(paymentMethod) {
+		return nil, status.Errorf(codes.InvalidArgument, "unsupported payment method: %s", paymentMethod)
+	}
+
 	rideFare, err := h.service.GetAndValidateFare(ctx, fareID, userID)
 	if err != nil {
 		return nil, status.Errorf(codes.Internal, "failed to validate fare: %v", err)
 	}
 
-	trip, err := h.service.CreateTrip(ctx, rideFare)
+	// The trip created event or payment authorize command is stored with the trip and relayed afterwards
+	trip, err := h.service.CreateTrip(ctx, rideFare, paymentMethod)
 	if err != nil {
+		if domain.IsPromoError(err) {
+			return nil, promoStatus(err)
+		}
 		return nil, status.Errorf(codes.Internal, "failed to create trip: %v", err)
 	}
 
-	if err := h.publisher.PublishTripCreated(ctx, trip); err != nil {
-		return nil, status.Errorf(codes.Internal, "faied to publish the trip created event: %v", err)
-	}
-
 	return &pb.CreateTripResponse{
 		TripID: trip.ID.Hex(),
 	}, nil
 }
+
+func (h *gRPCHandler) GetReceipt(ctx context.Context, req *pb.GetReceiptRequest) (*pb.GetReceiptResponse, error) {
+	if req.GetTripID() == "" || req.GetUserID() == "" {
+		return nil, status.Error(codes.InvalidArgument, "tripID and userID are required")
+	}
+
+	format := req.GetFormat()
+	if format == "" {
+		format = domain.ReceiptFormatPDF
+	}
+
+	receipt, content, contentType, err := h.receipts.GetReceiptDocument(ctx, req.GetTripID(), req.GetUserID(), format)
+	if err != nil {
+		switch {
+		case errors.Is(err, domain.ErrInvalidReceiptFormat):
+			return nil, status.Errorf(codes.InvalidArgument, "%v", err)
+		case errors.Is(err, domain.ErrReceiptNotFound):
+			return nil, status.Errorf(codes.NotFound, "%v", err)
+		}
+		return nil, status.Errorf(codes.Internal, "failed to get receipt: %v", err)
+	}
+
+	return &pb.GetReceiptResponse{
+		Number:      receipt.Number,
+		ContentType: contentType,
+		Content:     content,
+	}, nil
+}
+
+// promoStatus converts a rejected promo code into the status returned to the rider
+func promoStatus(err error) error {
+	if errors.Is(err, domain.ErrPromoNotFound) {
+		return status.Errorf(codes.InvalidArgument, "%v", err)
+	}
+	if domain.IsPromoError(err) {
+		return status.Errorf(codes.FailedPrecondition, "%v", err)
+	}
+	return status.Errorf(codes.Internal, "failed to apply promo code: %v", err)
+}
@@ -0,0 +1,102 @@
+package receipt
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+)
+
+// A4 in points, the unit PDF coordinates are expressed in
+const (
+	pageWidth     = 595
+	pageHeight    = 842
+	pageMargin    = 56
+	bodyFontSize  = 11
+	bodyLeading   = 16
+	titleFontSize = 14
+	titleLeading  = 26
+)
+
+// writePDF lays out lines of text on as many A4 pages as they need. Lines starting with "# " are
+// set as headings. Only the standard Helvetica fonts are used, so nothing has to be embedded.
+func writePDF(lines []string) []byte {
+	var pages []string
+	var content strings.Builder
+
+	y := pageHeight - pageMargin
+	for _, line := range lines {
+		font, size, leading := "F1", bodyFontSize, bodyLeading
+		if text, ok := strings.CutPrefix(line, "# "); ok {
+			font, size, leading, line = "F2", titleFontSize, titleLeading, text
+		}
+
+		if y-leading < pageMargin {
+			pages = append(pages, content.String())
+			content.Reset()
+			y = pageHeight - pageMargin
+		}
+		y -= leading
+
+		if line != "" {
+			fmt.Fprintf(&content, "BT /%s %d Tf %d %d Td (%s) Tj ET\n", font, size, pageMargin, y, escapePDFText(line))
+		}
+	}
+	pages = append(pages, content.String())
+
+	// Objects 1 to 4 are the catalog, the page tree and the fonts, each page adds a page and a content stream
+	objects := []string{
+		"<< /Type /Catalog /Pages 2 0 R >>",
+		"", // the page tree, filled in once the page objects are numbered
+		"<< /Type /Font /Subtype /Type1 /BaseFont /Helvetica /Encoding /WinAnsiEncoding >>",
+		"<< /Type /Font /Subtype /Type1 /BaseFont /Helvetica-Bold /Encoding /WinAnsiEncoding >>",
+	}
+
+	kids := make([]string, 0, len(pages))
+	for _, page := range pages {
+		pageID := len(objects) + 1
+		kids = append(kids, fmt.Sprintf("%d 0 R", pageID))
+		objects = append(objects,
+			fmt.Sprintf(
+				"<< /Type /Page /Parent 2 0 R /MediaBox [0 0 %d %d] /Resources << /Font << /F1 3 0 R /F2 4 0 R >> >> /Contents %d 0 R >>",
+				pageWidth, pageHeight, pageID+1,
+			),
+			fmt.Sprintf("<< /Length %d >>\nstream\n%sendstream", len(page), page),
+		)
+	}
+	objects[1] = fmt.Sprintf("<< /Type /Pages /Kids [%s] /Count %d >>", strings.Join(kids, " "), len(pages))
+
+	var buf bytes.Buffer
+	buf.WriteString("%PDF-1.4\n")
+
+	offsets := make([]int, len(objects))
+	for i, object := range objects {
+		offsets[i] = buf.Len()
+		fmt.Fprintf(&buf, "%d 0 obj\n%s\nendobj\n", i+1, object)
+	}
+
+	xref := buf.Len()
+	fmt.Fprintf(&buf, "xref\n0 %d\n0000000000 65535 f \n", len(objects)+1)
+	for _, offset := range offsets {
+		fmt.Fprintf(&buf, "%010d 00000 n \n", offset)
+	}
+	fmt.Fprintf(&buf, "trailer\n<< /Size %d /Root 1 0 R >>\nstartxref\n%d\n%%%%EOF\n", len(objects)+1, xref)
+
+	return buf.Bytes()
+}
+
+// escapePDFText escapes a PDF string literal, characters the standard fonts can't show are replaced
+func escapePDFText(s string) string {
+	var b strings.Builder
+	for _, r := range s {
+		switch {
+		case r == '\\' || r == '(' || r == ')':
+			b.WriteByte('\\')
+			b.WriteRune(r)
+		case r < ' ' || r > '~':
+			b.WriteByte('?')
+		default:
+			b.WriteRune(r)
+		}
+	}
+	return b.String()
+}
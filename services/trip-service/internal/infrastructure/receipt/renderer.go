@@ -0,0 +1,73 @@
+package receipt
+
+import (
+	"bytes"
+	"embed"
+	"fmt"
+	htmltemplate "html/template"
+	"strings"
+	texttemplate "text/template"
+	"time"
+
+	"github.com/AuraReaper/voom/services/trip-service/internal/domain"
+	"github.com/AuraReaper/voom/shared/types"
+)
+
+//go:embed templates
+var templates embed.FS
+
+var funcs = map[string]any{
+	"inr": func(amount float64) string {
+		return fmt.Sprintf("INR %.2f", amount)
+	},
+	"date": func(t time.Time) string {
+		return t.UTC().Format("02 Jan 2006 15:04 MST")
+	},
+	"coord": func(c *types.Coordinate) string {
+		if c == nil {
+			return "-"
+		}
+		return fmt.Sprintf("%.5f, %.5f", c.Latitude, c.Longitude)
+	},
+}
+
+type renderer struct {
+	html *htmltemplate.Template
+	text *texttemplate.Template
+}
+
+// NewRenderer creates a renderer for the receipt templates, the PDF is laid out from the text template
+func NewRenderer() (domain.ReceiptRenderer, error) {
+	html, err := htmltemplate.New("receipt.html").Funcs(funcs).ParseFS(templates, "templates/receipt.html")
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse the HTML receipt template: %w", err)
+	}
+
+	text, err := texttemplate.New("receipt.txt").Funcs(funcs).ParseFS(templates, "templates/receipt.txt")
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse the text receipt template: %w", err)
+	}
+
+	return &renderer{
+		html: html,
+		text: text,
+	}, nil
+}
+
+func (r *renderer) RenderHTML(receipt *domain.ReceiptModel) ([]byte, error) {
+	var buf bytes.Buffer
+	if err := r.html.Execute(&buf, receipt); err != nil {
+		return nil, fmt.Errorf("failed to render the HTML receipt: %w", err)
+	}
+
+	return buf.Bytes(), nil
+}
+
+func (r *renderer) RenderPDF(receipt *domain.ReceiptModel) ([]byte, error) {
+	var buf bytes.Buffer
+	if err := r.text.Execute(&buf, receipt); err != nil {
+		return nil, fmt.Errorf("failed to render the PDF receipt: %w", err)
+	}
+
+	return writePDF(strings.Split(strings.TrimRight(buf.String(), "\n"), "\n")), nil
+}
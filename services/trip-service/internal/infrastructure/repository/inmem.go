@@ -3,36 +3,84 @@ package repository
 import (
 	"context"
 	"fmt"
+	"sync"
+	"time"
 
 	pbd "github.com/AuraReaper/voom/shared/proto/driver"
-	pb "github.com/AuraReaper/voom/shared/proto/trip"
 
 	"github.com/AuraReaper/voom/services/trip-service/internal/domain"
 )
 
 type inmemRepository struct {
-	trips     map[string]*domain.TripModel
-	rideFares map[string]*domain.RideFareModel
+	trips       map[string]*domain.TripModel
+	rideFares   map[string]*domain.RideFareModel
+	promoCodes  map[string]*domain.PromoCodeModel
+	redemptions []*domain.PromoRedemptionModel
+	receipts    map[string]*domain.ReceiptModel       // tripID -> receipt
+	outbox      []*domain.OutboxMessageModel          // in the order the messages were stored
+	outboxByID  map[string]*domain.OutboxMessageModel // the messages not sent yet
+	outboxSent  int                                   // sent messages in outbox, dropped by the next compaction
+	mu          sync.RWMutex
 }
 
 func NewInmemRepository() *inmemRepository {
 	return &inmemRepository{
-		trips:     make(map[string]*domain.TripModel),
-		rideFares: make(map[string]*domain.RideFareModel),
+		trips:      make(map[string]*domain.TripModel),
+		rideFares:  make(map[string]*domain.RideFareModel),
+		promoCodes: make(map[string]*domain.PromoCodeModel),
+		receipts:   make(map[string]*domain.ReceiptModel),
+		outboxByID: make(map[string]*domain.OutboxMessageModel),
 	}
 }
 
-func (r *inmemRepository) CreateTrip(ctx context.Context, trip *domain.TripModel) (*domain.TripModel, error) {
-	r.trips[trip.ID.Hex()] = trip
+func (r *inmemRepository) CreateTrip(ctx context.Context, trip *domain.TripModel, outbox ...*domain.OutboxMessageModel) (*domain.TripModel, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	// The usage limits are checked under the same lock the redemption is recorded with
+	if code := trip.RideFare.PromoCode; code != "" {
+		promo, ok := r.promoCodes[code]
+		if !ok {
+			return nil, domain.ErrPromoNotFound
+		}
+
+		total, byUser := r.countRedemptions(code, trip.UserID)
+		if (promo.MaxTotalUses > 0 && total >= promo.MaxTotalUses) ||
+			(promo.MaxUsesPerUser > 0 && byUser >= promo.MaxUsesPerUser) {
+			return nil, domain.ErrPromoUsageExceeded
+		}
+
+		if promo.FirstRideOnly && r.hasTrips(trip.UserID) {
+			return nil, domain.ErrPromoFirstRideOnly
+		}
+
+		r.redemptions = append(r.redemptions, &domain.PromoRedemptionModel{
+			Code:          code,
+			UserID:        trip.UserID,
+			TripID:        trip.ID.Hex(),
+			DiscountInINR: trip.RideFare.Discount(),
+			RedeemedAt:    time.Now(),
+		})
+	}
+
+	// The stored trip is only changed under the lock, callers get copies of it
+	r.trips[trip.ID.Hex()] = copyTrip(trip)
+	r.addOutboxMessages(outbox)
 	return trip, nil
 }
 
 func (r *inmemRepository) SaveRideFare(ctx context.Context, f *domain.RideFareModel) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
 	r.rideFares[f.ID.Hex()] = f
 	return nil
 }
 
 func (r *inmemRepository) GetRideFareByID(ctx context.Context, id string) (*domain.RideFareModel, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
 	fmt.Printf("searching for fare with id: %s\n", id)
 	fare, exsist := r.rideFares[id]
 	if !exsist {
@@ -43,14 +91,31 @@ func (r *inmemRepository) GetRideFareByID(ctx context.Context, id string) (*doma
 }
 
 func (r *inmemRepository) GetTripByID(ctx context.Context, id string) (*domain.TripModel, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
 	trip, ok := r.trips[id]
 	if !ok {
 		return nil, nil
 	}
-	return trip, nil
+	return copyTrip(trip), nil
+}
+
+// copyTrip copies a trip down to the split participants its update methods change in place
+func copyTrip(trip *domain.TripModel) *domain.TripModel {
+	c := *trip
+	c.SplitParticipants = make([]*domain.SplitParticipantModel, 0, len(trip.SplitParticipants))
+	for _, p := range trip.SplitParticipants {
+		participant := *p
+		c.SplitParticipants = append(c.SplitParticipants, &participant)
+	}
+	return &c
 }
 
-func (r *inmemRepository) UpdateTrip(ctx context.Context, tripID string, status string, driver *pbd.Driver) error {
+func (r *inmemRepository) UpdateTrip(ctx context.Context, tripID string, status string, driver *pbd.Driver, outbox ...*domain.OutboxMessageModel) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
 	trip, ok := r.trips[tripID]
 	if !ok {
 		return fmt.Errorf("trip not found with ID: %s", tripID)
@@ -59,12 +124,237 @@ func (r *inmemRepository) UpdateTrip(ctx context.Context, tripID string, status
 	trip.Status = status
 
 	if driver != nil {
-		trip.Driver = &pb.TripDriver{
-			Id:             driver.Id,
-			Name:           driver.Name,
-			VehicleNumber:       driver.CarPlate,
-			ProfilePicture: driver.ProfilePicture,
+		trip.Driver = domain.ToTripDriver(driver)
+	}
+
+	r.addOutboxMessages(outbox)
+	return nil
+}
+
+func (r *inmemRepository) HasTrips(ctx context.Context, userID string) (bool, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	return r.hasTrips(userID), nil
+}
+
+func (r *inmemRepository) AddSplitParticipants(ctx context.Context, tripID string, userIDs []string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	trip, ok := r.trips[tripID]
+	if !ok {
+		return fmt.Errorf("trip not found with ID: %s", tripID)
+	}
+
+	for _, userID := range userIDs {
+		if findSplitParticipant(trip, userID) == nil {
+			trip.SplitParticipants = append(trip.SplitParticipants, &domain.SplitParticipantModel{
+				UserID: userID,
+				Status: domain.SplitStatusInvited,
+			})
+		}
+	}
+	return nil
+}
+
+func (r *inmemRepository) UpdateSplitParticipant(ctx context.Context, tripID, userID, status string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	trip, ok := r.trips[tripID]
+	if !ok {
+		return fmt.Errorf("trip not found with ID: %s", tripID)
+	}
+
+	participant := findSplitParticipant(trip, userID)
+	if participant == nil {
+		return domain.ErrNotInvitedToSplit
+	}
+
+	participant.Status = status
+	return nil
+}
+
+func findSplitParticipant(trip *domain.TripModel, userID string) *domain.SplitParticipantModel {
+	for _, p := range trip.SplitParticipants {
+		if p.UserID == userID {
+			return p
+		}
+	}
+	return nil
+}
+
+func (r *inmemRepository) SavePromoCode(ctx context.Context, promo *domain.PromoCodeModel) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.promoCodes[promo.Code] = promo
+	return nil
+}
+
+func (r *inmemRepository) GetPromoCode(ctx context.Context, code string) (*domain.PromoCodeModel, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	promo, ok := r.promoCodes[code]
+	if !ok {
+		return nil, domain.ErrPromoNotFound
+	}
+
+	return promo, nil
+}
+
+func (r *inmemRepository) CountPromoRedemptions(ctx context.Context, code, userID string) (int, int, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	total, byUser := r.countRedemptions(code, userID)
+	return total, byUser, nil
+}
+
+func (r *inmemRepository) IssueReceipt(ctx context.Context, receipt *domain.ReceiptModel) (*domain.ReceiptModel, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if issued, ok := r.receipts[receipt.TripID]; ok {
+		return issued, nil
+	}
+
+	// Receipts are never deleted, so the next number is one more than the receipts issued so far
+	receipt.Sequence = int64(len(r.receipts)) + 1
+	receipt.Number = domain.ReceiptNumber(receipt.Sequence)
+	r.receipts[receipt.TripID] = receipt
+
+	return receipt, nil
+}
+
+func (r *inmemRepository) GetReceipt(ctx context.Context, tripID string) (*domain.ReceiptModel, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	receipt, ok := r.receipts[tripID]
+	if !ok {
+		return nil, domain.ErrReceiptNotFound
+	}
+
+	return receipt, nil
+}
+
+func (r *inmemRepository) AddOutboxMessages(ctx context.Context, messages ...*domain.OutboxMessageModel) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.addOutboxMessages(messages)
+	return nil
+}
+
+func (r *inmemRepository) addOutboxMessages(messages []*domain.OutboxMessageModel) {
+	r.outbox = append(r.outbox, messages...)
+	for _, msg := range messages {
+		r.outboxByID[msg.ID.Hex()] = msg
+	}
+}
+
+func (r *inmemRepository) ListPendingOutboxMessages(ctx context.Context, limit int) ([]*domain.OutboxMessageModel, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	var pending []*domain.OutboxMessageModel
+	for _, msg := range r.outbox {
+		if len(pending) == limit {
+			break
 		}
+		if msg.SentAt == nil && msg.ParkedAt == nil {
+			m := *msg
+			pending = append(pending, &m)
+		}
+	}
+
+	return pending, nil
+}
+
+func (r *inmemRepository) MarkOutboxMessageSent(ctx context.Context, id string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	msg, ok := r.outboxByID[id]
+	if !ok {
+		return fmt.Errorf("outbox message not found with ID: %s", id)
 	}
+
+	sentAt := time.Now()
+	msg.SentAt = &sentAt
+	delete(r.outboxByID, id)
+
+	// Sent messages are dropped once they make up half of the outbox
+	r.outboxSent++
+	if r.outboxSent > len(r.outbox)/2 {
+		r.compactOutbox()
+	}
+	return nil
+}
+
+// compactOutbox drops the sent messages, keeping the order of the others
+func (r *inmemRepository) compactOutbox() {
+	outbox := make([]*domain.OutboxMessageModel, 0, len(r.outbox)-r.outboxSent)
+	for _, msg := range r.outbox {
+		if msg.SentAt == nil {
+			outbox = append(outbox, msg)
+		}
+	}
+	r.outbox = outbox
+	r.outboxSent = 0
+}
+
+func (r *inmemRepository) MarkOutboxMessageFailed(ctx context.Context, id string, cause error) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	msg, ok := r.outboxByID[id]
+	if !ok {
+		return fmt.Errorf("outbox message not found with ID: %s", id)
+	}
+
+	msg.Attempts++
+	msg.LastError = cause.Error()
+	return nil
+}
+
+func (r *inmemRepository) ParkOutboxMessage(ctx context.Context, id string, cause error) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	msg, ok := r.outboxByID[id]
+	if !ok {
+		return fmt.Errorf("outbox message not found with ID: %s", id)
+	}
+
+	parkedAt := time.Now()
+	msg.Attempts++
+	msg.LastError = cause.Error()
+	msg.ParkedAt = &parkedAt
 	return nil
 }
+
+func (r *inmemRepository) countRedemptions(code, userID string) (total int, byUser int) {
+	for _, redemption := range r.redemptions {
+		if redemption.Code != code {
+			continue
+		}
+		total++
+		if redemption.UserID == userID {
+			byUser++
+		}
+	}
+	return total, byUser
+}
+
+func (r *inmemRepository) hasTrips(userID string) bool {
+	for _, trip := range r.trips {
+		if trip.UserID == userID {
+			return true
+		}
+	}
+	return false
+}
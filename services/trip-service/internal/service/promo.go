@@ -0,0 +1,114 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"time"
+
+	"github.com/AuraReaper/voom/services/trip-service/internal/domain"
+)
+
+// ApplyPromoCode validates a promo code for the rider and takes its discount off every fare of a
+// package it applies to. The discount is added as a negative line item, the code itself is only
+// redeemed once one of the fares is booked.
+func (s *TripService) ApplyPromoCode(ctx context.Context, fares []*domain.RideFareModel, userID, code string) ([]*domain.RideFareModel, error) {
+	promo, err := s.validatePromoCode(ctx, domain.NormalizePromoCode(code), userID)
+	if err != nil {
+		return nil, err
+	}
+
+	applied := false
+	for _, fare := range fares {
+		if !promo.AppliesTo(fare.PackageSlug) {
+			continue
+		}
+
+		discount := promo.Discount(fare.TotalPriceInINR)
+		if discount <= 0 {
+			continue
+		}
+
+		fare.LineItems = append(fare.LineItems, &domain.FareLineItemModel{
+			Type:        domain.FareLineItemPromo,
+			Description: fmt.Sprintf("Promo %s", promo.Code),
+			AmountInINR: -discount,
+		})
+		fare.TotalPriceInINR = math.Round((fare.TotalPriceInINR-discount)*100) / 100
+		fare.PromoCode = promo.Code
+		applied = true
+	}
+
+	if !applied {
+		return nil, domain.ErrPromoNotApplicable
+	}
+
+	return fares, nil
+}
+
+// validatePromoCode checks the rules of a code for the rider, the usage limits are only checked
+// here to reject the code early, they are enforced when the code is redeemed
+func (s *TripService) validatePromoCode(ctx context.Context, code, userID string) (*domain.PromoCodeModel, error) {
+	promo, err := s.repo.GetPromoCode(ctx, code)
+	if err != nil {
+		return nil, err
+	}
+
+	if !promo.IsActive(time.Now()) {
+		return nil, domain.ErrPromoExpired
+	}
+
+	total, byUser, err := s.repo.CountPromoRedemptions(ctx, promo.Code, userID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to count redemptions of %s: %w", promo.Code, err)
+	}
+
+	if (promo.MaxTotalUses > 0 && total >= promo.MaxTotalUses) ||
+		(promo.MaxUsesPerUser > 0 && byUser >= promo.MaxUsesPerUser) {
+		return nil, domain.ErrPromoUsageExceeded
+	}
+
+	if promo.FirstRideOnly {
+		hasTrips, err := s.repo.HasTrips(ctx, userID)
+		if err != nil {
+			return nil, fmt.Errorf("failed to get trips of rider %s: %w", userID, err)
+		}
+		if hasTrips {
+			return nil, domain.ErrPromoFirstRideOnly
+		}
+	}
+
+	return promo, nil
+}
+
+// SeedPromoCodes stores the promo codes the service starts with
+func (s *TripService) SeedPromoCodes(ctx context.Context) error {
+	for _, promo := range getPromoCodes() {
+		if err := s.repo.SavePromoCode(ctx, promo); err != nil {
+			return fmt.Errorf("failed to save promo code %s: %w", promo.Code, err)
+		}
+	}
+
+	return nil
+}
+
+func getPromoCodes() []*domain.PromoCodeModel {
+	return []*domain.PromoCodeModel{
+		{
+			Code:             "WELCOME50",
+			DiscountType:     domain.PromoDiscountPercentage,
+			Value:            50,
+			MaxDiscountInINR: 150,
+			FirstRideOnly:    true,
+			MaxUsesPerUser:   1,
+		},
+		{
+			Code:           "FLAT100",
+			DiscountType:   domain.PromoDiscountFlat,
+			Value:          100,
+			MaxUsesPerUser: 3,
+			MaxTotalUses:   1000,
+			PackageSlugs:   []string{"suv", "van", "luxury"},
+		},
+	}
+}
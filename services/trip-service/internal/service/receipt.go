@@ -0,0 +1,155 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"time"
+
+	"github.com/AuraReaper/voom/services/trip-service/internal/domain"
+	"github.com/AuraReaper/voom/shared/types"
+)
+
+type ReceiptService struct {
+	repo       domain.TripRepository
+	store      domain.BlobStore
+	renderer   domain.ReceiptRenderer
+	taxPercent float64 // included in the fares, only broken out on the receipt
+}
+
+func NewReceiptService(repo domain.TripRepository, store domain.BlobStore, renderer domain.ReceiptRenderer, taxPercent float64) *ReceiptService {
+	return &ReceiptService{
+		repo:       repo,
+		store:      store,
+		renderer:   renderer,
+		taxPercent: taxPercent,
+	}
+}
+
+func (s *ReceiptService) IssueReceipt(ctx context.Context, tripID string) (*domain.ReceiptModel, error) {
+	receipt, err := s.repo.GetReceipt(ctx, tripID)
+	if errors.Is(err, domain.ErrReceiptNotFound) {
+		trip, err := s.repo.GetTripByID(ctx, tripID)
+		if err != nil {
+			return nil, err
+		}
+		if trip == nil {
+			return nil, domain.ErrTripNotFound
+		}
+
+		receipt, err = s.repo.IssueReceipt(ctx, s.newReceipt(trip))
+		if err != nil {
+			return nil, fmt.Errorf("failed to issue the receipt of trip %s: %w", tripID, err)
+		}
+	} else if err != nil {
+		return nil, err
+	}
+
+	// Documents are rendered from the stored snapshot, storing them again after a failure gives the same files
+	for format := range domain.ReceiptContentTypes {
+		if _, err := s.storeDocument(ctx, receipt, format); err != nil {
+			return nil, err
+		}
+	}
+
+	return receipt, nil
+}
+
+func (s *ReceiptService) GetReceiptDocument(ctx context.Context, tripID, userID, format string) (*domain.ReceiptModel, []byte, string, error) {
+	contentType, ok := domain.ReceiptContentTypes[format]
+	if !ok {
+		return nil, nil, "", fmt.Errorf("%w: %s", domain.ErrInvalidReceiptFormat, format)
+	}
+
+	receipt, err := s.repo.GetReceipt(ctx, tripID)
+	if err != nil {
+		return nil, nil, "", err
+	}
+
+	// Other riders' receipts are reported as missing rather than forbidden
+	if receipt.UserID != userID {
+		return nil, nil, "", domain.ErrReceiptNotFound
+	}
+
+	data, err := s.store.Get(ctx, receipt.BlobKey(format))
+	if errors.Is(err, domain.ErrBlobNotFound) {
+		data, err = s.storeDocument(ctx, receipt, format)
+	}
+	if err != nil {
+		return nil, nil, "", err
+	}
+
+	return receipt, data, contentType, nil
+}
+
+func (s *ReceiptService) storeDocument(ctx context.Context, receipt *domain.ReceiptModel, format string) ([]byte, error) {
+	var (
+		data []byte
+		err  error
+	)
+
+	switch format {
+	case domain.ReceiptFormatHTML:
+		data, err = s.renderer.RenderHTML(receipt)
+	case domain.ReceiptFormatPDF:
+		data, err = s.renderer.RenderPDF(receipt)
+	default:
+		err = fmt.Errorf("%w: %s", domain.ErrInvalidReceiptFormat, format)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if err := s.store.Put(ctx, receipt.BlobKey(format), data); err != nil {
+		return nil, fmt.Errorf("failed to store receipt %s: %w", receipt.Number, err)
+	}
+
+	return data, nil
+}
+
+// newReceipt takes the snapshot of a paid trip a receipt is rendered from
+func (s *ReceiptService) newReceipt(trip *domain.TripModel) *domain.ReceiptModel {
+	fare := trip.RideFare
+	total := fare.TotalPriceInINR
+	tax := math.Round(total*s.taxPercent/(100+s.taxPercent)*100) / 100
+
+	receipt := &domain.ReceiptModel{
+		TripID:        trip.ID.Hex(),
+		UserID:        trip.UserID,
+		IssuedAt:      time.Now(),
+		PackageSlug:   fare.PackageSlug,
+		LineItems:     fare.LineItems,
+		SubtotalInINR: math.Round((total-tax)*100) / 100,
+		TaxPercent:    s.taxPercent,
+		TaxInINR:      tax,
+		TotalInINR:    total,
+		PaymentMethod: trip.PaymentMethod,
+	}
+
+	if len(receipt.LineItems) == 0 {
+		receipt.LineItems = []*domain.FareLineItemModel{
+			{Type: domain.FareLineItemBase, Description: "Ride fare", AmountInINR: total},
+		}
+	}
+
+	if fare.Route != nil && len(fare.Route.Route) > 0 {
+		route := fare.Route.ToProto()
+		receipt.DistanceInKm = route.Distance / 1000
+		receipt.DurationInMinutes = route.Duration / 60
+
+		if len(route.Geometry) > 0 && len(route.Geometry[0].Coordinates) > 0 {
+			coordinates := route.Geometry[0].Coordinates
+			first, last := coordinates[0], coordinates[len(coordinates)-1]
+			receipt.Pickup = &types.Coordinate{Latitude: first.Latitude, Longitude: first.Longitude}
+			receipt.Dropoff = &types.Coordinate{Latitude: last.Latitude, Longitude: last.Longitude}
+		}
+	}
+
+	if trip.Driver != nil {
+		receipt.DriverName = trip.Driver.Name
+		receipt.VehicleNumber = trip.Driver.VehicleNumber
+	}
+
+	return receipt
+}
@@ -6,6 +6,7 @@ import (
 	"fmt"
 	"io"
 	"net/http"
+	"time"
 
 	"github.com/AuraReaper/voom/services/trip-service/internal/domain"
 	tripTypes "github.com/AuraReaper/voom/services/trip-service/pkg/types"
@@ -16,25 +17,57 @@ import (
 )
 
 type TripService struct {
-	repo domain.TripRepository
+	repo   domain.TripRepository
+	events domain.TripEventFactory
 }
 
-func NewTripService(repo domain.TripRepository) *TripService {
+func NewTripService(repo domain.TripRepository, events domain.TripEventFactory) *TripService {
 	return &TripService{
-		repo: repo,
+		repo:   repo,
+		events: events,
 	}
 }
 
-func (s *TripService) CreateTrip(ctx context.Context, fare *domain.RideFareModel) (*domain.TripModel, error) {
+func (s *TripService) CreateTrip(ctx context.Context, fare *domain.RideFareModel, paymentMethod string) (*domain.TripModel, error) {
+	// The code may have expired since the preview, its usage is checked again when the trip is stored
+	if fare.PromoCode != "" {
+		promo, err := s.repo.GetPromoCode(ctx, fare.PromoCode)
+		if err != nil {
+			return nil, err
+		}
+		if !promo.IsActive(time.Now()) {
+			return nil, domain.ErrPromoExpired
+		}
+	}
+
+	// Cash trips have no card hold to wait for
+	status := domain.TripStatusAwaitingAuthorization
+	if paymentMethod == domain.PaymentMethodCash {
+		status = domain.TripStatusPending
+	}
+
 	t := &domain.TripModel{
-		ID:       primitive.NewObjectID(),
-		UserID:   fare.UserID,
-		Status:   "pending",
-		RideFare: fare,
-		Driver:   &trip.TripDriver{},
+		ID:            primitive.NewObjectID(),
+		UserID:        fare.UserID,
+		Status:        status,
+		RideFare:      fare,
+		Driver:        &trip.TripDriver{},
+		PaymentMethod: paymentMethod,
 	}
 
-	return s.repo.CreateTrip(ctx, t)
+	// Drivers are only looked for once the card hold is authorized
+	var event *domain.OutboxMessageModel
+	var err error
+	if paymentMethod == domain.PaymentMethodCash {
+		event, err = s.events.TripCreated(t)
+	} else {
+		event, err = s.events.PaymentAuthorize(t)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return s.repo.CreateTrip(ctx, t, event)
 }
 
 func (s *TripService) GetRoute(ctx context.Context, pickup, destination *types.Coordinate) (*tripTypes.OsrmApiResponse, error) {
@@ -86,6 +119,8 @@ func (s *TripService) GenerateTripFares(ctx context.Context, rideFares []*domain
 			PackageSlug:     f.PackageSlug,
 			TotalPriceInINR: f.TotalPriceInINR,
 			Route:           route,
+			LineItems:       f.LineItems,
+			PromoCode:       f.PromoCode,
 		}
 
 		if err := s.repo.SaveRideFare(ctx, fare); err != nil {
@@ -130,6 +165,9 @@ func estimateRouteFare(f *domain.RideFareModel, route *tripTypes.OsrmApiResponse
 	return &domain.RideFareModel{
 		TotalPriceInINR: totalFare,
 		PackageSlug:     f.PackageSlug,
+		LineItems: []*domain.FareLineItemModel{
+			{Type: domain.FareLineItemBase, Description: "Ride fare", AmountInINR: totalFare},
+		},
 	}
 }
 
@@ -158,6 +196,10 @@ func (s *TripService) GetTripByID(ctx context.Context, id string) (*domain.TripM
 	return s.repo.GetTripByID(ctx, id)
 }
 
-func (s *TripService) UpdateTrip(ctx context.Context, tripID string, status string, driver *pbd.Driver) error {
-	return s.repo.UpdateTrip(ctx, tripID, status, driver)
+func (s *TripService) UpdateTrip(ctx context.Context, tripID string, status string, driver *pbd.Driver, outbox ...*domain.OutboxMessageModel) error {
+	return s.repo.UpdateTrip(ctx, tripID, status, driver, outbox...)
+}
+
+func (s *TripService) AddOutboxMessages(ctx context.Context, messages ...*domain.OutboxMessageModel) error {
+	return s.repo.AddOutboxMessages(ctx, messages...)
 }
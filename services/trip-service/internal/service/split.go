@@ -0,0 +1,84 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"slices"
+
+	"github.com/AuraReaper/voom/services/trip-service/internal/domain"
+)
+
+// InviteToSplit invites riders to share the fare of a trip with its owner. It returns the updated
+// trip along with the riders who were newly invited.
+func (s *TripService) InviteToSplit(ctx context.Context, tripID, ownerID string, userIDs []string) (*domain.TripModel, []string, error) {
+	trip, err := s.getSplittableTrip(ctx, tripID)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	if trip.UserID != ownerID {
+		return nil, nil, domain.ErrNotTripOwner
+	}
+
+	var invited []string
+	for _, userID := range userIDs {
+		if userID == "" || userID == ownerID || slices.Contains(invited, userID) {
+			continue
+		}
+		if slices.ContainsFunc(trip.SplitParticipants, func(p *domain.SplitParticipantModel) bool { return p.UserID == userID }) {
+			continue
+		}
+		invited = append(invited, userID)
+	}
+
+	if len(trip.SplitParticipants)+len(invited) > domain.MaxSplitParticipants {
+		return nil, nil, fmt.Errorf("%w: at most %d", domain.ErrTooManySplitRiders, domain.MaxSplitParticipants)
+	}
+
+	if err := s.repo.AddSplitParticipants(ctx, tripID, invited); err != nil {
+		return nil, nil, err
+	}
+
+	trip, err = s.repo.GetTripByID(ctx, tripID)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return trip, invited, nil
+}
+
+// RespondToSplit records whether an invited rider shares the fare of a trip
+func (s *TripService) RespondToSplit(ctx context.Context, tripID, userID string, accept bool) (*domain.TripModel, error) {
+	if _, err := s.getSplittableTrip(ctx, tripID); err != nil {
+		return nil, err
+	}
+
+	status := domain.SplitStatusDeclined
+	if accept {
+		status = domain.SplitStatusAccepted
+	}
+
+	if err := s.repo.UpdateSplitParticipant(ctx, tripID, userID, status); err != nil {
+		return nil, err
+	}
+
+	return s.repo.GetTripByID(ctx, tripID)
+}
+
+// getSplittableTrip returns a trip whose fare split can still change
+func (s *TripService) getSplittableTrip(ctx context.Context, tripID string) (*domain.TripModel, error) {
+	trip, err := s.repo.GetTripByID(ctx, tripID)
+	if err != nil {
+		return nil, err
+	}
+
+	if trip == nil {
+		return nil, domain.ErrTripNotFound
+	}
+
+	if !trip.CanBeSplit() {
+		return nil, fmt.Errorf("%w: %s trip in status %s", domain.ErrSplitNotAllowed, trip.PaymentMethod, trip.Status)
+	}
+
+	return trip, nil
+}
@@ -0,0 +1,67 @@
+package topology
+
+import (
+	"github.com/AuraReaper/voom/shared/contracts"
+	"github.com/AuraReaper/voom/shared/messaging"
+)
+
+// Topology is the part of the trip exchange the trip service owns
+var Topology = messaging.ServiceTopology{
+	Service: messaging.TripService,
+	Queues: []messaging.QueueBinding{
+		{
+			Queue: messaging.DriverTripResponseQueue,
+			RoutingKeys: []string{
+				contracts.DriverCmdTripAccept,
+				contracts.DriverCmdTripDecline,
+				contracts.DriverCmdTripComplete,
+				contracts.DriverCmdCashCollected,
+			},
+		},
+		{
+			Queue: messaging.NotifyPaymentSuccessQueue,
+			RoutingKeys: []string{
+				contracts.PaymentEventSuccess,
+			},
+		},
+		{
+			Queue: messaging.NotifyPaymentFailedQueue,
+			RoutingKeys: []string{
+				contracts.PaymentEventFailed,
+				contracts.PaymentEventCancelled,
+				contracts.PaymentEventDunning,
+			},
+		},
+		{
+			Queue: messaging.NotifyPaymentAuthorizedQueue,
+			RoutingKeys: []string{
+				contracts.PaymentEventAuthorized,
+			},
+		},
+		{
+			Queue: messaging.TripSplitQueue,
+			RoutingKeys: []string{
+				contracts.RiderCmdSplitInvite,
+				contracts.RiderCmdSplitRespond,
+			},
+		},
+		{
+			Queue: messaging.TripNoDriversFoundQueue,
+			RoutingKeys: []string{
+				contracts.TripEventNoDriversFound,
+			},
+		},
+	},
+	Produces: []string{
+		contracts.TripEventCreated,
+		contracts.TripEventDriverAssigned,
+		contracts.TripEventDriverNotInterested,
+		contracts.TripEventCompleted,
+		contracts.TripEventSplitInvited,
+		contracts.TripEventSplitUpdated,
+		contracts.PaymentCmdAuthorize,
+		contracts.PaymentCmdCapture,
+		contracts.PaymentCmdVoid,
+		contracts.PaymentCmdRecordCash,
+	},
+}
@@ -1,6 +1,9 @@
 package types
 
 import (
+	"math"
+
+	"github.com/AuraReaper/voom/shared/env"
 	pb "github.com/AuraReaper/voom/shared/proto/trip"
 )
 
@@ -43,11 +46,20 @@ func (o *OsrmApiResponse) ToProto() *pb.Route {
 type PricingConfig struct {
 	PricePerKm     float64
 	PricePerMinute float64
+	// HoldBufferPercent is added on top of the fare when placing the card hold at booking,
+	// it covers a final fare that ends up higher than the estimate
+	HoldBufferPercent float64
 }
 
 func DefaultPricingConfig() *PricingConfig {
 	return &PricingConfig{
-		PricePerKm:     12,
-		PricePerMinute: 1,
+		PricePerKm:        12,
+		PricePerMinute:    1,
+		HoldBufferPercent: float64(env.GetInt("PAYMENT_HOLD_BUFFER_PERCENT", 20)),
 	}
 }
+
+// HoldAmount returns the amount to authorize on the rider's card for a fare
+func (c *PricingConfig) HoldAmount(fare float64) float64 {
+	return math.Round(fare*(1+c.HoldBufferPercent/100)*100) / 100
+}
@@ -1,11 +1,26 @@
 package contracts
 
+import "time"
+
 // AmqpMessage is the message structure for AMQP.
 type AmqpMessage struct {
 	OwnerID string `json:"ownerId"`
 	Data    []byte `json:"data"`
 }
 
+// Envelope is the versioned message structure for AMQP. It carries the fields of AmqpMessage
+// under the same names, so consumers still reading AmqpMessage understand it.
+type Envelope struct {
+	EventID       string    `json:"eventId,omitempty"`
+	Type          string    `json:"type,omitempty"`          // the routing key the message was published with
+	SchemaVersion int       `json:"schemaVersion,omitempty"` // version of the payload schema of Type
+	OccurredAt    time.Time `json:"occurredAt,omitempty"`
+	Producer      string    `json:"producer,omitempty"`      // service that published the message
+	CorrelationID string    `json:"correlationId,omitempty"` // shared by the messages one request caused
+	OwnerID       string    `json:"ownerId"`
+	Data          []byte    `json:"data"`
+}
+
 // Routing keys - using consistent event/command patterns
 const (
 	// Trip events (trip.event.*)
@@ -13,20 +28,39 @@ const (
 	TripEventDriverAssigned      = "trip.event.driver_assigned"
 	TripEventNoDriversFound      = "trip.event.no_drivers_found"
 	TripEventDriverNotInterested = "trip.event.driver_not_interested"
+	TripEventCompleted           = "trip.event.completed"
+	TripEventSplitInvited        = "trip.event.split_invited"
+	TripEventSplitUpdated        = "trip.event.split_updated"
 
 	// Driver commands (driver.cmd.*)
-	DriverCmdTripRequest = "driver.cmd.trip_request"
-	DriverCmdTripAccept  = "driver.cmd.trip_accept"
-	DriverCmdTripDecline = "driver.cmd.trip_decline"
-	DriverCmdLocation    = "driver.cmd.location"
-	DriverCmdRegister    = "driver.cmd.register"
+	DriverCmdTripRequest   = "driver.cmd.trip_request"
+	DriverCmdTripAccept    = "driver.cmd.trip_accept"
+	DriverCmdTripDecline   = "driver.cmd.trip_decline"
+	DriverCmdTripComplete  = "driver.cmd.trip_complete"
+	DriverCmdCashCollected = "driver.cmd.cash_collected"
+	DriverCmdLocation      = "driver.cmd.location"
+	DriverCmdRegister      = "driver.cmd.register"
+
+	// Rider commands (rider.cmd.*)
+	RiderCmdTip          = "rider.cmd.tip"
+	RiderCmdSplitInvite  = "rider.cmd.split_invite"
+	RiderCmdSplitRespond = "rider.cmd.split_respond"
 
 	// Payment events (payment.event.*)
 	PaymentEventSessionCreated = "payment.event.session_created"
 	PaymentEventSuccess        = "payment.event.success"
 	PaymentEventFailed         = "payment.event.failed"
 	PaymentEventCancelled      = "payment.event.cancelled"
+	PaymentEventRetry          = "payment.event.retry"
+	PaymentEventDunning        = "payment.event.dunning"
+	PaymentEventRefunded       = "payment.event.refunded"
+	PaymentEventAuthorized     = "payment.event.authorized"
+	PaymentEventTipReceived    = "payment.event.tip_received"
 
 	// Payment commands (payment.cmd.*)
 	PaymentCmdCreateSession = "payment.cmd.create_session"
+	PaymentCmdAuthorize     = "payment.cmd.authorize"
+	PaymentCmdCapture       = "payment.cmd.capture"
+	PaymentCmdVoid          = "payment.cmd.void"
+	PaymentCmdRecordCash    = "payment.cmd.record_cash"
 )
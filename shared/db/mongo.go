@@ -0,0 +1,55 @@
+/*
+Package db provides helpers to connect services to MongoDB.
+*/
+package db
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/AuraReaper/voom/shared/env"
+
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+type MongoConfig struct {
+	URI      string
+	Database string
+}
+
+// NewMongoDefaultConfig reads the MongoDB configuration from the environment.
+// An empty URI means the service should fall back to in-memory storage.
+func NewMongoDefaultConfig() *MongoConfig {
+	return &MongoConfig{
+		URI:      env.GetString("MONGODB_URI", ""),
+		Database: env.GetString("MONGODB_DATABASE", "voom"),
+	}
+}
+
+// NewMongoClient connects to MongoDB and verifies the connection with a ping
+func NewMongoClient(ctx context.Context, cfg *MongoConfig) (*mongo.Client, error) {
+	if cfg.URI == "" {
+		return nil, fmt.Errorf("mongodb URI is required")
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, 10*time.Second)
+	defer cancel()
+
+	client, err := mongo.Connect(ctx, options.Client().ApplyURI(cfg.URI))
+	if err != nil {
+		return nil, fmt.Errorf("failed to connect to mongodb: %w", err)
+	}
+
+	if err := client.Ping(ctx, nil); err != nil {
+		_ = client.Disconnect(context.Background())
+		return nil, fmt.Errorf("failed to ping mongodb: %w", err)
+	}
+
+	return client, nil
+}
+
+func GetDatabase(client *mongo.Client, cfg *MongoConfig) *mongo.Database {
+	return client.Database(cfg.Database)
+}
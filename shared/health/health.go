@@ -0,0 +1,30 @@
+/*
+Package health reports the health of the gRPC services through the standard gRPC health protocol.
+*/
+package health
+
+import (
+	"github.com/AuraReaper/voom/shared/messaging"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/health"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+)
+
+// RegisterGRPC registers the health service on server. The service reports NOT_SERVING while
+// the broker connection is down and SERVING once it is back.
+func RegisterGRPC(server *grpc.Server, rabbitmq *messaging.RabbitMQ) *health.Server {
+	srv := health.NewServer()
+	healthpb.RegisterHealthServer(server, srv)
+
+	rabbitmq.NotifyConnectionState(func(connected bool) {
+		status := healthpb.HealthCheckResponse_NOT_SERVING
+		if connected {
+			status = healthpb.HealthCheckResponse_SERVING
+		}
+
+		// The empty service name is the overall health of the server
+		srv.SetServingStatus("", status)
+	})
+
+	return srv
+}
@@ -0,0 +1,270 @@
+/*
+Package lifecycle runs the servers, message consumers and background workers of a service and
+shuts them down in order when the service is asked to stop.
+
+On SIGINT or SIGTERM, or once a server fails, the manager:
+
+ 1. stops the servers from taking new requests and lets the ones in progress finish,
+ 2. stops the consumers from taking new deliveries and waits for the messages being handled,
+    requeueing the ones still running at the deadline,
+ 3. cancels the background workers and waits for them,
+ 4. flushes the tracer and the meter,
+ 5. closes the broker connection and the other resources, the last registered first.
+*/
+package lifecycle
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"net"
+	"net/http"
+	"os"
+	"os/signal"
+	"sync"
+	"syscall"
+	"time"
+
+	"google.golang.org/grpc"
+)
+
+// CloseTimeout bounds flushing the tracer and the meter and closing the resources, once the servers, consumers
+// and workers are stopped
+const CloseTimeout = 5 * time.Second
+
+// Broker is a broker connection whose consumers are drained before it is closed
+type Broker interface {
+	StopConsumers(ctx context.Context) error
+	Close()
+}
+
+// Manager shuts a service down in order, see the package documentation
+type Manager struct {
+	timeout time.Duration
+
+	ctx    context.Context // cancelled once the shutdown starts
+	cancel context.CancelFunc
+
+	workersCtx    context.Context // cancelled once the consumers are stopped
+	cancelWorkers context.CancelFunc
+	workers       sync.WaitGroup
+
+	mu        sync.Mutex
+	servers   []stopper
+	consumers []stopper
+	tracer    func(context.Context) error
+	meter     func(context.Context) error
+	closers   []stopper
+
+	failed       chan error
+	shutdownOnce sync.Once
+	shutdownErr  error
+}
+
+type stopper struct {
+	name string
+	stop func(context.Context) error
+}
+
+// New returns a manager giving the servers and consumers up to timeout to finish their work
+func New(timeout time.Duration) *Manager {
+	ctx, cancel := context.WithCancel(context.Background())
+	workersCtx, cancelWorkers := context.WithCancel(context.Background())
+
+	return &Manager{
+		timeout:       timeout,
+		ctx:           ctx,
+		cancel:        cancel,
+		workersCtx:    workersCtx,
+		cancelWorkers: cancelWorkers,
+		failed:        make(chan error, 1),
+	}
+}
+
+// Context returns a context cancelled once the shutdown starts
+func (m *Manager) Context() context.Context {
+	return m.ctx
+}
+
+// ServeGRPC serves server on lis. On shutdown the server stops taking new calls and the ones in
+// progress are given until the deadline, then cut.
+func (m *Manager) ServeGRPC(name string, server *grpc.Server, lis net.Listener) {
+	m.addServer(name, func(ctx context.Context) error {
+		done := make(chan struct{})
+		go func() {
+			server.GracefulStop()
+			close(done)
+		}()
+
+		select {
+		case <-done:
+			return nil
+		case <-ctx.Done():
+			server.Stop()
+			return fmt.Errorf("calls still in progress: %w", ctx.Err())
+		}
+	})
+
+	go func() {
+		if err := server.Serve(lis); err != nil {
+			m.fail(fmt.Errorf("%s failed to serve: %v", name, err))
+		}
+	}()
+}
+
+// ServeHTTP runs start, which serves until shutdown is called. On shutdown the server stops taking
+// new requests and the ones in progress are given until the deadline.
+func (m *Manager) ServeHTTP(name string, start func() error, shutdown func(context.Context) error) {
+	m.addServer(name, shutdown)
+
+	go func() {
+		if err := start(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			m.fail(fmt.Errorf("%s failed to serve: %v", name, err))
+		}
+	}()
+}
+
+func (m *Manager) addServer(name string, stop func(context.Context) error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.servers = append(m.servers, stopper{name: name, stop: stop})
+}
+
+// RegisterBroker drains the consumers of broker once the servers are stopped, and closes it last
+func (m *Manager) RegisterBroker(name string, broker Broker) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.consumers = append(m.consumers, stopper{name: name, stop: broker.StopConsumers})
+	m.closers = append(m.closers, stopper{name: name, stop: func(context.Context) error {
+		broker.Close()
+		return nil
+	}})
+}
+
+// RegisterTracer flushes the spans through shutdown once the work of the service is done
+func (m *Manager) RegisterTracer(shutdown func(context.Context) error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.tracer = shutdown
+}
+
+// RegisterMeter exports the metrics collected since the last interval through shutdown, along
+// with the spans
+func (m *Manager) RegisterMeter(shutdown func(context.Context) error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.meter = shutdown
+}
+
+// OnClose closes a resource, like a database connection, after the tracer is flushed. Resources
+// are closed the last registered first.
+func (m *Manager) OnClose(name string, close func(context.Context) error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.closers = append(m.closers, stopper{name: name, stop: close})
+}
+
+// Go runs a background worker. Its context is cancelled once the consumers are stopped, so the
+// work they left behind is still picked up, and the shutdown waits for run to return.
+func (m *Manager) Go(name string, run func(ctx context.Context)) {
+	m.workers.Add(1)
+	go func() {
+		defer m.workers.Done()
+		run(m.workersCtx)
+		log.Printf("%s stopped", name)
+	}()
+}
+
+func (m *Manager) fail(err error) {
+	select {
+	case m.failed <- err:
+	default:
+	}
+}
+
+// Wait blocks until SIGINT or SIGTERM is received or a server fails, then shuts the service down
+func (m *Manager) Wait() error {
+	sigCh := make(chan os.Signal, 1)
+	signal.Notify(sigCh, os.Interrupt, syscall.SIGTERM)
+	defer signal.Stop(sigCh)
+
+	select {
+	case sig := <-sigCh:
+		log.Printf("Received %v, shutting down", sig)
+	case err := <-m.failed:
+		log.Printf("Shutting down: %v", err)
+	case <-m.ctx.Done():
+	}
+
+	return m.Shutdown()
+}
+
+// Shutdown stops the service in order and returns what failed to stop cleanly. Calling it again
+// returns the result of the first call.
+func (m *Manager) Shutdown() error {
+	m.shutdownOnce.Do(func() {
+		m.shutdownErr = m.shutdown()
+	})
+	return m.shutdownErr
+}
+
+func (m *Manager) shutdown() error {
+	m.cancel()
+
+	m.mu.Lock()
+	servers, consumers, tracer, meter, closers := m.servers, m.consumers, m.tracer, m.meter, m.closers
+	m.mu.Unlock()
+
+	var errs []error
+	run := func(ctx context.Context, step string, s stopper) {
+		if err := s.stop(ctx); err != nil {
+			log.Printf("Failed to %s %s: %v", step, s.name, err)
+			errs = append(errs, fmt.Errorf("%s %s: %w", step, s.name, err))
+		}
+	}
+
+	drainCtx, drainCancel := context.WithTimeout(context.Background(), m.timeout)
+	defer drainCancel()
+
+	for _, s := range servers {
+		run(drainCtx, "stop", s)
+	}
+	for _, s := range consumers {
+		run(drainCtx, "drain the consumers of", s)
+	}
+
+	m.cancelWorkers()
+	done := make(chan struct{})
+	go func() {
+		m.workers.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-drainCtx.Done():
+		log.Printf("Background workers still running at the deadline")
+		errs = append(errs, fmt.Errorf("stop the background workers: %w", drainCtx.Err()))
+	}
+
+	closeCtx, closeCancel := context.WithTimeout(context.Background(), CloseTimeout)
+	defer closeCancel()
+
+	if tracer != nil {
+		run(closeCtx, "flush", stopper{name: "the tracer", stop: tracer})
+	}
+	if meter != nil {
+		run(closeCtx, "flush", stopper{name: "the meter", stop: meter})
+	}
+	for i := len(closers) - 1; i >= 0; i-- {
+		run(closeCtx, "close", closers[i])
+	}
+
+	log.Println("Shutdown complete")
+	return errors.Join(errs...)
+}
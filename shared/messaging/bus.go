@@ -0,0 +1,101 @@
+package messaging
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/AuraReaper/voom/shared/contracts"
+	amqp "github.com/rabbitmq/amqp091-go"
+)
+
+// Bus publishes messages to the trip exchange and consumes its queues. RabbitMQ is the bus of
+// the services, InmemBus runs their consumers within one process.
+type Bus interface {
+	// PublishMessage publishes message under a new unique message ID
+	PublishMessage(ctx context.Context, routingKey string, message contracts.AmqpMessage) error
+	// PublishMessageWithID publishes message under messageID, consumers skip a message published
+	// again with the same ID
+	PublishMessageWithID(ctx context.Context, messageID, routingKey string, message contracts.AmqpMessage) error
+	// ConsumeMessages hands the messages of queueName to handler. A message is acked once handler
+	// succeeds, retried when it fails and dead-lettered when it fails permanently.
+	ConsumeMessages(queueName string, handler MessageHandler, opts ...ConsumerOption) error
+	// DeclareQueue declares queueName bound to routingKeys and consumed by this service, next to
+	// the queues of its ServiceTopology
+	DeclareQueue(queueName string, routingKeys []string) error
+}
+
+var (
+	_ Bus = (*RabbitMQ)(nil)
+	_ Bus = (*InmemBus)(nil)
+)
+
+// envelopePublisher is implemented by the buses that publish payloads Publish already encoded
+type envelopePublisher interface {
+	payloadContentType(routingKey string) string
+	publishEnvelope(ctx context.Context, messageID, routingKey, contentType string, message contracts.AmqpMessage) error
+}
+
+// transcodeMessage converts the JSON data of message into contentType
+func transcodeMessage(routingKey, contentType string, message contracts.AmqpMessage) (contracts.AmqpMessage, error) {
+	data, err := transcodePayload(routingKey, contentType, message.Data)
+	if err != nil {
+		return message, fmt.Errorf("failed to marshal message: %v", err)
+	}
+	message.Data = data
+
+	return message, nil
+}
+
+// newPublishing wraps message in an envelope produced by producer and encodes it as contentType,
+// the encoding its data already has
+func newPublishing(ctx context.Context, producer, messageID, routingKey, contentType string, message contracts.AmqpMessage) (amqp.Publishing, error) {
+	// A message published while handling another one continues its correlation
+	correlationID := CorrelationID(ctx)
+	if correlationID == "" {
+		correlationID = messageID
+	}
+
+	env := contracts.Envelope{
+		EventID:       messageID,
+		Type:          routingKey,
+		SchemaVersion: SchemaVersion(routingKey),
+		OccurredAt:    time.Now().UTC(),
+		Producer:      producer,
+		CorrelationID: correlationID,
+		OwnerID:       message.OwnerID,
+		Data:          message.Data,
+	}
+
+	body, err := encodeEnvelope(&env, contentType)
+	if err != nil {
+		return amqp.Publishing{}, fmt.Errorf("failed to marshal message: %v", err)
+	}
+
+	return amqp.Publishing{
+		DeliveryMode:  amqp.Persistent,
+		ContentType:   contentType,
+		MessageId:     messageID,
+		CorrelationId: correlationID,
+		Type:          routingKey,
+		AppId:         producer,
+		Timestamp:     env.OccurredAt,
+		Body:          body,
+	}, nil
+}
+
+// DeclareQueue declares queueName with its dead-letter and retry queues and binds it to
+// routingKeys. The queue is declared again after every reconnect.
+func (r *RabbitMQ) DeclareQueue(queueName string, routingKeys []string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	binding := QueueBinding{Queue: queueName, RoutingKeys: routingKeys, Consumer: r.serviceName}
+	r.queues = append(r.queues, binding)
+
+	// Otherwise the queue is declared once the connection is back
+	if r.channel == nil || r.channel.IsClosed() {
+		return nil
+	}
+	return r.declareAndBindQueue(binding, TripExchange)
+}
@@ -0,0 +1,237 @@
+package messaging
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"reflect"
+
+	"github.com/AuraReaper/voom/shared/contracts"
+	pbe "github.com/AuraReaper/voom/shared/proto/events"
+	amqp "github.com/rabbitmq/amqp091-go"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+// The content types messages are published with. The envelope and its payload share the content
+// type, consumers read both as the content type of the delivery says.
+const (
+	ContentTypeJSON     = "application/json"
+	ContentTypeProtobuf = "application/x-protobuf"
+)
+
+var ErrUnsupportedContentType = errors.New("unsupported content type")
+
+// protoCodec converts a registered payload to and from its proto message
+type protoCodec struct {
+	newMessage func() proto.Message
+	toProto    func(payload any) proto.Message
+	fromProto  func(msg proto.Message) any
+}
+
+// RegisterProtoPayload lets the payload T registered for routingKey be published as the proto
+// message P. Proto payloads are never upcast, P evolves through its field numbers instead.
+func RegisterProtoPayload[T any, P proto.Message](routingKey string, toProto func(T) P, fromProto func(P) T) {
+	schemasMu.Lock()
+	defer schemasMu.Unlock()
+
+	schema, ok := schemas[routingKey]
+	if !ok {
+		panic(fmt.Sprintf("messaging: proto payload of %s registered before its payload", routingKey))
+	}
+	if t := reflect.TypeFor[T](); t != schema.payloadType {
+		panic(fmt.Sprintf("messaging: proto payload of %s converts %s, not %s", routingKey, t, schema.payloadType))
+	}
+
+	var zero P
+	schema.proto = &protoCodec{
+		newMessage: func() proto.Message { return zero.ProtoReflect().New().Interface() },
+		toProto:    func(payload any) proto.Message { return toProto(payload.(T)) },
+		fromProto:  func(msg proto.Message) any { return fromProto(msg.(P)) },
+	}
+}
+
+func lookupProtoCodec(routingKey string) *protoCodec {
+	if schema := lookupSchema(routingKey); schema != nil {
+		return schema.proto
+	}
+	return nil
+}
+
+// UsePayloadContentType sets the content type Publish and PublishMessage encode payloads as.
+// Routing keys without a proto payload are still published as JSON.
+func (r *RabbitMQ) UsePayloadContentType(contentType string) error {
+	switch contentType {
+	case ContentTypeJSON, ContentTypeProtobuf:
+	default:
+		return fmt.Errorf("%w: %s", ErrUnsupportedContentType, contentType)
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.contentType = contentType
+	return nil
+}
+
+// payloadContentType returns the content type payloads of routingKey are published with
+func (r *RabbitMQ) payloadContentType(routingKey string) string {
+	r.mu.RLock()
+	contentType := r.contentType
+	r.mu.RUnlock()
+
+	if contentType == ContentTypeProtobuf && lookupProtoCodec(routingKey) != nil {
+		return ContentTypeProtobuf
+	}
+	return ContentTypeJSON
+}
+
+// encodePayload encodes payload of routingKey as contentType
+func encodePayload(routingKey, contentType string, payload any) ([]byte, error) {
+	if contentType == ContentTypeProtobuf {
+		codec := lookupProtoCodec(routingKey)
+		if codec == nil {
+			return nil, fmt.Errorf("%s has no proto payload", routingKey)
+		}
+		return proto.Marshal(codec.toProto(payload))
+	}
+
+	return json.Marshal(payload)
+}
+
+// transcodePayload converts the JSON data of a registered payload into contentType
+func transcodePayload(routingKey, contentType string, data []byte) ([]byte, error) {
+	if contentType == ContentTypeJSON || len(data) == 0 {
+		return data, nil
+	}
+
+	schema := lookupSchema(routingKey)
+	payload := reflect.New(schema.payloadType)
+	if err := json.Unmarshal(data, payload.Interface()); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal %s payload: %w", routingKey, err)
+	}
+
+	return encodePayload(routingKey, contentType, payload.Elem().Interface())
+}
+
+// decodePayload decodes the data of env into a new value of its registered payload type. The
+// payload of JSON messages is lifted to the registered schema version first.
+func decodePayload(env *contracts.Envelope, contentType string) (any, error) {
+	schema := lookupSchema(env.Type)
+	if schema == nil {
+		return nil, fmt.Errorf("%s has no registered payload", env.Type)
+	}
+
+	if contentType == ContentTypeProtobuf {
+		if schema.proto == nil {
+			return nil, fmt.Errorf("%s has no proto payload", env.Type)
+		}
+
+		msg := schema.proto.newMessage()
+		if err := proto.Unmarshal(env.Data, msg); err != nil {
+			return nil, fmt.Errorf("failed to unmarshal %s payload: %w", env.Type, err)
+		}
+		return schema.proto.fromProto(msg), nil
+	}
+
+	data, err := upcast(env.Type, env.SchemaVersion, env.Data)
+	if err != nil {
+		return nil, err
+	}
+	env.Data = data
+	env.SchemaVersion = max(env.SchemaVersion, schema.version)
+
+	payload := reflect.New(schema.payloadType)
+	if len(data) > 0 {
+		if err := json.Unmarshal(data, payload.Interface()); err != nil {
+			return nil, fmt.Errorf("failed to unmarshal %s payload: %w", env.Type, err)
+		}
+	}
+	return payload.Elem().Interface(), nil
+}
+
+// encodeEnvelope encodes env as contentType
+func encodeEnvelope(env *contracts.Envelope, contentType string) ([]byte, error) {
+	switch contentType {
+	case ContentTypeJSON:
+		return json.Marshal(env)
+	case ContentTypeProtobuf:
+		return proto.Marshal(&pbe.Envelope{
+			EventId:       env.EventID,
+			Type:          env.Type,
+			SchemaVersion: int32(env.SchemaVersion),
+			OccurredAt:    timestamppb.New(env.OccurredAt),
+			Producer:      env.Producer,
+			CorrelationId: env.CorrelationID,
+			OwnerId:       env.OwnerID,
+			Data:          env.Data,
+		})
+	default:
+		return nil, fmt.Errorf("%w: %s", ErrUnsupportedContentType, contentType)
+	}
+}
+
+// decodeEnvelope reads the envelope of d as its content type. Deliveries without one come from
+// producers that predate it and are JSON. Fields the producer left out are taken from the delivery.
+func decodeEnvelope(d amqp.Delivery) (*contracts.Envelope, error) {
+	var env contracts.Envelope
+
+	switch d.ContentType {
+	case "", ContentTypeJSON:
+		if err := json.Unmarshal(d.Body, &env); err != nil {
+			return nil, fmt.Errorf("failed to unmarshal envelope: %w", err)
+		}
+	case ContentTypeProtobuf:
+		var msg pbe.Envelope
+		if err := proto.Unmarshal(d.Body, &msg); err != nil {
+			return nil, fmt.Errorf("failed to unmarshal envelope: %w", err)
+		}
+		env = contracts.Envelope{
+			EventID:       msg.EventId,
+			Type:          msg.Type,
+			SchemaVersion: int(msg.SchemaVersion),
+			Producer:      msg.Producer,
+			CorrelationID: msg.CorrelationId,
+			OwnerID:       msg.OwnerId,
+			Data:          msg.Data,
+		}
+		if msg.OccurredAt != nil {
+			env.OccurredAt = msg.OccurredAt.AsTime()
+		}
+	default:
+		return nil, fmt.Errorf("%w: %s", ErrUnsupportedContentType, d.ContentType)
+	}
+
+	if env.Type == "" {
+		env.Type = d.RoutingKey
+	}
+	if env.EventID == "" {
+		env.EventID = d.MessageId
+	}
+	if env.SchemaVersion == 0 {
+		env.SchemaVersion = 1
+	}
+
+	return &env, nil
+}
+
+// readableBody renders the body of d for people inspecting it. Protobuf bodies are shown as the
+// JSON they would have been published as, or base64 when they can't be decoded.
+func readableBody(d amqp.Delivery) string {
+	if d.ContentType != ContentTypeProtobuf {
+		return string(d.Body)
+	}
+
+	env, payload, err := DecodePayload(d)
+	if err == nil {
+		env.Data, err = json.Marshal(payload)
+	}
+	if err == nil {
+		if body, err := json.Marshal(env); err == nil {
+			return string(body)
+		}
+	}
+
+	return base64.StdEncoding.EncodeToString(d.Body)
+}
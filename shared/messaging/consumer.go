@@ -0,0 +1,435 @@
+package messaging
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"hash/fnv"
+	"log"
+	"maps"
+	"reflect"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/AuraReaper/voom/shared/tracing"
+	"github.com/google/uuid"
+	amqp "github.com/rabbitmq/amqp091-go"
+)
+
+// ConsumerOption configures how a consumer takes messages off its queue
+type ConsumerOption func(*consumerOptions)
+
+type consumerOptions struct {
+	prefetch    int
+	workers     int
+	orderingKey func(amqp.Delivery) string
+}
+
+// WithPrefetch sets how many unacked messages the broker hands the consumer at once, by default
+// one per worker
+func WithPrefetch(n int) ConsumerOption {
+	return func(o *consumerOptions) {
+		o.prefetch = n
+	}
+}
+
+// WithWorkers sets how many messages the consumer handles concurrently, 1 by default
+func WithWorkers(n int) ConsumerOption {
+	return func(o *consumerOptions) {
+		o.workers = n
+	}
+}
+
+// WithOrderingKey handles the messages with the same key one at a time in the order they
+// arrived, while messages with different keys run on the other workers. Messages with an empty
+// key go to any worker. A message handed back for a retry comes after the ones behind it.
+func WithOrderingKey(key func(amqp.Delivery) string) ConsumerOption {
+	return func(o *consumerOptions) {
+		o.orderingKey = key
+	}
+}
+
+func newConsumerOptions(opts []ConsumerOption) consumerOptions {
+	o := consumerOptions{workers: 1}
+	for _, opt := range opts {
+		opt(&o)
+	}
+
+	o.workers = max(o.workers, 1)
+	if o.prefetch <= 0 {
+		o.prefetch = o.workers
+	}
+	return o
+}
+
+// TripIDKey is an ordering key keeping the messages about one trip in order. It reads the trip ID
+// of the registered payload, messages without one have no key.
+func TripIDKey(d amqp.Delivery) string {
+	_, payload, err := DecodePayload(d)
+	if err != nil {
+		return ""
+	}
+
+	if p, ok := payload.(TripEventData); ok {
+		return p.Trip.GetId()
+	}
+
+	v := reflect.ValueOf(payload)
+	if v.Kind() != reflect.Struct {
+		return ""
+	}
+	if f := v.FieldByName("TripID"); f.IsValid() && f.Kind() == reflect.String {
+		return f.String()
+	}
+	return ""
+}
+
+// consumer hands the messages of a queue to its handler on a channel of its own, on every
+// connection made to the broker
+type consumer struct {
+	rb      *RabbitMQ
+	queue   string
+	handler MessageHandler
+	opts    consumerOptions
+	tag     string
+
+	mu       sync.Mutex
+	ch       *amqp.Channel
+	stopping bool
+	running  sync.WaitGroup // dispatchers still settling the deliveries of their channel
+	inflight map[*inflightDelivery]struct{}
+}
+
+// errRequeued is returned when settling a message stop already handed back to its queue
+var errRequeued = errors.New("message requeued by the shutdown of its consumer")
+
+// inflightDelivery is the acknowledger of a message being handled. The message is settled once,
+// by its handler or by stop handing it back to the queue when the handler is out of time.
+type inflightDelivery struct {
+	amqp.Acknowledger
+	tag uint64
+
+	mu       sync.Mutex
+	settled  bool
+	requeued bool
+	cancel   context.CancelFunc // cancels the context of the handler
+}
+
+func (d *inflightDelivery) settle(fn func() error) error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	if d.requeued {
+		return errRequeued
+	}
+	if d.settled {
+		return fmt.Errorf("message %d already settled", d.tag)
+	}
+	d.settled = true
+	return fn()
+}
+
+func (d *inflightDelivery) Ack(tag uint64, multiple bool) error {
+	return d.settle(func() error { return d.Acknowledger.Ack(tag, multiple) })
+}
+
+func (d *inflightDelivery) Nack(tag uint64, multiple, requeue bool) error {
+	return d.settle(func() error { return d.Acknowledger.Nack(tag, multiple, requeue) })
+}
+
+func (d *inflightDelivery) Reject(tag uint64, requeue bool) error {
+	return d.settle(func() error { return d.Acknowledger.Reject(tag, requeue) })
+}
+
+// bind makes cancel cancel the handler once the message is requeued
+func (d *inflightDelivery) bind(cancel context.CancelFunc) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	if d.requeued {
+		cancel()
+		return
+	}
+	d.cancel = cancel
+}
+
+// requeue hands the message back to its queue unless it was settled, and cancels its handler
+func (d *inflightDelivery) requeue() bool {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	if d.settled {
+		return false
+	}
+	if err := d.Acknowledger.Nack(d.tag, false, true); err != nil {
+		log.Printf("ERROR: Failed to requeue message %d: %v", d.tag, err)
+	}
+	d.settled = true
+	d.requeued = true
+	if d.cancel != nil {
+		d.cancel()
+	}
+	return true
+}
+
+func (d *inflightDelivery) isRequeued() bool {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	return d.requeued
+}
+
+func newConsumer(rb *RabbitMQ, queue string, handler MessageHandler, opts consumerOptions) *consumer {
+	return &consumer{
+		rb:       rb,
+		queue:    queue,
+		handler:  handler,
+		opts:     opts,
+		tag:      fmt.Sprintf("%s.%s.%s", rb.serviceName, queue, uuid.NewString()[:8]),
+		inflight: make(map[*inflightDelivery]struct{}),
+	}
+}
+
+// start consumes the queue on a new channel of conn
+func (c *consumer) start(conn *amqp.Connection) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.stopping {
+		return nil
+	}
+
+	ch, err := conn.Channel()
+	if err != nil {
+		return fmt.Errorf("failed to create channel for %s: %v", c.queue, err)
+	}
+
+	if err := ch.Qos(
+		c.opts.prefetch, // prefetchCount: unacked messages handed to the consumer at once
+		0,               // prefetchSize: No specific limit on message size
+		false,           // global: Apply prefetchCount to each consumer individually
+	); err != nil {
+		ch.Close()
+		return fmt.Errorf("failed to set QoS: %v", err)
+	}
+
+	msgs, err := ch.Consume(
+		c.queue, // queue
+		c.tag,   // consumer
+		false,   // auto-ack
+		false,   // exclusive
+		false,   // no-local
+		false,   // no-wait
+		nil,     // args
+	)
+	if err != nil {
+		ch.Close()
+		return err
+	}
+	c.ch = ch
+
+	c.running.Add(1)
+	go func() {
+		defer c.running.Done()
+		c.dispatch(msgs)
+	}()
+	go c.watch(conn, ch)
+
+	return nil
+}
+
+// watch restarts the consumer when its channel is closed while the connection stays up. When the
+// connection is lost RabbitMQ restarts every consumer on the next one.
+func (c *consumer) watch(conn *amqp.Connection, ch *amqp.Channel) {
+	err := <-ch.NotifyClose(make(chan *amqp.Error, 1))
+	if err == nil {
+		return
+	}
+
+	for {
+		// The channel is closed as well when the connection goes, give it time to be reported
+		time.Sleep(reconnectInitialWait)
+
+		c.mu.Lock()
+		stopping := c.stopping
+		c.mu.Unlock()
+		if stopping || conn.IsClosed() {
+			return
+		}
+
+		log.Printf("Channel of the consumer of %s closed: %v, restarting it", c.queue, err)
+		if err := c.start(conn); err != nil {
+			log.Printf("Failed to restart the consumer of %s: %v", c.queue, err)
+			continue
+		}
+		return
+	}
+}
+
+// dispatch hands the deliveries to the workers until the channel is closed or the consumer
+// cancelled, and returns once the workers settled them. Deliveries with an ordering key always go
+// to the same worker, the others to whichever is free.
+func (c *consumer) dispatch(msgs <-chan amqp.Delivery) {
+	shared := make(chan amqp.Delivery)
+	keyed := make([]chan amqp.Delivery, c.opts.workers)
+
+	var workers sync.WaitGroup
+	for i := range keyed {
+		keyed[i] = make(chan amqp.Delivery, c.opts.prefetch)
+		workers.Add(1)
+		go func() {
+			defer workers.Done()
+			c.work(shared, keyed[i])
+		}()
+	}
+
+	for msg := range msgs {
+		key := ""
+		if c.opts.orderingKey != nil {
+			key = c.opts.orderingKey(restoreRouting(msg))
+		}
+		if key == "" {
+			shared <- msg
+			continue
+		}
+
+		h := fnv.New32a()
+		h.Write([]byte(key))
+		keyed[h.Sum32()%uint32(len(keyed))] <- msg
+	}
+
+	close(shared)
+	for _, ch := range keyed {
+		close(ch)
+	}
+	workers.Wait()
+}
+
+// work handles the deliveries of its own queue and of the shared one until both are closed
+func (c *consumer) work(shared, own <-chan amqp.Delivery) {
+	for shared != nil || own != nil {
+		select {
+		case msg, ok := <-shared:
+			if !ok {
+				shared = nil
+				continue
+			}
+			c.handle(msg)
+		case msg, ok := <-own:
+			if !ok {
+				own = nil
+				continue
+			}
+			c.handle(msg)
+		}
+	}
+}
+
+// handle settles msg. Once the consumer is stopping, messages not started yet go back to the
+// queue for the next instance of the service.
+func (c *consumer) handle(msg amqp.Delivery) {
+	c.mu.Lock()
+	if c.stopping {
+		c.mu.Unlock()
+		if err := msg.Nack(false, true); err != nil {
+			log.Printf("ERROR: Failed to requeue message %s: %v", msg.MessageId, err)
+		}
+		return
+	}
+	inflight := &inflightDelivery{Acknowledger: msg.Acknowledger, tag: msg.DeliveryTag}
+	c.inflight[inflight] = struct{}{}
+	c.mu.Unlock()
+
+	defer func() {
+		c.mu.Lock()
+		delete(c.inflight, inflight)
+		c.mu.Unlock()
+	}()
+
+	msg.Acknowledger = inflight
+
+	if err := tracing.TracedConsumer(restoreRouting(msg), func(ctx context.Context, d amqp.Delivery) error {
+		log.Printf("Received a message: %s", msg.Body)
+		ctx = WithCorrelationID(ctx, d.CorrelationId)
+
+		ctx, cancel := context.WithCancel(ctx)
+		defer cancel()
+		inflight.bind(cancel)
+
+		if err := c.handler(ctx, d); err != nil {
+			// A message requeued by the shutdown is handled again by the next instance
+			if inflight.isRequeued() {
+				return err
+			}
+
+			// The message is acked either way, so the ones behind it are not held up by its retries
+			c.rb.handleFailure(ctx, c.queue, d, err)
+			return err
+		}
+
+		// Only Ack if the handler succeeds
+		if ackErr := msg.Ack(false); ackErr != nil {
+			if errors.Is(ackErr, errRequeued) {
+				log.Printf("Message %s finished after it was requeued, it is handled again", msg.MessageId)
+				return nil
+			}
+			log.Printf("ERROR: Failed to Ack message: %v. Message body: %s", ackErr, msg.Body)
+		}
+
+		return nil
+	}); err != nil {
+		log.Printf("Error processing message: %v", err)
+	}
+}
+
+// stop cancels the deliveries of the consumer, requeues the messages it holds that weren't started
+// and waits for the ones being handled until ctx is done. The messages still being handled then are
+// requeued as well and their handlers cancelled.
+func (c *consumer) stop(ctx context.Context) error {
+	c.mu.Lock()
+	c.stopping = true
+	ch := c.ch
+	c.mu.Unlock()
+
+	if ch != nil && !ch.IsClosed() {
+		if err := ch.Cancel(c.tag, false); err != nil {
+			log.Printf("Failed to cancel the consumer of %s: %v", c.queue, err)
+		}
+	}
+
+	done := make(chan struct{})
+	go func() {
+		c.running.Wait()
+		close(done)
+	}()
+
+	var err error
+	select {
+	case <-done:
+	case <-ctx.Done():
+		if n := c.requeueInflight(); n > 0 {
+			err = fmt.Errorf("consumer of %s requeued %d messages still being handled: %w", c.queue, n, ctx.Err())
+		}
+	}
+
+	if ch != nil {
+		ch.Close()
+	}
+	return err
+}
+
+// requeueInflight hands the messages being handled back to the queue and returns how many
+func (c *consumer) requeueInflight() int {
+	c.mu.Lock()
+	inflight := slices.Collect(maps.Keys(c.inflight))
+	c.mu.Unlock()
+
+	requeued := 0
+	for _, d := range inflight {
+		if d.requeue() {
+			requeued++
+		}
+	}
+	return requeued
+}
@@ -0,0 +1,252 @@
+package messaging
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"time"
+
+	amqp "github.com/rabbitmq/amqp091-go"
+)
+
+// DeadLetterExchange receives the messages consumers gave up on, routed by the name of the queue
+// they failed in to that queue's dead-letter queue
+const DeadLetterExchange = "trip.dlx"
+
+// Headers stamped on a dead-lettered message
+const (
+	HeaderFailureError       = "x-failure-error"
+	HeaderFailureAttempts    = "x-failure-attempts"
+	HeaderFailureService     = "x-failure-service"
+	HeaderFailureQueue       = "x-failure-queue"
+	HeaderFailedAt           = "x-failed-at"
+	HeaderOriginalExchange   = "x-original-exchange"
+	HeaderOriginalRoutingKey = "x-original-routing-key"
+)
+
+// DeadLetterQueue returns the name of the queue holding the dead letters of queue
+func DeadLetterQueue(queue string) string {
+	return queue + ".dlq"
+}
+
+// DeadLetter is a message parked in a dead-letter queue with the reason it failed
+type DeadLetter struct {
+	MessageID   string     `json:"messageID,omitempty"`
+	Queue       string     `json:"queue"`
+	RoutingKey  string     `json:"routingKey"`
+	Service     string     `json:"service,omitempty"`
+	Error       string     `json:"error,omitempty"`
+	Attempts    int64      `json:"attempts,omitempty"`
+	FailedAt    time.Time  `json:"failedAt,omitempty"`
+	Headers     amqp.Table `json:"headers,omitempty"`
+	ContentType string     `json:"contentType,omitempty"`
+	Body        string     `json:"body"` // protobuf bodies are rendered as JSON
+}
+
+// declareDeadLetterExchange declares the exchange every queue dead-letters into
+func (r *RabbitMQ) declareDeadLetterExchange() error {
+	if err := r.channel.ExchangeDeclare(
+		DeadLetterExchange, // name
+		"direct",           // type
+		true,               // durable
+		false,              // auto-deleted
+		false,              // internal
+		false,              // no-wait
+		nil,                // arguments
+	); err != nil {
+		return fmt.Errorf("failed to declare exchange: %s: %v", DeadLetterExchange, err)
+	}
+
+	return nil
+}
+
+// declareDeadLetterQueue declares the dead-letter queue of queue and binds it to the dead-letter exchange
+func (r *RabbitMQ) declareDeadLetterQueue(queue string) error {
+	dlq, err := r.channel.QueueDeclare(
+		DeadLetterQueue(queue), // name
+		true,                   // durable
+		false,                  // delete when unused
+		false,                  // exclusive
+		false,                  // no-wait
+		nil,                    // arguments
+	)
+	if err != nil {
+		return fmt.Errorf("failed to declare dead-letter queue of %s: %v", queue, err)
+	}
+
+	if err := r.channel.QueueBind(dlq.Name, queue, DeadLetterExchange, false, nil); err != nil {
+		return fmt.Errorf("failed to bind dead-letter queue of %s: %v", queue, err)
+	}
+
+	return nil
+}
+
+// deadLetter parks a message that failed in queue in its dead-letter queue, with the failure
+// stamped into its headers, and acks the original. When the message can't be parked it is
+// rejected, the broker then dead-letters it without the failure headers.
+func (r *RabbitMQ) deadLetter(ctx context.Context, queue string, d amqp.Delivery, cause error, attempts int) {
+	headers := amqp.Table{}
+	for k, v := range d.Headers {
+		headers[k] = v
+	}
+	headers[HeaderFailureError] = cause.Error()
+	headers[HeaderFailureAttempts] = int64(attempts)
+	headers[HeaderFailureService] = r.serviceName
+	headers[HeaderFailureQueue] = queue
+	headers[HeaderFailedAt] = time.Now().UTC()
+	headers[HeaderOriginalExchange] = d.Exchange
+	headers[HeaderOriginalRoutingKey] = d.RoutingKey
+
+	err := r.publish(ctx, DeadLetterExchange, queue, amqp.Publishing{
+		Headers:       headers,
+		ContentType:   d.ContentType,
+		DeliveryMode:  amqp.Persistent,
+		CorrelationId: d.CorrelationId,
+		MessageId:     d.MessageId,
+		Timestamp:     d.Timestamp,
+		Type:          d.Type,
+		AppId:         d.AppId,
+		Body:          d.Body,
+	})
+	if err != nil {
+		log.Printf("Failed to dead-letter message from %s, rejecting it instead: %v", queue, err)
+		_ = d.Reject(false)
+		return
+	}
+
+	if err := d.Ack(false); err != nil {
+		log.Printf("ERROR: Failed to Ack dead-lettered message: %v", err)
+	}
+}
+
+// InspectDeadLetters returns up to limit messages parked for queue without removing them
+func (r *RabbitMQ) InspectDeadLetters(queue string, limit int) ([]DeadLetter, error) {
+	// Unacked messages go back to the queue in their original order once the channel is closed
+	ch, err := r.openChannel()
+	if err != nil {
+		return nil, err
+	}
+	defer ch.Close()
+
+	var letters []DeadLetter
+	for len(letters) < limit {
+		d, ok, err := ch.Get(DeadLetterQueue(queue), false)
+		if err != nil {
+			return nil, fmt.Errorf("failed to get dead letter: %v", err)
+		}
+		if !ok {
+			break
+		}
+
+		letters = append(letters, toDeadLetter(queue, d))
+	}
+
+	return letters, nil
+}
+
+// ReplayDeadLetters moves up to limit parked messages back to queue, without their failure
+// headers and with their retries reset, and returns how many were replayed. Only the messages parked when the replay started
+// are replayed, a message failing again is parked anew.
+func (r *RabbitMQ) ReplayDeadLetters(ctx context.Context, queue string, limit int) (int, error) {
+	ch, err := r.openChannel()
+	if err != nil {
+		return 0, err
+	}
+	defer ch.Close()
+
+	dlq, err := ch.QueueDeclarePassive(DeadLetterQueue(queue), true, false, false, false, nil)
+	if err != nil {
+		return 0, fmt.Errorf("failed to inspect dead-letter queue of %s: %v", queue, err)
+	}
+	limit = min(limit, dlq.Messages)
+
+	replayed := 0
+	for replayed < limit {
+		d, ok, err := ch.Get(dlq.Name, false)
+		if err != nil {
+			return replayed, fmt.Errorf("failed to get dead letter: %v", err)
+		}
+		if !ok {
+			break
+		}
+
+		headers := amqp.Table{}
+		for k, v := range d.Headers {
+			headers[k] = v
+		}
+		for _, k := range []string{
+			HeaderFailureError, HeaderFailureAttempts, HeaderFailureService, HeaderFailureQueue,
+			HeaderFailedAt, HeaderRetryCount, "x-death",
+		} {
+			delete(headers, k)
+		}
+		// The consumer restores the routing key the message was first published with
+		if _, ok := headers[HeaderOriginalRoutingKey]; !ok {
+			headers[HeaderOriginalRoutingKey] = d.RoutingKey
+		}
+
+		// Published to the queue itself, so the other subscribers of the routing key don't see it twice
+		if err := ch.PublishWithContext(ctx, "", queue, false, false, amqp.Publishing{
+			Headers:       headers,
+			ContentType:   d.ContentType,
+			DeliveryMode:  amqp.Persistent,
+			CorrelationId: d.CorrelationId,
+			MessageId:     d.MessageId,
+			Timestamp:     d.Timestamp,
+			Type:          d.Type,
+			AppId:         d.AppId,
+			Body:          d.Body,
+		}); err != nil {
+			_ = d.Nack(false, true)
+			return replayed, fmt.Errorf("failed to replay dead letter: %v", err)
+		}
+
+		if err := d.Ack(false); err != nil {
+			return replayed, fmt.Errorf("failed to ack replayed dead letter: %v", err)
+		}
+		replayed++
+	}
+
+	return replayed, nil
+}
+
+// PurgeDeadLetters drops every message parked for queue and returns how many were dropped
+func (r *RabbitMQ) PurgeDeadLetters(queue string) (int, error) {
+	ch, err := r.currentChannel()
+	if err != nil {
+		return 0, err
+	}
+
+	purged, err := ch.QueuePurge(DeadLetterQueue(queue), false)
+	if err != nil {
+		return 0, fmt.Errorf("failed to purge dead-letter queue of %s: %v", queue, err)
+	}
+
+	return purged, nil
+}
+
+func toDeadLetter(queue string, d amqp.Delivery) DeadLetter {
+	letter := DeadLetter{
+		MessageID:   d.MessageId,
+		Queue:       queue,
+		RoutingKey:  d.RoutingKey,
+		Headers:     d.Headers,
+		ContentType: d.ContentType,
+		Body:        readableBody(d),
+	}
+
+	if key, ok := d.Headers[HeaderOriginalRoutingKey].(string); ok {
+		letter.RoutingKey = key
+	}
+	letter.Service, _ = d.Headers[HeaderFailureService].(string)
+	letter.Error, _ = d.Headers[HeaderFailureError].(string)
+	letter.FailedAt, _ = d.Headers[HeaderFailedAt].(time.Time)
+	switch attempts := d.Headers[HeaderFailureAttempts].(type) {
+	case int64:
+		letter.Attempts = attempts
+	case int32:
+		letter.Attempts = int64(attempts)
+	}
+
+	return letter
+}
@@ -0,0 +1,93 @@
+package messaging
+
+import (
+	"context"
+	"log"
+	"sync"
+	"time"
+
+	amqp "github.com/rabbitmq/amqp091-go"
+)
+
+// DefaultDedupTTL is how long processed messages are remembered, redeliveries come well within it
+const DefaultDedupTTL = 24 * time.Hour
+
+// DedupStore remembers the messages a consumer group processed, so redeliveries can be skipped
+type DedupStore interface {
+	// IsProcessed reports whether group already processed the message with messageID
+	IsProcessed(ctx context.Context, group, messageID string) (bool, error)
+	MarkProcessed(ctx context.Context, group, messageID string) error
+}
+
+// Deduplicate skips messages group already processed and records the ones handler processed
+// successfully. Messages without an ID are always handed to handler.
+func Deduplicate(store DedupStore, group string, handler MessageHandler) MessageHandler {
+	return func(ctx context.Context, d amqp.Delivery) error {
+		if d.MessageId == "" {
+			return handler(ctx, d)
+		}
+
+		processed, err := store.IsProcessed(ctx, group, d.MessageId)
+		if err != nil {
+			return err
+		}
+		if processed {
+			log.Printf("Skipping message %s already processed by %s", d.MessageId, group)
+			return nil
+		}
+
+		if err := handler(ctx, d); err != nil {
+			return err
+		}
+
+		// The message was handled, failing to record it only risks handling it again
+		if err := store.MarkProcessed(ctx, group, d.MessageId); err != nil {
+			log.Printf("Failed to record message %s as processed by %s: %v", d.MessageId, group, err)
+		}
+
+		return nil
+	}
+}
+
+// InmemDedupStore remembers processed messages in memory for ttl
+type InmemDedupStore struct {
+	ttl       time.Duration
+	mu        sync.Mutex
+	processed map[string]time.Time // group/messageID -> expiry
+	nextSweep time.Time
+}
+
+func NewInmemDedupStore(ttl time.Duration) *InmemDedupStore {
+	return &InmemDedupStore{
+		ttl:       ttl,
+		processed: make(map[string]time.Time),
+	}
+}
+
+func (s *InmemDedupStore) IsProcessed(ctx context.Context, group, messageID string) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	expiry, ok := s.processed[group+"/"+messageID]
+	return ok && time.Now().Before(expiry), nil
+}
+
+func (s *InmemDedupStore) MarkProcessed(ctx context.Context, group, messageID string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := time.Now()
+	s.processed[group+"/"+messageID] = now.Add(s.ttl)
+
+	// Expired entries are dropped at most once per ttl
+	if now.After(s.nextSweep) {
+		for key, expiry := range s.processed {
+			if now.After(expiry) {
+				delete(s.processed, key)
+			}
+		}
+		s.nextSweep = now.Add(s.ttl)
+	}
+
+	return nil
+}
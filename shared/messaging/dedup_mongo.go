@@ -0,0 +1,68 @@
+package messaging
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+const processedMessagesCollection = "processed_messages"
+
+// MongoDedupStore remembers processed messages in MongoDB, so redeliveries are skipped across
+// restarts and replicas. MongoDB drops the records ttl after they were written.
+type MongoDedupStore struct {
+	collection *mongo.Collection
+}
+
+type processedMessage struct {
+	ID          string    `bson:"_id"` // group/messageID
+	Group       string    `bson:"group"`
+	MessageID   string    `bson:"message_id"`
+	ProcessedAt time.Time `bson:"processed_at"`
+}
+
+func NewMongoDedupStore(ctx context.Context, db *mongo.Database, ttl time.Duration) (*MongoDedupStore, error) {
+	collection := db.Collection(processedMessagesCollection)
+
+	if _, err := collection.Indexes().CreateOne(ctx, mongo.IndexModel{
+		Keys:    bson.D{{Key: "processed_at", Value: 1}},
+		Options: options.Index().SetExpireAfterSeconds(int32(ttl.Seconds())),
+	}); err != nil {
+		return nil, fmt.Errorf("failed to create the processed messages TTL index: %w", err)
+	}
+
+	return &MongoDedupStore{collection: collection}, nil
+}
+
+func (s *MongoDedupStore) IsProcessed(ctx context.Context, group, messageID string) (bool, error) {
+	err := s.collection.FindOne(ctx, bson.M{"_id": group + "/" + messageID}).Err()
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return false, nil
+	}
+	if err != nil {
+		return false, fmt.Errorf("failed to look up processed message %s: %w", messageID, err)
+	}
+
+	return true, nil
+}
+
+func (s *MongoDedupStore) MarkProcessed(ctx context.Context, group, messageID string) error {
+	key := group + "/" + messageID
+
+	_, err := s.collection.ReplaceOne(ctx, bson.M{"_id": key}, processedMessage{
+		ID:          key,
+		Group:       group,
+		MessageID:   messageID,
+		ProcessedAt: time.Now(),
+	}, options.Replace().SetUpsert(true))
+	if err != nil {
+		return fmt.Errorf("failed to record processed message %s: %w", messageID, err)
+	}
+
+	return nil
+}
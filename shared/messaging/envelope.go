@@ -0,0 +1,224 @@
+package messaging
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"reflect"
+	"sync"
+
+	"github.com/AuraReaper/voom/shared/contracts"
+	"github.com/google/uuid"
+	amqp "github.com/rabbitmq/amqp091-go"
+)
+
+// Upcaster converts a payload of one schema version into the next version
+type Upcaster func(data json.RawMessage) (json.RawMessage, error)
+
+type payloadSchema struct {
+	payloadType reflect.Type
+	version     int
+	upcasters   []Upcaster  // upcasters[i] converts version i+1 into version i+2
+	proto       *protoCodec // set when the payload can be published as protobuf
+}
+
+var (
+	schemasMu sync.RWMutex
+	schemas   = make(map[string]*payloadSchema) // routing key -> schema
+)
+
+// RegisterPayload registers T as the payload of routingKey at schema version. Consumers lift payloads
+// published with an older version through upcasters, upcasters[i] converting version i+1 into i+2.
+func RegisterPayload[T any](routingKey string, version int, upcasters ...Upcaster) {
+	if version < 1 || len(upcasters) != version-1 {
+		panic(fmt.Sprintf("messaging: payload of %s at version %d needs %d upcasters, got %d", routingKey, version, version-1, len(upcasters)))
+	}
+
+	schemasMu.Lock()
+	defer schemasMu.Unlock()
+
+	schemas[routingKey] = &payloadSchema{
+		payloadType: reflect.TypeFor[T](),
+		version:     version,
+		upcasters:   upcasters,
+	}
+}
+
+// SchemaVersion returns the version messages with routingKey are published with, 1 for routing
+// keys without a registered payload
+func SchemaVersion(routingKey string) int {
+	if schema := lookupSchema(routingKey); schema != nil {
+		return schema.version
+	}
+	return 1
+}
+
+func lookupSchema(routingKey string) *payloadSchema {
+	schemasMu.RLock()
+	defer schemasMu.RUnlock()
+
+	return schemas[routingKey]
+}
+
+// checkPayloadType fails when routingKey has a payload registered other than T
+func checkPayloadType[T any](routingKey string) error {
+	schema := lookupSchema(routingKey)
+	if schema == nil {
+		return nil
+	}
+
+	if t := reflect.TypeFor[T](); t != schema.payloadType {
+		return fmt.Errorf("payload of %s is %s, not %s", routingKey, schema.payloadType, t)
+	}
+	return nil
+}
+
+// upcast lifts data from version to the registered version of routingKey
+func upcast(routingKey string, version int, data json.RawMessage) (json.RawMessage, error) {
+	schema := lookupSchema(routingKey)
+	if schema == nil {
+		return data, nil
+	}
+
+	// A newer producer may already be deployed, the fields this consumer knows are still read
+	if version > schema.version {
+		log.Printf("Reading %s payload of version %d as version %d", routingKey, version, schema.version)
+		return data, nil
+	}
+
+	for v := version; v < schema.version; v++ {
+		upcasted, err := schema.upcasters[v-1](data)
+		if err != nil {
+			return nil, fmt.Errorf("failed to upcast %s payload from version %d: %w", routingKey, v, err)
+		}
+		data = upcasted
+	}
+
+	return data, nil
+}
+
+type correlationIDKey struct{}
+
+// WithCorrelationID returns a context whose published messages carry id as their correlation ID
+func WithCorrelationID(ctx context.Context, id string) context.Context {
+	if id == "" {
+		return ctx
+	}
+	return context.WithValue(ctx, correlationIDKey{}, id)
+}
+
+// CorrelationID returns the correlation ID of the message being handled, if any
+func CorrelationID(ctx context.Context) string {
+	id, _ := ctx.Value(correlationIDKey{}).(string)
+	return id
+}
+
+// EventHandler handles a decoded message with its payload
+type EventHandler[T any] func(ctx context.Context, env *contracts.Envelope, payload T) error
+
+// Publish publishes payload with routingKey on behalf of ownerID, encoded as the payload content
+// type of bus. It fails when T is not the payload registered for routingKey.
+func Publish[T any](ctx context.Context, bus Bus, routingKey, ownerID string, payload T) error {
+	if err := checkPayloadType[T](routingKey); err != nil {
+		return err
+	}
+
+	contentType := ContentTypeJSON
+	pub, ok := bus.(envelopePublisher)
+	if ok {
+		contentType = pub.payloadContentType(routingKey)
+	}
+
+	data, err := encodePayload(routingKey, contentType, payload)
+	if err != nil {
+		return fmt.Errorf("failed to marshal %s payload: %v", routingKey, err)
+	}
+
+	message := contracts.AmqpMessage{
+		OwnerID: ownerID,
+		Data:    data,
+	}
+	if !ok {
+		return bus.PublishMessage(ctx, routingKey, message)
+	}
+	return pub.publishEnvelope(ctx, uuid.NewString(), routingKey, contentType, message)
+}
+
+// Consume hands the messages of queueName to handler with their payload decoded as T, the
+// payload every routing key bound to the queue must be registered with
+func Consume[T any](bus Bus, queueName string, handler EventHandler[T], opts ...ConsumerOption) error {
+	return bus.ConsumeMessages(queueName, func(ctx context.Context, d amqp.Delivery) error {
+		env, payload, err := Decode[T](d)
+		if err != nil {
+			log.Printf("Failed to decode message %s from %s: %v", d.MessageId, queueName, err)
+			return err
+		}
+
+		return handler(ctx, env, payload)
+	}, opts...)
+}
+
+// Decode reads the envelope of d and its payload as T, both as the content type of d. JSON payloads
+// are lifted to the registered schema version, messages published as a bare AmqpMessage are read
+// as version 1. Messages that can't be decoded never will be, so the errors are permanent.
+func Decode[T any](d amqp.Delivery) (*contracts.Envelope, T, error) {
+	var payload T
+
+	env, err := decodeEnvelope(d)
+	if err != nil {
+		return nil, payload, Permanent(err)
+	}
+
+	if err := checkPayloadType[T](env.Type); err != nil {
+		return nil, payload, Permanent(err)
+	}
+
+	if lookupSchema(env.Type) == nil {
+		if d.ContentType == ContentTypeProtobuf {
+			return nil, payload, Permanent(fmt.Errorf("%s has no proto payload", env.Type))
+		}
+		if len(env.Data) > 0 {
+			if err := json.Unmarshal(env.Data, &payload); err != nil {
+				return nil, payload, Permanent(fmt.Errorf("failed to unmarshal %s payload: %w", env.Type, err))
+			}
+		}
+		return env, payload, nil
+	}
+
+	decoded, err := decodePayload(env, d.ContentType)
+	if err != nil {
+		return nil, payload, Permanent(err)
+	}
+
+	return env, decoded.(T), nil
+}
+
+// DecodePayload reads the envelope of d and its payload as the type registered for its routing
+// key. Payloads of routing keys without one are read from JSON as they are.
+func DecodePayload(d amqp.Delivery) (*contracts.Envelope, any, error) {
+	env, err := decodeEnvelope(d)
+	if err != nil {
+		return nil, nil, Permanent(err)
+	}
+
+	if lookupSchema(env.Type) != nil {
+		payload, err := decodePayload(env, d.ContentType)
+		if err != nil {
+			return nil, nil, Permanent(err)
+		}
+		return env, payload, nil
+	}
+
+	if d.ContentType == ContentTypeProtobuf {
+		return nil, nil, Permanent(fmt.Errorf("%s has no proto payload", env.Type))
+	}
+
+	var payload any
+	if len(env.Data) > 0 {
+		if err := json.Unmarshal(env.Data, &payload); err != nil {
+			return nil, nil, Permanent(fmt.Errorf("failed to unmarshal %s payload: %w", env.Type, err))
+		}
+	}
+	return env, payload, nil
+}
@@ -14,6 +14,15 @@ const (
 	PaymentTripResponseQueue         = "payment_trip_response"
 	NotifyPaymentSessionCreatedQueue = "notify_payment_session_created"
 	NotifyPaymentSuccessQueue        = "payment_success"
+	NotifyPaymentFailedQueue         = "payment_failed"
+	NotifyPaymentRetryQueue          = "notify_payment_retry"
+	NotifyPaymentRefundedQueue       = "notify_payment_refunded"
+	NotifyPaymentAuthorizedQueue     = "payment_authorized"
+	NotifyDriverTipQueue             = "notify_driver_tip"
+	NotifyTripCompletedQueue         = "notify_trip_completed"
+	TripNoDriversFoundQueue          = "trip_no_drivers_found"
+	TripSplitQueue                   = "trip_split"
+	NotifySplitQueue                 = "notify_split"
 )
 
 type TripEventData struct {
@@ -41,8 +50,101 @@ type PaymentTripResponseData struct {
 	Currency string  `json:"currency"`
 }
 
+// PaymentAuthorizeData asks for a card hold of Amount before the trip is dispatched
+type PaymentAuthorizeData struct {
+	TripID   string  `json:"tripID"`
+	UserID   string  `json:"userID"`
+	Amount   float64 `json:"amount"`
+	Currency string  `json:"currency"`
+}
+
+// PaymentCaptureData captures the final amount of a completed trip from its hold. It also records
+// the cash a driver collected for payment.cmd.record_cash.
+type PaymentCaptureData struct {
+	TripID        string   `json:"tripID"`
+	UserID        string   `json:"userID"`
+	DriverID      string   `json:"driverID"`
+	Amount        float64  `json:"amount"`
+	Currency      string   `json:"currency"`
+	PaymentMethod string   `json:"paymentMethod"`       // wallet tries the rider's wallet before the card
+	SplitWith     []string `json:"splitWith,omitempty"` // riders sharing the fare with the trip owner
+}
+
+// PaymentVoidData releases the hold of a trip that won't be completed
+type PaymentVoidData struct {
+	TripID string `json:"tripID"`
+	Reason string `json:"reason,omitempty"`
+}
+
+// RiderSplitInviteData is sent by a trip owner to split the fare with other riders
+type RiderSplitInviteData struct {
+	TripID  string   `json:"tripID"`
+	UserIDs []string `json:"userIDs"`
+}
+
+// RiderSplitRespondData is an invited rider accepting or declining to split a fare
+type RiderSplitRespondData struct {
+	TripID string `json:"tripID"`
+	Accept bool   `json:"accept"`
+}
+
+// TripEventSplitData tells the riders of a trip who shares its fare
+type TripEventSplitData struct {
+	TripID       string                  `json:"tripID"`
+	OwnerID      string                  `json:"ownerID"`
+	Fare         float64                 `json:"fare"`
+	Participants []*pbt.SplitParticipant `json:"participants"`
+}
+
+// RiderTipData is sent by a rider to tip the driver of a completed trip
+type RiderTipData struct {
+	TripID string  `json:"tripID"`
+	Amount float64 `json:"amount"`
+}
+
+// PaymentEventTipData tells a driver they received a tip
+type PaymentEventTipData struct {
+	TripID   string  `json:"tripID"`
+	UserID   string  `json:"userID"`
+	DriverID string  `json:"driverID"`
+	Amount   float64 `json:"amount"`
+	Currency string  `json:"currency"`
+}
+
 type PaymentStatusUpdateData struct {
-	TripID   string `json:"tripID"`
-	UserID   string `json:"userID"`
-	DriverID string `json:"driverID"`
+	TripID    string `json:"tripID"`
+	UserID    string `json:"userID"`
+	DriverID  string `json:"driverID"`
+	SessionID string `json:"sessionID,omitempty"`
+	Reason    string `json:"reason,omitempty"`
+}
+
+type PaymentEventRetryData struct {
+	TripID      string  `json:"tripID"`
+	SessionID   string  `json:"sessionID"`
+	Amount      float64 `json:"amount"`
+	Currency    string  `json:"currency"`
+	Attempt     int     `json:"attempt"`
+	MaxAttempts int     `json:"maxAttempts"`
+	Reason      string  `json:"reason,omitempty"`
+}
+
+type PaymentEventDunningData struct {
+	TripID   string  `json:"tripID"`
+	UserID   string  `json:"userID"`
+	DriverID string  `json:"driverID"`
+	Amount   float64 `json:"amount"`
+	Currency string  `json:"currency"`
+	Attempts int     `json:"attempts"`
+}
+
+type PaymentEventRefundedData struct {
+	TripID        string  `json:"tripID"`
+	RefundID      string  `json:"refundID"`
+	UserID        string  `json:"userID"`
+	DriverID      string  `json:"driverID"`
+	Amount        float64 `json:"amount"`
+	TotalRefunded float64 `json:"totalRefunded"`
+	Currency      string  `json:"currency"`
+	Reason        string  `json:"reason,omitempty"`
 }
@@ -0,0 +1,334 @@
+package messaging
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/AuraReaper/voom/shared/contracts"
+	"github.com/google/uuid"
+	amqp "github.com/rabbitmq/amqp091-go"
+)
+
+// InmemBus is a Bus within one process, so the consumers of several services can be exercised
+// together without a broker. Messages are routed like the topic exchange routes them to the queues
+// of the topologies it was created with and the declared ones, and are handed to the consumers only by Drain.
+type InmemBus struct {
+	serviceName string
+
+	mu          sync.Mutex
+	queues      map[string]*inmemQueue
+	order       []string // queue names in declaration order, drained in turn
+	cursor      int      // index in order of the queue drained next
+	dedup       DedupStore
+	contentType string
+	deliveryTag uint64
+}
+
+type inmemQueue struct {
+	routingKeys []string // binding keys, * and # match words as on a topic exchange
+	ready       []amqp.Delivery
+	consumers   []MessageHandler
+	next        int // consumer the next message is handed to
+	deadLetters []amqp.Delivery
+}
+
+// NewInmemBus returns a bus with the queues of topology and peers declared. Messages are
+// published as topology.Service.
+func NewInmemBus(topology ServiceTopology, peers ...ServiceTopology) *InmemBus {
+	b := &InmemBus{
+		serviceName: topology.Service,
+		queues:      make(map[string]*inmemQueue),
+		contentType: ContentTypeJSON,
+	}
+
+	for _, t := range slices.Concat([]ServiceTopology{topology}, peers) {
+		for _, binding := range t.Queues {
+			_ = b.DeclareQueue(binding.Queue, binding.RoutingKeys)
+		}
+	}
+
+	return b
+}
+
+func (b *InmemBus) UseDeduplication(store DedupStore) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.dedup = store
+}
+
+// UsePayloadContentType sets the content type Publish and PublishMessage encode payloads as
+func (b *InmemBus) UsePayloadContentType(contentType string) error {
+	switch contentType {
+	case ContentTypeJSON, ContentTypeProtobuf:
+	default:
+		return fmt.Errorf("%w: %s", ErrUnsupportedContentType, contentType)
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.contentType = contentType
+	return nil
+}
+
+func (b *InmemBus) payloadContentType(routingKey string) string {
+	b.mu.Lock()
+	contentType := b.contentType
+	b.mu.Unlock()
+
+	if contentType == ContentTypeProtobuf && lookupProtoCodec(routingKey) != nil {
+		return ContentTypeProtobuf
+	}
+	return ContentTypeJSON
+}
+
+// DeclareQueue declares queueName bound to routingKeys. Declaring a queue again adds the
+// routing keys it isn't bound to yet.
+func (b *InmemBus) DeclareQueue(queueName string, routingKeys []string) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	q, ok := b.queues[queueName]
+	if !ok {
+		q = &inmemQueue{}
+		b.queues[queueName] = q
+		b.order = append(b.order, queueName)
+	}
+
+	for _, key := range routingKeys {
+		if !slices.Contains(q.routingKeys, key) {
+			q.routingKeys = append(q.routingKeys, key)
+		}
+	}
+
+	return nil
+}
+
+// PublishMessage publishes message under a new unique message ID
+func (b *InmemBus) PublishMessage(ctx context.Context, routingKey string, message contracts.AmqpMessage) error {
+	return b.PublishMessageWithID(ctx, uuid.NewString(), routingKey, message)
+}
+
+// PublishMessageWithID publishes message under messageID, its data must be JSON
+func (b *InmemBus) PublishMessageWithID(ctx context.Context, messageID, routingKey string, message contracts.AmqpMessage) error {
+	contentType := b.payloadContentType(routingKey)
+
+	message, err := transcodeMessage(routingKey, contentType, message)
+	if err != nil {
+		return err
+	}
+
+	return b.publishEnvelope(ctx, messageID, routingKey, contentType, message)
+}
+
+// publishEnvelope queues message on every queue bound to routingKey. Like the mandatory publishes
+// of RabbitMQ, a message no queue is bound to fails with ErrUnroutable.
+func (b *InmemBus) publishEnvelope(ctx context.Context, messageID, routingKey, contentType string, message contracts.AmqpMessage) error {
+	msg, err := newPublishing(ctx, b.serviceName, messageID, routingKey, contentType, message)
+	if err != nil {
+		return err
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	routed := false
+	for _, name := range b.order {
+		q := b.queues[name]
+		if !slices.ContainsFunc(q.routingKeys, func(key string) bool { return matchTopic(key, routingKey) }) {
+			continue
+		}
+
+		b.deliveryTag++
+		q.ready = append(q.ready, amqp.Delivery{
+			Headers:       amqp.Table{},
+			ContentType:   msg.ContentType,
+			DeliveryMode:  msg.DeliveryMode,
+			CorrelationId: msg.CorrelationId,
+			MessageId:     msg.MessageId,
+			Timestamp:     msg.Timestamp,
+			Type:          msg.Type,
+			AppId:         msg.AppId,
+			DeliveryTag:   b.deliveryTag,
+			Exchange:      TripExchange,
+			RoutingKey:    routingKey,
+			Body:          msg.Body,
+		})
+		routed = true
+	}
+
+	if !routed {
+		return fmt.Errorf("failed to publish %s: %w", routingKey, ErrUnroutable)
+	}
+	return nil
+}
+
+// ConsumeMessages adds handler to the consumers of queueName, the messages of a queue are handed
+// to its consumers in turn. Drain handles one message at a time, so opts change nothing and every
+// queue is handled in order.
+func (b *InmemBus) ConsumeMessages(queueName string, handler MessageHandler, opts ...ConsumerOption) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	q, ok := b.queues[queueName]
+	if !ok {
+		return fmt.Errorf("failed to consume %s: queue not declared", queueName)
+	}
+
+	if b.dedup != nil {
+		handler = Deduplicate(b.dedup, queueName, handler)
+	}
+	q.consumers = append(q.consumers, handler)
+
+	return nil
+}
+
+// Drain hands the queued messages to the consumers one at a time until no queue with a consumer
+// has messages left, including the ones the consumers published meanwhile and the failed ones
+// handed back for a retry. It returns how many messages were handled.
+func (b *InmemBus) Drain(ctx context.Context) (int, error) {
+	handled := 0
+	for {
+		if err := ctx.Err(); err != nil {
+			return handled, err
+		}
+
+		queue, d, handler, ok := b.nextDelivery()
+		if !ok {
+			return handled, nil
+		}
+
+		if err := handler(WithCorrelationID(ctx, d.CorrelationId), d); err != nil {
+			b.handleFailure(queue, d, err)
+		}
+		handled++
+	}
+}
+
+// nextDelivery takes the next message off the queues that have consumers, visiting the queues in
+// turn so a consumer publishing to its own queue doesn't starve the others
+func (b *InmemBus) nextDelivery() (string, amqp.Delivery, MessageHandler, bool) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	for i := range b.order {
+		name := b.order[(b.cursor+i)%len(b.order)]
+		q := b.queues[name]
+		if len(q.consumers) == 0 || len(q.ready) == 0 {
+			continue
+		}
+
+		d := q.ready[0]
+		q.ready = q.ready[1:]
+		handler := q.consumers[q.next%len(q.consumers)]
+		q.next++
+		b.cursor = (b.cursor + i + 1) % len(b.order)
+
+		return name, d, handler, true
+	}
+
+	return "", amqp.Delivery{}, nil, false
+}
+
+// handleFailure hands a failed message back to its queue marked as redelivered, without the
+// backoff of RabbitMQ. Permanent failures and messages out of retries are dead-lettered.
+func (b *InmemBus) handleFailure(queue string, d amqp.Delivery, cause error) {
+	retries := retryCount(d)
+
+	headers := amqp.Table{}
+	for k, v := range d.Headers {
+		headers[k] = v
+	}
+	d.Headers = headers
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	q := b.queues[queue]
+
+	if IsPermanent(cause) || retries >= len(RetryDelays) {
+		log.Printf("Message %s failed in %s after %d retries, dead-lettering it: %v", d.MessageId, queue, retries, cause)
+		headers[HeaderFailureError] = cause.Error()
+		headers[HeaderFailureAttempts] = int64(retries + 1)
+		headers[HeaderFailureService] = b.serviceName
+		headers[HeaderFailureQueue] = queue
+		headers[HeaderFailedAt] = time.Now().UTC()
+		q.deadLetters = append(q.deadLetters, d)
+		return
+	}
+
+	headers[HeaderRetryCount] = int64(retries + 1)
+	d.Redelivered = true
+	q.ready = append(q.ready, d)
+}
+
+// Pending returns the messages queued on queueName that no consumer took yet
+func (b *InmemBus) Pending(queueName string) []amqp.Delivery {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if q, ok := b.queues[queueName]; ok {
+		return slices.Clone(q.ready)
+	}
+	return nil
+}
+
+// DeadLetters returns the messages dead-lettered from queueName
+func (b *InmemBus) DeadLetters(queueName string) []DeadLetter {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	q, ok := b.queues[queueName]
+	if !ok {
+		return nil
+	}
+
+	letters := make([]DeadLetter, 0, len(q.deadLetters))
+	for _, d := range q.deadLetters {
+		letters = append(letters, toDeadLetter(queueName, d))
+	}
+	return letters
+}
+
+// Purge drops the pending messages and dead letters of every queue
+func (b *InmemBus) Purge() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	for _, q := range b.queues {
+		q.ready = nil
+		q.deadLetters = nil
+	}
+}
+
+// matchTopic reports whether routingKey matches the binding key of a topic exchange, where *
+// matches exactly one word and # zero or more
+func matchTopic(bindingKey, routingKey string) bool {
+	return matchWords(strings.Split(bindingKey, "."), strings.Split(routingKey, "."))
+}
+
+func matchWords(pattern, words []string) bool {
+	if len(pattern) == 0 {
+		return len(words) == 0
+	}
+
+	switch pattern[0] {
+	case "#":
+		for i := 0; i <= len(words); i++ {
+			if matchWords(pattern[1:], words[i:]) {
+				return true
+			}
+		}
+		return false
+	case "*":
+		return len(words) > 0 && matchWords(pattern[1:], words[1:])
+	default:
+		return len(words) > 0 && pattern[0] == words[0] && matchWords(pattern[1:], words[1:])
+	}
+}
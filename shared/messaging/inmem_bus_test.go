@@ -0,0 +1,243 @@
+package messaging
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/AuraReaper/voom/shared/contracts"
+	amqp "github.com/rabbitmq/amqp091-go"
+)
+
+func TestMatchTopic(t *testing.T) {
+	tests := []struct {
+		bindingKey string
+		routingKey string
+		want       bool
+	}{
+		{"trip.event.created", "trip.event.created", true},
+		{"trip.event.created", "trip.event.completed", false},
+		{"trip.event.*", "trip.event.created", true},
+		{"trip.event.*", "trip.event", false},
+		{"trip.event.*", "trip.event.driver.assigned", false},
+		{"trip.*.created", "trip.event.created", true},
+		{"*", "trip", true},
+		{"*", "trip.event", false},
+		{"trip.#", "trip", true},
+		{"trip.#", "trip.event", true},
+		{"trip.#", "trip.event.driver.assigned", true},
+		{"trip.#", "payment.event", false},
+		{"#", "payment.event.success", true},
+		{"#.success", "payment.event.success", true},
+		{"#.success", "success", true},
+		{"#.success", "payment.event.failed", false},
+		{"trip.#.assigned", "trip.assigned", true},
+		{"trip.#.assigned", "trip.event.driver.assigned", true},
+		{"trip.*.#", "trip", false},
+		{"trip.*.#", "trip.event", true},
+	}
+
+	for _, tt := range tests {
+		if got := matchTopic(tt.bindingKey, tt.routingKey); got != tt.want {
+			t.Errorf("matchTopic(%q, %q) = %v, want %v", tt.bindingKey, tt.routingKey, got, tt.want)
+		}
+	}
+}
+
+func TestInmemBusRoutesByTopic(t *testing.T) {
+	ctx := context.Background()
+	bus := NewInmemBus(ServiceTopology{Service: "test"})
+
+	declare(t, bus, "test.one-word", "test.*.created")
+	declare(t, bus, "test.any-words", "test.#")
+	declare(t, bus, "test.exact", "test.trip.created")
+
+	routes := map[string][]string{
+		"test.trip.created":        {"test.one-word", "test.any-words", "test.exact"},
+		"test.driver.created":      {"test.one-word", "test.any-words"},
+		"test.created":             {"test.any-words"},
+		"test.trip.driver.created": {"test.any-words"},
+	}
+
+	for routingKey, queues := range routes {
+		bus.Purge()
+		if err := bus.PublishMessage(ctx, routingKey, message(t)); err != nil {
+			t.Fatalf("publishing %s: %v", routingKey, err)
+		}
+
+		for _, queue := range []string{"test.one-word", "test.any-words", "test.exact"} {
+			want := 0
+			for _, q := range queues {
+				if q == queue {
+					want = 1
+				}
+			}
+			if got := len(bus.Pending(queue)); got != want {
+				t.Errorf("%s reached %s %d times, want %d", routingKey, queue, got, want)
+			}
+		}
+	}
+
+	if err := bus.PublishMessage(ctx, "other.trip.created", message(t)); !errors.Is(err, ErrUnroutable) {
+		t.Errorf("publishing a key no queue is bound to returned %v, want ErrUnroutable", err)
+	}
+}
+
+func TestInmemBusRetriesFailedMessages(t *testing.T) {
+	ctx := context.Background()
+	bus := NewInmemBus(ServiceTopology{Service: "test"})
+	declare(t, bus, "test.retry", "test.retry")
+
+	var redelivered []bool
+	if err := bus.ConsumeMessages("test.retry", func(ctx context.Context, d amqp.Delivery) error {
+		redelivered = append(redelivered, d.Redelivered)
+		if len(redelivered) == 1 {
+			return errors.New("temporary failure")
+		}
+		return nil
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := bus.PublishMessage(ctx, "test.retry", message(t)); err != nil {
+		t.Fatal(err)
+	}
+
+	handled, err := bus.Drain(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if handled != 2 || len(redelivered) != 2 {
+		t.Fatalf("handled %d messages in %d calls, want the message and its retry", handled, len(redelivered))
+	}
+	if redelivered[0] || !redelivered[1] {
+		t.Errorf("redelivered flags are %v, want only the retry redelivered", redelivered)
+	}
+	if letters := bus.DeadLetters("test.retry"); len(letters) != 0 {
+		t.Errorf("got %d dead letters, want none once the retry succeeded", len(letters))
+	}
+}
+
+func TestInmemBusDeadLettersMessagesOutOfRetries(t *testing.T) {
+	ctx := context.Background()
+	bus := NewInmemBus(ServiceTopology{Service: "test"})
+	declare(t, bus, "test.failing", "test.failing")
+
+	calls := 0
+	if err := bus.ConsumeMessages("test.failing", func(ctx context.Context, d amqp.Delivery) error {
+		calls++
+		return errors.New("always fails")
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := bus.PublishMessageWithID(ctx, "message-1", "test.failing", message(t)); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := bus.Drain(ctx); err != nil {
+		t.Fatal(err)
+	}
+
+	// The first attempt and one per retry tier
+	if want := len(RetryDelays) + 1; calls != want {
+		t.Errorf("handler was called %d times, want %d", calls, want)
+	}
+	if pending := bus.Pending("test.failing"); len(pending) != 0 {
+		t.Errorf("%d messages are still pending", len(pending))
+	}
+
+	letters := bus.DeadLetters("test.failing")
+	if len(letters) != 1 {
+		t.Fatalf("got %d dead letters, want 1", len(letters))
+	}
+
+	letter := letters[0]
+	if letter.MessageID != "message-1" || letter.RoutingKey != "test.failing" {
+		t.Errorf("dead letter is %s (%s), want message-1 (test.failing)", letter.MessageID, letter.RoutingKey)
+	}
+	if letter.Error != "always fails" || letter.Attempts != int64(len(RetryDelays)+1) {
+		t.Errorf("dead letter failed with %q after %d attempts, want %q after %d", letter.Error, letter.Attempts, "always fails", len(RetryDelays)+1)
+	}
+	if letter.Service != "test" || letter.Queue != "test.failing" {
+		t.Errorf("dead letter failed in %s/%s, want test/test.failing", letter.Service, letter.Queue)
+	}
+}
+
+func TestInmemBusDeadLettersPermanentFailuresRightAway(t *testing.T) {
+	ctx := context.Background()
+	bus := NewInmemBus(ServiceTopology{Service: "test"})
+	declare(t, bus, "test.rejected", "test.rejected")
+
+	calls := 0
+	if err := bus.ConsumeMessages("test.rejected", func(ctx context.Context, d amqp.Delivery) error {
+		calls++
+		return Permanent(errors.New("malformed"))
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := bus.PublishMessage(ctx, "test.rejected", message(t)); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := bus.Drain(ctx); err != nil {
+		t.Fatal(err)
+	}
+
+	if calls != 1 {
+		t.Errorf("handler was called %d times, want a permanent failure not to be retried", calls)
+	}
+	if letters := bus.DeadLetters("test.rejected"); len(letters) != 1 || letters[0].Attempts != 1 {
+		t.Errorf("got dead letters %+v, want one after a single attempt", letters)
+	}
+}
+
+func TestInmemBusDrainsMessagesPublishedByConsumers(t *testing.T) {
+	ctx := context.Background()
+	bus := NewInmemBus(ServiceTopology{Service: "test"})
+	declare(t, bus, "test.first", "test.first")
+	declare(t, bus, "test.second", "test.second")
+
+	var order []string
+	if err := bus.ConsumeMessages("test.first", func(ctx context.Context, d amqp.Delivery) error {
+		order = append(order, d.RoutingKey)
+		return bus.PublishMessage(ctx, "test.second", message(t))
+	}); err != nil {
+		t.Fatal(err)
+	}
+	if err := bus.ConsumeMessages("test.second", func(ctx context.Context, d amqp.Delivery) error {
+		order = append(order, d.RoutingKey)
+		return nil
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := bus.PublishMessage(ctx, "test.first", message(t)); err != nil {
+		t.Fatal(err)
+	}
+
+	handled, err := bus.Drain(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if handled != 2 || len(order) != 2 || order[0] != "test.first" || order[1] != "test.second" {
+		t.Errorf("handled %d messages in order %v, want test.first then test.second", handled, order)
+	}
+}
+
+func declare(t *testing.T, bus *InmemBus, queue string, routingKeys ...string) {
+	t.Helper()
+
+	if err := bus.DeclareQueue(queue, routingKeys); err != nil {
+		t.Fatalf("declaring %s: %v", queue, err)
+	}
+}
+
+func message(t *testing.T) contracts.AmqpMessage {
+	t.Helper()
+
+	return contracts.AmqpMessage{OwnerID: "user-1", Data: []byte(`{"tripID":"trip-1"}`)}
+}
@@ -0,0 +1,273 @@
+package messaging
+
+import (
+	pbe "github.com/AuraReaper/voom/shared/proto/events"
+)
+
+// Conversions between the payloads and their proto messages in proto/events.proto
+
+func tripEventToProto(p TripEventData) *pbe.TripEvent {
+	return &pbe.TripEvent{Trip: p.Trip}
+}
+
+func tripEventFromProto(m *pbe.TripEvent) TripEventData {
+	return TripEventData{Trip: m.Trip}
+}
+
+func tripEventSplitToProto(p TripEventSplitData) *pbe.TripEventSplit {
+	return &pbe.TripEventSplit{
+		TripID:       p.TripID,
+		OwnerID:      p.OwnerID,
+		Fare:         p.Fare,
+		Participants: p.Participants,
+	}
+}
+
+func tripEventSplitFromProto(m *pbe.TripEventSplit) TripEventSplitData {
+	return TripEventSplitData{
+		TripID:       m.TripID,
+		OwnerID:      m.OwnerID,
+		Fare:         m.Fare,
+		Participants: m.Participants,
+	}
+}
+
+func driverTripResponseToProto(p DriverTripResponseData) *pbe.DriverTripResponse {
+	return &pbe.DriverTripResponse{
+		Driver:  p.Driver,
+		TripID:  p.TripID,
+		RiderID: p.RiderID,
+	}
+}
+
+func driverTripResponseFromProto(m *pbe.DriverTripResponse) DriverTripResponseData {
+	return DriverTripResponseData{
+		Driver:  m.Driver,
+		TripID:  m.TripID,
+		RiderID: m.RiderID,
+	}
+}
+
+func riderTipToProto(p RiderTipData) *pbe.RiderTip {
+	return &pbe.RiderTip{TripID: p.TripID, Amount: p.Amount}
+}
+
+func riderTipFromProto(m *pbe.RiderTip) RiderTipData {
+	return RiderTipData{TripID: m.TripID, Amount: m.Amount}
+}
+
+func riderSplitInviteToProto(p RiderSplitInviteData) *pbe.RiderSplitInvite {
+	return &pbe.RiderSplitInvite{TripID: p.TripID, UserIDs: p.UserIDs}
+}
+
+func riderSplitInviteFromProto(m *pbe.RiderSplitInvite) RiderSplitInviteData {
+	return RiderSplitInviteData{TripID: m.TripID, UserIDs: m.UserIDs}
+}
+
+func riderSplitRespondToProto(p RiderSplitRespondData) *pbe.RiderSplitRespond {
+	return &pbe.RiderSplitRespond{TripID: p.TripID, Accept: p.Accept}
+}
+
+func riderSplitRespondFromProto(m *pbe.RiderSplitRespond) RiderSplitRespondData {
+	return RiderSplitRespondData{TripID: m.TripID, Accept: m.Accept}
+}
+
+func paymentSessionCreatedToProto(p PaymentEventSessionCreatedData) *pbe.PaymentSessionCreated {
+	return &pbe.PaymentSessionCreated{
+		TripID:    p.TripID,
+		SessionID: p.SessionID,
+		Amount:    p.Amount,
+		Currency:  p.Currency,
+	}
+}
+
+func paymentSessionCreatedFromProto(m *pbe.PaymentSessionCreated) PaymentEventSessionCreatedData {
+	return PaymentEventSessionCreatedData{
+		TripID:    m.TripID,
+		SessionID: m.SessionID,
+		Amount:    m.Amount,
+		Currency:  m.Currency,
+	}
+}
+
+func paymentStatusUpdateToProto(p PaymentStatusUpdateData) *pbe.PaymentStatusUpdate {
+	return &pbe.PaymentStatusUpdate{
+		TripID:    p.TripID,
+		UserID:    p.UserID,
+		DriverID:  p.DriverID,
+		SessionID: p.SessionID,
+		Reason:    p.Reason,
+	}
+}
+
+func paymentStatusUpdateFromProto(m *pbe.PaymentStatusUpdate) PaymentStatusUpdateData {
+	return PaymentStatusUpdateData{
+		TripID:    m.TripID,
+		UserID:    m.UserID,
+		DriverID:  m.DriverID,
+		SessionID: m.SessionID,
+		Reason:    m.Reason,
+	}
+}
+
+func paymentRetryToProto(p PaymentEventRetryData) *pbe.PaymentRetry {
+	return &pbe.PaymentRetry{
+		TripID:      p.TripID,
+		SessionID:   p.SessionID,
+		Amount:      p.Amount,
+		Currency:    p.Currency,
+		Attempt:     int32(p.Attempt),
+		MaxAttempts: int32(p.MaxAttempts),
+		Reason:      p.Reason,
+	}
+}
+
+func paymentRetryFromProto(m *pbe.PaymentRetry) PaymentEventRetryData {
+	return PaymentEventRetryData{
+		TripID:      m.TripID,
+		SessionID:   m.SessionID,
+		Amount:      m.Amount,
+		Currency:    m.Currency,
+		Attempt:     int(m.Attempt),
+		MaxAttempts: int(m.MaxAttempts),
+		Reason:      m.Reason,
+	}
+}
+
+func paymentDunningToProto(p PaymentEventDunningData) *pbe.PaymentDunning {
+	return &pbe.PaymentDunning{
+		TripID:   p.TripID,
+		UserID:   p.UserID,
+		DriverID: p.DriverID,
+		Amount:   p.Amount,
+		Currency: p.Currency,
+		Attempts: int32(p.Attempts),
+	}
+}
+
+func paymentDunningFromProto(m *pbe.PaymentDunning) PaymentEventDunningData {
+	return PaymentEventDunningData{
+		TripID:   m.TripID,
+		UserID:   m.UserID,
+		DriverID: m.DriverID,
+		Amount:   m.Amount,
+		Currency: m.Currency,
+		Attempts: int(m.Attempts),
+	}
+}
+
+func paymentRefundedToProto(p PaymentEventRefundedData) *pbe.PaymentRefunded {
+	return &pbe.PaymentRefunded{
+		TripID:        p.TripID,
+		RefundID:      p.RefundID,
+		UserID:        p.UserID,
+		DriverID:      p.DriverID,
+		Amount:        p.Amount,
+		TotalRefunded: p.TotalRefunded,
+		Currency:      p.Currency,
+		Reason:        p.Reason,
+	}
+}
+
+func paymentRefundedFromProto(m *pbe.PaymentRefunded) PaymentEventRefundedData {
+	return PaymentEventRefundedData{
+		TripID:        m.TripID,
+		RefundID:      m.RefundID,
+		UserID:        m.UserID,
+		DriverID:      m.DriverID,
+		Amount:        m.Amount,
+		TotalRefunded: m.TotalRefunded,
+		Currency:      m.Currency,
+		Reason:        m.Reason,
+	}
+}
+
+func paymentTipToProto(p PaymentEventTipData) *pbe.PaymentTipReceived {
+	return &pbe.PaymentTipReceived{
+		TripID:   p.TripID,
+		UserID:   p.UserID,
+		DriverID: p.DriverID,
+		Amount:   p.Amount,
+		Currency: p.Currency,
+	}
+}
+
+func paymentTipFromProto(m *pbe.PaymentTipReceived) PaymentEventTipData {
+	return PaymentEventTipData{
+		TripID:   m.TripID,
+		UserID:   m.UserID,
+		DriverID: m.DriverID,
+		Amount:   m.Amount,
+		Currency: m.Currency,
+	}
+}
+
+func paymentCreateSessionToProto(p PaymentTripResponseData) *pbe.PaymentCreateSession {
+	return &pbe.PaymentCreateSession{
+		TripID:   p.TripID,
+		UserID:   p.UserID,
+		DriverID: p.DriverID,
+		Amount:   p.Amount,
+		Currency: p.Currency,
+	}
+}
+
+func paymentCreateSessionFromProto(m *pbe.PaymentCreateSession) PaymentTripResponseData {
+	return PaymentTripResponseData{
+		TripID:   m.TripID,
+		UserID:   m.UserID,
+		DriverID: m.DriverID,
+		Amount:   m.Amount,
+		Currency: m.Currency,
+	}
+}
+
+func paymentAuthorizeToProto(p PaymentAuthorizeData) *pbe.PaymentAuthorize {
+	return &pbe.PaymentAuthorize{
+		TripID:   p.TripID,
+		UserID:   p.UserID,
+		Amount:   p.Amount,
+		Currency: p.Currency,
+	}
+}
+
+func paymentAuthorizeFromProto(m *pbe.PaymentAuthorize) PaymentAuthorizeData {
+	return PaymentAuthorizeData{
+		TripID:   m.TripID,
+		UserID:   m.UserID,
+		Amount:   m.Amount,
+		Currency: m.Currency,
+	}
+}
+
+func paymentCaptureToProto(p PaymentCaptureData) *pbe.PaymentCapture {
+	return &pbe.PaymentCapture{
+		TripID:        p.TripID,
+		UserID:        p.UserID,
+		DriverID:      p.DriverID,
+		Amount:        p.Amount,
+		Currency:      p.Currency,
+		PaymentMethod: p.PaymentMethod,
+		SplitWith:     p.SplitWith,
+	}
+}
+
+func paymentCaptureFromProto(m *pbe.PaymentCapture) PaymentCaptureData {
+	return PaymentCaptureData{
+		TripID:        m.TripID,
+		UserID:        m.UserID,
+		DriverID:      m.DriverID,
+		Amount:        m.Amount,
+		Currency:      m.Currency,
+		PaymentMethod: m.PaymentMethod,
+		SplitWith:     m.SplitWith,
+	}
+}
+
+func paymentVoidToProto(p PaymentVoidData) *pbe.PaymentVoid {
+	return &pbe.PaymentVoid{TripID: p.TripID, Reason: p.Reason}
+}
+
+func paymentVoidFromProto(m *pbe.PaymentVoid) PaymentVoidData {
+	return PaymentVoidData{TripID: m.TripID, Reason: m.Reason}
+}
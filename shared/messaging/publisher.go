@@ -0,0 +1,111 @@
+package messaging
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	amqp "github.com/rabbitmq/amqp091-go"
+)
+
+// HeaderPublishID tells the publish a message returned by the broker belongs to
+const HeaderPublishID = "x-publish-id"
+
+// PublishConfirmTimeout bounds the wait for the broker's confirm of a publish whose context has no deadline
+const PublishConfirmTimeout = 5 * time.Second
+
+var (
+	// ErrPublishNacked is returned when the broker refused to take responsibility for a message
+	ErrPublishNacked = errors.New("message nacked by RabbitMQ")
+	// ErrUnroutable is returned when no queue is bound for the routing key of a message
+	ErrUnroutable = errors.New("message unroutable")
+)
+
+// publisher publishes on a channel in confirm mode. Messages are published as mandatory and one
+// at a time, so a message returned by the broker is always seen by the publish it belongs to.
+type publisher struct {
+	mu      sync.Mutex
+	channel *amqp.Channel
+	returns chan amqp.Return
+}
+
+func openPublisher(conn *amqp.Connection) (*publisher, error) {
+	ch, err := conn.Channel()
+	if err != nil {
+		return nil, fmt.Errorf("failed to create publishing channel: %v", err)
+	}
+
+	if err := ch.Confirm(false); err != nil {
+		ch.Close()
+		return nil, fmt.Errorf("failed to put publishing channel in confirm mode: %v", err)
+	}
+
+	return &publisher{
+		channel: ch,
+		// Buffered so returns of publishes that gave up waiting don't hold up the connection
+		returns: ch.NotifyReturn(make(chan amqp.Return, 64)),
+	}, nil
+}
+
+// publish publishes msg and waits until the broker confirmed it. It fails when the message was
+// nacked, returned as unroutable, or not confirmed before ctx is done.
+func (p *publisher) publish(ctx context.Context, exchange, routingKey string, msg amqp.Publishing) error {
+	if _, ok := ctx.Deadline(); !ok {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, PublishConfirmTimeout)
+		defer cancel()
+	}
+
+	id := uuid.NewString()
+	headers := amqp.Table{}
+	for k, v := range msg.Headers {
+		headers[k] = v
+	}
+	headers[HeaderPublishID] = id
+	msg.Headers = headers
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	confirm, err := p.channel.PublishWithDeferredConfirmWithContext(ctx,
+		exchange,   // exchange
+		routingKey, // routing key
+		true,       // mandatory
+		false,      // immediate
+		msg,
+	)
+	if err != nil {
+		return err
+	}
+
+	acked, err := confirm.WaitContext(ctx)
+	if err != nil {
+		return fmt.Errorf("no confirm for %s: %w", routingKey, err)
+	}
+
+	// The broker returns an unroutable message before confirming it, so its return is already buffered
+drain:
+	for {
+		select {
+		case ret := <-p.returns:
+			if ret.Headers[HeaderPublishID] == id {
+				return fmt.Errorf("%w: no queue bound for %s on exchange %q: %s", ErrUnroutable, routingKey, exchange, ret.ReplyText)
+			}
+		default:
+			break drain
+		}
+	}
+
+	if !acked {
+		return ErrPublishNacked
+	}
+
+	return nil
+}
+
+func (p *publisher) close() {
+	p.channel.Close()
+}
@@ -1,10 +1,11 @@
 package messaging
 
 import (
-	"encoding/json"
+	"context"
 	"log"
 
 	"github.com/AuraReaper/voom/shared/contracts"
+	amqp "github.com/rabbitmq/amqp091-go"
 )
 
 type QueueConsumer struct {
@@ -21,51 +22,36 @@ func NewQueueConsumer(rb *RabbitMQ, connMgr *ConnectionManager, queueName string
 	}
 }
 
-func (qc *QueueConsumer) Start() error {
-	msgs, err := qc.rb.Channel.Consume(
-		qc.queueName,
-		"",
-		true,
-		false,
-		false,
-		false,
-		nil,
-	)
+// Start consumes the queue, and again after every reconnect to the broker. Messages for users
+// that aren't connected are dropped.
+func (qc *QueueConsumer) Start(opts ...ConsumerOption) error {
+	return qc.rb.ConsumeMessages(qc.queueName, qc.forward, opts...)
+}
+
+// forward sends a message to the websocket of its owner
+func (qc *QueueConsumer) forward(ctx context.Context, msg amqp.Delivery) error {
+	// Registered payloads are decoded into their type, so JSON and protobuf producers
+	// reach the clients as the same JSON
+	env, payload, err := DecodePayload(msg)
 	if err != nil {
+		log.Println("Failed to decode message:", err)
 		return err
 	}
 
-	go func() {
-		for msg := range msgs {
-			var msgBody contracts.AmqpMessage
-			if err := json.Unmarshal(msg.Body, &msgBody); err != nil {
-				log.Println("Failed to unmarshal message:", err)
-				continue
-			}
-
-			userID := msgBody.OwnerID
+	userID := env.OwnerID
 
-			var payload any
-			if msgBody.Data != nil {
-				if err := json.Unmarshal(msgBody.Data, &payload); err != nil {
-					log.Println("Failed to unmarshal payload:", err)
-					continue
-				}
-			}
-
-			clientMsg := contracts.WSMessage{
-				Type: msg.RoutingKey,
-				Data: payload,
-			}
+	clientMsg := contracts.WSMessage{
+		Type: msg.RoutingKey,
+		Data: payload,
+	}
 
-			if err := qc.connMgr.SendMessage(userID, clientMsg); err != nil {
-							if err == ErrConnectionNotFound {
-								log.Printf("Failed to send message to user %s: connection not found", userID)
-							} else {
-								log.Printf("error sending message to user %s: %v", userID, err)
-							}
-						}		}
-	}()
+	if err := qc.connMgr.SendMessage(userID, clientMsg); err != nil {
+		if err == ErrConnectionNotFound {
+			log.Printf("Failed to send message to user %s: connection not found", userID)
+		} else {
+			log.Printf("error sending message to user %s: %v", userID, err)
+		}
+	}
 
 	return nil
 }
@@ -2,252 +2,481 @@ package messaging
 
 import (
 	"context"
-	"encoding/json"
+	"errors"
 	"fmt"
 	"log"
+	"slices"
+	"sync"
+	"time"
 
 	"github.com/AuraReaper/voom/shared/contracts"
-	"github.com/AuraReaper/voom/shared/retry"
 	"github.com/AuraReaper/voom/shared/tracing"
+	"github.com/google/uuid"
 	amqp "github.com/rabbitmq/amqp091-go"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/metric"
 )
 
 const (
 	TripExchange       = "trip"
 )
 
+// ErrNotConnected is returned by publishes made while the broker connection is down
+var ErrNotConnected = errors.New("not connected to RabbitMQ")
+
+// Backoff between reconnection attempts after the broker connection was lost
+const (
+	reconnectInitialWait = 1 * time.Second
+	reconnectMaxWait     = 30 * time.Second
+)
+
+// RabbitMQ is a broker connection that recovers by itself. When the connection or its channel is
+// closed it reconnects with backoff, declares the topology again and restarts every consumer.
 type RabbitMQ struct {
-	conn    *amqp.Connection
-	Channel *amqp.Channel
+	uri         string
+	serviceName string // stamped on the messages this service publishes and dead-letters
+	topology    ServiceTopology
+	peers       []ServiceTopology // the other services, whose queues the messages of this one reach
+
+	mu        sync.RWMutex
+	conn      *amqp.Connection
+	channel   *amqp.Channel // declares the topology, the consumers have channels of their own
+	publisher *publisher
+	connected bool
+	consumers []*consumer    // started again on every new connection
+	queues    []QueueBinding // declared with DeclareQueue, next to the queues of topology
+	listeners []func(connected bool)
+
+	nacks       metric.Int64Counter
+	dedup       DedupStore // skips redelivered messages in the consumers when set
+	contentType string     // encoding of the payloads published with Publish
+
+	done      chan struct{}
+	closeOnce sync.Once
 }
 
-func NewRabbitMQ(uri string) (*RabbitMQ, error) {
-	conn, err := amqp.Dial(uri)
+// NewRabbitMQ connects topology.Service to the broker and declares its queues. It fails when a
+// routing key the service publishes reaches no queue of topology and peers, or when the queues of
+// peers it reaches are still not declared after routeWait.
+func NewRabbitMQ(uri string, topology ServiceTopology, peers ...ServiceTopology) (*RabbitMQ, error) {
+	// Exported by the meter provider the service set up with tracing.InitMeter
+	nacks, err := otel.Meter("messaging").Int64Counter("messaging.publish.nacks",
+		metric.WithDescription("Messages the broker nacked or returned as unroutable, by exchange and routing key"))
 	if err != nil {
-		return nil, fmt.Errorf("failed to connect to RabbitMQ: %v", err)
+		log.Printf("Failed to create the publish nacks counter: %v", err)
 	}
 
-	ch, err := conn.Channel()
-	if err != nil {
-		conn.Close()
-		return nil, fmt.Errorf("failed to create channel: %v", err)
+	if err := ValidateTopology(slices.Concat([]ServiceTopology{topology}, peers)...); err != nil {
+		return nil, fmt.Errorf("invalid topology: %w", err)
 	}
 
 	rmq := &RabbitMQ{
-		conn:    conn,
-		Channel: ch,
+		uri:         uri,
+		serviceName: topology.Service,
+		topology:    topology,
+		peers:       peers,
+		nacks:       nacks,
+		contentType: ContentTypeJSON,
+		done:        make(chan struct{}),
 	}
 
-	if err := rmq.setupExchangesAndQueues(); err != nil {
-		// Clean up if setup fails
+	if err := rmq.connect(); err != nil {
+		return nil, err
+	}
+
+	if err := rmq.verifyRoutes(); err != nil {
 		rmq.Close()
-		return nil, fmt.Errorf("failed to setup exchanges and queues: %v", err)
+		return nil, fmt.Errorf("unroutable messages: %w", err)
 	}
 
+	go rmq.watch()
+
 	return rmq, nil
 }
 
-type MessageHandler func(context.Context, amqp.Delivery) error
+// connect dials the broker, declares the topology and starts the registered consumers
+func (r *RabbitMQ) connect() error {
+	conn, err := amqp.Dial(r.uri)
+	if err != nil {
+		return fmt.Errorf("failed to connect to RabbitMQ: %v", err)
+	}
 
-func (r *RabbitMQ) ConsumeMessages(queueName string, handler MessageHandler) error {
-	// Set prefetch count to 1 for fair dispatch
-	err := r.Channel.Qos(
-		1,     // prefetchCount: Limit to 1 unacknowledged message per consumer
-		0,     // prefetchSize: No specific limit on message size
-		false, // global: Apply prefetchCount to each consumer individually
-	)
+	ch, err := conn.Channel()
 	if err != nil {
-		return fmt.Errorf("failed to set QoS: %v", err)
+		conn.Close()
+		return fmt.Errorf("failed to create channel: %v", err)
 	}
 
-	msgs, err := r.Channel.Consume(
-		queueName, // queue
-		"",        // consumer
-		false,     // auto-ack
-		false,     // exclusive
-		false,     // no-local
-		false,     // no-wait
-		nil,       // args
-	)
+	pub, err := openPublisher(conn)
 	if err != nil {
+		conn.Close()
 		return err
 	}
 
-	go func() {
-		for msg := range msgs {
-			if err := tracing.TracedConsumer(msg, func(ctx context.Context, d amqp.Delivery) error {
-				log.Printf("Received a message: %s", msg.Body)
-
-				cfg := retry.DefaultConfig()
-				err := retry.WithBackoff(ctx, cfg, func() error {
-					return handler(ctx, d)
-				})
-				if err != nil {
-					log.Printf("Message processing failed after %d retries for message ID: %s, err: %v", cfg.MaxRetries, d.MessageId, err)
-
-					// Reject without requeue
-					_ = d.Reject(false)
-					return err
-				}
-
-				// Only Ack if the handler succeeds
-				if ackErr := msg.Ack(false); ackErr != nil {
-					log.Printf("ERROR: Failed to Ack message: %v. Message body: %s", ackErr, msg.Body)
-				}
-
-				return nil
-			}); err != nil {
-				log.Printf("Error processing message: %v", err)
-			}
+	r.mu.Lock()
+	r.conn = conn
+	r.channel = ch
+	r.publisher = pub
+	r.mu.Unlock()
+
+	// Queues declared and consumers registered meanwhile wait for the lock, so each of them is
+	// declared and started exactly once
+	r.mu.Lock()
+	if err := r.setupExchangesAndQueues(); err != nil {
+		// Clean up if setup fails
+		r.mu.Unlock()
+		conn.Close()
+		return fmt.Errorf("failed to setup exchanges and queues: %v", err)
+	}
+
+	for _, c := range r.consumers {
+		if err := c.start(conn); err != nil {
+			r.mu.Unlock()
+			conn.Close()
+			return fmt.Errorf("failed to restart consumer: %v", err)
 		}
-	}()
+	}
+	r.mu.Unlock()
 
+	r.setConnected(true)
 	return nil
 }
 
-func (r *RabbitMQ) PublishMessage(ctx context.Context, routingKey string, message contracts.AmqpMessage) error {
-	log.Printf("Publishing message with routing key: %s", routingKey)
+// watch reconnects whenever the connection or its channel is closed, until Close is called
+func (r *RabbitMQ) watch() {
+	for {
+		r.mu.RLock()
+		conn, ch, pub := r.conn, r.channel, r.publisher
+		r.mu.RUnlock()
+
+		connClosed := conn.NotifyClose(make(chan *amqp.Error, 1))
+		chClosed := ch.NotifyClose(make(chan *amqp.Error, 1))
+		pubClosed := pub.channel.NotifyClose(make(chan *amqp.Error, 1))
+
+		select {
+		case <-r.done:
+			return
+		case err := <-connClosed:
+			log.Printf("RabbitMQ connection closed: %v", err)
+		case err := <-chClosed:
+			log.Printf("RabbitMQ channel closed: %v", err)
+		case err := <-pubClosed:
+			log.Printf("RabbitMQ publishing channel closed: %v", err)
+		}
 
-	jsonMsg, err := json.Marshal(message)
-	if err != nil {
-		return fmt.Errorf("failed to marshal message: %v", err)
+		r.setConnected(false)
+		// A closed channel leaves the connection open, it is replaced with its channels
+		conn.Close()
+
+		if !r.reconnect() {
+			return
+		}
+	}
+}
+
+// reconnect retries connecting with backoff, it returns false when Close was called meanwhile
+func (r *RabbitMQ) reconnect() bool {
+	wait := reconnectInitialWait
+	for {
+		select {
+		case <-r.done:
+			return false
+		case <-time.After(wait):
+		}
+
+		err := r.connect()
+		if err == nil {
+			log.Printf("Reconnected to RabbitMQ")
+			return true
+		}
+
+		log.Printf("Failed to reconnect to RabbitMQ, retrying in %v: %v", wait, err)
+		wait = min(wait*2, reconnectMaxWait)
 	}
+}
+
+// IsConnected reports whether the broker can currently be reached
+func (r *RabbitMQ) IsConnected() bool {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	return r.connected
+}
+
+// NotifyConnectionState calls fn with the current connection state and again every time it changes
+func (r *RabbitMQ) NotifyConnectionState(fn func(connected bool)) {
+	r.mu.Lock()
+	r.listeners = append(r.listeners, fn)
+	connected := r.connected
+	r.mu.Unlock()
 
-	msg := amqp.Publishing{
-		DeliveryMode: amqp.Persistent,
-		ContentType:  "application/json",
-		Body:         jsonMsg,
+	fn(connected)
+}
+
+func (r *RabbitMQ) setConnected(connected bool) {
+	r.mu.Lock()
+	changed := r.connected != connected
+	r.connected = connected
+	listeners := append([]func(bool){}, r.listeners...)
+	r.mu.Unlock()
+
+	if !changed {
+		return
 	}
+	for _, fn := range listeners {
+		fn(connected)
+	}
+}
 
-	return tracing.TracedPublisher(ctx, TripExchange, routingKey, msg, r.publish)
+// currentChannel returns the channel of the live connection, or ErrNotConnected during an outage
+func (r *RabbitMQ) currentChannel() (*amqp.Channel, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	if !r.connected {
+		return nil, ErrNotConnected
+	}
+	return r.channel, nil
 }
 
-func (r *RabbitMQ) publish(ctx context.Context, exchange, routingKey string, msg amqp.Publishing) error {
-	return r.Channel.PublishWithContext(ctx,
-		exchange,   // exchange
-		routingKey, // routing key
-		false,      // mandatory
-		false,      // immediate
-		msg,
-	)
+// currentPublisher returns the publisher of the live connection, or ErrNotConnected during an outage
+func (r *RabbitMQ) currentPublisher() (*publisher, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	if !r.connected {
+		return nil, ErrNotConnected
+	}
+	return r.publisher, nil
 }
 
-func (r *RabbitMQ) setupExchangesAndQueues() error {
-	err := r.Channel.ExchangeDeclare(
-		TripExchange, // name
-		"topic",      // type
-		true,         // durable
-		false,        // auto-deleted
-		false,        // internal
-		false,        // no-wait
-		nil,          // arguments
-	)
+// openChannel opens a channel of its own on the live connection
+func (r *RabbitMQ) openChannel() (*amqp.Channel, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	if !r.connected {
+		return nil, ErrNotConnected
+	}
+
+	ch, err := r.conn.Channel()
 	if err != nil {
-		return fmt.Errorf("failed to declare exchange: %s: %v", TripExchange, err)
+		return nil, fmt.Errorf("failed to create channel: %v", err)
 	}
+	return ch, nil
+}
 
-	if err := r.declareAndBindQueue(
-		FindAvailableDriversQueue,
-		[]string{
-			contracts.TripEventCreated, contracts.TripEventDriverNotInterested,
-		},
-		TripExchange,
-	); err != nil {
-		return err
+// addConsumer starts a consumer on the current connection, and again on every connection made
+// after the broker connection was lost
+func (r *RabbitMQ) addConsumer(c *consumer) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	// During an outage the consumer is started once the connection is back
+	if r.connected {
+		if err := c.start(r.conn); err != nil {
+			return err
+		}
 	}
 
-	if err := r.declareAndBindQueue(
-		DriverCmdTripRequestQueue,
-		[]string{contracts.DriverCmdTripRequest},
-		TripExchange,
-	); err != nil {
+	r.consumers = append(r.consumers, c)
+	return nil
+}
+
+type MessageHandler func(context.Context, amqp.Delivery) error
+
+// UseDeduplication makes the consumers started afterwards skip messages their queue already
+// processed, every queue is its own consumer group
+func (r *RabbitMQ) UseDeduplication(store DedupStore) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.dedup = store
+}
+
+// ConsumeMessages hands the messages of queueName to handler on a channel of its own. By default
+// one message is handled at a time, opts let a consumer handle several concurrently.
+func (r *RabbitMQ) ConsumeMessages(queueName string, handler MessageHandler, opts ...ConsumerOption) error {
+	if err := r.checkConsumes(queueName); err != nil {
 		return err
 	}
 
-	if err := r.declareAndBindQueue(
-		DriverTripResponseQueue,
-		[]string{contracts.DriverCmdTripAccept, contracts.DriverCmdTripDecline},
-		TripExchange,
-	); err != nil {
-		return err
+	r.mu.RLock()
+	dedup := r.dedup
+	r.mu.RUnlock()
+
+	if dedup != nil {
+		handler = Deduplicate(dedup, queueName, handler)
 	}
 
-	if err := r.declareAndBindQueue(
-		NotifyNoDriverFoundQueue,
-		[]string{contracts.TripEventNoDriversFound},
-		TripExchange,
-	); err != nil {
-		return err
+	return r.addConsumer(newConsumer(r, queueName, handler, newConsumerOptions(opts)))
+}
+
+// StopConsumers stops every consumer for good. They take no more messages, the ones they were
+// handed but didn't start go back to their queue and the ones being handled are finished until
+// ctx is done.
+func (r *RabbitMQ) StopConsumers(ctx context.Context) error {
+	r.mu.RLock()
+	consumers := slices.Clone(r.consumers)
+	r.mu.RUnlock()
+
+	errs := make([]error, len(consumers))
+	var wg sync.WaitGroup
+	for i, c := range consumers {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			errs[i] = c.stop(ctx)
+		}()
 	}
+	wg.Wait()
 
-	if err := r.declareAndBindQueue(
-		NotifyDriverAssignQueue,
-		[]string{contracts.TripEventDriverAssigned},
-		TripExchange,
-	); err != nil {
+	return errors.Join(errs...)
+}
+
+// PublishMessage publishes message under a new unique message ID
+func (r *RabbitMQ) PublishMessage(ctx context.Context, routingKey string, message contracts.AmqpMessage) error {
+	return r.PublishMessageWithID(ctx, uuid.NewString(), routingKey, message)
+}
+
+// PublishMessageWithID publishes message under messageID. Publishing the same message again with
+// the same ID lets the consumers skip it. The message is wrapped in a contracts.Envelope, its data
+// must be JSON and is converted to the payload content type of r.
+func (r *RabbitMQ) PublishMessageWithID(ctx context.Context, messageID, routingKey string, message contracts.AmqpMessage) error {
+	contentType := r.payloadContentType(routingKey)
+
+	message, err := transcodeMessage(routingKey, contentType, message)
+	if err != nil {
 		return err
 	}
 
-	if err := r.declareAndBindQueue(
-		PaymentTripResponseQueue,
-		[]string{contracts.PaymentCmdCreateSession},
-		TripExchange,
-	); err != nil {
+	return r.publishEnvelope(ctx, messageID, routingKey, contentType, message)
+}
+
+// publishEnvelope publishes message wrapped in an envelope encoded as contentType, the encoding
+// its data already has
+func (r *RabbitMQ) publishEnvelope(ctx context.Context, messageID, routingKey, contentType string, message contracts.AmqpMessage) error {
+	log.Printf("Publishing message %s with routing key: %s", messageID, routingKey)
+
+	msg, err := newPublishing(ctx, r.serviceName, messageID, routingKey, contentType, message)
+	if err != nil {
 		return err
 	}
 
-	if err := r.declareAndBindQueue(
-		NotifyPaymentSessionCreatedQueue,
-		[]string{contracts.PaymentEventSessionCreated},
-		TripExchange,
-	); err != nil {
-		return err
+	return tracing.TracedPublisher(ctx, TripExchange, routingKey, msg, r.publish)
+}
+
+// publish publishes msg as mandatory and waits for the broker to confirm it. Nacked and
+// unroutable messages are reported as errors, so the caller knows the message was not taken.
+func (r *RabbitMQ) publish(ctx context.Context, exchange, routingKey string, msg amqp.Publishing) error {
+	pub, err := r.currentPublisher()
+	if err != nil {
+		return fmt.Errorf("failed to publish %s: %w", routingKey, err)
+	}
+
+	err = pub.publish(ctx, exchange, routingKey, msg)
+	if errors.Is(err, ErrPublishNacked) || errors.Is(err, ErrUnroutable) {
+		r.nacks.Add(ctx, 1, metric.WithAttributes(
+			attribute.String("exchange", exchange),
+			attribute.String("routing_key", routingKey),
+			attribute.Bool("unroutable", errors.Is(err, ErrUnroutable)),
+		))
+	}
+	if err != nil {
+		return fmt.Errorf("failed to publish %s: %w", routingKey, err)
 	}
 
-	if err := r.declareAndBindQueue(
-		NotifyPaymentSuccessQueue,
-		[]string{contracts.PaymentEventSuccess},
-		TripExchange,
-	); err != nil {
+	return nil
+}
+
+func (r *RabbitMQ) setupExchangesAndQueues() error {
+	if err := r.declareDeadLetterExchange(); err != nil {
 		return err
 	}
 
+	err := r.channel.ExchangeDeclare(
+		TripExchange, // name
+		"topic",      // type
+		true,         // durable
+		false,        // auto-deleted
+		false,        // internal
+		false,        // no-wait
+		nil,          // arguments
+	)
+	if err != nil {
+		return fmt.Errorf("failed to declare exchange: %s: %v", TripExchange, err)
+	}
+
+	// Each service declares only the queues it consumes
+	for _, binding := range r.ownQueues() {
+		if err := r.declareAndBindQueue(binding, TripExchange); err != nil {
+			return err
+		}
+	}
+
 	return nil
 }
 
-func (r *RabbitMQ) declareAndBindQueue(queueName string, messageTypes []string, exchange string) error {
-	q, err := r.Channel.QueueDeclare(
-		queueName, // name
-		true,      // durable
-		false,     // delete when unused
-		false,     // exclusive
-		false,     // no-wait
-		nil,       // arguments
+// declareAndBindQueue declares the queue of binding with its dead-letter and retry queues and binds
+// it to its routing keys. Messages the broker rejects or expires from the queue are routed to the
+// dead-letter queue as well.
+func (r *RabbitMQ) declareAndBindQueue(binding QueueBinding, exchange string) error {
+	if !binding.Options.NoDeadLetter {
+		if err := r.declareDeadLetterQueue(binding.Queue); err != nil {
+			return err
+		}
+	}
+
+	if err := r.declareRetryQueues(binding.Queue); err != nil {
+		return err
+	}
+
+	q, err := r.channel.QueueDeclare(
+		binding.Queue, // name
+		true,          // durable
+		false,         // delete when unused
+		false,         // exclusive
+		false,         // no-wait
+		binding.Options.arguments(binding.Queue),
 	)
 	if err != nil {
-		log.Fatal(err)
+		return fmt.Errorf("failed to declare queue %s: %v", binding.Queue, err)
 	}
 
-	for _, msg := range messageTypes {
-		if err := r.Channel.QueueBind(
+	for _, key := range binding.RoutingKeys {
+		if err := r.channel.QueueBind(
 			q.Name,   // queue name
-			msg,      // routing key
+			key,      // routing key
 			exchange, // exchange
 			false,
 			nil,
 		); err != nil {
-			return fmt.Errorf("failed to bind queue to %s: %v", queueName, err)
+			return fmt.Errorf("failed to bind queue to %s: %v", binding.Queue, err)
 		}
 	}
 
 	return nil
 }
 
+// Close closes the connection for good, it is not recovered afterwards
 func (r *RabbitMQ) Close() {
-	if r.conn != nil {
-		r.conn.Close()
-	}
-	if r.Channel != nil {
-		r.Channel.Close()
-	}
+	r.closeOnce.Do(func() {
+		close(r.done)
+
+		r.mu.Lock()
+		conn, ch, pub := r.conn, r.channel, r.publisher
+		r.connected = false
+		r.mu.Unlock()
+
+		if pub != nil {
+			pub.close()
+		}
+		if ch != nil {
+			ch.Close()
+		}
+		if conn != nil {
+			conn.Close()
+		}
+	})
 }
\ No newline at end of file
@@ -0,0 +1,157 @@
+package messaging
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	amqp "github.com/rabbitmq/amqp091-go"
+)
+
+// HeaderRetryCount counts how often a message was handed back to its queue after failing
+const HeaderRetryCount = "x-retry-count"
+
+// RetryDelays are the backoff tiers of a failed message, the nth retry waits RetryDelays[n-1].
+// A message that fails once more after the last tier is dead-lettered.
+var RetryDelays = []time.Duration{1 * time.Second, 10 * time.Second, 60 * time.Second}
+
+// RetryQueue returns the name of the queue that holds messages of queue for delay before
+// handing them back to it
+func RetryQueue(queue string, delay time.Duration) string {
+	return fmt.Sprintf("%s.retry.%s", queue, delay)
+}
+
+type permanentError struct {
+	err error
+}
+
+func (e *permanentError) Error() string { return e.err.Error() }
+func (e *permanentError) Unwrap() error { return e.err }
+
+// Permanent marks an error a handler can't recover from by retrying the message, the message is
+// dead-lettered right away
+func Permanent(err error) error {
+	if err == nil {
+		return nil
+	}
+	return &permanentError{err: err}
+}
+
+// IsPermanent reports whether retrying the message that failed with err is pointless. Besides the
+// errors marked with Permanent, a message that can't be decoded never will be.
+func IsPermanent(err error) bool {
+	var (
+		permanent *permanentError
+		syntax    *json.SyntaxError
+		typeErr   *json.UnmarshalTypeError
+	)
+	return errors.As(err, &permanent) || errors.As(err, &syntax) || errors.As(err, &typeErr)
+}
+
+// declareRetryQueues declares the delay queues of queue. They have no consumers, a message
+// expires after the delay of its queue and is dead-lettered back into queue through the default exchange.
+func (r *RabbitMQ) declareRetryQueues(queue string) error {
+	for _, delay := range RetryDelays {
+		if _, err := r.channel.QueueDeclare(
+			RetryQueue(queue, delay), // name
+			true,                     // durable
+			false,                    // delete when unused
+			false,                    // exclusive
+			false,                    // no-wait
+			amqp.Table{
+				"x-message-ttl":             delay.Milliseconds(),
+				"x-dead-letter-exchange":    "",
+				"x-dead-letter-routing-key": queue,
+			},
+		); err != nil {
+			return fmt.Errorf("failed to declare retry queue of %s: %v", queue, err)
+		}
+	}
+
+	return nil
+}
+
+// handleFailure acks a message whose handler failed and schedules its next attempt on the delay
+// queue of the next backoff tier. Permanent failures and messages out of retries are dead-lettered.
+func (r *RabbitMQ) handleFailure(ctx context.Context, queue string, d amqp.Delivery, cause error) {
+	retries := retryCount(d)
+
+	if IsPermanent(cause) {
+		log.Printf("Message %s failed permanently in %s, dead-lettering it: %v", d.MessageId, queue, cause)
+		r.deadLetter(ctx, queue, d, cause, retries+1)
+		return
+	}
+
+	if retries >= len(RetryDelays) {
+		log.Printf("Message %s failed in %s after %d retries, dead-lettering it: %v", d.MessageId, queue, retries, cause)
+		r.deadLetter(ctx, queue, d, cause, retries+1)
+		return
+	}
+
+	delay := RetryDelays[retries]
+
+	headers := amqp.Table{}
+	for k, v := range d.Headers {
+		headers[k] = v
+	}
+	headers[HeaderRetryCount] = int64(retries + 1)
+	// The message comes back through the default exchange, its routing key is restored from here
+	headers[HeaderOriginalExchange] = d.Exchange
+	headers[HeaderOriginalRoutingKey] = d.RoutingKey
+
+	err := r.publish(ctx, "", RetryQueue(queue, delay), amqp.Publishing{
+		Headers:       headers,
+		ContentType:   d.ContentType,
+		DeliveryMode:  amqp.Persistent,
+		CorrelationId: d.CorrelationId,
+		MessageId:     d.MessageId,
+		Timestamp:     d.Timestamp,
+		Type:          d.Type,
+		AppId:         d.AppId,
+		Body:          d.Body,
+	})
+	if err != nil {
+		log.Printf("Failed to schedule retry of message %s from %s: %v", d.MessageId, queue, err)
+		r.deadLetter(ctx, queue, d, cause, retries+1)
+		return
+	}
+
+	if err := d.Ack(false); err != nil {
+		log.Printf("ERROR: Failed to Ack message scheduled for retry: %v", err)
+		return
+	}
+
+	log.Printf("Message %s failed in %s, retry %d/%d in %s: %v", d.MessageId, queue, retries+1, len(RetryDelays), delay, cause)
+}
+
+// restoreRouting gives a message handed back through the default exchange, after a retry delay
+// or a replay from the dead-letter queue, the exchange and routing key it was first published with
+func restoreRouting(d amqp.Delivery) amqp.Delivery {
+	if key, ok := d.Headers[HeaderOriginalRoutingKey].(string); ok {
+		d.RoutingKey = key
+	}
+	if exchange, ok := d.Headers[HeaderOriginalExchange].(string); ok {
+		d.Exchange = exchange
+	}
+	return d
+}
+
+func retryCount(d amqp.Delivery) int {
+	switch count := d.Headers[HeaderRetryCount].(type) {
+	case int64:
+		return int(count)
+	case int32:
+		return int(count)
+	case int:
+		return count
+	}
+	return 0
+}
+
+// IsLastAttempt reports whether d is out of retries, failing it again dead-letters it
+func IsLastAttempt(d amqp.Delivery) bool {
+	return retryCount(d) >= len(RetryDelays)
+}
@@ -0,0 +1,72 @@
+package messaging
+
+import "github.com/AuraReaper/voom/shared/contracts"
+
+// The payloads of the routing keys, all at their first schema version. A change that breaks
+// older consumers bumps the version and registers the upcaster from the previous one. Each
+// payload can also be published as its proto message in proto/events.proto.
+//
+// trip.event.driver_assigned and the driver location and register commands are forwarded to the
+// websocket clients as they are and have no registered payload.
+func init() {
+	RegisterPayload[TripEventData](contracts.TripEventCreated, 1)
+	RegisterProtoPayload(contracts.TripEventCreated, tripEventToProto, tripEventFromProto)
+	RegisterPayload[TripEventData](contracts.TripEventNoDriversFound, 1)
+	RegisterProtoPayload(contracts.TripEventNoDriversFound, tripEventToProto, tripEventFromProto)
+	RegisterPayload[TripEventData](contracts.TripEventDriverNotInterested, 1)
+	RegisterProtoPayload(contracts.TripEventDriverNotInterested, tripEventToProto, tripEventFromProto)
+	RegisterPayload[TripEventData](contracts.TripEventCompleted, 1)
+	RegisterProtoPayload(contracts.TripEventCompleted, tripEventToProto, tripEventFromProto)
+	RegisterPayload[TripEventSplitData](contracts.TripEventSplitInvited, 1)
+	RegisterProtoPayload(contracts.TripEventSplitInvited, tripEventSplitToProto, tripEventSplitFromProto)
+	RegisterPayload[TripEventSplitData](contracts.TripEventSplitUpdated, 1)
+	RegisterProtoPayload(contracts.TripEventSplitUpdated, tripEventSplitToProto, tripEventSplitFromProto)
+
+	RegisterPayload[TripEventData](contracts.DriverCmdTripRequest, 1)
+	RegisterProtoPayload(contracts.DriverCmdTripRequest, tripEventToProto, tripEventFromProto)
+	RegisterPayload[DriverTripResponseData](contracts.DriverCmdTripAccept, 1)
+	RegisterProtoPayload(contracts.DriverCmdTripAccept, driverTripResponseToProto, driverTripResponseFromProto)
+	RegisterPayload[DriverTripResponseData](contracts.DriverCmdTripDecline, 1)
+	RegisterProtoPayload(contracts.DriverCmdTripDecline, driverTripResponseToProto, driverTripResponseFromProto)
+	RegisterPayload[DriverTripResponseData](contracts.DriverCmdTripComplete, 1)
+	RegisterProtoPayload(contracts.DriverCmdTripComplete, driverTripResponseToProto, driverTripResponseFromProto)
+	RegisterPayload[DriverTripResponseData](contracts.DriverCmdCashCollected, 1)
+	RegisterProtoPayload(contracts.DriverCmdCashCollected, driverTripResponseToProto, driverTripResponseFromProto)
+
+	RegisterPayload[RiderTipData](contracts.RiderCmdTip, 1)
+	RegisterProtoPayload(contracts.RiderCmdTip, riderTipToProto, riderTipFromProto)
+	RegisterPayload[RiderSplitInviteData](contracts.RiderCmdSplitInvite, 1)
+	RegisterProtoPayload(contracts.RiderCmdSplitInvite, riderSplitInviteToProto, riderSplitInviteFromProto)
+	RegisterPayload[RiderSplitRespondData](contracts.RiderCmdSplitRespond, 1)
+	RegisterProtoPayload(contracts.RiderCmdSplitRespond, riderSplitRespondToProto, riderSplitRespondFromProto)
+
+	RegisterPayload[PaymentEventSessionCreatedData](contracts.PaymentEventSessionCreated, 1)
+	RegisterProtoPayload(contracts.PaymentEventSessionCreated, paymentSessionCreatedToProto, paymentSessionCreatedFromProto)
+	RegisterPayload[PaymentStatusUpdateData](contracts.PaymentEventSuccess, 1)
+	RegisterProtoPayload(contracts.PaymentEventSuccess, paymentStatusUpdateToProto, paymentStatusUpdateFromProto)
+	RegisterPayload[PaymentStatusUpdateData](contracts.PaymentEventFailed, 1)
+	RegisterProtoPayload(contracts.PaymentEventFailed, paymentStatusUpdateToProto, paymentStatusUpdateFromProto)
+	RegisterPayload[PaymentStatusUpdateData](contracts.PaymentEventCancelled, 1)
+	RegisterProtoPayload(contracts.PaymentEventCancelled, paymentStatusUpdateToProto, paymentStatusUpdateFromProto)
+	RegisterPayload[PaymentStatusUpdateData](contracts.PaymentEventAuthorized, 1)
+	RegisterProtoPayload(contracts.PaymentEventAuthorized, paymentStatusUpdateToProto, paymentStatusUpdateFromProto)
+	RegisterPayload[PaymentEventRetryData](contracts.PaymentEventRetry, 1)
+	RegisterProtoPayload(contracts.PaymentEventRetry, paymentRetryToProto, paymentRetryFromProto)
+	RegisterPayload[PaymentEventDunningData](contracts.PaymentEventDunning, 1)
+	RegisterProtoPayload(contracts.PaymentEventDunning, paymentDunningToProto, paymentDunningFromProto)
+	RegisterPayload[PaymentEventRefundedData](contracts.PaymentEventRefunded, 1)
+	RegisterProtoPayload(contracts.PaymentEventRefunded, paymentRefundedToProto, paymentRefundedFromProto)
+	RegisterPayload[PaymentEventTipData](contracts.PaymentEventTipReceived, 1)
+	RegisterProtoPayload(contracts.PaymentEventTipReceived, paymentTipToProto, paymentTipFromProto)
+
+	RegisterPayload[PaymentTripResponseData](contracts.PaymentCmdCreateSession, 1)
+	RegisterProtoPayload(contracts.PaymentCmdCreateSession, paymentCreateSessionToProto, paymentCreateSessionFromProto)
+	RegisterPayload[PaymentAuthorizeData](contracts.PaymentCmdAuthorize, 1)
+	RegisterProtoPayload(contracts.PaymentCmdAuthorize, paymentAuthorizeToProto, paymentAuthorizeFromProto)
+	RegisterPayload[PaymentCaptureData](contracts.PaymentCmdCapture, 1)
+	RegisterProtoPayload(contracts.PaymentCmdCapture, paymentCaptureToProto, paymentCaptureFromProto)
+	RegisterPayload[PaymentCaptureData](contracts.PaymentCmdRecordCash, 1)
+	RegisterProtoPayload(contracts.PaymentCmdRecordCash, paymentCaptureToProto, paymentCaptureFromProto)
+	RegisterPayload[PaymentVoidData](contracts.PaymentCmdVoid, 1)
+	RegisterProtoPayload(contracts.PaymentCmdVoid, paymentVoidToProto, paymentVoidFromProto)
+}
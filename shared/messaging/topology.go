@@ -0,0 +1,208 @@
+package messaging
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"slices"
+	"time"
+
+	amqp "github.com/rabbitmq/amqp091-go"
+)
+
+// The services of the trip exchange. Messages are stamped with the name of the service that
+// published them and each queue is declared by the service consuming it.
+const (
+	APIGatewayService = "api-gateway"
+	TripService       = "trip-service"
+	DriverService     = "driver-service"
+	PaymentService    = "payment-service"
+)
+
+// How long a service waits at startup for the queues its messages are routed to
+const (
+	routeWait          = 30 * time.Second
+	routeCheckInterval = 2 * time.Second
+)
+
+// QueueOptions are the arguments a queue is declared with. The zero value declares a queue that
+// keeps its messages until consumed and dead-letters the failed ones. Changing the options of a
+// queue the broker already has fails its declaration, the queue has to be deleted first.
+type QueueOptions struct {
+	MessageTTL   time.Duration // messages unconsumed for longer are dead-lettered, 0 keeps them
+	MaxLength    int           // beyond it the oldest messages are dead-lettered, 0 is unbounded
+	MaxPriority  uint8         // enables message priorities up to it, 0 disables them
+	NoDeadLetter bool          // failed and expired messages are dropped instead of parked in a dead-letter queue
+}
+
+func (o QueueOptions) arguments(queue string) amqp.Table {
+	args := amqp.Table{}
+	if !o.NoDeadLetter {
+		args["x-dead-letter-exchange"] = DeadLetterExchange
+		args["x-dead-letter-routing-key"] = queue
+	}
+	if o.MessageTTL > 0 {
+		args["x-message-ttl"] = o.MessageTTL.Milliseconds()
+	}
+	if o.MaxLength > 0 {
+		args["x-max-length"] = int64(o.MaxLength)
+	}
+	if o.MaxPriority > 0 {
+		args["x-max-priority"] = int64(o.MaxPriority)
+	}
+	return args
+}
+
+// QueueBinding is a queue of the trip exchange with the routing keys bound to it, declared by
+// the service consuming it
+type QueueBinding struct {
+	Queue       string
+	RoutingKeys []string
+	Consumer    string // set from the ServiceTopology the queue belongs to
+	Options     QueueOptions
+}
+
+// ServiceTopology is the part of the trip exchange a service owns, the queues it declares and
+// consumes and the routing keys it publishes. Each service keeps its own next to its code.
+type ServiceTopology struct {
+	Service  string
+	Queues   []QueueBinding
+	Produces []string
+}
+
+// Bindings returns the queues of t consumed by t.Service
+func (t ServiceTopology) Bindings() []QueueBinding {
+	bindings := make([]QueueBinding, 0, len(t.Queues))
+	for _, binding := range t.Queues {
+		binding.Consumer = t.Service
+		bindings = append(bindings, binding)
+	}
+	return bindings
+}
+
+// Route returns the queues of topologies a message published with routingKey reaches
+func Route(routingKey string, topologies ...ServiceTopology) []QueueBinding {
+	var queues []QueueBinding
+	for _, topology := range topologies {
+		for _, binding := range topology.Bindings() {
+			if slices.ContainsFunc(binding.RoutingKeys, func(key string) bool { return matchTopic(key, routingKey) }) {
+				queues = append(queues, binding)
+			}
+		}
+	}
+	return queues
+}
+
+// ValidateTopology checks every queue of topologies has a single declaration and routing keys,
+// and that every routing key a service publishes reaches a queue of topologies
+func ValidateTopology(topologies ...ServiceTopology) error {
+	var errs []error
+
+	consumers := make(map[string]string) // queue -> service declaring it
+	for _, topology := range topologies {
+		if topology.Service == "" {
+			errs = append(errs, errors.New("a topology has no service"))
+		}
+
+		for _, binding := range topology.Bindings() {
+			if consumer, ok := consumers[binding.Queue]; ok {
+				errs = append(errs, fmt.Errorf("queue %s is declared by both %s and %s", binding.Queue, consumer, binding.Consumer))
+			}
+			consumers[binding.Queue] = binding.Consumer
+
+			if len(binding.RoutingKeys) == 0 {
+				errs = append(errs, fmt.Errorf("queue %s is bound to no routing key", binding.Queue))
+			}
+		}
+	}
+
+	for _, topology := range topologies {
+		for _, key := range topology.Produces {
+			if len(Route(key, topologies...)) == 0 {
+				errs = append(errs, fmt.Errorf("%s publishes %s, which reaches no queue", topology.Service, key))
+			}
+		}
+	}
+
+	return errors.Join(errs...)
+}
+
+// ownQueues returns the queues r declares, the caller holds r.mu
+func (r *RabbitMQ) ownQueues() []QueueBinding {
+	return slices.Concat(r.topology.Bindings(), r.queues)
+}
+
+// checkConsumes fails when queueName is not a queue r declares, consuming a queue no one declared
+// would close the channel
+func (r *RabbitMQ) checkConsumes(queueName string) error {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	for _, binding := range r.ownQueues() {
+		if binding.Queue == queueName {
+			return nil
+		}
+	}
+
+	for _, peer := range r.peers {
+		for _, binding := range peer.Queues {
+			if binding.Queue == queueName {
+				return fmt.Errorf("%s can't consume %s, the queue is consumed by %s", r.serviceName, queueName, peer.Service)
+			}
+		}
+	}
+	return fmt.Errorf("%s can't consume %s, the queue is not in the topology", r.serviceName, queueName)
+}
+
+// verifyRoutes checks the queues of the peers the messages of r are routed to exist. The services
+// declare their own queues, so it waits up to routeWait for the peers starting meanwhile and then
+// fails with the queues still missing.
+func (r *RabbitMQ) verifyRoutes() error {
+	deadline := time.Now().Add(routeWait)
+	for {
+		missing, err := r.missingRoutes()
+		if err != nil {
+			return fmt.Errorf("failed to verify the topology: %w", err)
+		}
+		if len(missing) == 0 {
+			return nil
+		}
+		if time.Now().After(deadline) {
+			return errors.Join(missing...)
+		}
+
+		log.Printf("Waiting for %d queues %s publishes to be declared", len(missing), r.serviceName)
+		time.Sleep(routeCheckInterval)
+	}
+}
+
+// missingRoutes returns an error for each queue of the peers a message of r is routed to that the
+// broker doesn't have
+func (r *RabbitMQ) missingRoutes() ([]error, error) {
+	r.mu.RLock()
+	conn := r.conn
+	r.mu.RUnlock()
+
+	var missing []error
+	checked := make(map[string]bool)
+	for _, key := range r.topology.Produces {
+		for _, binding := range Route(key, r.peers...) {
+			if checked[binding.Queue] {
+				continue
+			}
+			checked[binding.Queue] = true
+
+			// A failed passive declare closes the channel, so every queue is checked on its own
+			ch, err := conn.Channel()
+			if err != nil {
+				return nil, err
+			}
+			if _, err := ch.QueueDeclarePassive(binding.Queue, true, false, false, false, nil); err != nil {
+				missing = append(missing, fmt.Errorf("%s publishes %s to queue %s, which %s has not declared", r.serviceName, key, binding.Queue, binding.Consumer))
+				continue
+			}
+			ch.Close()
+		}
+	}
+	return missing, nil
+}
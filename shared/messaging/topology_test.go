@@ -0,0 +1,50 @@
+package messaging
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestValidateTopologyRejectsUnroutableKeys(t *testing.T) {
+	consumer := ServiceTopology{
+		Service: "consumer",
+		Queues: []QueueBinding{
+			{Queue: "test.created", RoutingKeys: []string{"test.*.created"}},
+		},
+	}
+	producer := ServiceTopology{
+		Service:  "producer",
+		Produces: []string{"test.trip.created", "test.trip.completed"},
+	}
+
+	err := ValidateTopology(producer, consumer)
+	if err == nil {
+		t.Fatal("a produced key bound to no queue passed validation")
+	}
+	if !strings.Contains(err.Error(), "producer publishes test.trip.completed, which reaches no queue") {
+		t.Errorf("got %q, want test.trip.completed reported", err)
+	}
+	if strings.Contains(err.Error(), "test.trip.created") {
+		t.Errorf("got %q, want test.trip.created routed to test.created", err)
+	}
+
+	// Without the consumer registering its queue even the routed key is lost
+	if err := ValidateTopology(producer); err == nil || !strings.Contains(err.Error(), "test.trip.created") {
+		t.Errorf("got %v, want test.trip.created reported once its queue is gone", err)
+	}
+}
+
+func TestValidateTopologyRejectsQueuesDeclaredTwice(t *testing.T) {
+	first := ServiceTopology{
+		Service: "first",
+		Queues:  []QueueBinding{{Queue: "test.shared", RoutingKeys: []string{"test.shared"}}},
+	}
+	second := ServiceTopology{
+		Service: "second",
+		Queues:  []QueueBinding{{Queue: "test.shared", RoutingKeys: []string{"test.shared"}}},
+	}
+
+	if err := ValidateTopology(first, second); err == nil || !strings.Contains(err.Error(), "declared by both first and second") {
+		t.Errorf("got %v, want the queue declared twice reported", err)
+	}
+}
@@ -369,7 +369,7 @@ const file_driver_proto_rawDesc = "" +
 	"\x0eRegisterDriver\x12\x1d.driver.RegisterDriverRequest\x1a\x1e.driver.RegisterDriverResponse\x12Q\n" +
 	"\x10UnRegisterDriver\x12\x1d.driver.RegisterDriverRequest\x1a\x1e.driver.RegisterDriverResponse\x12C\n" +
 	"\n" +
-	"GetDrivers\x12\x19.driver.GetDriversRequest\x1a\x1a.driver.GetDriversResponseB\x1cZ\x1ashared/proto/driver;driverb\x06proto3"
+	"GetDrivers\x12\x19.driver.GetDriversRequest\x1a\x1a.driver.GetDriversResponseB7Z5github.com/AuraReaper/voom/shared/proto/driver;driverb\x06proto3"
 
 var (
 	file_driver_proto_rawDescOnce sync.Once
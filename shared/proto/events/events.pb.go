@@ -0,0 +1,1428 @@
+// Code generated by protoc-gen-go. DO NOT EDIT.
+// versions:
+// 	protoc-gen-go v1.36.8
+// 	protoc        v6.32.1
+// source: events.proto
+
+package events
+
+import (
+	driver "github.com/AuraReaper/voom/shared/proto/driver"
+	trip "github.com/AuraReaper/voom/shared/proto/trip"
+	protoreflect "google.golang.org/protobuf/reflect/protoreflect"
+	protoimpl "google.golang.org/protobuf/runtime/protoimpl"
+	timestamppb "google.golang.org/protobuf/types/known/timestamppb"
+	reflect "reflect"
+	sync "sync"
+	unsafe "unsafe"
+)
+
+const (
+	// Verify that this generated code is sufficiently up-to-date.
+	_ = protoimpl.EnforceVersion(20 - protoimpl.MinVersion)
+	// Verify that runtime/protoimpl is sufficiently up-to-date.
+	_ = protoimpl.EnforceVersion(protoimpl.MaxVersion - 20)
+)
+
+// Envelope is the body of a message published as application/x-protobuf
+type Envelope struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	EventId       string                 `protobuf:"bytes,1,opt,name=eventId,proto3" json:"eventId,omitempty"`
+	Type          string                 `protobuf:"bytes,2,opt,name=type,proto3" json:"type,omitempty"` // the routing key the message was published with
+	SchemaVersion int32                  `protobuf:"varint,3,opt,name=schemaVersion,proto3" json:"schemaVersion,omitempty"`
+	OccurredAt    *timestamppb.Timestamp `protobuf:"bytes,4,opt,name=occurredAt,proto3" json:"occurredAt,omitempty"`
+	Producer      string                 `protobuf:"bytes,5,opt,name=producer,proto3" json:"producer,omitempty"`
+	CorrelationId string                 `protobuf:"bytes,6,opt,name=correlationId,proto3" json:"correlationId,omitempty"`
+	OwnerId       string                 `protobuf:"bytes,7,opt,name=ownerId,proto3" json:"ownerId,omitempty"`
+	Data          []byte                 `protobuf:"bytes,8,opt,name=data,proto3" json:"data,omitempty"` // the payload message of type
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *Envelope) Reset() {
+	*x = Envelope{}
+	mi := &file_events_proto_msgTypes[0]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *Envelope) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*Envelope) ProtoMessage() {}
+
+func (x *Envelope) ProtoReflect() protoreflect.Message {
+	mi := &file_events_proto_msgTypes[0]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use Envelope.ProtoReflect.Descriptor instead.
+func (*Envelope) Descriptor() ([]byte, []int) {
+	return file_events_proto_rawDescGZIP(), []int{0}
+}
+
+func (x *Envelope) GetEventId() string {
+	if x != nil {
+		return x.EventId
+	}
+	return ""
+}
+
+func (x *Envelope) GetType() string {
+	if x != nil {
+		return x.Type
+	}
+	return ""
+}
+
+func (x *Envelope) GetSchemaVersion() int32 {
+	if x != nil {
+		return x.SchemaVersion
+	}
+	return 0
+}
+
+func (x *Envelope) GetOccurredAt() *timestamppb.Timestamp {
+	if x != nil {
+		return x.OccurredAt
+	}
+	return nil
+}
+
+func (x *Envelope) GetProducer() string {
+	if x != nil {
+		return x.Producer
+	}
+	return ""
+}
+
+func (x *Envelope) GetCorrelationId() string {
+	if x != nil {
+		return x.CorrelationId
+	}
+	return ""
+}
+
+func (x *Envelope) GetOwnerId() string {
+	if x != nil {
+		return x.OwnerId
+	}
+	return ""
+}
+
+func (x *Envelope) GetData() []byte {
+	if x != nil {
+		return x.Data
+	}
+	return nil
+}
+
+// trip.event.created, no_drivers_found, driver_not_interested, completed and driver.cmd.trip_request
+type TripEvent struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Trip          *trip.Trip             `protobuf:"bytes,1,opt,name=trip,proto3" json:"trip,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *TripEvent) Reset() {
+	*x = TripEvent{}
+	mi := &file_events_proto_msgTypes[1]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *TripEvent) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*TripEvent) ProtoMessage() {}
+
+func (x *TripEvent) ProtoReflect() protoreflect.Message {
+	mi := &file_events_proto_msgTypes[1]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use TripEvent.ProtoReflect.Descriptor instead.
+func (*TripEvent) Descriptor() ([]byte, []int) {
+	return file_events_proto_rawDescGZIP(), []int{1}
+}
+
+func (x *TripEvent) GetTrip() *trip.Trip {
+	if x != nil {
+		return x.Trip
+	}
+	return nil
+}
+
+// trip.event.split_invited and split_updated
+type TripEventSplit struct {
+	state         protoimpl.MessageState   `protogen:"open.v1"`
+	TripID        string                   `protobuf:"bytes,1,opt,name=tripID,proto3" json:"tripID,omitempty"`
+	OwnerID       string                   `protobuf:"bytes,2,opt,name=ownerID,proto3" json:"ownerID,omitempty"`
+	Fare          float64                  `protobuf:"fixed64,3,opt,name=fare,proto3" json:"fare,omitempty"`
+	Participants  []*trip.SplitParticipant `protobuf:"bytes,4,rep,name=participants,proto3" json:"participants,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *TripEventSplit) Reset() {
+	*x = TripEventSplit{}
+	mi := &file_events_proto_msgTypes[2]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *TripEventSplit) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*TripEventSplit) ProtoMessage() {}
+
+func (x *TripEventSplit) ProtoReflect() protoreflect.Message {
+	mi := &file_events_proto_msgTypes[2]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use TripEventSplit.ProtoReflect.Descriptor instead.
+func (*TripEventSplit) Descriptor() ([]byte, []int) {
+	return file_events_proto_rawDescGZIP(), []int{2}
+}
+
+func (x *TripEventSplit) GetTripID() string {
+	if x != nil {
+		return x.TripID
+	}
+	return ""
+}
+
+func (x *TripEventSplit) GetOwnerID() string {
+	if x != nil {
+		return x.OwnerID
+	}
+	return ""
+}
+
+func (x *TripEventSplit) GetFare() float64 {
+	if x != nil {
+		return x.Fare
+	}
+	return 0
+}
+
+func (x *TripEventSplit) GetParticipants() []*trip.SplitParticipant {
+	if x != nil {
+		return x.Participants
+	}
+	return nil
+}
+
+// driver.cmd.trip_accept, trip_decline, trip_complete and cash_collected
+type DriverTripResponse struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Driver        *driver.Driver         `protobuf:"bytes,1,opt,name=driver,proto3" json:"driver,omitempty"`
+	TripID        string                 `protobuf:"bytes,2,opt,name=tripID,proto3" json:"tripID,omitempty"`
+	RiderID       string                 `protobuf:"bytes,3,opt,name=riderID,proto3" json:"riderID,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *DriverTripResponse) Reset() {
+	*x = DriverTripResponse{}
+	mi := &file_events_proto_msgTypes[3]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *DriverTripResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*DriverTripResponse) ProtoMessage() {}
+
+func (x *DriverTripResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_events_proto_msgTypes[3]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use DriverTripResponse.ProtoReflect.Descriptor instead.
+func (*DriverTripResponse) Descriptor() ([]byte, []int) {
+	return file_events_proto_rawDescGZIP(), []int{3}
+}
+
+func (x *DriverTripResponse) GetDriver() *driver.Driver {
+	if x != nil {
+		return x.Driver
+	}
+	return nil
+}
+
+func (x *DriverTripResponse) GetTripID() string {
+	if x != nil {
+		return x.TripID
+	}
+	return ""
+}
+
+func (x *DriverTripResponse) GetRiderID() string {
+	if x != nil {
+		return x.RiderID
+	}
+	return ""
+}
+
+// rider.cmd.tip
+type RiderTip struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	TripID        string                 `protobuf:"bytes,1,opt,name=tripID,proto3" json:"tripID,omitempty"`
+	Amount        float64                `protobuf:"fixed64,2,opt,name=amount,proto3" json:"amount,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *RiderTip) Reset() {
+	*x = RiderTip{}
+	mi := &file_events_proto_msgTypes[4]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *RiderTip) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*RiderTip) ProtoMessage() {}
+
+func (x *RiderTip) ProtoReflect() protoreflect.Message {
+	mi := &file_events_proto_msgTypes[4]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use RiderTip.ProtoReflect.Descriptor instead.
+func (*RiderTip) Descriptor() ([]byte, []int) {
+	return file_events_proto_rawDescGZIP(), []int{4}
+}
+
+func (x *RiderTip) GetTripID() string {
+	if x != nil {
+		return x.TripID
+	}
+	return ""
+}
+
+func (x *RiderTip) GetAmount() float64 {
+	if x != nil {
+		return x.Amount
+	}
+	return 0
+}
+
+// rider.cmd.split_invite
+type RiderSplitInvite struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	TripID        string                 `protobuf:"bytes,1,opt,name=tripID,proto3" json:"tripID,omitempty"`
+	UserIDs       []string               `protobuf:"bytes,2,rep,name=userIDs,proto3" json:"userIDs,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *RiderSplitInvite) Reset() {
+	*x = RiderSplitInvite{}
+	mi := &file_events_proto_msgTypes[5]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *RiderSplitInvite) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*RiderSplitInvite) ProtoMessage() {}
+
+func (x *RiderSplitInvite) ProtoReflect() protoreflect.Message {
+	mi := &file_events_proto_msgTypes[5]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use RiderSplitInvite.ProtoReflect.Descriptor instead.
+func (*RiderSplitInvite) Descriptor() ([]byte, []int) {
+	return file_events_proto_rawDescGZIP(), []int{5}
+}
+
+func (x *RiderSplitInvite) GetTripID() string {
+	if x != nil {
+		return x.TripID
+	}
+	return ""
+}
+
+func (x *RiderSplitInvite) GetUserIDs() []string {
+	if x != nil {
+		return x.UserIDs
+	}
+	return nil
+}
+
+// rider.cmd.split_respond
+type RiderSplitRespond struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	TripID        string                 `protobuf:"bytes,1,opt,name=tripID,proto3" json:"tripID,omitempty"`
+	Accept        bool                   `protobuf:"varint,2,opt,name=accept,proto3" json:"accept,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *RiderSplitRespond) Reset() {
+	*x = RiderSplitRespond{}
+	mi := &file_events_proto_msgTypes[6]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *RiderSplitRespond) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*RiderSplitRespond) ProtoMessage() {}
+
+func (x *RiderSplitRespond) ProtoReflect() protoreflect.Message {
+	mi := &file_events_proto_msgTypes[6]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use RiderSplitRespond.ProtoReflect.Descriptor instead.
+func (*RiderSplitRespond) Descriptor() ([]byte, []int) {
+	return file_events_proto_rawDescGZIP(), []int{6}
+}
+
+func (x *RiderSplitRespond) GetTripID() string {
+	if x != nil {
+		return x.TripID
+	}
+	return ""
+}
+
+func (x *RiderSplitRespond) GetAccept() bool {
+	if x != nil {
+		return x.Accept
+	}
+	return false
+}
+
+// payment.event.session_created
+type PaymentSessionCreated struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	TripID        string                 `protobuf:"bytes,1,opt,name=tripID,proto3" json:"tripID,omitempty"`
+	SessionID     string                 `protobuf:"bytes,2,opt,name=sessionID,proto3" json:"sessionID,omitempty"`
+	Amount        float64                `protobuf:"fixed64,3,opt,name=amount,proto3" json:"amount,omitempty"`
+	Currency      string                 `protobuf:"bytes,4,opt,name=currency,proto3" json:"currency,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *PaymentSessionCreated) Reset() {
+	*x = PaymentSessionCreated{}
+	mi := &file_events_proto_msgTypes[7]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *PaymentSessionCreated) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*PaymentSessionCreated) ProtoMessage() {}
+
+func (x *PaymentSessionCreated) ProtoReflect() protoreflect.Message {
+	mi := &file_events_proto_msgTypes[7]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use PaymentSessionCreated.ProtoReflect.Descriptor instead.
+func (*PaymentSessionCreated) Descriptor() ([]byte, []int) {
+	return file_events_proto_rawDescGZIP(), []int{7}
+}
+
+func (x *PaymentSessionCreated) GetTripID() string {
+	if x != nil {
+		return x.TripID
+	}
+	return ""
+}
+
+func (x *PaymentSessionCreated) GetSessionID() string {
+	if x != nil {
+		return x.SessionID
+	}
+	return ""
+}
+
+func (x *PaymentSessionCreated) GetAmount() float64 {
+	if x != nil {
+		return x.Amount
+	}
+	return 0
+}
+
+func (x *PaymentSessionCreated) GetCurrency() string {
+	if x != nil {
+		return x.Currency
+	}
+	return ""
+}
+
+// payment.event.success, failed, cancelled and authorized
+type PaymentStatusUpdate struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	TripID        string                 `protobuf:"bytes,1,opt,name=tripID,proto3" json:"tripID,omitempty"`
+	UserID        string                 `protobuf:"bytes,2,opt,name=userID,proto3" json:"userID,omitempty"`
+	DriverID      string                 `protobuf:"bytes,3,opt,name=driverID,proto3" json:"driverID,omitempty"`
+	SessionID     string                 `protobuf:"bytes,4,opt,name=sessionID,proto3" json:"sessionID,omitempty"`
+	Reason        string                 `protobuf:"bytes,5,opt,name=reason,proto3" json:"reason,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *PaymentStatusUpdate) Reset() {
+	*x = PaymentStatusUpdate{}
+	mi := &file_events_proto_msgTypes[8]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *PaymentStatusUpdate) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*PaymentStatusUpdate) ProtoMessage() {}
+
+func (x *PaymentStatusUpdate) ProtoReflect() protoreflect.Message {
+	mi := &file_events_proto_msgTypes[8]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use PaymentStatusUpdate.ProtoReflect.Descriptor instead.
+func (*PaymentStatusUpdate) Descriptor() ([]byte, []int) {
+	return file_events_proto_rawDescGZIP(), []int{8}
+}
+
+func (x *PaymentStatusUpdate) GetTripID() string {
+	if x != nil {
+		return x.TripID
+	}
+	return ""
+}
+
+func (x *PaymentStatusUpdate) GetUserID() string {
+	if x != nil {
+		return x.UserID
+	}
+	return ""
+}
+
+func (x *PaymentStatusUpdate) GetDriverID() string {
+	if x != nil {
+		return x.DriverID
+	}
+	return ""
+}
+
+func (x *PaymentStatusUpdate) GetSessionID() string {
+	if x != nil {
+		return x.SessionID
+	}
+	return ""
+}
+
+func (x *PaymentStatusUpdate) GetReason() string {
+	if x != nil {
+		return x.Reason
+	}
+	return ""
+}
+
+// payment.event.retry
+type PaymentRetry struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	TripID        string                 `protobuf:"bytes,1,opt,name=tripID,proto3" json:"tripID,omitempty"`
+	SessionID     string                 `protobuf:"bytes,2,opt,name=sessionID,proto3" json:"sessionID,omitempty"`
+	Amount        float64                `protobuf:"fixed64,3,opt,name=amount,proto3" json:"amount,omitempty"`
+	Currency      string                 `protobuf:"bytes,4,opt,name=currency,proto3" json:"currency,omitempty"`
+	Attempt       int32                  `protobuf:"varint,5,opt,name=attempt,proto3" json:"attempt,omitempty"`
+	MaxAttempts   int32                  `protobuf:"varint,6,opt,name=maxAttempts,proto3" json:"maxAttempts,omitempty"`
+	Reason        string                 `protobuf:"bytes,7,opt,name=reason,proto3" json:"reason,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *PaymentRetry) Reset() {
+	*x = PaymentRetry{}
+	mi := &file_events_proto_msgTypes[9]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *PaymentRetry) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*PaymentRetry) ProtoMessage() {}
+
+func (x *PaymentRetry) ProtoReflect() protoreflect.Message {
+	mi := &file_events_proto_msgTypes[9]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use PaymentRetry.ProtoReflect.Descriptor instead.
+func (*PaymentRetry) Descriptor() ([]byte, []int) {
+	return file_events_proto_rawDescGZIP(), []int{9}
+}
+
+func (x *PaymentRetry) GetTripID() string {
+	if x != nil {
+		return x.TripID
+	}
+	return ""
+}
+
+func (x *PaymentRetry) GetSessionID() string {
+	if x != nil {
+		return x.SessionID
+	}
+	return ""
+}
+
+func (x *PaymentRetry) GetAmount() float64 {
+	if x != nil {
+		return x.Amount
+	}
+	return 0
+}
+
+func (x *PaymentRetry) GetCurrency() string {
+	if x != nil {
+		return x.Currency
+	}
+	return ""
+}
+
+func (x *PaymentRetry) GetAttempt() int32 {
+	if x != nil {
+		return x.Attempt
+	}
+	return 0
+}
+
+func (x *PaymentRetry) GetMaxAttempts() int32 {
+	if x != nil {
+		return x.MaxAttempts
+	}
+	return 0
+}
+
+func (x *PaymentRetry) GetReason() string {
+	if x != nil {
+		return x.Reason
+	}
+	return ""
+}
+
+// payment.event.dunning
+type PaymentDunning struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	TripID        string                 `protobuf:"bytes,1,opt,name=tripID,proto3" json:"tripID,omitempty"`
+	UserID        string                 `protobuf:"bytes,2,opt,name=userID,proto3" json:"userID,omitempty"`
+	DriverID      string                 `protobuf:"bytes,3,opt,name=driverID,proto3" json:"driverID,omitempty"`
+	Amount        float64                `protobuf:"fixed64,4,opt,name=amount,proto3" json:"amount,omitempty"`
+	Currency      string                 `protobuf:"bytes,5,opt,name=currency,proto3" json:"currency,omitempty"`
+	Attempts      int32                  `protobuf:"varint,6,opt,name=attempts,proto3" json:"attempts,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *PaymentDunning) Reset() {
+	*x = PaymentDunning{}
+	mi := &file_events_proto_msgTypes[10]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *PaymentDunning) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*PaymentDunning) ProtoMessage() {}
+
+func (x *PaymentDunning) ProtoReflect() protoreflect.Message {
+	mi := &file_events_proto_msgTypes[10]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use PaymentDunning.ProtoReflect.Descriptor instead.
+func (*PaymentDunning) Descriptor() ([]byte, []int) {
+	return file_events_proto_rawDescGZIP(), []int{10}
+}
+
+func (x *PaymentDunning) GetTripID() string {
+	if x != nil {
+		return x.TripID
+	}
+	return ""
+}
+
+func (x *PaymentDunning) GetUserID() string {
+	if x != nil {
+		return x.UserID
+	}
+	return ""
+}
+
+func (x *PaymentDunning) GetDriverID() string {
+	if x != nil {
+		return x.DriverID
+	}
+	return ""
+}
+
+func (x *PaymentDunning) GetAmount() float64 {
+	if x != nil {
+		return x.Amount
+	}
+	return 0
+}
+
+func (x *PaymentDunning) GetCurrency() string {
+	if x != nil {
+		return x.Currency
+	}
+	return ""
+}
+
+func (x *PaymentDunning) GetAttempts() int32 {
+	if x != nil {
+		return x.Attempts
+	}
+	return 0
+}
+
+// payment.event.refunded
+type PaymentRefunded struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	TripID        string                 `protobuf:"bytes,1,opt,name=tripID,proto3" json:"tripID,omitempty"`
+	RefundID      string                 `protobuf:"bytes,2,opt,name=refundID,proto3" json:"refundID,omitempty"`
+	UserID        string                 `protobuf:"bytes,3,opt,name=userID,proto3" json:"userID,omitempty"`
+	DriverID      string                 `protobuf:"bytes,4,opt,name=driverID,proto3" json:"driverID,omitempty"`
+	Amount        float64                `protobuf:"fixed64,5,opt,name=amount,proto3" json:"amount,omitempty"`
+	TotalRefunded float64                `protobuf:"fixed64,6,opt,name=totalRefunded,proto3" json:"totalRefunded,omitempty"`
+	Currency      string                 `protobuf:"bytes,7,opt,name=currency,proto3" json:"currency,omitempty"`
+	Reason        string                 `protobuf:"bytes,8,opt,name=reason,proto3" json:"reason,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *PaymentRefunded) Reset() {
+	*x = PaymentRefunded{}
+	mi := &file_events_proto_msgTypes[11]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *PaymentRefunded) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*PaymentRefunded) ProtoMessage() {}
+
+func (x *PaymentRefunded) ProtoReflect() protoreflect.Message {
+	mi := &file_events_proto_msgTypes[11]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use PaymentRefunded.ProtoReflect.Descriptor instead.
+func (*PaymentRefunded) Descriptor() ([]byte, []int) {
+	return file_events_proto_rawDescGZIP(), []int{11}
+}
+
+func (x *PaymentRefunded) GetTripID() string {
+	if x != nil {
+		return x.TripID
+	}
+	return ""
+}
+
+func (x *PaymentRefunded) GetRefundID() string {
+	if x != nil {
+		return x.RefundID
+	}
+	return ""
+}
+
+func (x *PaymentRefunded) GetUserID() string {
+	if x != nil {
+		return x.UserID
+	}
+	return ""
+}
+
+func (x *PaymentRefunded) GetDriverID() string {
+	if x != nil {
+		return x.DriverID
+	}
+	return ""
+}
+
+func (x *PaymentRefunded) GetAmount() float64 {
+	if x != nil {
+		return x.Amount
+	}
+	return 0
+}
+
+func (x *PaymentRefunded) GetTotalRefunded() float64 {
+	if x != nil {
+		return x.TotalRefunded
+	}
+	return 0
+}
+
+func (x *PaymentRefunded) GetCurrency() string {
+	if x != nil {
+		return x.Currency
+	}
+	return ""
+}
+
+func (x *PaymentRefunded) GetReason() string {
+	if x != nil {
+		return x.Reason
+	}
+	return ""
+}
+
+// payment.event.tip_received
+type PaymentTipReceived struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	TripID        string                 `protobuf:"bytes,1,opt,name=tripID,proto3" json:"tripID,omitempty"`
+	UserID        string                 `protobuf:"bytes,2,opt,name=userID,proto3" json:"userID,omitempty"`
+	DriverID      string                 `protobuf:"bytes,3,opt,name=driverID,proto3" json:"driverID,omitempty"`
+	Amount        float64                `protobuf:"fixed64,4,opt,name=amount,proto3" json:"amount,omitempty"`
+	Currency      string                 `protobuf:"bytes,5,opt,name=currency,proto3" json:"currency,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *PaymentTipReceived) Reset() {
+	*x = PaymentTipReceived{}
+	mi := &file_events_proto_msgTypes[12]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *PaymentTipReceived) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*PaymentTipReceived) ProtoMessage() {}
+
+func (x *PaymentTipReceived) ProtoReflect() protoreflect.Message {
+	mi := &file_events_proto_msgTypes[12]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use PaymentTipReceived.ProtoReflect.Descriptor instead.
+func (*PaymentTipReceived) Descriptor() ([]byte, []int) {
+	return file_events_proto_rawDescGZIP(), []int{12}
+}
+
+func (x *PaymentTipReceived) GetTripID() string {
+	if x != nil {
+		return x.TripID
+	}
+	return ""
+}
+
+func (x *PaymentTipReceived) GetUserID() string {
+	if x != nil {
+		return x.UserID
+	}
+	return ""
+}
+
+func (x *PaymentTipReceived) GetDriverID() string {
+	if x != nil {
+		return x.DriverID
+	}
+	return ""
+}
+
+func (x *PaymentTipReceived) GetAmount() float64 {
+	if x != nil {
+		return x.Amount
+	}
+	return 0
+}
+
+func (x *PaymentTipReceived) GetCurrency() string {
+	if x != nil {
+		return x.Currency
+	}
+	return ""
+}
+
+// payment.cmd.create_session
+type PaymentCreateSession struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	TripID        string                 `protobuf:"bytes,1,opt,name=tripID,proto3" json:"tripID,omitempty"`
+	UserID        string                 `protobuf:"bytes,2,opt,name=userID,proto3" json:"userID,omitempty"`
+	DriverID      string                 `protobuf:"bytes,3,opt,name=driverID,proto3" json:"driverID,omitempty"`
+	Amount        float64                `protobuf:"fixed64,4,opt,name=amount,proto3" json:"amount,omitempty"`
+	Currency      string                 `protobuf:"bytes,5,opt,name=currency,proto3" json:"currency,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *PaymentCreateSession) Reset() {
+	*x = PaymentCreateSession{}
+	mi := &file_events_proto_msgTypes[13]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *PaymentCreateSession) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*PaymentCreateSession) ProtoMessage() {}
+
+func (x *PaymentCreateSession) ProtoReflect() protoreflect.Message {
+	mi := &file_events_proto_msgTypes[13]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use PaymentCreateSession.ProtoReflect.Descriptor instead.
+func (*PaymentCreateSession) Descriptor() ([]byte, []int) {
+	return file_events_proto_rawDescGZIP(), []int{13}
+}
+
+func (x *PaymentCreateSession) GetTripID() string {
+	if x != nil {
+		return x.TripID
+	}
+	return ""
+}
+
+func (x *PaymentCreateSession) GetUserID() string {
+	if x != nil {
+		return x.UserID
+	}
+	return ""
+}
+
+func (x *PaymentCreateSession) GetDriverID() string {
+	if x != nil {
+		return x.DriverID
+	}
+	return ""
+}
+
+func (x *PaymentCreateSession) GetAmount() float64 {
+	if x != nil {
+		return x.Amount
+	}
+	return 0
+}
+
+func (x *PaymentCreateSession) GetCurrency() string {
+	if x != nil {
+		return x.Currency
+	}
+	return ""
+}
+
+// payment.cmd.authorize
+type PaymentAuthorize struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	TripID        string                 `protobuf:"bytes,1,opt,name=tripID,proto3" json:"tripID,omitempty"`
+	UserID        string                 `protobuf:"bytes,2,opt,name=userID,proto3" json:"userID,omitempty"`
+	Amount        float64                `protobuf:"fixed64,3,opt,name=amount,proto3" json:"amount,omitempty"`
+	Currency      string                 `protobuf:"bytes,4,opt,name=currency,proto3" json:"currency,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *PaymentAuthorize) Reset() {
+	*x = PaymentAuthorize{}
+	mi := &file_events_proto_msgTypes[14]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *PaymentAuthorize) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*PaymentAuthorize) ProtoMessage() {}
+
+func (x *PaymentAuthorize) ProtoReflect() protoreflect.Message {
+	mi := &file_events_proto_msgTypes[14]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use PaymentAuthorize.ProtoReflect.Descriptor instead.
+func (*PaymentAuthorize) Descriptor() ([]byte, []int) {
+	return file_events_proto_rawDescGZIP(), []int{14}
+}
+
+func (x *PaymentAuthorize) GetTripID() string {
+	if x != nil {
+		return x.TripID
+	}
+	return ""
+}
+
+func (x *PaymentAuthorize) GetUserID() string {
+	if x != nil {
+		return x.UserID
+	}
+	return ""
+}
+
+func (x *PaymentAuthorize) GetAmount() float64 {
+	if x != nil {
+		return x.Amount
+	}
+	return 0
+}
+
+func (x *PaymentAuthorize) GetCurrency() string {
+	if x != nil {
+		return x.Currency
+	}
+	return ""
+}
+
+// payment.cmd.capture and record_cash
+type PaymentCapture struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	TripID        string                 `protobuf:"bytes,1,opt,name=tripID,proto3" json:"tripID,omitempty"`
+	UserID        string                 `protobuf:"bytes,2,opt,name=userID,proto3" json:"userID,omitempty"`
+	DriverID      string                 `protobuf:"bytes,3,opt,name=driverID,proto3" json:"driverID,omitempty"`
+	Amount        float64                `protobuf:"fixed64,4,opt,name=amount,proto3" json:"amount,omitempty"`
+	Currency      string                 `protobuf:"bytes,5,opt,name=currency,proto3" json:"currency,omitempty"`
+	PaymentMethod string                 `protobuf:"bytes,6,opt,name=paymentMethod,proto3" json:"paymentMethod,omitempty"`
+	SplitWith     []string               `protobuf:"bytes,7,rep,name=splitWith,proto3" json:"splitWith,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *PaymentCapture) Reset() {
+	*x = PaymentCapture{}
+	mi := &file_events_proto_msgTypes[15]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *PaymentCapture) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*PaymentCapture) ProtoMessage() {}
+
+func (x *PaymentCapture) ProtoReflect() protoreflect.Message {
+	mi := &file_events_proto_msgTypes[15]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use PaymentCapture.ProtoReflect.Descriptor instead.
+func (*PaymentCapture) Descriptor() ([]byte, []int) {
+	return file_events_proto_rawDescGZIP(), []int{15}
+}
+
+func (x *PaymentCapture) GetTripID() string {
+	if x != nil {
+		return x.TripID
+	}
+	return ""
+}
+
+func (x *PaymentCapture) GetUserID() string {
+	if x != nil {
+		return x.UserID
+	}
+	return ""
+}
+
+func (x *PaymentCapture) GetDriverID() string {
+	if x != nil {
+		return x.DriverID
+	}
+	return ""
+}
+
+func (x *PaymentCapture) GetAmount() float64 {
+	if x != nil {
+		return x.Amount
+	}
+	return 0
+}
+
+func (x *PaymentCapture) GetCurrency() string {
+	if x != nil {
+		return x.Currency
+	}
+	return ""
+}
+
+func (x *PaymentCapture) GetPaymentMethod() string {
+	if x != nil {
+		return x.PaymentMethod
+	}
+	return ""
+}
+
+func (x *PaymentCapture) GetSplitWith() []string {
+	if x != nil {
+		return x.SplitWith
+	}
+	return nil
+}
+
+// payment.cmd.void
+type PaymentVoid struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	TripID        string                 `protobuf:"bytes,1,opt,name=tripID,proto3" json:"tripID,omitempty"`
+	Reason        string                 `protobuf:"bytes,2,opt,name=reason,proto3" json:"reason,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *PaymentVoid) Reset() {
+	*x = PaymentVoid{}
+	mi := &file_events_proto_msgTypes[16]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *PaymentVoid) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*PaymentVoid) ProtoMessage() {}
+
+func (x *PaymentVoid) ProtoReflect() protoreflect.Message {
+	mi := &file_events_proto_msgTypes[16]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use PaymentVoid.ProtoReflect.Descriptor instead.
+func (*PaymentVoid) Descriptor() ([]byte, []int) {
+	return file_events_proto_rawDescGZIP(), []int{16}
+}
+
+func (x *PaymentVoid) GetTripID() string {
+	if x != nil {
+		return x.TripID
+	}
+	return ""
+}
+
+func (x *PaymentVoid) GetReason() string {
+	if x != nil {
+		return x.Reason
+	}
+	return ""
+}
+
+var File_events_proto protoreflect.FileDescriptor
+
+const file_events_proto_rawDesc = "" +
+	"\n" +
+	"\fevents.proto\x12\x06events\x1a\x1fgoogle/protobuf/timestamp.proto\x1a\fdriver.proto\x1a\n" +
+	"trip.proto\"\x8a\x02\n" +
+	"\bEnvelope\x12\x18\n" +
+	"\aeventId\x18\x01 \x01(\tR\aeventId\x12\x12\n" +
+	"\x04type\x18\x02 \x01(\tR\x04type\x12$\n" +
+	"\rschemaVersion\x18\x03 \x01(\x05R\rschemaVersion\x12:\n" +
+	"\n" +
+	"occurredAt\x18\x04 \x01(\v2\x1a.google.protobuf.TimestampR\n" +
+	"occurredAt\x12\x1a\n" +
+	"\bproducer\x18\x05 \x01(\tR\bproducer\x12$\n" +
+	"\rcorrelationId\x18\x06 \x01(\tR\rcorrelationId\x12\x18\n" +
+	"\aownerId\x18\a \x01(\tR\aownerId\x12\x12\n" +
+	"\x04data\x18\b \x01(\fR\x04data\"+\n" +
+	"\tTripEvent\x12\x1e\n" +
+	"\x04trip\x18\x01 \x01(\v2\n" +
+	".trip.TripR\x04trip\"\x92\x01\n" +
+	"\x0eTripEventSplit\x12\x16\n" +
+	"\x06tripID\x18\x01 \x01(\tR\x06tripID\x12\x18\n" +
+	"\aownerID\x18\x02 \x01(\tR\aownerID\x12\x12\n" +
+	"\x04fare\x18\x03 \x01(\x01R\x04fare\x12:\n" +
+	"\fparticipants\x18\x04 \x03(\v2\x16.trip.SplitParticipantR\fparticipants\"n\n" +
+	"\x12DriverTripResponse\x12&\n" +
+	"\x06driver\x18\x01 \x01(\v2\x0e.driver.DriverR\x06driver\x12\x16\n" +
+	"\x06tripID\x18\x02 \x01(\tR\x06tripID\x12\x18\n" +
+	"\ariderID\x18\x03 \x01(\tR\ariderID\":\n" +
+	"\bRiderTip\x12\x16\n" +
+	"\x06tripID\x18\x01 \x01(\tR\x06tripID\x12\x16\n" +
+	"\x06amount\x18\x02 \x01(\x01R\x06amount\"D\n" +
+	"\x10RiderSplitInvite\x12\x16\n" +
+	"\x06tripID\x18\x01 \x01(\tR\x06tripID\x12\x18\n" +
+	"\auserIDs\x18\x02 \x03(\tR\auserIDs\"C\n" +
+	"\x11RiderSplitRespond\x12\x16\n" +
+	"\x06tripID\x18\x01 \x01(\tR\x06tripID\x12\x16\n" +
+	"\x06accept\x18\x02 \x01(\bR\x06accept\"\x81\x01\n" +
+	"\x15PaymentSessionCreated\x12\x16\n" +
+	"\x06tripID\x18\x01 \x01(\tR\x06tripID\x12\x1c\n" +
+	"\tsessionID\x18\x02 \x01(\tR\tsessionID\x12\x16\n" +
+	"\x06amount\x18\x03 \x01(\x01R\x06amount\x12\x1a\n" +
+	"\bcurrency\x18\x04 \x01(\tR\bcurrency\"\x97\x01\n" +
+	"\x13PaymentStatusUpdate\x12\x16\n" +
+	"\x06tripID\x18\x01 \x01(\tR\x06tripID\x12\x16\n" +
+	"\x06userID\x18\x02 \x01(\tR\x06userID\x12\x1a\n" +
+	"\bdriverID\x18\x03 \x01(\tR\bdriverID\x12\x1c\n" +
+	"\tsessionID\x18\x04 \x01(\tR\tsessionID\x12\x16\n" +
+	"\x06reason\x18\x05 \x01(\tR\x06reason\"\xcc\x01\n" +
+	"\fPaymentRetry\x12\x16\n" +
+	"\x06tripID\x18\x01 \x01(\tR\x06tripID\x12\x1c\n" +
+	"\tsessionID\x18\x02 \x01(\tR\tsessionID\x12\x16\n" +
+	"\x06amount\x18\x03 \x01(\x01R\x06amount\x12\x1a\n" +
+	"\bcurrency\x18\x04 \x01(\tR\bcurrency\x12\x18\n" +
+	"\aattempt\x18\x05 \x01(\x05R\aattempt\x12 \n" +
+	"\vmaxAttempts\x18\x06 \x01(\x05R\vmaxAttempts\x12\x16\n" +
+	"\x06reason\x18\a \x01(\tR\x06reason\"\xac\x01\n" +
+	"\x0ePaymentDunning\x12\x16\n" +
+	"\x06tripID\x18\x01 \x01(\tR\x06tripID\x12\x16\n" +
+	"\x06userID\x18\x02 \x01(\tR\x06userID\x12\x1a\n" +
+	"\bdriverID\x18\x03 \x01(\tR\bdriverID\x12\x16\n" +
+	"\x06amount\x18\x04 \x01(\x01R\x06amount\x12\x1a\n" +
+	"\bcurrency\x18\x05 \x01(\tR\bcurrency\x12\x1a\n" +
+	"\battempts\x18\x06 \x01(\x05R\battempts\"\xeb\x01\n" +
+	"\x0fPaymentRefunded\x12\x16\n" +
+	"\x06tripID\x18\x01 \x01(\tR\x06tripID\x12\x1a\n" +
+	"\brefundID\x18\x02 \x01(\tR\brefundID\x12\x16\n" +
+	"\x06userID\x18\x03 \x01(\tR\x06userID\x12\x1a\n" +
+	"\bdriverID\x18\x04 \x01(\tR\bdriverID\x12\x16\n" +
+	"\x06amount\x18\x05 \x01(\x01R\x06amount\x12$\n" +
+	"\rtotalRefunded\x18\x06 \x01(\x01R\rtotalRefunded\x12\x1a\n" +
+	"\bcurrency\x18\a \x01(\tR\bcurrency\x12\x16\n" +
+	"\x06reason\x18\b \x01(\tR\x06reason\"\x94\x01\n" +
+	"\x12PaymentTipReceived\x12\x16\n" +
+	"\x06tripID\x18\x01 \x01(\tR\x06tripID\x12\x16\n" +
+	"\x06userID\x18\x02 \x01(\tR\x06userID\x12\x1a\n" +
+	"\bdriverID\x18\x03 \x01(\tR\bdriverID\x12\x16\n" +
+	"\x06amount\x18\x04 \x01(\x01R\x06amount\x12\x1a\n" +
+	"\bcurrency\x18\x05 \x01(\tR\bcurrency\"\x96\x01\n" +
+	"\x14PaymentCreateSession\x12\x16\n" +
+	"\x06tripID\x18\x01 \x01(\tR\x06tripID\x12\x16\n" +
+	"\x06userID\x18\x02 \x01(\tR\x06userID\x12\x1a\n" +
+	"\bdriverID\x18\x03 \x01(\tR\bdriverID\x12\x16\n" +
+	"\x06amount\x18\x04 \x01(\x01R\x06amount\x12\x1a\n" +
+	"\bcurrency\x18\x05 \x01(\tR\bcurrency\"v\n" +
+	"\x10PaymentAuthorize\x12\x16\n" +
+	"\x06tripID\x18\x01 \x01(\tR\x06tripID\x12\x16\n" +
+	"\x06userID\x18\x02 \x01(\tR\x06userID\x12\x16\n" +
+	"\x06amount\x18\x03 \x01(\x01R\x06amount\x12\x1a\n" +
+	"\bcurrency\x18\x04 \x01(\tR\bcurrency\"\xd4\x01\n" +
+	"\x0ePaymentCapture\x12\x16\n" +
+	"\x06tripID\x18\x01 \x01(\tR\x06tripID\x12\x16\n" +
+	"\x06userID\x18\x02 \x01(\tR\x06userID\x12\x1a\n" +
+	"\bdriverID\x18\x03 \x01(\tR\bdriverID\x12\x16\n" +
+	"\x06amount\x18\x04 \x01(\x01R\x06amount\x12\x1a\n" +
+	"\bcurrency\x18\x05 \x01(\tR\bcurrency\x12$\n" +
+	"\rpaymentMethod\x18\x06 \x01(\tR\rpaymentMethod\x12\x1c\n" +
+	"\tsplitWith\x18\a \x03(\tR\tsplitWith\"=\n" +
+	"\vPaymentVoid\x12\x16\n" +
+	"\x06tripID\x18\x01 \x01(\tR\x06tripID\x12\x16\n" +
+	"\x06reason\x18\x02 \x01(\tR\x06reasonB7Z5github.com/AuraReaper/voom/shared/proto/events;eventsb\x06proto3"
+
+var (
+	file_events_proto_rawDescOnce sync.Once
+	file_events_proto_rawDescData []byte
+)
+
+func file_events_proto_rawDescGZIP() []byte {
+	file_events_proto_rawDescOnce.Do(func() {
+		file_events_proto_rawDescData = protoimpl.X.CompressGZIP(unsafe.Slice(unsafe.StringData(file_events_proto_rawDesc), len(file_events_proto_rawDesc)))
+	})
+	return file_events_proto_rawDescData
+}
+
+var file_events_proto_msgTypes = make([]protoimpl.MessageInfo, 17)
+var file_events_proto_goTypes = []any{
+	(*Envelope)(nil),              // 0: events.Envelope
+	(*TripEvent)(nil),             // 1: events.TripEvent
+	(*TripEventSplit)(nil),        // 2: events.TripEventSplit
+	(*DriverTripResponse)(nil),    // 3: events.DriverTripResponse
+	(*RiderTip)(nil),              // 4: events.RiderTip
+	(*RiderSplitInvite)(nil),      // 5: events.RiderSplitInvite
+	(*RiderSplitRespond)(nil),     // 6: events.RiderSplitRespond
+	(*PaymentSessionCreated)(nil), // 7: events.PaymentSessionCreated
+	(*PaymentStatusUpdate)(nil),   // 8: events.PaymentStatusUpdate
+	(*PaymentRetry)(nil),          // 9: events.PaymentRetry
+	(*PaymentDunning)(nil),        // 10: events.PaymentDunning
+	(*PaymentRefunded)(nil),       // 11: events.PaymentRefunded
+	(*PaymentTipReceived)(nil),    // 12: events.PaymentTipReceived
+	(*PaymentCreateSession)(nil),  // 13: events.PaymentCreateSession
+	(*PaymentAuthorize)(nil),      // 14: events.PaymentAuthorize
+	(*PaymentCapture)(nil),        // 15: events.PaymentCapture
+	(*PaymentVoid)(nil),           // 16: events.PaymentVoid
+	(*timestamppb.Timestamp)(nil), // 17: google.protobuf.Timestamp
+	(*trip.Trip)(nil),             // 18: trip.Trip
+	(*trip.SplitParticipant)(nil), // 19: trip.SplitParticipant
+	(*driver.Driver)(nil),         // 20: driver.Driver
+}
+var file_events_proto_depIdxs = []int32{
+	17, // 0: events.Envelope.occurredAt:type_name -> google.protobuf.Timestamp
+	18, // 1: events.TripEvent.trip:type_name -> trip.Trip
+	19, // 2: events.TripEventSplit.participants:type_name -> trip.SplitParticipant
+	20, // 3: events.DriverTripResponse.driver:type_name -> driver.Driver
+	4,  // [4:4] is the sub-list for method output_type
+	4,  // [4:4] is the sub-list for method input_type
+	4,  // [4:4] is the sub-list for extension type_name
+	4,  // [4:4] is the sub-list for extension extendee
+	0,  // [0:4] is the sub-list for field type_name
+}
+
+func init() { file_events_proto_init() }
+func file_events_proto_init() {
+	if File_events_proto != nil {
+		return
+	}
+	type x struct{}
+	out := protoimpl.TypeBuilder{
+		File: protoimpl.DescBuilder{
+			GoPackagePath: reflect.TypeOf(x{}).PkgPath(),
+			RawDescriptor: unsafe.Slice(unsafe.StringData(file_events_proto_rawDesc), len(file_events_proto_rawDesc)),
+			NumEnums:      0,
+			NumMessages:   17,
+			NumExtensions: 0,
+			NumServices:   0,
+		},
+		GoTypes:           file_events_proto_goTypes,
+		DependencyIndexes: file_events_proto_depIdxs,
+		MessageInfos:      file_events_proto_msgTypes,
+	}.Build()
+	File_events_proto = out.File
+	file_events_proto_goTypes = nil
+	file_events_proto_depIdxs = nil
+}
@@ -0,0 +1,1540 @@
+// Code generated by protoc-gen-go. DO NOT EDIT.
+// versions:
+// 	protoc-gen-go v1.36.8
+// 	protoc        v6.32.1
+// source: payment.proto
+
+package payment
+
+import (
+	protoreflect "google.golang.org/protobuf/reflect/protoreflect"
+	protoimpl "google.golang.org/protobuf/runtime/protoimpl"
+	reflect "reflect"
+	sync "sync"
+	unsafe "unsafe"
+)
+
+const (
+	// Verify that this generated code is sufficiently up-to-date.
+	_ = protoimpl.EnforceVersion(20 - protoimpl.MinVersion)
+	// Verify that runtime/protoimpl is sufficiently up-to-date.
+	_ = protoimpl.EnforceVersion(protoimpl.MaxVersion - 20)
+)
+
+type GetPaymentByTripRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	TripID        string                 `protobuf:"bytes,1,opt,name=tripID,proto3" json:"tripID,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *GetPaymentByTripRequest) Reset() {
+	*x = GetPaymentByTripRequest{}
+	mi := &file_payment_proto_msgTypes[0]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *GetPaymentByTripRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*GetPaymentByTripRequest) ProtoMessage() {}
+
+func (x *GetPaymentByTripRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_payment_proto_msgTypes[0]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use GetPaymentByTripRequest.ProtoReflect.Descriptor instead.
+func (*GetPaymentByTripRequest) Descriptor() ([]byte, []int) {
+	return file_payment_proto_rawDescGZIP(), []int{0}
+}
+
+func (x *GetPaymentByTripRequest) GetTripID() string {
+	if x != nil {
+		return x.TripID
+	}
+	return ""
+}
+
+type GetPaymentByTripResponse struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Payment       *Payment               `protobuf:"bytes,1,opt,name=payment,proto3" json:"payment,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *GetPaymentByTripResponse) Reset() {
+	*x = GetPaymentByTripResponse{}
+	mi := &file_payment_proto_msgTypes[1]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *GetPaymentByTripResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*GetPaymentByTripResponse) ProtoMessage() {}
+
+func (x *GetPaymentByTripResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_payment_proto_msgTypes[1]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use GetPaymentByTripResponse.ProtoReflect.Descriptor instead.
+func (*GetPaymentByTripResponse) Descriptor() ([]byte, []int) {
+	return file_payment_proto_rawDescGZIP(), []int{1}
+}
+
+func (x *GetPaymentByTripResponse) GetPayment() *Payment {
+	if x != nil {
+		return x.Payment
+	}
+	return nil
+}
+
+type Payment struct {
+	state           protoimpl.MessageState `protogen:"open.v1"`
+	Id              string                 `protobuf:"bytes,1,opt,name=id,proto3" json:"id,omitempty"`
+	TripID          string                 `protobuf:"bytes,2,opt,name=tripID,proto3" json:"tripID,omitempty"`
+	UserID          string                 `protobuf:"bytes,3,opt,name=userID,proto3" json:"userID,omitempty"`
+	DriverID        string                 `protobuf:"bytes,4,opt,name=driverID,proto3" json:"driverID,omitempty"`
+	Amount          int64                  `protobuf:"varint,5,opt,name=amount,proto3" json:"amount,omitempty"`
+	Currency        string                 `protobuf:"bytes,6,opt,name=currency,proto3" json:"currency,omitempty"`
+	Status          string                 `protobuf:"bytes,7,opt,name=status,proto3" json:"status,omitempty"`
+	StripeSessionID string                 `protobuf:"bytes,8,opt,name=stripeSessionID,proto3" json:"stripeSessionID,omitempty"`
+	CreatedAt       int64                  `protobuf:"varint,9,opt,name=createdAt,proto3" json:"createdAt,omitempty"`
+	UpdatedAt       int64                  `protobuf:"varint,10,opt,name=updatedAt,proto3" json:"updatedAt,omitempty"`
+	Attempt         int32                  `protobuf:"varint,11,opt,name=attempt,proto3" json:"attempt,omitempty"`
+	RefundedAmount  int64                  `protobuf:"varint,12,opt,name=refundedAmount,proto3" json:"refundedAmount,omitempty"`
+	Purpose         string                 `protobuf:"bytes,13,opt,name=purpose,proto3" json:"purpose,omitempty"`
+	CapturedAmount  int64                  `protobuf:"varint,14,opt,name=capturedAmount,proto3" json:"capturedAmount,omitempty"`
+	Method          string                 `protobuf:"bytes,15,opt,name=method,proto3" json:"method,omitempty"`
+	unknownFields   protoimpl.UnknownFields
+	sizeCache       protoimpl.SizeCache
+}
+
+func (x *Payment) Reset() {
+	*x = Payment{}
+	mi := &file_payment_proto_msgTypes[2]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *Payment) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*Payment) ProtoMessage() {}
+
+func (x *Payment) ProtoReflect() protoreflect.Message {
+	mi := &file_payment_proto_msgTypes[2]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use Payment.ProtoReflect.Descriptor instead.
+func (*Payment) Descriptor() ([]byte, []int) {
+	return file_payment_proto_rawDescGZIP(), []int{2}
+}
+
+func (x *Payment) GetId() string {
+	if x != nil {
+		return x.Id
+	}
+	return ""
+}
+
+func (x *Payment) GetTripID() string {
+	if x != nil {
+		return x.TripID
+	}
+	return ""
+}
+
+func (x *Payment) GetUserID() string {
+	if x != nil {
+		return x.UserID
+	}
+	return ""
+}
+
+func (x *Payment) GetDriverID() string {
+	if x != nil {
+		return x.DriverID
+	}
+	return ""
+}
+
+func (x *Payment) GetAmount() int64 {
+	if x != nil {
+		return x.Amount
+	}
+	return 0
+}
+
+func (x *Payment) GetCurrency() string {
+	if x != nil {
+		return x.Currency
+	}
+	return ""
+}
+
+func (x *Payment) GetStatus() string {
+	if x != nil {
+		return x.Status
+	}
+	return ""
+}
+
+func (x *Payment) GetStripeSessionID() string {
+	if x != nil {
+		return x.StripeSessionID
+	}
+	return ""
+}
+
+func (x *Payment) GetCreatedAt() int64 {
+	if x != nil {
+		return x.CreatedAt
+	}
+	return 0
+}
+
+func (x *Payment) GetUpdatedAt() int64 {
+	if x != nil {
+		return x.UpdatedAt
+	}
+	return 0
+}
+
+func (x *Payment) GetAttempt() int32 {
+	if x != nil {
+		return x.Attempt
+	}
+	return 0
+}
+
+func (x *Payment) GetRefundedAmount() int64 {
+	if x != nil {
+		return x.RefundedAmount
+	}
+	return 0
+}
+
+func (x *Payment) GetPurpose() string {
+	if x != nil {
+		return x.Purpose
+	}
+	return ""
+}
+
+func (x *Payment) GetCapturedAmount() int64 {
+	if x != nil {
+		return x.CapturedAmount
+	}
+	return 0
+}
+
+func (x *Payment) GetMethod() string {
+	if x != nil {
+		return x.Method
+	}
+	return ""
+}
+
+type RefundPaymentRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	TripID        string                 `protobuf:"bytes,1,opt,name=tripID,proto3" json:"tripID,omitempty"`
+	Amount        int64                  `protobuf:"varint,2,opt,name=amount,proto3" json:"amount,omitempty"` // Amount in cents
+	Reason        string                 `protobuf:"bytes,3,opt,name=reason,proto3" json:"reason,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *RefundPaymentRequest) Reset() {
+	*x = RefundPaymentRequest{}
+	mi := &file_payment_proto_msgTypes[3]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *RefundPaymentRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*RefundPaymentRequest) ProtoMessage() {}
+
+func (x *RefundPaymentRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_payment_proto_msgTypes[3]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use RefundPaymentRequest.ProtoReflect.Descriptor instead.
+func (*RefundPaymentRequest) Descriptor() ([]byte, []int) {
+	return file_payment_proto_rawDescGZIP(), []int{3}
+}
+
+func (x *RefundPaymentRequest) GetTripID() string {
+	if x != nil {
+		return x.TripID
+	}
+	return ""
+}
+
+func (x *RefundPaymentRequest) GetAmount() int64 {
+	if x != nil {
+		return x.Amount
+	}
+	return 0
+}
+
+func (x *RefundPaymentRequest) GetReason() string {
+	if x != nil {
+		return x.Reason
+	}
+	return ""
+}
+
+type RefundPaymentResponse struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Refund        *Refund                `protobuf:"bytes,1,opt,name=refund,proto3" json:"refund,omitempty"`
+	Payment       *Payment               `protobuf:"bytes,2,opt,name=payment,proto3" json:"payment,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *RefundPaymentResponse) Reset() {
+	*x = RefundPaymentResponse{}
+	mi := &file_payment_proto_msgTypes[4]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *RefundPaymentResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*RefundPaymentResponse) ProtoMessage() {}
+
+func (x *RefundPaymentResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_payment_proto_msgTypes[4]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use RefundPaymentResponse.ProtoReflect.Descriptor instead.
+func (*RefundPaymentResponse) Descriptor() ([]byte, []int) {
+	return file_payment_proto_rawDescGZIP(), []int{4}
+}
+
+func (x *RefundPaymentResponse) GetRefund() *Refund {
+	if x != nil {
+		return x.Refund
+	}
+	return nil
+}
+
+func (x *RefundPaymentResponse) GetPayment() *Payment {
+	if x != nil {
+		return x.Payment
+	}
+	return nil
+}
+
+type Refund struct {
+	state             protoimpl.MessageState `protogen:"open.v1"`
+	Id                string                 `protobuf:"bytes,1,opt,name=id,proto3" json:"id,omitempty"`
+	PaymentID         string                 `protobuf:"bytes,2,opt,name=paymentID,proto3" json:"paymentID,omitempty"`
+	TripID            string                 `protobuf:"bytes,3,opt,name=tripID,proto3" json:"tripID,omitempty"`
+	Amount            int64                  `protobuf:"varint,4,opt,name=amount,proto3" json:"amount,omitempty"`
+	Currency          string                 `protobuf:"bytes,5,opt,name=currency,proto3" json:"currency,omitempty"`
+	Reason            string                 `protobuf:"bytes,6,opt,name=reason,proto3" json:"reason,omitempty"`
+	ProcessorRefundID string                 `protobuf:"bytes,7,opt,name=processorRefundID,proto3" json:"processorRefundID,omitempty"`
+	CreatedAt         int64                  `protobuf:"varint,8,opt,name=createdAt,proto3" json:"createdAt,omitempty"`
+	unknownFields     protoimpl.UnknownFields
+	sizeCache         protoimpl.SizeCache
+}
+
+func (x *Refund) Reset() {
+	*x = Refund{}
+	mi := &file_payment_proto_msgTypes[5]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *Refund) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*Refund) ProtoMessage() {}
+
+func (x *Refund) ProtoReflect() protoreflect.Message {
+	mi := &file_payment_proto_msgTypes[5]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use Refund.ProtoReflect.Descriptor instead.
+func (*Refund) Descriptor() ([]byte, []int) {
+	return file_payment_proto_rawDescGZIP(), []int{5}
+}
+
+func (x *Refund) GetId() string {
+	if x != nil {
+		return x.Id
+	}
+	return ""
+}
+
+func (x *Refund) GetPaymentID() string {
+	if x != nil {
+		return x.PaymentID
+	}
+	return ""
+}
+
+func (x *Refund) GetTripID() string {
+	if x != nil {
+		return x.TripID
+	}
+	return ""
+}
+
+func (x *Refund) GetAmount() int64 {
+	if x != nil {
+		return x.Amount
+	}
+	return 0
+}
+
+func (x *Refund) GetCurrency() string {
+	if x != nil {
+		return x.Currency
+	}
+	return ""
+}
+
+func (x *Refund) GetReason() string {
+	if x != nil {
+		return x.Reason
+	}
+	return ""
+}
+
+func (x *Refund) GetProcessorRefundID() string {
+	if x != nil {
+		return x.ProcessorRefundID
+	}
+	return ""
+}
+
+func (x *Refund) GetCreatedAt() int64 {
+	if x != nil {
+		return x.CreatedAt
+	}
+	return 0
+}
+
+type HandleStripeWebhookRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Payload       []byte                 `protobuf:"bytes,1,opt,name=payload,proto3" json:"payload,omitempty"`     // Raw request body, the signature is computed over it
+	Signature     string                 `protobuf:"bytes,2,opt,name=signature,proto3" json:"signature,omitempty"` // Stripe-Signature header
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *HandleStripeWebhookRequest) Reset() {
+	*x = HandleStripeWebhookRequest{}
+	mi := &file_payment_proto_msgTypes[6]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *HandleStripeWebhookRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*HandleStripeWebhookRequest) ProtoMessage() {}
+
+func (x *HandleStripeWebhookRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_payment_proto_msgTypes[6]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use HandleStripeWebhookRequest.ProtoReflect.Descriptor instead.
+func (*HandleStripeWebhookRequest) Descriptor() ([]byte, []int) {
+	return file_payment_proto_rawDescGZIP(), []int{6}
+}
+
+func (x *HandleStripeWebhookRequest) GetPayload() []byte {
+	if x != nil {
+		return x.Payload
+	}
+	return nil
+}
+
+func (x *HandleStripeWebhookRequest) GetSignature() string {
+	if x != nil {
+		return x.Signature
+	}
+	return ""
+}
+
+type HandleStripeWebhookResponse struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	EventID       string                 `protobuf:"bytes,1,opt,name=eventID,proto3" json:"eventID,omitempty"`
+	Duplicate     bool                   `protobuf:"varint,2,opt,name=duplicate,proto3" json:"duplicate,omitempty"` // The event was already processed, Stripe retried the delivery
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *HandleStripeWebhookResponse) Reset() {
+	*x = HandleStripeWebhookResponse{}
+	mi := &file_payment_proto_msgTypes[7]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *HandleStripeWebhookResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*HandleStripeWebhookResponse) ProtoMessage() {}
+
+func (x *HandleStripeWebhookResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_payment_proto_msgTypes[7]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use HandleStripeWebhookResponse.ProtoReflect.Descriptor instead.
+func (*HandleStripeWebhookResponse) Descriptor() ([]byte, []int) {
+	return file_payment_proto_rawDescGZIP(), []int{7}
+}
+
+func (x *HandleStripeWebhookResponse) GetEventID() string {
+	if x != nil {
+		return x.EventID
+	}
+	return ""
+}
+
+func (x *HandleStripeWebhookResponse) GetDuplicate() bool {
+	if x != nil {
+		return x.Duplicate
+	}
+	return false
+}
+
+type TopUpWalletRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	UserID        string                 `protobuf:"bytes,1,opt,name=userID,proto3" json:"userID,omitempty"`
+	Amount        int64                  `protobuf:"varint,2,opt,name=amount,proto3" json:"amount,omitempty"` // Amount in cents
+	Currency      string                 `protobuf:"bytes,3,opt,name=currency,proto3" json:"currency,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *TopUpWalletRequest) Reset() {
+	*x = TopUpWalletRequest{}
+	mi := &file_payment_proto_msgTypes[8]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *TopUpWalletRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*TopUpWalletRequest) ProtoMessage() {}
+
+func (x *TopUpWalletRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_payment_proto_msgTypes[8]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use TopUpWalletRequest.ProtoReflect.Descriptor instead.
+func (*TopUpWalletRequest) Descriptor() ([]byte, []int) {
+	return file_payment_proto_rawDescGZIP(), []int{8}
+}
+
+func (x *TopUpWalletRequest) GetUserID() string {
+	if x != nil {
+		return x.UserID
+	}
+	return ""
+}
+
+func (x *TopUpWalletRequest) GetAmount() int64 {
+	if x != nil {
+		return x.Amount
+	}
+	return 0
+}
+
+func (x *TopUpWalletRequest) GetCurrency() string {
+	if x != nil {
+		return x.Currency
+	}
+	return ""
+}
+
+type TopUpWalletResponse struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	SessionID     string                 `protobuf:"bytes,1,opt,name=sessionID,proto3" json:"sessionID,omitempty"` // Checkout session the rider pays the top-up with
+	Payment       *Payment               `protobuf:"bytes,2,opt,name=payment,proto3" json:"payment,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *TopUpWalletResponse) Reset() {
+	*x = TopUpWalletResponse{}
+	mi := &file_payment_proto_msgTypes[9]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *TopUpWalletResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*TopUpWalletResponse) ProtoMessage() {}
+
+func (x *TopUpWalletResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_payment_proto_msgTypes[9]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use TopUpWalletResponse.ProtoReflect.Descriptor instead.
+func (*TopUpWalletResponse) Descriptor() ([]byte, []int) {
+	return file_payment_proto_rawDescGZIP(), []int{9}
+}
+
+func (x *TopUpWalletResponse) GetSessionID() string {
+	if x != nil {
+		return x.SessionID
+	}
+	return ""
+}
+
+func (x *TopUpWalletResponse) GetPayment() *Payment {
+	if x != nil {
+		return x.Payment
+	}
+	return nil
+}
+
+type GetWalletRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	UserID        string                 `protobuf:"bytes,1,opt,name=userID,proto3" json:"userID,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *GetWalletRequest) Reset() {
+	*x = GetWalletRequest{}
+	mi := &file_payment_proto_msgTypes[10]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *GetWalletRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*GetWalletRequest) ProtoMessage() {}
+
+func (x *GetWalletRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_payment_proto_msgTypes[10]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use GetWalletRequest.ProtoReflect.Descriptor instead.
+func (*GetWalletRequest) Descriptor() ([]byte, []int) {
+	return file_payment_proto_rawDescGZIP(), []int{10}
+}
+
+func (x *GetWalletRequest) GetUserID() string {
+	if x != nil {
+		return x.UserID
+	}
+	return ""
+}
+
+type GetWalletResponse struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Wallet        *Wallet                `protobuf:"bytes,1,opt,name=wallet,proto3" json:"wallet,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *GetWalletResponse) Reset() {
+	*x = GetWalletResponse{}
+	mi := &file_payment_proto_msgTypes[11]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *GetWalletResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*GetWalletResponse) ProtoMessage() {}
+
+func (x *GetWalletResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_payment_proto_msgTypes[11]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use GetWalletResponse.ProtoReflect.Descriptor instead.
+func (*GetWalletResponse) Descriptor() ([]byte, []int) {
+	return file_payment_proto_rawDescGZIP(), []int{11}
+}
+
+func (x *GetWalletResponse) GetWallet() *Wallet {
+	if x != nil {
+		return x.Wallet
+	}
+	return nil
+}
+
+type ListWalletTransactionsRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	UserID        string                 `protobuf:"bytes,1,opt,name=userID,proto3" json:"userID,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *ListWalletTransactionsRequest) Reset() {
+	*x = ListWalletTransactionsRequest{}
+	mi := &file_payment_proto_msgTypes[12]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *ListWalletTransactionsRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ListWalletTransactionsRequest) ProtoMessage() {}
+
+func (x *ListWalletTransactionsRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_payment_proto_msgTypes[12]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ListWalletTransactionsRequest.ProtoReflect.Descriptor instead.
+func (*ListWalletTransactionsRequest) Descriptor() ([]byte, []int) {
+	return file_payment_proto_rawDescGZIP(), []int{12}
+}
+
+func (x *ListWalletTransactionsRequest) GetUserID() string {
+	if x != nil {
+		return x.UserID
+	}
+	return ""
+}
+
+type ListWalletTransactionsResponse struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Transactions  []*WalletTransaction   `protobuf:"bytes,1,rep,name=transactions,proto3" json:"transactions,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *ListWalletTransactionsResponse) Reset() {
+	*x = ListWalletTransactionsResponse{}
+	mi := &file_payment_proto_msgTypes[13]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *ListWalletTransactionsResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ListWalletTransactionsResponse) ProtoMessage() {}
+
+func (x *ListWalletTransactionsResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_payment_proto_msgTypes[13]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ListWalletTransactionsResponse.ProtoReflect.Descriptor instead.
+func (*ListWalletTransactionsResponse) Descriptor() ([]byte, []int) {
+	return file_payment_proto_rawDescGZIP(), []int{13}
+}
+
+func (x *ListWalletTransactionsResponse) GetTransactions() []*WalletTransaction {
+	if x != nil {
+		return x.Transactions
+	}
+	return nil
+}
+
+type Wallet struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	UserID        string                 `protobuf:"bytes,1,opt,name=userID,proto3" json:"userID,omitempty"`
+	Balance       int64                  `protobuf:"varint,2,opt,name=balance,proto3" json:"balance,omitempty"`
+	Currency      string                 `protobuf:"bytes,3,opt,name=currency,proto3" json:"currency,omitempty"`
+	UpdatedAt     int64                  `protobuf:"varint,4,opt,name=updatedAt,proto3" json:"updatedAt,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *Wallet) Reset() {
+	*x = Wallet{}
+	mi := &file_payment_proto_msgTypes[14]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *Wallet) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*Wallet) ProtoMessage() {}
+
+func (x *Wallet) ProtoReflect() protoreflect.Message {
+	mi := &file_payment_proto_msgTypes[14]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use Wallet.ProtoReflect.Descriptor instead.
+func (*Wallet) Descriptor() ([]byte, []int) {
+	return file_payment_proto_rawDescGZIP(), []int{14}
+}
+
+func (x *Wallet) GetUserID() string {
+	if x != nil {
+		return x.UserID
+	}
+	return ""
+}
+
+func (x *Wallet) GetBalance() int64 {
+	if x != nil {
+		return x.Balance
+	}
+	return 0
+}
+
+func (x *Wallet) GetCurrency() string {
+	if x != nil {
+		return x.Currency
+	}
+	return ""
+}
+
+func (x *Wallet) GetUpdatedAt() int64 {
+	if x != nil {
+		return x.UpdatedAt
+	}
+	return 0
+}
+
+type WalletTransaction struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Id            string                 `protobuf:"bytes,1,opt,name=id,proto3" json:"id,omitempty"`
+	UserID        string                 `protobuf:"bytes,2,opt,name=userID,proto3" json:"userID,omitempty"`
+	Type          string                 `protobuf:"bytes,3,opt,name=type,proto3" json:"type,omitempty"`
+	Amount        int64                  `protobuf:"varint,4,opt,name=amount,proto3" json:"amount,omitempty"` // Signed amount in cents, negative for debits
+	Currency      string                 `protobuf:"bytes,5,opt,name=currency,proto3" json:"currency,omitempty"`
+	TripID        string                 `protobuf:"bytes,6,opt,name=tripID,proto3" json:"tripID,omitempty"`
+	Reference     string                 `protobuf:"bytes,7,opt,name=reference,proto3" json:"reference,omitempty"`
+	BalanceAfter  int64                  `protobuf:"varint,8,opt,name=balanceAfter,proto3" json:"balanceAfter,omitempty"`
+	CreatedAt     int64                  `protobuf:"varint,9,opt,name=createdAt,proto3" json:"createdAt,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *WalletTransaction) Reset() {
+	*x = WalletTransaction{}
+	mi := &file_payment_proto_msgTypes[15]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *WalletTransaction) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*WalletTransaction) ProtoMessage() {}
+
+func (x *WalletTransaction) ProtoReflect() protoreflect.Message {
+	mi := &file_payment_proto_msgTypes[15]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use WalletTransaction.ProtoReflect.Descriptor instead.
+func (*WalletTransaction) Descriptor() ([]byte, []int) {
+	return file_payment_proto_rawDescGZIP(), []int{15}
+}
+
+func (x *WalletTransaction) GetId() string {
+	if x != nil {
+		return x.Id
+	}
+	return ""
+}
+
+func (x *WalletTransaction) GetUserID() string {
+	if x != nil {
+		return x.UserID
+	}
+	return ""
+}
+
+func (x *WalletTransaction) GetType() string {
+	if x != nil {
+		return x.Type
+	}
+	return ""
+}
+
+func (x *WalletTransaction) GetAmount() int64 {
+	if x != nil {
+		return x.Amount
+	}
+	return 0
+}
+
+func (x *WalletTransaction) GetCurrency() string {
+	if x != nil {
+		return x.Currency
+	}
+	return ""
+}
+
+func (x *WalletTransaction) GetTripID() string {
+	if x != nil {
+		return x.TripID
+	}
+	return ""
+}
+
+func (x *WalletTransaction) GetReference() string {
+	if x != nil {
+		return x.Reference
+	}
+	return ""
+}
+
+func (x *WalletTransaction) GetBalanceAfter() int64 {
+	if x != nil {
+		return x.BalanceAfter
+	}
+	return 0
+}
+
+func (x *WalletTransaction) GetCreatedAt() int64 {
+	if x != nil {
+		return x.CreatedAt
+	}
+	return 0
+}
+
+type TipTripRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	TripID        string                 `protobuf:"bytes,1,opt,name=tripID,proto3" json:"tripID,omitempty"`
+	UserID        string                 `protobuf:"bytes,2,opt,name=userID,proto3" json:"userID,omitempty"`
+	Amount        int64                  `protobuf:"varint,3,opt,name=amount,proto3" json:"amount,omitempty"` // Amount in cents
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *TipTripRequest) Reset() {
+	*x = TipTripRequest{}
+	mi := &file_payment_proto_msgTypes[16]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *TipTripRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*TipTripRequest) ProtoMessage() {}
+
+func (x *TipTripRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_payment_proto_msgTypes[16]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use TipTripRequest.ProtoReflect.Descriptor instead.
+func (*TipTripRequest) Descriptor() ([]byte, []int) {
+	return file_payment_proto_rawDescGZIP(), []int{16}
+}
+
+func (x *TipTripRequest) GetTripID() string {
+	if x != nil {
+		return x.TripID
+	}
+	return ""
+}
+
+func (x *TipTripRequest) GetUserID() string {
+	if x != nil {
+		return x.UserID
+	}
+	return ""
+}
+
+func (x *TipTripRequest) GetAmount() int64 {
+	if x != nil {
+		return x.Amount
+	}
+	return 0
+}
+
+type TipTripResponse struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	SessionID     string                 `protobuf:"bytes,1,opt,name=sessionID,proto3" json:"sessionID,omitempty"` // Checkout session the rider pays the tip with
+	Payment       *Payment               `protobuf:"bytes,2,opt,name=payment,proto3" json:"payment,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *TipTripResponse) Reset() {
+	*x = TipTripResponse{}
+	mi := &file_payment_proto_msgTypes[17]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *TipTripResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*TipTripResponse) ProtoMessage() {}
+
+func (x *TipTripResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_payment_proto_msgTypes[17]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use TipTripResponse.ProtoReflect.Descriptor instead.
+func (*TipTripResponse) Descriptor() ([]byte, []int) {
+	return file_payment_proto_rawDescGZIP(), []int{17}
+}
+
+func (x *TipTripResponse) GetSessionID() string {
+	if x != nil {
+		return x.SessionID
+	}
+	return ""
+}
+
+func (x *TipTripResponse) GetPayment() *Payment {
+	if x != nil {
+		return x.Payment
+	}
+	return nil
+}
+
+type GetDriverBalanceRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	DriverID      string                 `protobuf:"bytes,1,opt,name=driverID,proto3" json:"driverID,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *GetDriverBalanceRequest) Reset() {
+	*x = GetDriverBalanceRequest{}
+	mi := &file_payment_proto_msgTypes[18]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *GetDriverBalanceRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*GetDriverBalanceRequest) ProtoMessage() {}
+
+func (x *GetDriverBalanceRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_payment_proto_msgTypes[18]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use GetDriverBalanceRequest.ProtoReflect.Descriptor instead.
+func (*GetDriverBalanceRequest) Descriptor() ([]byte, []int) {
+	return file_payment_proto_rawDescGZIP(), []int{18}
+}
+
+func (x *GetDriverBalanceRequest) GetDriverID() string {
+	if x != nil {
+		return x.DriverID
+	}
+	return ""
+}
+
+type GetDriverBalanceResponse struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Balance       *DriverBalance         `protobuf:"bytes,1,opt,name=balance,proto3" json:"balance,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *GetDriverBalanceResponse) Reset() {
+	*x = GetDriverBalanceResponse{}
+	mi := &file_payment_proto_msgTypes[19]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *GetDriverBalanceResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*GetDriverBalanceResponse) ProtoMessage() {}
+
+func (x *GetDriverBalanceResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_payment_proto_msgTypes[19]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use GetDriverBalanceResponse.ProtoReflect.Descriptor instead.
+func (*GetDriverBalanceResponse) Descriptor() ([]byte, []int) {
+	return file_payment_proto_rawDescGZIP(), []int{19}
+}
+
+func (x *GetDriverBalanceResponse) GetBalance() *DriverBalance {
+	if x != nil {
+		return x.Balance
+	}
+	return nil
+}
+
+// DriverBalance sums the ledger of a driver, amounts in cents
+type DriverBalance struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	DriverID      string                 `protobuf:"bytes,1,opt,name=driverID,proto3" json:"driverID,omitempty"`
+	Earnings      int64                  `protobuf:"varint,2,opt,name=earnings,proto3" json:"earnings,omitempty"`
+	Tips          int64                  `protobuf:"varint,3,opt,name=tips,proto3" json:"tips,omitempty"`
+	Refunds       int64                  `protobuf:"varint,4,opt,name=refunds,proto3" json:"refunds,omitempty"`       // Negative, refunds taken back from the driver
+	Commission    int64                  `protobuf:"varint,5,opt,name=commission,proto3" json:"commission,omitempty"` // Negative, commission owed on cash fares
+	Balance       int64                  `protobuf:"varint,6,opt,name=balance,proto3" json:"balance,omitempty"`       // Sum of the above, negative when the driver owes the platform
+	Currency      string                 `protobuf:"bytes,7,opt,name=currency,proto3" json:"currency,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *DriverBalance) Reset() {
+	*x = DriverBalance{}
+	mi := &file_payment_proto_msgTypes[20]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *DriverBalance) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*DriverBalance) ProtoMessage() {}
+
+func (x *DriverBalance) ProtoReflect() protoreflect.Message {
+	mi := &file_payment_proto_msgTypes[20]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use DriverBalance.ProtoReflect.Descriptor instead.
+func (*DriverBalance) Descriptor() ([]byte, []int) {
+	return file_payment_proto_rawDescGZIP(), []int{20}
+}
+
+func (x *DriverBalance) GetDriverID() string {
+	if x != nil {
+		return x.DriverID
+	}
+	return ""
+}
+
+func (x *DriverBalance) GetEarnings() int64 {
+	if x != nil {
+		return x.Earnings
+	}
+	return 0
+}
+
+func (x *DriverBalance) GetTips() int64 {
+	if x != nil {
+		return x.Tips
+	}
+	return 0
+}
+
+func (x *DriverBalance) GetRefunds() int64 {
+	if x != nil {
+		return x.Refunds
+	}
+	return 0
+}
+
+func (x *DriverBalance) GetCommission() int64 {
+	if x != nil {
+		return x.Commission
+	}
+	return 0
+}
+
+func (x *DriverBalance) GetBalance() int64 {
+	if x != nil {
+		return x.Balance
+	}
+	return 0
+}
+
+func (x *DriverBalance) GetCurrency() string {
+	if x != nil {
+		return x.Currency
+	}
+	return ""
+}
+
+var File_payment_proto protoreflect.FileDescriptor
+
+const file_payment_proto_rawDesc = "" +
+	"\n" +
+	"\rpayment.proto\x12\apayment\"1\n" +
+	"\x17GetPaymentByTripRequest\x12\x16\n" +
+	"\x06tripID\x18\x01 \x01(\tR\x06tripID\"F\n" +
+	"\x18GetPaymentByTripResponse\x12*\n" +
+	"\apayment\x18\x01 \x01(\v2\x10.payment.PaymentR\apayment\"\xb3\x03\n" +
+	"\aPayment\x12\x0e\n" +
+	"\x02id\x18\x01 \x01(\tR\x02id\x12\x16\n" +
+	"\x06tripID\x18\x02 \x01(\tR\x06tripID\x12\x16\n" +
+	"\x06userID\x18\x03 \x01(\tR\x06userID\x12\x1a\n" +
+	"\bdriverID\x18\x04 \x01(\tR\bdriverID\x12\x16\n" +
+	"\x06amount\x18\x05 \x01(\x03R\x06amount\x12\x1a\n" +
+	"\bcurrency\x18\x06 \x01(\tR\bcurrency\x12\x16\n" +
+	"\x06status\x18\a \x01(\tR\x06status\x12(\n" +
+	"\x0fstripeSessionID\x18\b \x01(\tR\x0fstripeSessionID\x12\x1c\n" +
+	"\tcreatedAt\x18\t \x01(\x03R\tcreatedAt\x12\x1c\n" +
+	"\tupdatedAt\x18\n" +
+	" \x01(\x03R\tupdatedAt\x12\x18\n" +
+	"\aattempt\x18\v \x01(\x05R\aattempt\x12&\n" +
+	"\x0erefundedAmount\x18\f \x01(\x03R\x0erefundedAmount\x12\x18\n" +
+	"\apurpose\x18\r \x01(\tR\apurpose\x12&\n" +
+	"\x0ecapturedAmount\x18\x0e \x01(\x03R\x0ecapturedAmount\x12\x16\n" +
+	"\x06method\x18\x0f \x01(\tR\x06method\"^\n" +
+	"\x14RefundPaymentRequest\x12\x16\n" +
+	"\x06tripID\x18\x01 \x01(\tR\x06tripID\x12\x16\n" +
+	"\x06amount\x18\x02 \x01(\x03R\x06amount\x12\x16\n" +
+	"\x06reason\x18\x03 \x01(\tR\x06reason\"l\n" +
+	"\x15RefundPaymentResponse\x12'\n" +
+	"\x06refund\x18\x01 \x01(\v2\x0f.payment.RefundR\x06refund\x12*\n" +
+	"\apayment\x18\x02 \x01(\v2\x10.payment.PaymentR\apayment\"\xe6\x01\n" +
+	"\x06Refund\x12\x0e\n" +
+	"\x02id\x18\x01 \x01(\tR\x02id\x12\x1c\n" +
+	"\tpaymentID\x18\x02 \x01(\tR\tpaymentID\x12\x16\n" +
+	"\x06tripID\x18\x03 \x01(\tR\x06tripID\x12\x16\n" +
+	"\x06amount\x18\x04 \x01(\x03R\x06amount\x12\x1a\n" +
+	"\bcurrency\x18\x05 \x01(\tR\bcurrency\x12\x16\n" +
+	"\x06reason\x18\x06 \x01(\tR\x06reason\x12,\n" +
+	"\x11processorRefundID\x18\a \x01(\tR\x11processorRefundID\x12\x1c\n" +
+	"\tcreatedAt\x18\b \x01(\x03R\tcreatedAt\"T\n" +
+	"\x1aHandleStripeWebhookRequest\x12\x18\n" +
+	"\apayload\x18\x01 \x01(\fR\apayload\x12\x1c\n" +
+	"\tsignature\x18\x02 \x01(\tR\tsignature\"U\n" +
+	"\x1bHandleStripeWebhookResponse\x12\x18\n" +
+	"\aeventID\x18\x01 \x01(\tR\aeventID\x12\x1c\n" +
+	"\tduplicate\x18\x02 \x01(\bR\tduplicate\"`\n" +
+	"\x12TopUpWalletRequest\x12\x16\n" +
+	"\x06userID\x18\x01 \x01(\tR\x06userID\x12\x16\n" +
+	"\x06amount\x18\x02 \x01(\x03R\x06amount\x12\x1a\n" +
+	"\bcurrency\x18\x03 \x01(\tR\bcurrency\"_\n" +
+	"\x13TopUpWalletResponse\x12\x1c\n" +
+	"\tsessionID\x18\x01 \x01(\tR\tsessionID\x12*\n" +
+	"\apayment\x18\x02 \x01(\v2\x10.payment.PaymentR\apayment\"*\n" +
+	"\x10GetWalletRequest\x12\x16\n" +
+	"\x06userID\x18\x01 \x01(\tR\x06userID\"<\n" +
+	"\x11GetWalletResponse\x12'\n" +
+	"\x06wallet\x18\x01 \x01(\v2\x0f.payment.WalletR\x06wallet\"7\n" +
+	"\x1dListWalletTransactionsRequest\x12\x16\n" +
+	"\x06userID\x18\x01 \x01(\tR\x06userID\"`\n" +
+	"\x1eListWalletTransactionsResponse\x12>\n" +
+	"\ftransactions\x18\x01 \x03(\v2\x1a.payment.WalletTransactionR\ftransactions\"t\n" +
+	"\x06Wallet\x12\x16\n" +
+	"\x06userID\x18\x01 \x01(\tR\x06userID\x12\x18\n" +
+	"\abalance\x18\x02 \x01(\x03R\abalance\x12\x1a\n" +
+	"\bcurrency\x18\x03 \x01(\tR\bcurrency\x12\x1c\n" +
+	"\tupdatedAt\x18\x04 \x01(\x03R\tupdatedAt\"\xfb\x01\n" +
+	"\x11WalletTransaction\x12\x0e\n" +
+	"\x02id\x18\x01 \x01(\tR\x02id\x12\x16\n" +
+	"\x06userID\x18\x02 \x01(\tR\x06userID\x12\x12\n" +
+	"\x04type\x18\x03 \x01(\tR\x04type\x12\x16\n" +
+	"\x06amount\x18\x04 \x01(\x03R\x06amount\x12\x1a\n" +
+	"\bcurrency\x18\x05 \x01(\tR\bcurrency\x12\x16\n" +
+	"\x06tripID\x18\x06 \x01(\tR\x06tripID\x12\x1c\n" +
+	"\treference\x18\a \x01(\tR\treference\x12\"\n" +
+	"\fbalanceAfter\x18\b \x01(\x03R\fbalanceAfter\x12\x1c\n" +
+	"\tcreatedAt\x18\t \x01(\x03R\tcreatedAt\"X\n" +
+	"\x0eTipTripRequest\x12\x16\n" +
+	"\x06tripID\x18\x01 \x01(\tR\x06tripID\x12\x16\n" +
+	"\x06userID\x18\x02 \x01(\tR\x06userID\x12\x16\n" +
+	"\x06amount\x18\x03 \x01(\x03R\x06amount\"[\n" +
+	"\x0fTipTripResponse\x12\x1c\n" +
+	"\tsessionID\x18\x01 \x01(\tR\tsessionID\x12*\n" +
+	"\apayment\x18\x02 \x01(\v2\x10.payment.PaymentR\apayment\"5\n" +
+	"\x17GetDriverBalanceRequest\x12\x1a\n" +
+	"\bdriverID\x18\x01 \x01(\tR\bdriverID\"L\n" +
+	"\x18GetDriverBalanceResponse\x120\n" +
+	"\abalance\x18\x01 \x01(\v2\x16.payment.DriverBalanceR\abalance\"\xcb\x01\n" +
+	"\rDriverBalance\x12\x1a\n" +
+	"\bdriverID\x18\x01 \x01(\tR\bdriverID\x12\x1a\n" +
+	"\bearnings\x18\x02 \x01(\x03R\bearnings\x12\x12\n" +
+	"\x04tips\x18\x03 \x01(\x03R\x04tips\x12\x18\n" +
+	"\arefunds\x18\x04 \x01(\x03R\arefunds\x12\x1e\n" +
+	"\n" +
+	"commission\x18\x05 \x01(\x03R\n" +
+	"commission\x12\x18\n" +
+	"\abalance\x18\x06 \x01(\x03R\abalance\x12\x1a\n" +
+	"\bcurrency\x18\a \x01(\tR\bcurrency2\xab\x05\n" +
+	"\x0ePaymentService\x12W\n" +
+	"\x10GetPaymentByTrip\x12 .payment.GetPaymentByTripRequest\x1a!.payment.GetPaymentByTripResponse\x12N\n" +
+	"\rRefundPayment\x12\x1d.payment.RefundPaymentRequest\x1a\x1e.payment.RefundPaymentResponse\x12`\n" +
+	"\x13HandleStripeWebhook\x12#.payment.HandleStripeWebhookRequest\x1a$.payment.HandleStripeWebhookResponse\x12H\n" +
+	"\vTopUpWallet\x12\x1b.payment.TopUpWalletRequest\x1a\x1c.payment.TopUpWalletResponse\x12B\n" +
+	"\tGetWallet\x12\x19.payment.GetWalletRequest\x1a\x1a.payment.GetWalletResponse\x12i\n" +
+	"\x16ListWalletTransactions\x12&.payment.ListWalletTransactionsRequest\x1a'.payment.ListWalletTransactionsResponse\x12<\n" +
+	"\aTipTrip\x12\x17.payment.TipTripRequest\x1a\x18.payment.TipTripResponse\x12W\n" +
+	"\x10GetDriverBalance\x12 .payment.GetDriverBalanceRequest\x1a!.payment.GetDriverBalanceResponseB9Z7github.com/AuraReaper/voom/shared/proto/payment;paymentb\x06proto3"
+
+var (
+	file_payment_proto_rawDescOnce sync.Once
+	file_payment_proto_rawDescData []byte
+)
+
+func file_payment_proto_rawDescGZIP() []byte {
+	file_payment_proto_rawDescOnce.Do(func() {
+		file_payment_proto_rawDescData = protoimpl.X.CompressGZIP(unsafe.Slice(unsafe.StringData(file_payment_proto_rawDesc), len(file_payment_proto_rawDesc)))
+	})
+	return file_payment_proto_rawDescData
+}
+
+var file_payment_proto_msgTypes = make([]protoimpl.MessageInfo, 21)
+var file_payment_proto_goTypes = []any{
+	(*GetPaymentByTripRequest)(nil),        // 0: payment.GetPaymentByTripRequest
+	(*GetPaymentByTripResponse)(nil),       // 1: payment.GetPaymentByTripResponse
+	(*Payment)(nil),                        // 2: payment.Payment
+	(*RefundPaymentRequest)(nil),           // 3: payment.RefundPaymentRequest
+	(*RefundPaymentResponse)(nil),          // 4: payment.RefundPaymentResponse
+	(*Refund)(nil),                         // 5: payment.Refund
+	(*HandleStripeWebhookRequest)(nil),     // 6: payment.HandleStripeWebhookRequest
+	(*HandleStripeWebhookResponse)(nil),    // 7: payment.HandleStripeWebhookResponse
+	(*TopUpWalletRequest)(nil),             // 8: payment.TopUpWalletRequest
+	(*TopUpWalletResponse)(nil),            // 9: payment.TopUpWalletResponse
+	(*GetWalletRequest)(nil),               // 10: payment.GetWalletRequest
+	(*GetWalletResponse)(nil),              // 11: payment.GetWalletResponse
+	(*ListWalletTransactionsRequest)(nil),  // 12: payment.ListWalletTransactionsRequest
+	(*ListWalletTransactionsResponse)(nil), // 13: payment.ListWalletTransactionsResponse
+	(*Wallet)(nil),                         // 14: payment.Wallet
+	(*WalletTransaction)(nil),              // 15: payment.WalletTransaction
+	(*TipTripRequest)(nil),                 // 16: payment.TipTripRequest
+	(*TipTripResponse)(nil),                // 17: payment.TipTripResponse
+	(*GetDriverBalanceRequest)(nil),        // 18: payment.GetDriverBalanceRequest
+	(*GetDriverBalanceResponse)(nil),       // 19: payment.GetDriverBalanceResponse
+	(*DriverBalance)(nil),                  // 20: payment.DriverBalance
+}
+var file_payment_proto_depIdxs = []int32{
+	2,  // 0: payment.GetPaymentByTripResponse.payment:type_name -> payment.Payment
+	5,  // 1: payment.RefundPaymentResponse.refund:type_name -> payment.Refund
+	2,  // 2: payment.RefundPaymentResponse.payment:type_name -> payment.Payment
+	2,  // 3: payment.TopUpWalletResponse.payment:type_name -> payment.Payment
+	14, // 4: payment.GetWalletResponse.wallet:type_name -> payment.Wallet
+	15, // 5: payment.ListWalletTransactionsResponse.transactions:type_name -> payment.WalletTransaction
+	2,  // 6: payment.TipTripResponse.payment:type_name -> payment.Payment
+	20, // 7: payment.GetDriverBalanceResponse.balance:type_name -> payment.DriverBalance
+	0,  // 8: payment.PaymentService.GetPaymentByTrip:input_type -> payment.GetPaymentByTripRequest
+	3,  // 9: payment.PaymentService.RefundPayment:input_type -> payment.RefundPaymentRequest
+	6,  // 10: payment.PaymentService.HandleStripeWebhook:input_type -> payment.HandleStripeWebhookRequest
+	8,  // 11: payment.PaymentService.TopUpWallet:input_type -> payment.TopUpWalletRequest
+	10, // 12: payment.PaymentService.GetWallet:input_type -> payment.GetWalletRequest
+	12, // 13: payment.PaymentService.ListWalletTransactions:input_type -> payment.ListWalletTransactionsRequest
+	16, // 14: payment.PaymentService.TipTrip:input_type -> payment.TipTripRequest
+	18, // 15: payment.PaymentService.GetDriverBalance:input_type -> payment.GetDriverBalanceRequest
+	1,  // 16: payment.PaymentService.GetPaymentByTrip:output_type -> payment.GetPaymentByTripResponse
+	4,  // 17: payment.PaymentService.RefundPayment:output_type -> payment.RefundPaymentResponse
+	7,  // 18: payment.PaymentService.HandleStripeWebhook:output_type -> payment.HandleStripeWebhookResponse
+	9,  // 19: payment.PaymentService.TopUpWallet:output_type -> payment.TopUpWalletResponse
+	11, // 20: payment.PaymentService.GetWallet:output_type -> payment.GetWalletResponse
+	13, // 21: payment.PaymentService.ListWalletTransactions:output_type -> payment.ListWalletTransactionsResponse
+	17, // 22: payment.PaymentService.TipTrip:output_type -> payment.TipTripResponse
+	19, // 23: payment.PaymentService.GetDriverBalance:output_type -> payment.GetDriverBalanceResponse
+	16, // [16:24] is the sub-list for method output_type
+	8,  // [8:16] is the sub-list for method input_type
+	8,  // [8:8] is the sub-list for extension type_name
+	8,  // [8:8] is the sub-list for extension extendee
+	0,  // [0:8] is the sub-list for field type_name
+}
+
+func init() { file_payment_proto_init() }
+func file_payment_proto_init() {
+	if File_payment_proto != nil {
+		return
+	}
+	type x struct{}
+	out := protoimpl.TypeBuilder{
+		File: protoimpl.DescBuilder{
+			GoPackagePath: reflect.TypeOf(x{}).PkgPath(),
+			RawDescriptor: unsafe.Slice(unsafe.StringData(file_payment_proto_rawDesc), len(file_payment_proto_rawDesc)),
+			NumEnums:      0,
+			NumMessages:   21,
+			NumExtensions: 0,
+			NumServices:   1,
+		},
+		GoTypes:           file_payment_proto_goTypes,
+		DependencyIndexes: file_payment_proto_depIdxs,
+		MessageInfos:      file_payment_proto_msgTypes,
+	}.Build()
+	File_payment_proto = out.File
+	file_payment_proto_goTypes = nil
+	file_payment_proto_depIdxs = nil
+}
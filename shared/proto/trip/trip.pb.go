@@ -26,6 +26,7 @@ type PreviewTripRequest struct {
 	UserID        string                 `protobuf:"bytes,1,opt,name=userID,proto3" json:"userID,omitempty"`
 	StartLocation *Coordinate            `protobuf:"bytes,2,opt,name=startLocation,proto3" json:"startLocation,omitempty"`
 	EndLocation   *Coordinate            `protobuf:"bytes,3,opt,name=endLocation,proto3" json:"endLocation,omitempty"`
+	PromoCode     string                 `protobuf:"bytes,4,opt,name=promoCode,proto3" json:"promoCode,omitempty"` // optional, applied to every fare it is valid for
 	unknownFields protoimpl.UnknownFields
 	sizeCache     protoimpl.SizeCache
 }
@@ -81,6 +82,13 @@ func (x *PreviewTripRequest) GetEndLocation() *Coordinate {
 	return nil
 }
 
+func (x *PreviewTripRequest) GetPromoCode() string {
+	if x != nil {
+		return x.PromoCode
+	}
+	return ""
+}
+
 type PreviewTripResponse struct {
 	state         protoimpl.MessageState `protogen:"open.v1"`
 	TripID        string                 `protobuf:"bytes,1,opt,name=tripID,proto3" json:"tripID,omitempty"`
@@ -302,7 +310,9 @@ type RideFare struct {
 	Id              string                 `protobuf:"bytes,1,opt,name=id,proto3" json:"id,omitempty"`
 	UserID          string                 `protobuf:"bytes,2,opt,name=userID,proto3" json:"userID,omitempty"`
 	PackageSlug     string                 `protobuf:"bytes,3,opt,name=packageSlug,proto3" json:"packageSlug,omitempty"`
-	TotalPriceInINR float64                `protobuf:"fixed64,4,opt,name=totalPriceInINR,proto3" json:"totalPriceInINR,omitempty"`
+	TotalPriceInINR float64                `protobuf:"fixed64,4,opt,name=totalPriceInINR,proto3" json:"totalPriceInINR,omitempty"` // after discounts
+	LineItems       []*FareLineItem        `protobuf:"bytes,5,rep,name=lineItems,proto3" json:"lineItems,omitempty"`
+	PromoCode       string                 `protobuf:"bytes,6,opt,name=promoCode,proto3" json:"promoCode,omitempty"`
 	unknownFields   protoimpl.UnknownFields
 	sizeCache       protoimpl.SizeCache
 }
@@ -365,17 +375,92 @@ func (x *RideFare) GetTotalPriceInINR() float64 {
 	return 0
 }
 
+func (x *RideFare) GetLineItems() []*FareLineItem {
+	if x != nil {
+		return x.LineItems
+	}
+	return nil
+}
+
+func (x *RideFare) GetPromoCode() string {
+	if x != nil {
+		return x.PromoCode
+	}
+	return ""
+}
+
+type FareLineItem struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Type          string                 `protobuf:"bytes,1,opt,name=type,proto3" json:"type,omitempty"` // base or promo
+	Description   string                 `protobuf:"bytes,2,opt,name=description,proto3" json:"description,omitempty"`
+	AmountInINR   float64                `protobuf:"fixed64,3,opt,name=amountInINR,proto3" json:"amountInINR,omitempty"` // negative for discounts
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *FareLineItem) Reset() {
+	*x = FareLineItem{}
+	mi := &file_trip_proto_msgTypes[6]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *FareLineItem) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*FareLineItem) ProtoMessage() {}
+
+func (x *FareLineItem) ProtoReflect() protoreflect.Message {
+	mi := &file_trip_proto_msgTypes[6]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use FareLineItem.ProtoReflect.Descriptor instead.
+func (*FareLineItem) Descriptor() ([]byte, []int) {
+	return file_trip_proto_rawDescGZIP(), []int{6}
+}
+
+func (x *FareLineItem) GetType() string {
+	if x != nil {
+		return x.Type
+	}
+	return ""
+}
+
+func (x *FareLineItem) GetDescription() string {
+	if x != nil {
+		return x.Description
+	}
+	return ""
+}
+
+func (x *FareLineItem) GetAmountInINR() float64 {
+	if x != nil {
+		return x.AmountInINR
+	}
+	return 0
+}
+
 type CreateTripRequest struct {
 	state         protoimpl.MessageState `protogen:"open.v1"`
 	RideFareID    string                 `protobuf:"bytes,1,opt,name=RideFareID,proto3" json:"RideFareID,omitempty"`
 	UserID        string                 `protobuf:"bytes,2,opt,name=userID,proto3" json:"userID,omitempty"`
+	PaymentMethod string                 `protobuf:"bytes,3,opt,name=paymentMethod,proto3" json:"paymentMethod,omitempty"` // card, wallet or cash, wallet when empty
 	unknownFields protoimpl.UnknownFields
 	sizeCache     protoimpl.SizeCache
 }
 
 func (x *CreateTripRequest) Reset() {
 	*x = CreateTripRequest{}
-	mi := &file_trip_proto_msgTypes[6]
+	mi := &file_trip_proto_msgTypes[7]
 	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 	ms.StoreMessageInfo(mi)
 }
@@ -387,7 +472,7 @@ func (x *CreateTripRequest) String() string {
 func (*CreateTripRequest) ProtoMessage() {}
 
 func (x *CreateTripRequest) ProtoReflect() protoreflect.Message {
-	mi := &file_trip_proto_msgTypes[6]
+	mi := &file_trip_proto_msgTypes[7]
 	if x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -400,7 +485,7 @@ func (x *CreateTripRequest) ProtoReflect() protoreflect.Message {
 
 // Deprecated: Use CreateTripRequest.ProtoReflect.Descriptor instead.
 func (*CreateTripRequest) Descriptor() ([]byte, []int) {
-	return file_trip_proto_rawDescGZIP(), []int{6}
+	return file_trip_proto_rawDescGZIP(), []int{7}
 }
 
 func (x *CreateTripRequest) GetRideFareID() string {
@@ -417,6 +502,13 @@ func (x *CreateTripRequest) GetUserID() string {
 	return ""
 }
 
+func (x *CreateTripRequest) GetPaymentMethod() string {
+	if x != nil {
+		return x.PaymentMethod
+	}
+	return ""
+}
+
 type CreateTripResponse struct {
 	state         protoimpl.MessageState `protogen:"open.v1"`
 	TripID        string                 `protobuf:"bytes,1,opt,name=tripID,proto3" json:"tripID,omitempty"`
@@ -427,7 +519,7 @@ type CreateTripResponse struct {
 
 func (x *CreateTripResponse) Reset() {
 	*x = CreateTripResponse{}
-	mi := &file_trip_proto_msgTypes[7]
+	mi := &file_trip_proto_msgTypes[8]
 	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 	ms.StoreMessageInfo(mi)
 }
@@ -439,7 +531,7 @@ func (x *CreateTripResponse) String() string {
 func (*CreateTripResponse) ProtoMessage() {}
 
 func (x *CreateTripResponse) ProtoReflect() protoreflect.Message {
-	mi := &file_trip_proto_msgTypes[7]
+	mi := &file_trip_proto_msgTypes[8]
 	if x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -452,7 +544,7 @@ func (x *CreateTripResponse) ProtoReflect() protoreflect.Message {
 
 // Deprecated: Use CreateTripResponse.ProtoReflect.Descriptor instead.
 func (*CreateTripResponse) Descriptor() ([]byte, []int) {
-	return file_trip_proto_rawDescGZIP(), []int{7}
+	return file_trip_proto_rawDescGZIP(), []int{8}
 }
 
 func (x *CreateTripResponse) GetTripID() string {
@@ -470,20 +562,22 @@ func (x *CreateTripResponse) GetTrip() *Trip {
 }
 
 type Trip struct {
-	state         protoimpl.MessageState `protogen:"open.v1"`
-	Id            string                 `protobuf:"bytes,1,opt,name=id,proto3" json:"id,omitempty"`
-	SelectedFare  *RideFare              `protobuf:"bytes,2,opt,name=selectedFare,proto3" json:"selectedFare,omitempty"`
-	Route         *Route                 `protobuf:"bytes,3,opt,name=route,proto3" json:"route,omitempty"`
-	Status        string                 `protobuf:"bytes,4,opt,name=status,proto3" json:"status,omitempty"`
-	UserID        string                 `protobuf:"bytes,5,opt,name=userID,proto3" json:"userID,omitempty"`
-	Driber        *TripDriver            `protobuf:"bytes,6,opt,name=driber,proto3" json:"driber,omitempty"`
-	unknownFields protoimpl.UnknownFields
-	sizeCache     protoimpl.SizeCache
+	state             protoimpl.MessageState `protogen:"open.v1"`
+	Id                string                 `protobuf:"bytes,1,opt,name=id,proto3" json:"id,omitempty"`
+	SelectedFare      *RideFare              `protobuf:"bytes,2,opt,name=selectedFare,proto3" json:"selectedFare,omitempty"`
+	Route             *Route                 `protobuf:"bytes,3,opt,name=route,proto3" json:"route,omitempty"`
+	Status            string                 `protobuf:"bytes,4,opt,name=status,proto3" json:"status,omitempty"`
+	UserID            string                 `protobuf:"bytes,5,opt,name=userID,proto3" json:"userID,omitempty"`
+	Driber            *TripDriver            `protobuf:"bytes,6,opt,name=driber,proto3" json:"driber,omitempty"`
+	PaymentMethod     string                 `protobuf:"bytes,7,opt,name=paymentMethod,proto3" json:"paymentMethod,omitempty"`
+	SplitParticipants []*SplitParticipant    `protobuf:"bytes,8,rep,name=splitParticipants,proto3" json:"splitParticipants,omitempty"`
+	unknownFields     protoimpl.UnknownFields
+	sizeCache         protoimpl.SizeCache
 }
 
 func (x *Trip) Reset() {
 	*x = Trip{}
-	mi := &file_trip_proto_msgTypes[8]
+	mi := &file_trip_proto_msgTypes[9]
 	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 	ms.StoreMessageInfo(mi)
 }
@@ -495,7 +589,7 @@ func (x *Trip) String() string {
 func (*Trip) ProtoMessage() {}
 
 func (x *Trip) ProtoReflect() protoreflect.Message {
-	mi := &file_trip_proto_msgTypes[8]
+	mi := &file_trip_proto_msgTypes[9]
 	if x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -508,7 +602,7 @@ func (x *Trip) ProtoReflect() protoreflect.Message {
 
 // Deprecated: Use Trip.ProtoReflect.Descriptor instead.
 func (*Trip) Descriptor() ([]byte, []int) {
-	return file_trip_proto_rawDescGZIP(), []int{8}
+	return file_trip_proto_rawDescGZIP(), []int{9}
 }
 
 func (x *Trip) GetId() string {
@@ -553,6 +647,72 @@ func (x *Trip) GetDriber() *TripDriver {
 	return nil
 }
 
+func (x *Trip) GetPaymentMethod() string {
+	if x != nil {
+		return x.PaymentMethod
+	}
+	return ""
+}
+
+func (x *Trip) GetSplitParticipants() []*SplitParticipant {
+	if x != nil {
+		return x.SplitParticipants
+	}
+	return nil
+}
+
+type SplitParticipant struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	UserID        string                 `protobuf:"bytes,1,opt,name=userID,proto3" json:"userID,omitempty"`
+	Status        string                 `protobuf:"bytes,2,opt,name=status,proto3" json:"status,omitempty"` // invited, accepted or declined
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *SplitParticipant) Reset() {
+	*x = SplitParticipant{}
+	mi := &file_trip_proto_msgTypes[10]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *SplitParticipant) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*SplitParticipant) ProtoMessage() {}
+
+func (x *SplitParticipant) ProtoReflect() protoreflect.Message {
+	mi := &file_trip_proto_msgTypes[10]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use SplitParticipant.ProtoReflect.Descriptor instead.
+func (*SplitParticipant) Descriptor() ([]byte, []int) {
+	return file_trip_proto_rawDescGZIP(), []int{10}
+}
+
+func (x *SplitParticipant) GetUserID() string {
+	if x != nil {
+		return x.UserID
+	}
+	return ""
+}
+
+func (x *SplitParticipant) GetStatus() string {
+	if x != nil {
+		return x.Status
+	}
+	return ""
+}
+
 type TripDriver struct {
 	state          protoimpl.MessageState `protogen:"open.v1"`
 	Id             string                 `protobuf:"bytes,1,opt,name=id,proto3" json:"id,omitempty"`
@@ -565,7 +725,7 @@ type TripDriver struct {
 
 func (x *TripDriver) Reset() {
 	*x = TripDriver{}
-	mi := &file_trip_proto_msgTypes[9]
+	mi := &file_trip_proto_msgTypes[11]
 	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 	ms.StoreMessageInfo(mi)
 }
@@ -577,7 +737,7 @@ func (x *TripDriver) String() string {
 func (*TripDriver) ProtoMessage() {}
 
 func (x *TripDriver) ProtoReflect() protoreflect.Message {
-	mi := &file_trip_proto_msgTypes[9]
+	mi := &file_trip_proto_msgTypes[11]
 	if x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -590,7 +750,7 @@ func (x *TripDriver) ProtoReflect() protoreflect.Message {
 
 // Deprecated: Use TripDriver.ProtoReflect.Descriptor instead.
 func (*TripDriver) Descriptor() ([]byte, []int) {
-	return file_trip_proto_rawDescGZIP(), []int{9}
+	return file_trip_proto_rawDescGZIP(), []int{11}
 }
 
 func (x *TripDriver) GetId() string {
@@ -621,16 +781,137 @@ func (x *TripDriver) GetVehicleNumber() string {
 	return ""
 }
 
+type GetReceiptRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	TripID        string                 `protobuf:"bytes,1,opt,name=tripID,proto3" json:"tripID,omitempty"`
+	UserID        string                 `protobuf:"bytes,2,opt,name=userID,proto3" json:"userID,omitempty"`
+	Format        string                 `protobuf:"bytes,3,opt,name=format,proto3" json:"format,omitempty"` // html or pdf
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *GetReceiptRequest) Reset() {
+	*x = GetReceiptRequest{}
+	mi := &file_trip_proto_msgTypes[12]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *GetReceiptRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*GetReceiptRequest) ProtoMessage() {}
+
+func (x *GetReceiptRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_trip_proto_msgTypes[12]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use GetReceiptRequest.ProtoReflect.Descriptor instead.
+func (*GetReceiptRequest) Descriptor() ([]byte, []int) {
+	return file_trip_proto_rawDescGZIP(), []int{12}
+}
+
+func (x *GetReceiptRequest) GetTripID() string {
+	if x != nil {
+		return x.TripID
+	}
+	return ""
+}
+
+func (x *GetReceiptRequest) GetUserID() string {
+	if x != nil {
+		return x.UserID
+	}
+	return ""
+}
+
+func (x *GetReceiptRequest) GetFormat() string {
+	if x != nil {
+		return x.Format
+	}
+	return ""
+}
+
+type GetReceiptResponse struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Number        string                 `protobuf:"bytes,1,opt,name=number,proto3" json:"number,omitempty"`
+	ContentType   string                 `protobuf:"bytes,2,opt,name=contentType,proto3" json:"contentType,omitempty"`
+	Content       []byte                 `protobuf:"bytes,3,opt,name=content,proto3" json:"content,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *GetReceiptResponse) Reset() {
+	*x = GetReceiptResponse{}
+	mi := &file_trip_proto_msgTypes[13]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *GetReceiptResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*GetReceiptResponse) ProtoMessage() {}
+
+func (x *GetReceiptResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_trip_proto_msgTypes[13]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use GetReceiptResponse.ProtoReflect.Descriptor instead.
+func (*GetReceiptResponse) Descriptor() ([]byte, []int) {
+	return file_trip_proto_rawDescGZIP(), []int{13}
+}
+
+func (x *GetReceiptResponse) GetNumber() string {
+	if x != nil {
+		return x.Number
+	}
+	return ""
+}
+
+func (x *GetReceiptResponse) GetContentType() string {
+	if x != nil {
+		return x.ContentType
+	}
+	return ""
+}
+
+func (x *GetReceiptResponse) GetContent() []byte {
+	if x != nil {
+		return x.Content
+	}
+	return nil
+}
+
 var File_trip_proto protoreflect.FileDescriptor
 
 const file_trip_proto_rawDesc = "" +
 	"\n" +
 	"\n" +
-	"trip.proto\x12\x04trip\"\x98\x01\n" +
+	"trip.proto\x12\x04trip\"\xb6\x01\n" +
 	"\x12PreviewTripRequest\x12\x16\n" +
 	"\x06userID\x18\x01 \x01(\tR\x06userID\x126\n" +
 	"\rstartLocation\x18\x02 \x01(\v2\x10.trip.CoordinateR\rstartLocation\x122\n" +
-	"\vendLocation\x18\x03 \x01(\v2\x10.trip.CoordinateR\vendLocation\"|\n" +
+	"\vendLocation\x18\x03 \x01(\v2\x10.trip.CoordinateR\vendLocation\x12\x1c\n" +
+	"\tpromoCode\x18\x04 \x01(\tR\tpromoCode\"|\n" +
 	"\x13PreviewTripResponse\x12\x16\n" +
 	"\x06tripID\x18\x01 \x01(\tR\x06tripID\x12!\n" +
 	"\x05route\x18\x02 \x01(\v2\v.trip.RouteR\x05route\x12*\n" +
@@ -644,38 +925,60 @@ const file_trip_proto_rawDesc = "" +
 	"\bdistance\x18\x02 \x01(\x01R\bdistance\x12\x1a\n" +
 	"\bduration\x18\x03 \x01(\x01R\bduration\">\n" +
 	"\bGeometry\x122\n" +
-	"\vcoordinates\x18\x01 \x03(\v2\x10.trip.CoordinateR\vcoordinates\"~\n" +
+	"\vcoordinates\x18\x01 \x03(\v2\x10.trip.CoordinateR\vcoordinates\"\xce\x01\n" +
 	"\bRideFare\x12\x0e\n" +
 	"\x02id\x18\x01 \x01(\tR\x02id\x12\x16\n" +
 	"\x06userID\x18\x02 \x01(\tR\x06userID\x12 \n" +
 	"\vpackageSlug\x18\x03 \x01(\tR\vpackageSlug\x12(\n" +
-	"\x0ftotalPriceInINR\x18\x04 \x01(\x01R\x0ftotalPriceInINR\"K\n" +
+	"\x0ftotalPriceInINR\x18\x04 \x01(\x01R\x0ftotalPriceInINR\x120\n" +
+	"\tlineItems\x18\x05 \x03(\v2\x12.trip.FareLineItemR\tlineItems\x12\x1c\n" +
+	"\tpromoCode\x18\x06 \x01(\tR\tpromoCode\"f\n" +
+	"\fFareLineItem\x12\x12\n" +
+	"\x04type\x18\x01 \x01(\tR\x04type\x12 \n" +
+	"\vdescription\x18\x02 \x01(\tR\vdescription\x12 \n" +
+	"\vamountInINR\x18\x03 \x01(\x01R\vamountInINR\"q\n" +
 	"\x11CreateTripRequest\x12\x1e\n" +
 	"\n" +
 	"RideFareID\x18\x01 \x01(\tR\n" +
 	"RideFareID\x12\x16\n" +
-	"\x06userID\x18\x02 \x01(\tR\x06userID\"L\n" +
+	"\x06userID\x18\x02 \x01(\tR\x06userID\x12$\n" +
+	"\rpaymentMethod\x18\x03 \x01(\tR\rpaymentMethod\"L\n" +
 	"\x12CreateTripResponse\x12\x16\n" +
 	"\x06tripID\x18\x01 \x01(\tR\x06tripID\x12\x1e\n" +
 	"\x04trip\x18\x02 \x01(\v2\n" +
-	".trip.TripR\x04trip\"\xc7\x01\n" +
+	".trip.TripR\x04trip\"\xb3\x02\n" +
 	"\x04Trip\x12\x0e\n" +
 	"\x02id\x18\x01 \x01(\tR\x02id\x122\n" +
 	"\fselectedFare\x18\x02 \x01(\v2\x0e.trip.RideFareR\fselectedFare\x12!\n" +
 	"\x05route\x18\x03 \x01(\v2\v.trip.RouteR\x05route\x12\x16\n" +
 	"\x06status\x18\x04 \x01(\tR\x06status\x12\x16\n" +
 	"\x06userID\x18\x05 \x01(\tR\x06userID\x12(\n" +
-	"\x06driber\x18\x06 \x01(\v2\x10.trip.TripDriverR\x06driber\"~\n" +
+	"\x06driber\x18\x06 \x01(\v2\x10.trip.TripDriverR\x06driber\x12$\n" +
+	"\rpaymentMethod\x18\a \x01(\tR\rpaymentMethod\x12D\n" +
+	"\x11splitParticipants\x18\b \x03(\v2\x16.trip.SplitParticipantR\x11splitParticipants\"B\n" +
+	"\x10SplitParticipant\x12\x16\n" +
+	"\x06userID\x18\x01 \x01(\tR\x06userID\x12\x16\n" +
+	"\x06status\x18\x02 \x01(\tR\x06status\"~\n" +
 	"\n" +
 	"TripDriver\x12\x0e\n" +
 	"\x02id\x18\x01 \x01(\tR\x02id\x12\x12\n" +
 	"\x04name\x18\x02 \x01(\tR\x04name\x12&\n" +
 	"\x0eprofilePicture\x18\x03 \x01(\tR\x0eprofilePicture\x12$\n" +
-	"\rvehicleNumber\x18\x04 \x01(\tR\rvehicleNumber2\x92\x01\n" +
+	"\rvehicleNumber\x18\x04 \x01(\tR\rvehicleNumber\"[\n" +
+	"\x11GetReceiptRequest\x12\x16\n" +
+	"\x06tripID\x18\x01 \x01(\tR\x06tripID\x12\x16\n" +
+	"\x06userID\x18\x02 \x01(\tR\x06userID\x12\x16\n" +
+	"\x06format\x18\x03 \x01(\tR\x06format\"h\n" +
+	"\x12GetReceiptResponse\x12\x16\n" +
+	"\x06number\x18\x01 \x01(\tR\x06number\x12 \n" +
+	"\vcontentType\x18\x02 \x01(\tR\vcontentType\x12\x18\n" +
+	"\acontent\x18\x03 \x01(\fR\acontent2\xd3\x01\n" +
 	"\vTripService\x12B\n" +
 	"\vPreviewTrip\x12\x18.trip.PreviewTripRequest\x1a\x19.trip.PreviewTripResponse\x12?\n" +
 	"\n" +
-	"CreateTrip\x12\x17.trip.CreateTripRequest\x1a\x18.trip.CreateTripResponseB\x18Z\x16shared/proto/trip;tripb\x06proto3"
+	"CreateTrip\x12\x17.trip.CreateTripRequest\x1a\x18.trip.CreateTripResponse\x12?\n" +
+	"\n" +
+	"GetReceipt\x12\x17.trip.GetReceiptRequest\x1a\x18.trip.GetReceiptResponseB3Z1github.com/AuraReaper/voom/shared/proto/trip;tripb\x06proto3"
 
 var (
 	file_trip_proto_rawDescOnce sync.Once
@@ -689,7 +992,7 @@ func file_trip_proto_rawDescGZIP() []byte {
 	return file_trip_proto_rawDescData
 }
 
-var file_trip_proto_msgTypes = make([]protoimpl.MessageInfo, 10)
+var file_trip_proto_msgTypes = make([]protoimpl.MessageInfo, 14)
 var file_trip_proto_goTypes = []any{
 	(*PreviewTripRequest)(nil),  // 0: trip.PreviewTripRequest
 	(*PreviewTripResponse)(nil), // 1: trip.PreviewTripResponse
@@ -697,10 +1000,14 @@ var file_trip_proto_goTypes = []any{
 	(*Route)(nil),               // 3: trip.Route
 	(*Geometry)(nil),            // 4: trip.Geometry
 	(*RideFare)(nil),            // 5: trip.RideFare
-	(*CreateTripRequest)(nil),   // 6: trip.CreateTripRequest
-	(*CreateTripResponse)(nil),  // 7: trip.CreateTripResponse
-	(*Trip)(nil),                // 8: trip.Trip
-	(*TripDriver)(nil),          // 9: trip.TripDriver
+	(*FareLineItem)(nil),        // 6: trip.FareLineItem
+	(*CreateTripRequest)(nil),   // 7: trip.CreateTripRequest
+	(*CreateTripResponse)(nil),  // 8: trip.CreateTripResponse
+	(*Trip)(nil),                // 9: trip.Trip
+	(*SplitParticipant)(nil),    // 10: trip.SplitParticipant
+	(*TripDriver)(nil),          // 11: trip.TripDriver
+	(*GetReceiptRequest)(nil),   // 12: trip.GetReceiptRequest
+	(*GetReceiptResponse)(nil),  // 13: trip.GetReceiptResponse
 }
 var file_trip_proto_depIdxs = []int32{
 	2,  // 0: trip.PreviewTripRequest.startLocation:type_name -> trip.Coordinate
@@ -709,19 +1016,23 @@ var file_trip_proto_depIdxs = []int32{
 	5,  // 3: trip.PreviewTripResponse.rideFare:type_name -> trip.RideFare
 	4,  // 4: trip.Route.geometry:type_name -> trip.Geometry
 	2,  // 5: trip.Geometry.coordinates:type_name -> trip.Coordinate
-	8,  // 6: trip.CreateTripResponse.trip:type_name -> trip.Trip
-	5,  // 7: trip.Trip.selectedFare:type_name -> trip.RideFare
-	3,  // 8: trip.Trip.route:type_name -> trip.Route
-	9,  // 9: trip.Trip.driber:type_name -> trip.TripDriver
-	0,  // 10: trip.TripService.PreviewTrip:input_type -> trip.PreviewTripRequest
-	6,  // 11: trip.TripService.CreateTrip:input_type -> trip.CreateTripRequest
-	1,  // 12: trip.TripService.PreviewTrip:output_type -> trip.PreviewTripResponse
-	7,  // 13: trip.TripService.CreateTrip:output_type -> trip.CreateTripResponse
-	12, // [12:14] is the sub-list for method output_type
-	10, // [10:12] is the sub-list for method input_type
-	10, // [10:10] is the sub-list for extension type_name
-	10, // [10:10] is the sub-list for extension extendee
-	0,  // [0:10] is the sub-list for field type_name
+	6,  // 6: trip.RideFare.lineItems:type_name -> trip.FareLineItem
+	9,  // 7: trip.CreateTripResponse.trip:type_name -> trip.Trip
+	5,  // 8: trip.Trip.selectedFare:type_name -> trip.RideFare
+	3,  // 9: trip.Trip.route:type_name -> trip.Route
+	11, // 10: trip.Trip.driber:type_name -> trip.TripDriver
+	10, // 11: trip.Trip.splitParticipants:type_name -> trip.SplitParticipant
+	0,  // 12: trip.TripService.PreviewTrip:input_type -> trip.PreviewTripRequest
+	7,  // 13: trip.TripService.CreateTrip:input_type -> trip.CreateTripRequest
+	12, // 14: trip.TripService.GetReceipt:input_type -> trip.GetReceiptRequest
+	1,  // 15: trip.TripService.PreviewTrip:output_type -> trip.PreviewTripResponse
+	8,  // 16: trip.TripService.CreateTrip:output_type -> trip.CreateTripResponse
+	13, // 17: trip.TripService.GetReceipt:output_type -> trip.GetReceiptResponse
+	15, // [15:18] is the sub-list for method output_type
+	12, // [12:15] is the sub-list for method input_type
+	12, // [12:12] is the sub-list for extension type_name
+	12, // [12:12] is the sub-list for extension extendee
+	0,  // [0:12] is the sub-list for field type_name
 }
 
 func init() { file_trip_proto_init() }
@@ -735,7 +1046,7 @@ func file_trip_proto_init() {
 			GoPackagePath: reflect.TypeOf(x{}).PkgPath(),
 			RawDescriptor: unsafe.Slice(unsafe.StringData(file_trip_proto_rawDesc), len(file_trip_proto_rawDesc)),
 			NumEnums:      0,
-			NumMessages:   10,
+			NumMessages:   14,
 			NumExtensions: 0,
 			NumServices:   1,
 		},
@@ -21,6 +21,7 @@ const _ = grpc.SupportPackageIsVersion9
 const (
 	TripService_PreviewTrip_FullMethodName = "/trip.TripService/PreviewTrip"
 	TripService_CreateTrip_FullMethodName  = "/trip.TripService/CreateTrip"
+	TripService_GetReceipt_FullMethodName  = "/trip.TripService/GetReceipt"
 )
 
 // TripServiceClient is the client API for TripService service.
@@ -29,6 +30,7 @@ const (
 type TripServiceClient interface {
 	PreviewTrip(ctx context.Context, in *PreviewTripRequest, opts ...grpc.CallOption) (*PreviewTripResponse, error)
 	CreateTrip(ctx context.Context, in *CreateTripRequest, opts ...grpc.CallOption) (*CreateTripResponse, error)
+	GetReceipt(ctx context.Context, in *GetReceiptRequest, opts ...grpc.CallOption) (*GetReceiptResponse, error)
 }
 
 type tripServiceClient struct {
@@ -59,12 +61,23 @@ func (c *tripServiceClient) CreateTrip(ctx context.Context, in *CreateTripReques
 	return out, nil
 }
 
+func (c *tripServiceClient) GetReceipt(ctx context.Context, in *GetReceiptRequest, opts ...grpc.CallOption) (*GetReceiptResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(GetReceiptResponse)
+	err := c.cc.Invoke(ctx, TripService_GetReceipt_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
 // TripServiceServer is the server API for TripService service.
 // All implementations must embed UnimplementedTripServiceServer
 // for forward compatibility.
 type TripServiceServer interface {
 	PreviewTrip(context.Context, *PreviewTripRequest) (*PreviewTripResponse, error)
 	CreateTrip(context.Context, *CreateTripRequest) (*CreateTripResponse, error)
+	GetReceipt(context.Context, *GetReceiptRequest) (*GetReceiptResponse, error)
 	mustEmbedUnimplementedTripServiceServer()
 }
 
@@ -81,6 +94,9 @@ func (UnimplementedTripServiceServer) PreviewTrip(context.Context, *PreviewTripR
 func (UnimplementedTripServiceServer) CreateTrip(context.Context, *CreateTripRequest) (*CreateTripResponse, error) {
 	return nil, status.Errorf(codes.Unimplemented, "method CreateTrip not implemented")
 }
+func (UnimplementedTripServiceServer) GetReceipt(context.Context, *GetReceiptRequest) (*GetReceiptResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method GetReceipt not implemented")
+}
 func (UnimplementedTripServiceServer) mustEmbedUnimplementedTripServiceServer() {}
 func (UnimplementedTripServiceServer) testEmbeddedByValue()                     {}
 
@@ -138,6 +154,24 @@ func _TripService_CreateTrip_Handler(srv interface{}, ctx context.Context, dec f
 	return interceptor(ctx, in, info, handler)
 }
 
+func _TripService_GetReceipt_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(GetReceiptRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(TripServiceServer).GetReceipt(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: TripService_GetReceipt_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(TripServiceServer).GetReceipt(ctx, req.(*GetReceiptRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
 // TripService_ServiceDesc is the grpc.ServiceDesc for TripService service.
 // It's only intended for direct use with grpc.RegisterService,
 // and not to be introspected or modified (even as a copy)
@@ -153,6 +187,10 @@ var TripService_ServiceDesc = grpc.ServiceDesc{
 			MethodName: "CreateTrip",
 			Handler:    _TripService_CreateTrip_Handler,
 		},
+		{
+			MethodName: "GetReceipt",
+			Handler:    _TripService_GetReceipt_Handler,
+		},
 	},
 	Streams:  []grpc.StreamDesc{},
 	Metadata: "trip.proto",
@@ -0,0 +1,39 @@
+package tracing
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/exporters/otlp/otlpmetric/otlpmetrichttp"
+	sdkmetric "go.opentelemetry.io/otel/sdk/metric"
+)
+
+// MetricsInterval is how often the meter provider exports the metrics it collected
+const MetricsInterval = 15 * time.Second
+
+// InitMeter exports the metrics of the service to cfg.OTLPMetricsEndpoint every MetricsInterval.
+// Instruments created from otel.Meter before it was called report through the provider too. The
+// returned function exports what was collected since the last interval and stops the provider.
+func InitMeter(cfg Config) (func(context.Context) error, error) {
+	metricExporter, err := otlpmetrichttp.New(context.Background(),
+		otlpmetrichttp.WithEndpointURL(cfg.OTLPMetricsEndpoint),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create otlp metric exporter: %w", err)
+	}
+
+	res, err := newResource(cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	meterProvider := sdkmetric.NewMeterProvider(
+		sdkmetric.WithReader(sdkmetric.NewPeriodicReader(metricExporter, sdkmetric.WithInterval(MetricsInterval))),
+		sdkmetric.WithResource(res),
+	)
+	otel.SetMeterProvider(meterProvider)
+
+	return meterProvider.Shutdown, nil
+}
@@ -18,6 +18,8 @@ type Config struct {
 	ServiceName  string
 	Environment  string
 	OTLPEndpoint string
+	// OTLPMetricsEndpoint receives the metrics of the service, see InitMeter
+	OTLPMetricsEndpoint string
 }
 
 func InitTracer(cfg Config) (func(context.Context) error, error) {
@@ -50,7 +52,7 @@ func newPropagator() propagation.TextMapPropagator {
 	)
 }
 
-func newTraceProvider(cfg Config, exporter sdktrace.SpanExporter) (*sdktrace.TracerProvider, error) {
+func newResource(cfg Config) (*resource.Resource, error) {
 	res, err := resource.New(context.Background(),
 		resource.WithAttributes(
 			semconv.ServiceNameKey.String(cfg.ServiceName),
@@ -61,6 +63,15 @@ func newTraceProvider(cfg Config, exporter sdktrace.SpanExporter) (*sdktrace.Tra
 		return nil, fmt.Errorf("failed to create resource: %w", err)
 	}
 
+	return res, nil
+}
+
+func newTraceProvider(cfg Config, exporter sdktrace.SpanExporter) (*sdktrace.TracerProvider, error) {
+	res, err := newResource(cfg)
+	if err != nil {
+		return nil, err
+	}
+
 	traceProvider := sdktrace.NewTracerProvider(
 		sdktrace.WithBatcher(exporter),
 		sdktrace.WithResource(res),
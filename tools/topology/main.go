@@ -0,0 +1,181 @@
+// Command topology prints the messaging topology of the services, from the services publishing
+// each routing key through the queues it reaches to the services consuming them. The topology is
+// validated first, an invalid one is reported and nothing printed.
+//
+//	go run ./tools/topology
+//	go run ./tools/topology -format dot | dot -Tsvg > topology.svg
+//	go run ./tools/topology -format mermaid
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"maps"
+	"os"
+	"slices"
+	"strings"
+
+	gatewayTopology "github.com/AuraReaper/voom/services/api-gateway/pkg/topology"
+	driverTopology "github.com/AuraReaper/voom/services/driver-service/pkg/topology"
+	paymentTopology "github.com/AuraReaper/voom/services/payment-service/pkg/topology"
+	tripTopology "github.com/AuraReaper/voom/services/trip-service/pkg/topology"
+	"github.com/AuraReaper/voom/shared/messaging"
+)
+
+// topologies are the parts of the trip exchange each service registers
+var topologies = []messaging.ServiceTopology{
+	gatewayTopology.Topology,
+	driverTopology.Topology,
+	paymentTopology.Topology,
+	tripTopology.Topology,
+}
+
+// edge is a routing key reaching a queue
+type edge struct {
+	producers  []string
+	routingKey string
+	queue      messaging.QueueBinding
+}
+
+func main() {
+	format := flag.String("format", "text", "Output format: text, dot or mermaid")
+	flag.Usage = func() {
+		fmt.Fprintf(flag.CommandLine.Output(), "Usage: %s [flags]\n", os.Args[0])
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+
+	if err := messaging.ValidateTopology(topologies...); err != nil {
+		fmt.Printf("Invalid topology:\n%v\n", err)
+		os.Exit(1)
+	}
+
+	edges := buildEdges()
+
+	switch *format {
+	case "text":
+		printText(os.Stdout, edges)
+	case "dot":
+		printDot(os.Stdout, edges)
+	case "mermaid":
+		printMermaid(os.Stdout, edges)
+	default:
+		flag.Usage()
+		os.Exit(1)
+	}
+}
+
+// buildEdges returns every routing key bound to a queue with the services publishing it, ordered
+// by routing key and queue
+func buildEdges() []edge {
+	producers := make(map[string][]string) // routing key -> services
+	for _, topology := range topologies {
+		for _, key := range topology.Produces {
+			producers[key] = append(producers[key], topology.Service)
+		}
+	}
+	for key := range producers {
+		slices.Sort(producers[key])
+	}
+
+	var edges []edge
+	for _, topology := range topologies {
+		for _, binding := range topology.Bindings() {
+			for _, key := range binding.RoutingKeys {
+				edges = append(edges, edge{
+					producers:  producers[key],
+					routingKey: key,
+					queue:      binding,
+				})
+			}
+		}
+	}
+
+	slices.SortFunc(edges, func(a, b edge) int {
+		if c := strings.Compare(a.routingKey, b.routingKey); c != 0 {
+			return c
+		}
+		return strings.Compare(a.queue.Queue, b.queue.Queue)
+	})
+	return edges
+}
+
+func printText(w io.Writer, edges []edge) {
+	fmt.Fprintf(w, "%-18s %-34s %-32s %s\n", "PRODUCER", "ROUTING KEY", "QUEUE", "CONSUMER")
+	for _, e := range edges {
+		producers := strings.Join(e.producers, ",")
+		if producers == "" {
+			producers = "-"
+		}
+		fmt.Fprintf(w, "%-18s %-34s %-32s %s%s\n", producers, e.routingKey, e.queue.Queue, e.queue.Consumer, describeOptions(e.queue.Options))
+	}
+}
+
+func printDot(w io.Writer, edges []edge) {
+	fmt.Fprintln(w, "digraph topology {")
+	fmt.Fprintln(w, "  rankdir=LR;")
+
+	lines := make(map[string]bool)
+	for _, e := range edges {
+		for _, producer := range e.producers {
+			lines[fmt.Sprintf("  %q [shape=box];", producer)] = true
+			lines[fmt.Sprintf("  %q -> %q;", producer, e.routingKey)] = true
+		}
+		lines[fmt.Sprintf("  %q [shape=ellipse];", e.routingKey)] = true
+		lines[fmt.Sprintf("  %q [shape=cylinder, label=%q];", "queue:"+e.queue.Queue, e.queue.Queue+describeOptions(e.queue.Options))] = true
+		lines[fmt.Sprintf("  %q -> %q;", e.routingKey, "queue:"+e.queue.Queue)] = true
+		lines[fmt.Sprintf("  %q [shape=box];", e.queue.Consumer)] = true
+		lines[fmt.Sprintf("  %q -> %q;", "queue:"+e.queue.Queue, e.queue.Consumer)] = true
+	}
+	for _, line := range slices.Sorted(maps.Keys(lines)) {
+		fmt.Fprintln(w, line)
+	}
+
+	fmt.Fprintln(w, "}")
+}
+
+func printMermaid(w io.Writer, edges []edge) {
+	fmt.Fprintln(w, "flowchart LR")
+
+	// Mermaid ids can't contain dots, the labels keep the names
+	id := func(kind, name string) string {
+		return kind + "_" + strings.NewReplacer(".", "_", "-", "_").Replace(name)
+	}
+
+	lines := make(map[string]bool)
+	for _, e := range edges {
+		key := id("key", e.routingKey)
+		queue := id("queue", e.queue.Queue)
+		for _, producer := range e.producers {
+			lines[fmt.Sprintf("  %s[%s] --> %s([%s])", id("svc", producer), producer, key, e.routingKey)] = true
+		}
+		lines[fmt.Sprintf("  %s([%s]) --> %s[(%s)]", key, e.routingKey, queue, e.queue.Queue)] = true
+		lines[fmt.Sprintf("  %s[(%s)] --> %s[%s]", queue, e.queue.Queue, id("svc", e.queue.Consumer), e.queue.Consumer)] = true
+	}
+	for _, line := range slices.Sorted(maps.Keys(lines)) {
+		fmt.Fprintln(w, line)
+	}
+}
+
+// describeOptions lists the options of a queue that differ from the defaults
+func describeOptions(o messaging.QueueOptions) string {
+	var opts []string
+	if o.MessageTTL > 0 {
+		opts = append(opts, "ttl="+o.MessageTTL.String())
+	}
+	if o.MaxLength > 0 {
+		opts = append(opts, fmt.Sprintf("max-length=%d", o.MaxLength))
+	}
+	if o.MaxPriority > 0 {
+		opts = append(opts, fmt.Sprintf("max-priority=%d", o.MaxPriority))
+	}
+	if o.NoDeadLetter {
+		opts = append(opts, "no-dlx")
+	}
+
+	if len(opts) == 0 {
+		return ""
+	}
+	return " (" + strings.Join(opts, ", ") + ")"
+}
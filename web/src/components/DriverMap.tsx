@@ -136,6 +136,48 @@ export const DriverMap = ({ packageSlug }: { packageSlug: CarPackageSlug }) => {
     resetTripStatus()
   }
 
+  const handleCompleteTrip = () => {
+    if (!requestedTrip || !requestedTrip.id || !driver) {
+      alert("No trip ID found or driver is not set")
+      return
+    }
+
+    sendMessage({
+      type: TripEvents.DriverTripComplete,
+      data: {
+        tripID: requestedTrip.id,
+        riderID: requestedTrip.userID,
+        driver: driver,
+      }
+    })
+
+    // Cash trips stay open until the driver confirms they collected the fare
+    if (requestedTrip.paymentMethod === "cash") {
+      setTripStatus(TripEvents.DriverTripComplete)
+      return
+    }
+
+    resetTripStatus()
+  }
+
+  const handleCashCollected = () => {
+    if (!requestedTrip || !requestedTrip.id || !driver) {
+      alert("No trip ID found or driver is not set")
+      return
+    }
+
+    sendMessage({
+      type: TripEvents.DriverCashCollected,
+      data: {
+        tripID: requestedTrip.id,
+        riderID: requestedTrip.userID,
+        driver: driver,
+      }
+    })
+
+    resetTripStatus()
+  }
+
   const parsedRoute = useMemo(() =>
     requestedTrip?.route?.geometry[0]?.coordinates
       .map((coord) => [coord?.longitude, coord?.latitude] as [number, number])
@@ -242,6 +284,8 @@ export const DriverMap = ({ packageSlug }: { packageSlug: CarPackageSlug }) => {
             status={tripStatus}
             onAcceptTrip={handleAcceptTrip}
             onDeclineTrip={handleDeclineTrip}
+            onCompleteTrip={handleCompleteTrip}
+            onCashCollected={handleCashCollected}
           />
         </div>
       </div>
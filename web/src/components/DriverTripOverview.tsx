@@ -7,10 +7,12 @@ interface DriverTripOverviewProps {
   trip?: Trip | null,
   status?: TripEvents | null,
   onAcceptTrip?: () => void,
-  onDeclineTrip?: () => void
+  onDeclineTrip?: () => void,
+  onCompleteTrip?: () => void,
+  onCashCollected?: () => void
 }
 
-export const DriverTripOverview = ({ trip, status, onAcceptTrip, onDeclineTrip }: DriverTripOverviewProps) => {
+export const DriverTripOverview = ({ trip, status, onAcceptTrip, onDeclineTrip, onCompleteTrip, onCashCollected }: DriverTripOverviewProps) => {
   if (!trip) {
     return (
       <TripOverviewCard
@@ -49,10 +51,22 @@ export const DriverTripOverview = ({ trip, status, onAcceptTrip, onDeclineTrip }
               Rider ID: {trip.userID}
             </p>
           </div>
+          <Button onClick={onCompleteTrip}>Complete trip</Button>
         </div>
       </TripOverviewCard>
     )
   }
 
+  if (status === TripEvents.DriverTripComplete && trip.paymentMethod === "cash") {
+    return (
+      <TripOverviewCard
+        title="Collect the fare"
+        description={`This trip is paid in cash, collect ₹${trip.selectedFare.totalPriceInINR.toFixed(2)} from the rider.`}
+      >
+        <Button onClick={onCashCollected}>Cash collected</Button>
+      </TripOverviewCard>
+    )
+  }
+
   return null
 }
\ No newline at end of file
@@ -1,4 +1,4 @@
-import { Coordinate, Driver, Route, RouteFare, Trip } from "./types";
+import { Coordinate, Driver, PaymentMethod, Route, RouteFare, Trip } from "./types";
 
 
 // These are the endpoints the API Gateway must have for the frontend to work correctly
@@ -19,28 +19,55 @@ export enum TripEvents {
   DriverTripRequest = "driver.cmd.trip_request",
   DriverTripAccept = "driver.cmd.trip_accept",
   DriverTripDecline = "driver.cmd.trip_decline",
+  DriverTripComplete = "driver.cmd.trip_complete",
+  DriverCashCollected = "driver.cmd.cash_collected",
   DriverRegister = "driver.cmd.register",
   PaymentSessionCreated = "payment.event.session_created",
+  PaymentRetry = "payment.event.retry",
+  PaymentDunning = "payment.event.dunning",
+  PaymentRefunded = "payment.event.refunded",
+  PaymentTipReceived = "payment.event.tip_received",
+  RiderTip = "rider.cmd.tip",
+  RiderSplitInvite = "rider.cmd.split_invite",
+  RiderSplitRespond = "rider.cmd.split_respond",
+  SplitInvited = "trip.event.split_invited",
+  SplitUpdated = "trip.event.split_updated",
 }
 
 // Messages sent from the server to the client via the websocket
 export type ServerWsMessage =
   | PaymentSessionCreatedRequest
+  | PaymentRetryRequest
+  | PaymentDunningRequest
+  | PaymentRefundedRequest
+  | PaymentTipReceivedRequest
+  | SplitRequest
   | DriverAssignedRequest
   | DriverLocationRequest
   | DriverTripRequest
   | DriverRegisterRequest
   | TripCreatedRequest
+  | TripCompletedRequest
   | NoDriversFoundRequest;
 
 // Messages sent from the client to the server via the websocket
-export type ClientWsMessage = DriverResponseToTripResponse | DriverLocationRequest
+export type ClientWsMessage =
+  | DriverResponseToTripResponse
+  | DriverLocationRequest
+  | RiderTipRequest
+  | RiderSplitInviteRequest
+  | RiderSplitRespondRequest
 
 interface TripCreatedRequest {
   type: TripEvents.Created;
   data: Trip;
 }
 
+interface TripCompletedRequest {
+  type: TripEvents.Completed;
+  data: { trip: Trip };
+}
+
 interface NoDriversFoundRequest {
   type: TripEvents.NoDriversFound;
 }
@@ -66,6 +93,96 @@ interface PaymentSessionCreatedRequest {
   data: PaymentEventSessionCreatedData;
 }
 
+export interface PaymentEventRetryData extends PaymentEventSessionCreatedData {
+  attempt: number;
+  maxAttempts: number;
+  reason?: string;
+}
+
+interface PaymentRetryRequest {
+  type: TripEvents.PaymentRetry;
+  data: PaymentEventRetryData;
+}
+
+export interface PaymentEventDunningData {
+  tripID: string;
+  userID: string;
+  driverID: string;
+  amount: number;
+  currency: string;
+  attempts: number;
+}
+
+interface PaymentDunningRequest {
+  type: TripEvents.PaymentDunning;
+  data: PaymentEventDunningData;
+}
+
+export interface PaymentEventRefundedData {
+  tripID: string;
+  refundID: string;
+  userID: string;
+  driverID: string;
+  amount: number;
+  totalRefunded: number;
+  currency: string;
+  reason?: string;
+}
+
+interface PaymentRefundedRequest {
+  type: TripEvents.PaymentRefunded;
+  data: PaymentEventRefundedData;
+}
+
+export interface PaymentEventTipData {
+  tripID: string;
+  userID: string;
+  driverID: string;
+  amount: number;
+  currency: string;
+}
+
+interface PaymentTipReceivedRequest {
+  type: TripEvents.PaymentTipReceived;
+  data: PaymentEventTipData;
+}
+
+interface RiderTipRequest {
+  type: TripEvents.RiderTip;
+  data: {
+    tripID: string;
+    amount: number;
+  };
+}
+
+export interface TripEventSplitData {
+  tripID: string;
+  ownerID: string;
+  fare: number;
+  participants: { userID: string; status: "invited" | "accepted" | "declined" }[];
+}
+
+interface SplitRequest {
+  type: TripEvents.SplitInvited | TripEvents.SplitUpdated;
+  data: TripEventSplitData;
+}
+
+interface RiderSplitInviteRequest {
+  type: TripEvents.RiderSplitInvite;
+  data: {
+    tripID: string;
+    userIDs: string[];
+  };
+}
+
+interface RiderSplitRespondRequest {
+  type: TripEvents.RiderSplitRespond;
+  data: {
+    tripID: string;
+    accept: boolean;
+  };
+}
+
 interface DriverAssignedRequest {
   type: TripEvents.DriverAssigned;
   data: Trip;
@@ -77,7 +194,7 @@ interface DriverLocationRequest {
 }
 
 interface DriverResponseToTripResponse {
-  type: TripEvents.DriverTripAccept | TripEvents.DriverTripDecline;
+  type: TripEvents.DriverTripAccept | TripEvents.DriverTripDecline | TripEvents.DriverTripComplete | TripEvents.DriverCashCollected;
   data: {
     tripID: string;
     riderID: string;
@@ -93,12 +210,14 @@ export interface HTTPTripPreviewResponse {
 export interface HTTPTripStartRequestPayload {
   rideFareID: string;
   userID: string;
+  paymentMethod?: PaymentMethod;
 }
 
 export interface HTTPTripPreviewRequestPayload {
   userID: string;
   pickup: Coordinate;
   destination: Coordinate;
+  promoCode?: string;
 }
 
 export function isValidTripEvent(event: string): event is TripEvents {
@@ -71,6 +71,13 @@ export const useDriverStreamConnection = ({
 
             break;
 
+          case TripEvents.PaymentTipReceived:
+
+            // A tip doesn't change the state of the driver's current trip
+            console.log(`Received a tip of ${message.data.amount} ${message.data.currency} for trip ${message.data.tripID}`);
+
+            return;
+
         }
 
   
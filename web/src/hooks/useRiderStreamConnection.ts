@@ -2,12 +2,13 @@ import { useEffect, useState } from 'react';
 import { WEBSOCKET_URL } from "../constants";
 import { Trip } from '../types';
 import { Driver, Coordinate } from '../types';
-import { PaymentEventSessionCreatedData, TripEvents, isValidWsMessage, BackendEndpoints } from '../contracts';
+import { PaymentEventSessionCreatedData, TripEventSplitData, TripEvents, isValidWsMessage, BackendEndpoints } from '../contracts';
 
 export function useRiderStreamConnection(location: Coordinate, userID: string) {
   const [drivers, setDrivers] = useState<Driver[]>([]);
   const [tripStatus, setTripStatus] = useState<TripEvents | null>(null);
   const [paymentSession, setPaymentSession] = useState<PaymentEventSessionCreatedData | null>(null);
+  const [split, setSplit] = useState<TripEventSplitData | null>(null);
   const [assignedDriver, setAssignedDriver] = useState<Trip["driver"] | null>(null);
   const [error, setError] = useState<string | null>(null);
 
@@ -44,6 +45,17 @@ export function useRiderStreamConnection(location: Coordinate, userID: string) {
           setPaymentSession(message.data);
           setTripStatus(message.type);
           break;
+        case TripEvents.PaymentRetry:
+          // A fresh session replaces the failed one, the rider is prompted to pay again
+          setPaymentSession(message.data);
+          setTripStatus(TripEvents.PaymentSessionCreated);
+          setError(`Payment was not completed${message.data.reason ? `: ${message.data.reason}` : ''}. Please try again (attempt ${message.data.attempt}/${message.data.maxAttempts}).`);
+          break;
+        case TripEvents.PaymentDunning:
+          setPaymentSession(null);
+          setTripStatus(message.type);
+          setError(`Payment for trip ${message.data.tripID} is overdue, please settle ${message.data.amount} ${message.data.currency}.`);
+          break;
         case TripEvents.DriverAssigned:
           setAssignedDriver(message.data.driver);
           setTripStatus(message.type);
@@ -54,6 +66,16 @@ export function useRiderStreamConnection(location: Coordinate, userID: string) {
         case TripEvents.NoDriversFound:
           setTripStatus(message.type);
           break;
+        case TripEvents.SplitInvited:
+        case TripEvents.SplitUpdated:
+          // Sharing the fare doesn't change the status of the trip
+          setSplit(message.data);
+          break;
+        case TripEvents.Completed:
+          // The fare is captured from the card hold placed at booking
+          setPaymentSession(null);
+          setTripStatus(message.type);
+          break;
       }
     };
 
@@ -80,5 +102,5 @@ export function useRiderStreamConnection(location: Coordinate, userID: string) {
     setPaymentSession(null);
   }
 
-  return { drivers, assignedDriver, error, tripStatus, paymentSession, resetTripStatus };
+  return { drivers, assignedDriver, error, tripStatus, paymentSession, split, resetTripStatus };
 }
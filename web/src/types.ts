@@ -5,9 +5,12 @@ export interface Trip {
     selectedFare: RouteFare;
     route: Route;
     driver?: Driver;
+    paymentMethod?: PaymentMethod;
     trip: Trip;
 }
 
+export type PaymentMethod = "card" | "wallet" | "cash";
+
 export interface RequestRideProps {
     pickup: [number, number],
     destination: [number, number],
@@ -38,6 +41,8 @@ export interface RouteFare {
     packageSlug: CarPackageSlug,
     basePrice: number,
     totalPriceInINR: number,
+    lineItems?: FareLineItem[],
+    promoCode?: string,
     expiresAt: Date,
     route: Route,
 }
@@ -47,6 +52,12 @@ export interface HTTPTripStartResponse {
     tripID: string;
 }
 
+export interface FareLineItem {
+    type: "base" | "promo",
+    description: string,
+    amountInINR: number,
+}
+
 export interface TripPreview {
     tripID: string,
     route: [number, number][],